@@ -0,0 +1,212 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"rpc-gateway/pkg/core/config"
+	"rpc-gateway/pkg/plugins/auth"
+	"runtime"
+	"strings"
+
+	"github.com/ghodss/yaml"
+)
+
+// build info, set by -ldflags "-X main.Version=..."
+var (
+	Version   = "dev"
+	GitCommit = ""
+	BuildTime = ""
+)
+
+// exit code
+const (
+	EXIT_OK    = 0
+	EXIT_ERROR = 1
+	EXIT_USAGE = 2
+)
+
+const usage = `Usage: pigeon <command> [options]
+
+Commands:
+  serve                      run gateway (default)
+  config validate            validate config files, exit non-zero on errors
+  config dump [--effective]  print config with secrets redacted
+  token mint [options]       mint a signed token with the active key, e.g.
+                             --engine asr --scene-code 8tv22s8i0 --ttl 24h
+                             --scope admin for the token admin api
+  version                    print version
+
+Options:
+  --config-dir dir           config dir, may be repeated or separated by ':'
+                             (default $PIGEON_CONFIG_DIR or config/)
+`
+
+// repeatable string flag
+type stringsFlag []string
+
+func (f *stringsFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *stringsFlag) Set(value string) error {
+	*f = append(*f, value)
+	return nil
+}
+
+// new flag set with --config-dir
+func newFlagSet(name string) (*flag.FlagSet, *stringsFlag) {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	configDirs := &stringsFlag{}
+	fs.Var(configDirs, "config-dir", "config dir, may be repeated")
+	return fs, configDirs
+}
+
+// parse flags and set config search paths
+func parseFlags(fs *flag.FlagSet, configDirs *stringsFlag, args []string) bool {
+	if err := fs.Parse(args); err != nil {
+		return false
+	}
+	config.SetSearchPaths(config.ResolveSearchPaths(*configDirs))
+	return true
+}
+
+// run subcommand, return exit code
+func runCommand(args []string) int {
+	if len(args) == 0 {
+		return serveCommand(args)
+	}
+	switch args[0] {
+	case "serve":
+		return serveCommand(args[1:])
+	case "config":
+		return configCommand(args[1:], os.Stdout, os.Stderr)
+	case "token":
+		return tokenCommand(args[1:], os.Stdout, os.Stderr)
+	case "version":
+		return versionCommand(os.Stdout)
+	case "help", "-h", "--help":
+		fmt.Fprint(os.Stdout, usage)
+		return EXIT_OK
+	}
+	fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", args[0], usage)
+	return EXIT_USAGE
+}
+
+// serve command
+func serveCommand(args []string) int {
+	fs, configDirs := newFlagSet("serve")
+	if !parseFlags(fs, configDirs, args) {
+		return EXIT_USAGE
+	}
+	// load and validate config before any plugin starts
+	if err := config.Load(); err != nil {
+		fmt.Fprintf(os.Stderr, "fatal error config:\n%s\n", err)
+		return EXIT_ERROR
+	}
+	serve()
+	return EXIT_OK
+}
+
+// config command
+func configCommand(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usage)
+		return EXIT_USAGE
+	}
+	switch args[0] {
+	case "validate":
+		fs, configDirs := newFlagSet("config validate")
+		if !parseFlags(fs, configDirs, args[1:]) {
+			return EXIT_USAGE
+		}
+		if err := config.Load(); err != nil {
+			fmt.Fprintln(stderr, err)
+			return EXIT_ERROR
+		}
+		fmt.Fprintln(stdout, "config ok")
+		return EXIT_OK
+	case "dump":
+		fs, configDirs := newFlagSet("config dump")
+		effective := fs.Bool("effective", false, "print merged config with defaults and env overrides")
+		if !parseFlags(fs, configDirs, args[1:]) {
+			return EXIT_USAGE
+		}
+		var out map[string]interface{}
+		if *effective {
+			if err := config.Load(); err != nil {
+				fmt.Fprintln(stderr, err)
+				return EXIT_ERROR
+			}
+			out = config.DumpEffective(config.Get())
+		} else {
+			files, err := config.DumpFiles()
+			if err != nil {
+				fmt.Fprintln(stderr, err)
+				return EXIT_ERROR
+			}
+			out = files
+		}
+		data, err := yaml.Marshal(out)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return EXIT_ERROR
+		}
+		stdout.Write(data)
+		return EXIT_OK
+	}
+	fmt.Fprintf(stderr, "unknown config command %q\n\n%s", args[0], usage)
+	return EXIT_USAGE
+}
+
+// token command
+func tokenCommand(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 || args[0] != "mint" {
+		fmt.Fprint(stderr, usage)
+		return EXIT_USAGE
+	}
+	fs, configDirs := newFlagSet("token mint")
+	tenantId := fs.String("tenant-id", "", "tenant id")
+	engine := fs.String("engine", "", "engine, asr or tts")
+	sceneCode := fs.String("scene-code", "", "scene code")
+	scope := fs.String("scope", auth.SCOPE_INVOKE, "scopes, separated by ','")
+	ttl := fs.Duration("ttl", 0, "token ttl (default tokens.TTL)")
+	if !parseFlags(fs, configDirs, args[1:]) {
+		return EXIT_USAGE
+	}
+	if err := config.Load(); err != nil {
+		fmt.Fprintln(stderr, err)
+		return EXIT_ERROR
+	}
+	claims := auth.Claims{
+		TenantId:  *tenantId,
+		Engine:    *engine,
+		SceneCode: *sceneCode,
+		Scopes:    strings.Split(*scope, ","),
+	}
+	if claims.HasScope(auth.SCOPE_INVOKE) && (claims.Engine == "" || claims.SceneCode == "") {
+		fmt.Fprintln(stderr, "--engine and --scene-code are required for scope invoke")
+		return EXIT_USAGE
+	}
+	token, _, err := auth.Mint(claims, *ttl)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return EXIT_ERROR
+	}
+	fmt.Fprintln(stdout, token)
+	return EXIT_OK
+}
+
+// version command
+func versionCommand(stdout io.Writer) int {
+	fmt.Fprintf(stdout, "pigeon %s", Version)
+	if GitCommit != "" {
+		fmt.Fprintf(stdout, " (%s)", GitCommit)
+	}
+	if BuildTime != "" {
+		fmt.Fprintf(stdout, " built %s", BuildTime)
+	}
+	fmt.Fprintf(stdout, " %s %s/%s\n", runtime.Version(), runtime.GOOS, runtime.GOARCH)
+	return EXIT_OK
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"rpc-gateway/pkg/core/config"
+)
+
+const (
+	testAPIKey        = "SUPERSECRETAPIKEY123"
+	testSessionSecret = "SUPERSECRETSESSION0123456789abcdef"
+)
+
+var testAppConfig = `RUN_MODE: 'dev'
+HTTP_DEBUG_MODE: 'debug'
+HTTP_TIME_DURATION: 10
+PAGE_SIZE: 20
+HTTP_SERVER_PORT: '9800'
+ADMIN_SERVER_ADDR: '127.0.0.1:9801'
+SESSION_SECRET: '` + testSessionSecret + `'
+DB_ENABLED: false
+CACHE_ENABLED: false
+LOG_LEVEL: 'info'
+LOG_MAX_AGE: 43200
+LOG_ROTATION_TIME: 1140
+API_KEYS:
+  - NAME: 'ops'
+    KEY: '` + testAPIKey + `'
+    ROLE: 'operator'
+`
+
+// config dir of test, files by name without extension
+func configDir(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "pigeon-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		os.RemoveAll(dir)
+		config.Set(nil)
+		config.SetSearchPaths(nil)
+	})
+	base := map[string]string{
+		config.APP_CONFIG:   testAppConfig,
+		config.POOL_CONFIG:  "pool:\n  setting:\n    ENABLED: false\n",
+		config.ROUTE_CONFIG: "route: []\n",
+	}
+	for name, content := range files {
+		base[name] = content
+	}
+	for name, content := range base {
+		if err := ioutil.WriteFile(filepath.Join(dir, name+".yaml"), []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func runConfig(args ...string) (int, string, string) {
+	var stdout, stderr bytes.Buffer
+	code := configCommand(args, &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func TestConfigValidate(t *testing.T) {
+	valid := configDir(t, nil)
+	invalid := configDir(t, map[string]string{
+		config.APP_CONFIG: strings.NewReplacer("'9800'", "'abc'", "'info'", "'trace'").Replace(testAppConfig),
+	})
+	cases := []struct {
+		name   string
+		args   []string
+		code   int
+		stdout string
+		stderr []string
+	}{
+		{"valid", []string{"validate", "--config-dir", valid}, EXIT_OK, "config ok\n", nil},
+		{
+			"invalid", []string{"validate", "--config-dir", invalid}, EXIT_ERROR, "",
+			[]string{"Config: HTTP_SERVER_PORT: invalid port \"abc\"", "Config: LOG_LEVEL: invalid value \"trace\""},
+		},
+		{"missing files", []string{"validate", "--config-dir", filepath.Join(valid, "missing")}, EXIT_ERROR, "", []string{"Config"}},
+		{"unknown flag", []string{"validate", "--effective"}, EXIT_USAGE, "", nil},
+		{"unknown command", []string{"check"}, EXIT_USAGE, "", []string{"unknown config command \"check\""}},
+		{"no command", nil, EXIT_USAGE, "", []string{"Usage: pigeon"}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			code, stdout, stderr := runConfig(tc.args...)
+			if code != tc.code {
+				t.Errorf("exit code = %d, want %d, stderr %q", code, tc.code, stderr)
+			}
+			if stdout != tc.stdout {
+				t.Errorf("stdout = %q, want %q", stdout, tc.stdout)
+			}
+			for _, want := range tc.stderr {
+				if !strings.Contains(stderr, want) {
+					t.Errorf("stderr %q does not contain %q", stderr, want)
+				}
+			}
+		})
+	}
+}
+
+func TestConfigDumpRedactsSecrets(t *testing.T) {
+	dir := configDir(t, nil)
+	for _, args := range [][]string{
+		{"dump", "--config-dir", dir},
+		{"dump", "--effective", "--config-dir", dir},
+	} {
+		t.Run(strings.Join(args[:len(args)-2], " "), func(t *testing.T) {
+			code, stdout, stderr := runConfig(args...)
+			if code != EXIT_OK {
+				t.Fatalf("exit code = %d, stderr %q", code, stderr)
+			}
+			for _, secret := range []string{testAPIKey, testSessionSecret} {
+				if strings.Contains(stdout, secret) {
+					t.Errorf("secret %s not redacted:\n%s", secret, stdout)
+				}
+			}
+			if !strings.Contains(stdout, config.REDACTED) || !strings.Contains(stdout, "9800") || !strings.Contains(stdout, "ops") {
+				t.Errorf("dump missing redacted or plain values:\n%s", stdout)
+			}
+		})
+	}
+}
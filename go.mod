@@ -13,6 +13,7 @@ require (
 	github.com/jonboulle/clockwork v0.2.2 // indirect
 	github.com/lestrrat-go/file-rotatelogs v2.4.0+incompatible
 	github.com/lestrrat-go/strftime v1.0.5 // indirect
+	github.com/mitchellh/mapstructure v1.4.2
 	github.com/rs/xid v1.3.0
 	github.com/spf13/viper v1.9.0
 	github.com/valyala/fasthttp v1.31.0
@@ -1,30 +1,60 @@
-//main
+// main
 package main
 
 import (
+	"os"
+	"rpc-gateway/pkg/core/config"
+	logging "rpc-gateway/pkg/core/log"
 	"rpc-gateway/pkg/plugins"
-	"rpc-gateway/pkg/plugins/httpserver/util"
+	"rpc-gateway/pkg/plugins/httpserver/db"
 	"rpc-gateway/pkg/plugins/metrics"
 	"rpc-gateway/pkg/plugins/proxy"
 )
 
 // main
 func main() {
-	util.InitConfig()
-	var httpPlugin, gRPCPlugin, vsPlugin proxy.Plugin
+	os.Exit(runCommand(os.Args[1:]))
+}
+
+// serve
+func serve() {
+	logging.Init()
+	for _, ref := range config.Get().Secrets {
+		logging.Log.Info("config secret ", ref.File, ": ", ref.Key, " resolved from ", ref.Source, " (", config.REDACTED, ")")
+	}
+	db.Init()
+	// tenants shared by replicas, TenantConfig.yaml seeds empty db, routes are loaded by http server
+	if db.Enabled() {
+		if err := db.SeedTenants(config.Get().Tenants); err != nil {
+			logging.Log.Error("seed tenants to db error: ", err)
+		}
+		if tenants, err := db.TenantConfigs(); err != nil {
+			logging.Log.Error("load tenants from db error, use TenantConfig.yaml: ", err)
+		} else {
+			cfg := *config.Get()
+			cfg.Tenants = tenants
+			config.Set(&cfg)
+		}
+	}
+	var httpPlugin, adminPlugin, gRPCPlugin, vsPlugin proxy.Plugin
 	var metricsPlugin metrics.Plugin
 	// register gRPC 、HTTP Server
 	gRPCPluginChan := registerPlugins(gRPCPlugin.GRPCServer)
 	httpPluginChan := registerPlugins(httpPlugin.HttpServer)
+	// register admin server
+	adminPluginChan := registerPlugins(adminPlugin.AdminServer)
 	// register Metrics Data Server
 	metricsPluginChan := registerPlugins(metricsPlugin.ShowMetrics)
 	// register VS
 	kvsPluginChan := registerPlugins(vsPlugin.KvsServer)
+	dvsPluginChan := registerPlugins(vsPlugin.DvsServer)
 
 	// return status chan
 	<-kvsPluginChan
+	<-dvsPluginChan
 	<-gRPCPluginChan
 	<-httpPluginChan
+	<-adminPluginChan
 	<-metricsPluginChan
 }
 
@@ -0,0 +1,335 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/mitchellh/mapstructure"
+	"github.com/spf13/viper"
+)
+
+const (
+	// default config dir
+	CONFIG_PATH = "config/"
+	// config dirs env, separated by os.PathListSeparator
+	CONFIG_DIR_ENV = "PIGEON_CONFIG_DIR"
+	// config files
+	APP_CONFIG    = "Config"
+	POOL_CONFIG   = "PoolConfig"
+	TENANT_CONFIG = "TenantConfig"
+	ROUTE_CONFIG  = "RouteConfig"
+	VS_CONFIG     = "VSConfig"
+)
+
+// all configs
+type Configs struct {
+	App     AppConfig
+	Pool    PoolConfig
+	Tenants []TenantConfig
+	Tokens  TokenConfig
+	Route   RouteConfig
+	VS      VSConfig
+	// resolved secret references
+	Secrets []SecretRef
+}
+
+// file layouts
+type poolFile struct {
+	Pool PoolConfig `mapstructure:"pool"`
+}
+
+type tenantFile struct {
+	Tenants []TenantConfig `mapstructure:"tenants"`
+	Tokens  TokenConfig    `mapstructure:"tokens"`
+}
+
+type vsFile struct {
+	VS VSConfig `mapstructure:"vs"`
+}
+
+var current *Configs
+var configLock sync.RWMutex
+
+// config search paths, the first dir containing the file wins
+var searchPaths = []string{CONFIG_PATH}
+
+// resolve search paths from --config-dir, PIGEON_CONFIG_DIR or default
+func ResolveSearchPaths(dirs []string) []string {
+	var paths []string
+	for _, dir := range dirs {
+		paths = append(paths, splitPaths(dir)...)
+	}
+	if len(paths) == 0 {
+		paths = splitPaths(os.Getenv(CONFIG_DIR_ENV))
+	}
+	if len(paths) == 0 {
+		paths = []string{CONFIG_PATH}
+	}
+	return paths
+}
+
+// split dirs by os.PathListSeparator
+func splitPaths(dirs string) []string {
+	var paths []string
+	for _, dir := range filepath.SplitList(dirs) {
+		if dir = strings.TrimSpace(dir); dir != "" {
+			paths = append(paths, dir)
+		}
+	}
+	return paths
+}
+
+// set config search paths, must be called before Load
+func SetSearchPaths(paths []string) {
+	configLock.Lock()
+	defer configLock.Unlock()
+	if len(paths) == 0 {
+		paths = []string{CONFIG_PATH}
+	}
+	searchPaths = append([]string(nil), paths...)
+}
+
+// config search paths
+func SearchPaths() []string {
+	configLock.RLock()
+	defer configLock.RUnlock()
+	return append([]string(nil), searchPaths...)
+}
+
+// setup viper for config file in search paths
+func SetupViper(v *viper.Viper, name string) {
+	v.SetConfigName(name)
+	v.SetConfigType("yaml")
+	for _, path := range SearchPaths() {
+		v.AddConfigPath(path)
+	}
+}
+
+// new viper for config file, env overrides keys, e.g. POOL_SETTING_DIAL_TIMEOUT
+func NewViper(name string) *viper.Viper {
+	v := viper.New()
+	SetupViper(v, name)
+	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	v.AutomaticEnv()
+	return v
+}
+
+// bind env of scalar keys, env overrides keys missing in file, e.g. DB_HOST
+func bindEnv(v *viper.Viper, out interface{}) {
+	t := reflect.TypeOf(out)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		key := field.Tag.Get("mapstructure")
+		switch field.Type.Kind() {
+		case reflect.Slice, reflect.Struct, reflect.Map:
+			continue
+		}
+		if key != "" {
+			v.BindEnv(key)
+		}
+	}
+}
+
+// load and validate all config files, every error is returned
+func Load() error {
+	cfg := &Configs{}
+	var errs Errors
+
+	// Config.yaml
+	appViper := NewViper(APP_CONFIG)
+	SetAppDefaults(appViper)
+	bindEnv(appViper, AppConfig{})
+	if fileErrs := readAndDecode(appViper, APP_CONFIG, true, &cfg.App); len(fileErrs) > 0 {
+		errs = append(errs, fileErrs...)
+	} else if fileErrs = cfg.resolveSecrets(APP_CONFIG, "", &cfg.App); len(fileErrs) > 0 {
+		errs = append(errs, fileErrs...)
+	} else {
+		errs = append(errs, validateApp(&cfg.App)...)
+		// 生产环境拒绝默认或弱密钥
+		if cfg.App.IsRelease() {
+			errs = append(errs, validateAppSecrets(&cfg.App)...)
+		}
+	}
+
+	// PoolConfig.yaml and TenantConfig.yaml
+	errs = append(errs, loadPool(cfg)...)
+
+	// RouteConfig.yaml
+	route, routeRefs, routeErrs := decodeRoute(NewViper(ROUTE_CONFIG), true)
+	cfg.Route = route
+	cfg.Secrets = append(cfg.Secrets, routeRefs...)
+	errs = append(errs, routeErrs...)
+
+	// VSConfig.yaml
+	vs := vsFile{}
+	vsViper := NewViper(VS_CONFIG)
+	setVSDefaults(vsViper)
+	if fileErrs := readAndDecode(vsViper, VS_CONFIG, false, &vs); len(fileErrs) > 0 {
+		errs = append(errs, fileErrs...)
+	} else if fileErrs = cfg.resolveSecrets(VS_CONFIG, "vs", &vs.VS); len(fileErrs) > 0 {
+		errs = append(errs, fileErrs...)
+	} else {
+		cfg.VS = vs.VS
+		errs = append(errs, validateVS(&cfg.VS)...)
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+
+	Set(cfg)
+	return nil
+}
+
+// reload PoolConfig.yaml and TenantConfig.yaml, other configs are copied from current
+func LoadPool() (*Configs, error) {
+	cfg := *Get()
+	cfg.Secrets = nil
+	for _, ref := range Get().Secrets {
+		if ref.File != POOL_CONFIG && ref.File != TENANT_CONFIG {
+			cfg.Secrets = append(cfg.Secrets, ref)
+		}
+	}
+	if errs := loadPool(&cfg); len(errs) > 0 {
+		return nil, errs
+	}
+	return &cfg, nil
+}
+
+// replace current configs, e.g. after reload applied
+func Set(cfg *Configs) {
+	configLock.Lock()
+	current = cfg
+	configLock.Unlock()
+}
+
+// read pool and tenant config
+func loadPool(cfg *Configs) Errors {
+	var errs Errors
+	poolViper := NewViper(POOL_CONFIG)
+	setPoolDefaults(poolViper)
+	pool := poolFile{}
+	if fileErrs := readAndDecode(poolViper, POOL_CONFIG, true, &pool); len(fileErrs) > 0 {
+		errs = append(errs, fileErrs...)
+	} else {
+		cfg.Pool = pool.Pool
+		cfg.Pool.setDefaults()
+		if fileErrs = cfg.resolveSecrets(POOL_CONFIG, "pool", &cfg.Pool); len(fileErrs) > 0 {
+			errs = append(errs, fileErrs...)
+		} else {
+			errs = append(errs, validatePool(&cfg.Pool)...)
+		}
+	}
+
+	// TenantConfig.yaml, required when tenant enabled
+	tenantRequired := cfg.Pool.Setting.Enabled && cfg.Pool.Setting.TenantEnabled && !cfg.Pool.Setting.OMPEnabled
+	// tokens are verified when omp or tenant enabled
+	tokenRequired := cfg.Pool.Setting.Enabled && (cfg.Pool.Setting.TenantEnabled || cfg.Pool.Setting.OMPEnabled)
+	tenantViper := NewViper(TENANT_CONFIG)
+	setTenantDefaults(tenantViper)
+	tenant := tenantFile{}
+	if fileErrs := readAndDecode(tenantViper, TENANT_CONFIG, tenantRequired, &tenant); len(fileErrs) > 0 {
+		errs = append(errs, fileErrs...)
+	} else {
+		cfg.Tenants = tenant.Tenants
+		cfg.Tokens = tenant.Tokens
+		fileErrs = append(cfg.resolveSecrets(TENANT_CONFIG, "tenants", &cfg.Tenants),
+			cfg.resolveSecrets(TENANT_CONFIG, "tokens", &cfg.Tokens)...)
+		if len(fileErrs) > 0 {
+			errs = append(errs, fileErrs...)
+			return errs
+		}
+		if tenantRequired {
+			errs = append(errs, ValidateTenants(cfg.Tenants)...)
+		}
+		errs = append(errs, validateTokens(&cfg.Tokens, tokenRequired)...)
+		// 生产环境拒绝默认或弱 token 和签名 key
+		if cfg.App.IsRelease() {
+			errs = append(errs, validateTenantSecrets(cfg.Tenants, &cfg.Tokens)...)
+		}
+	}
+	return errs
+}
+
+// configs loaded, without loading
+func Loaded() bool {
+	configLock.RLock()
+	defer configLock.RUnlock()
+	return current != nil
+}
+
+// current configs, Load is called once on startup, empty configs before loaded
+func Get() *Configs {
+	configLock.RLock()
+	defer configLock.RUnlock()
+	if current == nil {
+		return &Configs{}
+	}
+	return current
+}
+
+// read route config, used on startup and reload
+func DecodeRoute(v *viper.Viper, read bool) (RouteConfig, Errors) {
+	route, _, errs := decodeRoute(v, read)
+	return route, errs
+}
+
+// read route config and resolve secret references
+func decodeRoute(v *viper.Viper, read bool) (RouteConfig, []SecretRef, Errors) {
+	setRouteDefaults(v)
+	route := RouteConfig{}
+	var errs Errors
+	if read {
+		errs = append(errs, readAndDecode(v, ROUTE_CONFIG, true, &route)...)
+	} else {
+		errs = append(errs, decode(v, ROUTE_CONFIG, &route)...)
+	}
+	if len(errs) > 0 {
+		return route, nil, errs
+	}
+	refs, errs := resolveSecrets(ROUTE_CONFIG, "", &route)
+	if len(errs) > 0 {
+		return route, refs, errs
+	}
+	return route, refs, validateRoute(&route)
+}
+
+// resolve secret references of config file, resolved refs are recorded
+func (cfg *Configs) resolveSecrets(file, prefix string, out interface{}) Errors {
+	refs, errs := resolveSecrets(file, prefix, out)
+	cfg.Secrets = append(cfg.Secrets, refs...)
+	return errs
+}
+
+// read config file and decode
+func readAndDecode(v *viper.Viper, file string, required bool, out interface{}) Errors {
+	if err := v.ReadInConfig(); err != nil {
+		var notFound viper.ConfigFileNotFoundError
+		if errors.As(err, &notFound) && !required {
+			return nil
+		}
+		return Errors{&FieldError{File: file, Message: err.Error()}}
+	}
+	return decode(v, file, out)
+}
+
+// decode settings, type errors reported one by one
+func decode(v *viper.Viper, file string, out interface{}) Errors {
+	err := v.Unmarshal(out)
+	if err == nil {
+		return nil
+	}
+	var errs Errors
+	var decodeErr *mapstructure.Error
+	if errors.As(err, &decodeErr) {
+		for _, msg := range decodeErr.Errors {
+			errs = append(errs, &FieldError{File: file, Message: msg})
+		}
+		return errs
+	}
+	return Errors{&FieldError{File: file, Message: err.Error()}}
+}
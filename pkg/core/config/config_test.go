@@ -0,0 +1,18 @@
+package config
+
+import "testing"
+
+func TestGetWithoutLoad(t *testing.T) {
+	Set(nil)
+	t.Cleanup(func() { Set(nil) })
+	// 未加载时不读取配置文件
+	if cfg := Get(); cfg == nil || Loaded() {
+		t.Fatalf("Get before Load = %+v, loaded %v", cfg, Loaded())
+	}
+	cfg := &Configs{}
+	cfg.App.RunMode = "testing"
+	Set(cfg)
+	if got := Get(); got != cfg || !Loaded() {
+		t.Errorf("Get = %p, want %p", got, cfg)
+	}
+}
@@ -0,0 +1,101 @@
+package config
+
+import "github.com/spf13/viper"
+
+// Config.yaml defaults
+func SetAppDefaults(v *viper.Viper) {
+	v.SetDefault("RUN_MODE", "dev")
+	v.SetDefault("HTTP_DEBUG_MODE", "debug")
+	v.SetDefault("HTTP_TIME_DURATION", 10)
+	v.SetDefault("PAGE_SIZE", 20)
+	v.SetDefault("HTTP_SERVER_PORT", "9800")
+	v.SetDefault("ADMIN_SERVER_ADDR", "127.0.0.1:9801")
+	v.SetDefault("SESSION_SECRET", "secret")
+	v.SetDefault("DB_ENABLED", true)
+	v.SetDefault("DB_DRIVER", "mysql")
+	v.SetDefault("DB_PORT", "3306")
+	v.SetDefault("DB_MAX_IDLE_CONNS", 10)
+	v.SetDefault("DB_MAX_OPEN_CONNS", 100)
+	v.SetDefault("DB_CONN_MAX_LIFETIME", 60)
+	v.SetDefault("DB_SLOWTHRESHOLD", 5)
+	v.SetDefault("DB_LOGMODE", "info")
+	v.SetDefault("DB_SYNC_INTERVAL", 10)
+	v.SetDefault("CACHE_CONNECT_MODE", "single")
+	v.SetDefault("CACHE_POOL_SIZE", 15)
+	v.SetDefault("CACHE_MINIDLE_CONNS", 10)
+	v.SetDefault("HTTP_CACHE_LRU_SIZE", 1024)
+	v.SetDefault("LOG_LEVEL", "info")
+	v.SetDefault("LOG_MAX_AGE", 43200)
+	v.SetDefault("LOG_ROTATION_TIME", 1140)
+}
+
+// PoolConfig.yaml setting defaults
+func setPoolDefaults(v *viper.Viper) {
+	v.SetDefault("pool.setting.ENABLED", true)
+	v.SetDefault("pool.setting.CLUSTER_NODE_NUM", 1)
+	v.SetDefault("pool.setting.CLUSTER_MEMBERSHIP", "static")
+	v.SetDefault("pool.setting.CLUSTER_HEARTBEAT", 5)
+	v.SetDefault("pool.setting.DIAL_TIMEOUT", 5)
+	v.SetDefault("pool.setting.BACKOFF_MAX_DELAY", 3)
+	v.SetDefault("pool.setting.KEEPALIVE_TIME", 10)
+	v.SetDefault("pool.setting.KEEPALIVE_TIMEOUT", 3)
+	v.SetDefault("pool.setting.NETWORK_MODE", 1)
+	v.SetDefault("pool.setting.GATEWAY_PROXY_ADDR", "0.0.0.0")
+	v.SetDefault("pool.setting.ENGINE_SERVICE_SELECTOR_KEY", "engine")
+	v.SetDefault("pool.setting.tls.CLIENT_AUTH", "none")
+	v.SetDefault("pool.setting.READY_MIN_HEALTHY_POOLS", 1)
+	v.SetDefault("pool.setting.limiter.LEASE", 30)
+	v.SetDefault("pool.setting.limiter.FAIL_POLICY", "open")
+}
+
+// engine defaults, list items are not covered by viper defaults
+func (pool *PoolConfig) setDefaults() {
+	for i := range pool.Engine {
+		engine := &pool.Engine[i]
+		if engine.RequestIdleTime == 0 {
+			engine.RequestIdleTime = 10
+		}
+		if engine.RequestMaxLife == 0 {
+			engine.RequestMaxLife = 60
+		}
+		if engine.RequestTimeout == 0 {
+			engine.RequestTimeout = 10
+		}
+		if engine.EnginePoolInitIntervalTime == 0 {
+			engine.EnginePoolInitIntervalTime = 30
+		}
+		if engine.Discovery.Type == "" {
+			engine.Discovery.Type = "static"
+		}
+		if engine.Discovery.DNSType == "" {
+			engine.Discovery.DNSType = "A"
+		}
+		if engine.Discovery.PoolSize == 0 {
+			engine.Discovery.PoolSize = 10
+		}
+		if engine.Discovery.RefreshInterval == 0 {
+			engine.Discovery.RefreshInterval = 10
+		}
+	}
+}
+
+// TenantConfig.yaml tokens defaults
+func setTenantDefaults(v *viper.Viper) {
+	v.SetDefault("tokens.TTL", 86400)
+	v.SetDefault("tokens.MAX_TTL", 2592000)
+	v.SetDefault("tokens.REVOCATION_CACHE_TTL", 5)
+	v.SetDefault("tokens.REVOCATION_FAIL_POLICY", "closed")
+}
+
+// VSConfig.yaml dvs defaults
+func setVSDefaults(v *viper.Viper) {
+	v.SetDefault("vs.dvs.API_VERSION", "v1.40")
+	v.SetDefault("vs.dvs.TEMPLATE_PATH", "config/docker/template/")
+	v.SetDefault("vs.dvs.ADDRESS_MODE", "container")
+}
+
+// RouteConfig.yaml transcode defaults
+func setRouteDefaults(v *viper.Viper) {
+	v.SetDefault("transcode.STREAM_FORMAT", "ndjson")
+	v.SetDefault("transcode.REQUEST_TIMEOUT", 30)
+}
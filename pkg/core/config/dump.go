@@ -0,0 +1,139 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// redacted secret value
+const REDACTED = "******"
+
+// secret key words, matched against upper case keys
+var secretKeyWords = []string{"PASSWD", "PASSWORD", "TOKEN", "SECRET", "AUTHORIZATION", "CREDENTIAL", "PRIVATE_KEY", "API_KEY"}
+
+// secret keys, exact match, e.g. API_KEYS[].KEY
+var secretKeys = map[string]bool{"KEY": true}
+
+// config files in dump order
+var configFiles = []string{APP_CONFIG, POOL_CONFIG, TENANT_CONFIG, ROUTE_CONFIG, VS_CONFIG}
+
+// dump config files as read, secrets redacted
+func DumpFiles() (map[string]interface{}, error) {
+	out := make(map[string]interface{})
+	for _, file := range configFiles {
+		v := viper.New()
+		SetupViper(v, file)
+		if err := v.ReadInConfig(); err != nil {
+			if _, ok := err.(viper.ConfigFileNotFoundError); ok {
+				continue
+			}
+			return nil, fmt.Errorf("%s: %v", file, err)
+		}
+		out[file] = redact("", v.AllSettings())
+	}
+	return out, nil
+}
+
+// dump effective configs with defaults and env overrides, secrets redacted
+func DumpEffective(cfg *Configs) map[string]interface{} {
+	return map[string]interface{}{
+		APP_CONFIG:  redact("", toMap(reflect.ValueOf(cfg.App))),
+		POOL_CONFIG: map[string]interface{}{"pool": redact("", toMap(reflect.ValueOf(cfg.Pool)))},
+		TENANT_CONFIG: map[string]interface{}{
+			"tenants": redact("", toMap(reflect.ValueOf(cfg.Tenants))),
+			"tokens":  redact("", toMap(reflect.ValueOf(cfg.Tokens))),
+		},
+		ROUTE_CONFIG: redact("", toMap(reflect.ValueOf(cfg.Route))),
+		VS_CONFIG:    map[string]interface{}{"vs": redact("", toMap(reflect.ValueOf(cfg.VS)))},
+	}
+}
+
+// is secret key
+func IsSecretKey(key string) bool {
+	key = strings.ToUpper(key)
+	if secretKeys[key] {
+		return true
+	}
+	for _, word := range secretKeyWords {
+		if strings.Contains(key, word) {
+			return true
+		}
+	}
+	return false
+}
+
+// redact secrets and normalize maps for marshal
+func redact(key string, v interface{}) interface{} {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(value))
+		for k, item := range value {
+			out[k] = redact(k, item)
+		}
+		return out
+	case map[interface{}]interface{}:
+		out := make(map[string]interface{}, len(value))
+		for k, item := range value {
+			name := fmt.Sprintf("%v", k)
+			out[name] = redact(name, item)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, 0, len(value))
+		for _, item := range value {
+			out = append(out, redact(key, item))
+		}
+		return out
+	}
+	// 密钥引用不是密钥本身
+	if s, ok := v.(string); ok && secretRefPattern.MatchString(strings.TrimSpace(s)) {
+		return v
+	}
+	if IsSecretKey(key) && v != nil && fmt.Sprintf("%v", v) != "" {
+		return REDACTED
+	}
+	return v
+}
+
+// typed config to map by mapstructure tags
+func toMap(v reflect.Value) interface{} {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return toMap(v.Elem())
+	case reflect.Struct:
+		out := make(map[string]interface{})
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if field.PkgPath != "" {
+				continue
+			}
+			name := strings.Split(field.Tag.Get("mapstructure"), ",")[0]
+			if name == "" {
+				name = field.Name
+			}
+			out[name] = toMap(v.Field(i))
+		}
+		return out
+	case reflect.Slice, reflect.Array:
+		out := make([]interface{}, 0, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			out = append(out, toMap(v.Index(i)))
+		}
+		return out
+	case reflect.Map:
+		out := make(map[string]interface{}, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			out[fmt.Sprintf("%v", iter.Key().Interface())] = toMap(iter.Value())
+		}
+		return out
+	}
+	return v.Interface()
+}
@@ -0,0 +1,63 @@
+package config
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ghodss/yaml"
+)
+
+func TestIsSecretKey(t *testing.T) {
+	cases := []struct {
+		key    string
+		secret bool
+	}{
+		{"KEY", true},
+		{"key", true},
+		{"CACHE_PASSWD", true},
+		{"SESSION_SECRET", true},
+		{"TOKEN", true},
+		{"KEY_FILE", false},
+		{"KID", false},
+		{"NAME", false},
+		{"ROLE", false},
+	}
+	for _, c := range cases {
+		if got := IsSecretKey(c.key); got != c.secret {
+			t.Errorf("IsSecretKey(%q) = %v, want %v", c.key, got, c.secret)
+		}
+	}
+}
+
+func TestDumpEffectiveRedactsAPIKeys(t *testing.T) {
+	cfg := &Configs{}
+	cfg.App.APIKeys = []APIKey{{Name: "ops", Key: "SUPERSECRETAPIKEY123", Role: "operator"}}
+	cfg.App.SessionSecret = "SUPERSECRETSESSION"
+	out, err := yaml.Marshal(DumpEffective(cfg))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dump := string(out)
+	if strings.Contains(dump, "SUPERSECRETAPIKEY123") || strings.Contains(dump, "SUPERSECRETSESSION") {
+		t.Fatalf("secret in dump:\n%s", dump)
+	}
+	if !strings.Contains(dump, "KEY: '"+REDACTED+"'") {
+		t.Fatalf("api key not redacted:\n%s", dump)
+	}
+	if !strings.Contains(dump, "NAME: ops") {
+		t.Fatalf("api key name missing:\n%s", dump)
+	}
+}
+
+func TestRedactKeepsSecretRefs(t *testing.T) {
+	out := redact("", map[string]interface{}{
+		"KEY":          "${file:/run/secrets/api-key}",
+		"CACHE_PASSWD": "plain",
+	}).(map[string]interface{})
+	if out["KEY"] != "${file:/run/secrets/api-key}" {
+		t.Errorf("secret ref redacted: %v", out["KEY"])
+	}
+	if out["CACHE_PASSWD"] != REDACTED {
+		t.Errorf("password not redacted: %v", out["CACHE_PASSWD"])
+	}
+}
@@ -0,0 +1,147 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// min secret length in release mode
+const (
+	MIN_SECRET_LENGTH         = 8
+	MIN_TOKEN_LENGTH          = 16
+	MIN_SESSION_SECRET_LENGTH = 32
+	MIN_SIGNING_KEY_LENGTH    = 32
+)
+
+// secret reference, e.g. ${env:DB_PASSWD} or ${file:/run/secrets/db_passwd}
+var secretRefPattern = regexp.MustCompile(`^\$\{(env|file):([^}]+)\}$`)
+
+// default or well-known secrets, refused in release mode
+var weakSecrets = []string{
+	"secret", "password", "passwd", "123456", "12345678", "admin", "root", "changeme", "change_me", "***",
+	// shipped with sample configs
+	"zhuiyi123", "uWXf87plmQGz8zMM", "OHR2MjJzLTh0djIyczhpMC0xMjM0", "MjJzOGkxLTh0djIyczhpMS01NDMy",
+}
+
+// resolved secret reference, value is never kept
+type SecretRef struct {
+	File   string
+	Key    string
+	Source string // env:NAME or file:PATH
+}
+
+// resolve secret reference, source is empty when value is not a reference
+func ResolveSecret(value string) (string, string, error) {
+	match := secretRefPattern.FindStringSubmatch(strings.TrimSpace(value))
+	if match == nil {
+		return value, "", nil
+	}
+	kind, name := match[1], strings.TrimSpace(match[2])
+	source := kind + ":" + name
+	switch kind {
+	case "env":
+		resolved, ok := os.LookupEnv(name)
+		if !ok {
+			return "", source, fmt.Errorf("env %s is not set", name)
+		}
+		return resolved, source, nil
+	case "file":
+		data, err := ioutil.ReadFile(name)
+		if err != nil {
+			return "", source, fmt.Errorf("read secret file: %v", err)
+		}
+		// k8s secret 挂载文件可能以换行结尾
+		return strings.TrimRight(string(data), "\r\n"), source, nil
+	}
+	return value, "", nil
+}
+
+// resolve secret references in typed config
+func resolveSecrets(file, prefix string, out interface{}) ([]SecretRef, Errors) {
+	r := &secretResolver{file: file}
+	r.walk(prefix, reflect.ValueOf(out))
+	return r.refs, r.errs
+}
+
+type secretResolver struct {
+	file string
+	refs []SecretRef
+	errs Errors
+}
+
+func (r *secretResolver) resolve(key, value string) (string, bool) {
+	resolved, source, err := ResolveSecret(value)
+	if source == "" {
+		return value, false
+	}
+	if err != nil {
+		r.errs = append(r.errs, &FieldError{File: r.file, Key: key, Message: err.Error()})
+		return value, false
+	}
+	r.refs = append(r.refs, SecretRef{File: r.file, Key: key, Source: source})
+	return resolved, true
+}
+
+func (r *secretResolver) walk(key string, v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if !v.IsNil() {
+			r.walk(key, v.Elem())
+		}
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if field.PkgPath != "" {
+				continue
+			}
+			name := strings.Split(field.Tag.Get("mapstructure"), ",")[0]
+			if name == "" {
+				name = field.Name
+			}
+			if key != "" {
+				name = key + "." + name
+			}
+			r.walk(name, v.Field(i))
+		}
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			r.walk(fmt.Sprintf("%s[%d]", key, i), v.Index(i))
+		}
+	case reflect.Map:
+		if v.Type().Elem().Kind() != reflect.String {
+			return
+		}
+		iter := v.MapRange()
+		for iter.Next() {
+			mapKey := fmt.Sprintf("%s.%v", key, iter.Key().Interface())
+			if resolved, ok := r.resolve(mapKey, iter.Value().String()); ok {
+				v.SetMapIndex(iter.Key(), reflect.ValueOf(resolved).Convert(v.Type().Elem()))
+			}
+		}
+	case reflect.String:
+		if resolved, ok := r.resolve(key, v.String()); ok && v.CanSet() {
+			v.SetString(resolved)
+		}
+	}
+}
+
+// default or weak secret, returns the reason
+func weakSecret(value string, minLength int) string {
+	if value == "" {
+		return "is empty"
+	}
+	for _, weak := range weakSecrets {
+		if strings.EqualFold(value, weak) {
+			return "is a default or well-known value"
+		}
+	}
+	if len(value) < minLength {
+		return fmt.Sprintf("is shorter than %d characters", minLength)
+	}
+	return ""
+}
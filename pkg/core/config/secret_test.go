@@ -0,0 +1,23 @@
+package config
+
+import "testing"
+
+func TestWeakSecret(t *testing.T) {
+	cases := []struct {
+		value string
+		weak  bool
+	}{
+		{"", true},
+		{"secret", true},
+		{"ChangeMe", true},
+		{"zhuiyi123", true},
+		{"short", true},
+		{"0123456789abcdef", false},
+		{"0123456789abcde", true},
+	}
+	for _, c := range cases {
+		if got := weakSecret(c.value, 16) != ""; got != c.weak {
+			t.Errorf("weakSecret(%q) = %v, want %v", c.value, got, c.weak)
+		}
+	}
+}
@@ -0,0 +1,290 @@
+package config
+
+import "strings"
+
+// Config.yaml
+type AppConfig struct {
+	// runtime setting
+	RunMode           string `mapstructure:"RUN_MODE"`
+	HttpDebugMode     string `mapstructure:"HTTP_DEBUG_MODE"`
+	HttpTimeDuration  int    `mapstructure:"HTTP_TIME_DURATION"` // second
+	PageSize          int    `mapstructure:"PAGE_SIZE"`
+	HttpServerPort    string `mapstructure:"HTTP_SERVER_PORT"`
+	GrpcPort          string `mapstructure:"GRPC_PORT"`
+	AdminServerAddr   string `mapstructure:"ADMIN_SERVER_ADDR"` // 管理端口, host:port
+	AdminPprofEnabled bool   `mapstructure:"ADMIN_PPROF_ENABLED"`
+	SessionSecret     string `mapstructure:"SESSION_SECRET"`
+	// db setting
+	DBEnabled         bool   `mapstructure:"DB_ENABLED"`
+	DBHost            string `mapstructure:"DB_HOST"`
+	DBDriver          string `mapstructure:"DB_DRIVER"`
+	DBPort            string `mapstructure:"DB_PORT"`
+	DBName            string `mapstructure:"DB_NAME"`
+	DBUser            string `mapstructure:"DB_USER"`
+	DBPasswd          string `mapstructure:"DB_PASSWD"`
+	DBMaxIdleConns    int    `mapstructure:"DB_MAX_IDLE_CONNS"`
+	DBMaxOpenConns    int    `mapstructure:"DB_MAX_OPEN_CONNS"`
+	DBConnMaxLifetime int    `mapstructure:"DB_CONN_MAX_LIFETIME"` // minutes
+	DBSlowThreshold   int    `mapstructure:"DB_SLOWTHRESHOLD"`
+	DBLogMode         string `mapstructure:"DB_LOGMODE"`
+	DBSyncInterval    int    `mapstructure:"DB_SYNC_INTERVAL"` // second, 轮询 db 中的租户、路由和引擎
+	// cache setting
+	CacheEnabled      bool   `mapstructure:"CACHE_ENABLED"`
+	CacheConnectMode  string `mapstructure:"CACHE_CONNECT_MODE"`
+	CacheAddress      string `mapstructure:"CACHE_ADDRESS"`
+	CachePasswd       string `mapstructure:"CACHE_PASSWD"`
+	CachePoolSize     int    `mapstructure:"CACHE_POOL_SIZE"`
+	CacheMinidleConns int    `mapstructure:"CACHE_MINIDLE_CONNS"`
+	CacheDB           int    `mapstructure:"CACHE_DB"`
+	HttpCacheLruSize  int    `mapstructure:"HTTP_CACHE_LRU_SIZE"`
+	// log setting
+	LogLevel        string `mapstructure:"LOG_LEVEL"`
+	LogMaxAge       int    `mapstructure:"LOG_MAX_AGE"`       // minutes
+	LogRotationTime int    `mapstructure:"LOG_ROTATION_TIME"` // minutes
+	// management api keys
+	APIKeys []APIKey `mapstructure:"API_KEYS"`
+}
+
+// management api key
+type APIKey struct {
+	Name string `mapstructure:"NAME"`
+	Key  string `mapstructure:"KEY"`
+	Role string `mapstructure:"ROLE"` // viewer, operator, admin
+}
+
+// release mode, default or weak secrets are refused
+func (app *AppConfig) IsRelease() bool {
+	return strings.EqualFold(app.HttpDebugMode, "release") || strings.EqualFold(app.RunMode, "release")
+}
+
+// PoolConfig.yaml, pool section
+type PoolConfig struct {
+	Setting PoolSetting  `mapstructure:"setting"`
+	Engine  []PoolEngine `mapstructure:"engine"`
+}
+
+// pool setting
+type PoolSetting struct {
+	Enabled                       bool      `mapstructure:"ENABLED"`
+	ClusterEnabled                bool      `mapstructure:"CLUSTER_ENABLED"`
+	ClusterNodeNum                int       `mapstructure:"CLUSTER_NODE_NUM"`
+	ClusterMembership             string    `mapstructure:"CLUSTER_MEMBERSHIP"` // static: CLUSTER_NODE_NUM, redis 或 lease: 节点注册
+	ClusterHeartbeat              int       `mapstructure:"CLUSTER_HEARTBEAT"`  // second, 3 次未续约视为离开
+	DialTimeout                   int       `mapstructure:"DIAL_TIMEOUT"`       // second
+	BackoffMaxDelay               int       `mapstructure:"BACKOFF_MAX_DELAY"`  // second
+	KeepaliveTime                 int       `mapstructure:"KEEPALIVE_TIME"`     // second
+	KeepaliveTimeout              int       `mapstructure:"KEEPALIVE_TIMEOUT"`  // second
+	OMPEnabled                    bool      `mapstructure:"OMP_ENABLED"`
+	TenantEnabled                 bool      `mapstructure:"TENANT_ENABLED"`
+	NetworkMode                   int       `mapstructure:"NETWORK_MODE"`
+	GatewayProxyAddr              string    `mapstructure:"GATEWAY_PROXY_ADDR"`
+	EngineServiceSelectorKey      string    `mapstructure:"ENGINE_SERVICE_SELECTOR_KEY"`
+	AsrEngineServiceSelectorValue string    `mapstructure:"ASR_ENGINE_SERVICE_SELECTOR_VALUE"`
+	TtsEngineServiceSelectorValue string    `mapstructure:"TTS_ENGINE_SERVICE_SELECTOR_VALUE"`
+	GrpcWebAllowOrigins           []string  `mapstructure:"GRPC_WEB_ALLOW_ORIGINS"`
+	ReadyMinHealthyPools          int       `mapstructure:"READY_MIN_HEALTHY_POOLS"` // readyz 每个引擎至少可用的连接池数
+	TLS                           ServerTLS `mapstructure:"tls"`
+	Limiter                       Limiter   `mapstructure:"limiter"`
+}
+
+// distributed concurrency limiter of tenants and engines, slots are shared by gateways on redis
+type Limiter struct {
+	Enabled    bool   `mapstructure:"ENABLED"`
+	Lease      int    `mapstructure:"LEASE"`       // second, 持有期间自动续约, 节点崩溃后到期释放
+	FailPolicy string `mapstructure:"FAIL_POLICY"` // redis 不可用时, open: 使用本地限流, closed: 拒绝请求
+}
+
+// gateway listener tls, cert files are reloaded when rotated
+type ServerTLS struct {
+	Enabled      bool   `mapstructure:"ENABLED"`
+	CertFile     string `mapstructure:"CERT_FILE"`
+	KeyFile      string `mapstructure:"KEY_FILE"`
+	ClientCAFile string `mapstructure:"CLIENT_CA_FILE"` // 校验客户端证书的 CA
+	ClientAuth   string `mapstructure:"CLIENT_AUTH"`    // none, request, require
+}
+
+// engine pool setting
+type PoolEngine struct {
+	EngineName                 string          `mapstructure:"ENGINE_NAME"`
+	PoolEnabled                bool            `mapstructure:"POOL_ENABLED"`
+	GatewayProxyPort           string          `mapstructure:"GATEWAY_PROXY_PORT"`
+	GrpcWebPort                string          `mapstructure:"GRPC_WEB_PORT"`
+	PoolModel                  int             `mapstructure:"POOL_MODEL"`
+	GrpcRequestReusable        bool            `mapstructure:"GRPC_REQUEST_REUSABLE"`
+	RequestIdleTime            int             `mapstructure:"REQUEST_IDLE_TIME"` // second
+	RequestMaxLife             int             `mapstructure:"REQUEST_MAX_LIFE"`  // second
+	RequestTimeout             int             `mapstructure:"REQUEST_TIMEOUT"`   // second
+	EnginePoolInitIntervalTime int             `mapstructure:"ENGINE_POOL_INIT_INTERVAL_TIME"`
+	EngineServerPort           string          `mapstructure:"ENGINE_SERVER_PORT"`
+	EngineList                 []EngineServer  `mapstructure:"ENGINE_LIST"`
+	TLS                        EngineTLS       `mapstructure:"tls"`
+	Discovery                  EngineDiscovery `mapstructure:"discovery"`
+}
+
+// engine discovery when OMP disabled, static uses ENGINE_LIST
+type EngineDiscovery struct {
+	Type            string `mapstructure:"TYPE"`             // static, dns, file, http
+	DNSName         string `mapstructure:"DNS_NAME"`         // dns 域名, SRV 如 _grpc._tcp.asr.example.com
+	DNSType         string `mapstructure:"DNS_TYPE"`         // A, SRV
+	File            string `mapstructure:"FILE"`             // json 或 yaml endpoints 文件
+	URL             string `mapstructure:"URL"`              // http catalog, 支持 consul health api
+	Token           string `mapstructure:"TOKEN"`            // http catalog token
+	SceneCode       string `mapstructure:"SCENE_CODE"`       // endpoint 未指定时的场景码
+	PoolSize        int    `mapstructure:"POOL_SIZE"`        // endpoint 未指定时的连接池大小
+	RefreshInterval int    `mapstructure:"REFRESH_INTERVAL"` // second
+}
+
+// engine connection tls, cert files are reloaded when rotated
+type EngineTLS struct {
+	Enabled            bool   `mapstructure:"ENABLED"`
+	CAFile             string `mapstructure:"CA_FILE"`   // 为空时使用系统 CA
+	CertFile           string `mapstructure:"CERT_FILE"` // 引擎要求客户端证书时配置
+	KeyFile            string `mapstructure:"KEY_FILE"`
+	ServerName         string `mapstructure:"SERVER_NAME"`
+	InsecureSkipVerify bool   `mapstructure:"INSECURE_SKIP_VERIFY"`
+}
+
+// engine server
+type EngineServer struct {
+	ServerHost         string `mapstructure:"SERVER_HOST"`
+	EngineGrpcPoolSize int    `mapstructure:"ENGINE_GRPC_POOL_SIZE"`
+}
+
+// TenantConfig.yaml, tenants item
+type TenantConfig struct {
+	TenantId       string `mapstructure:"TENANT_ID"`
+	TenantName     string `mapstructure:"TENANT_NAME"`
+	EngineName     string `mapstructure:"ENGINE_NAME"`
+	EnginePoolSize int    `mapstructure:"ENGINE_POOL_SIZE"`
+	SceneCode      string `mapstructure:"SCENE_CODE"`
+	Token          string `mapstructure:"TOKEN"`
+	// 客户端证书身份, CN, DNS/URI SAN 或 email
+	ClientIdentities []string `mapstructure:"CLIENT_IDENTITIES"`
+}
+
+// TenantConfig.yaml, tokens section
+type TokenConfig struct {
+	ActiveKid string     `mapstructure:"ACTIVE_KID"` // 签发使用的 key
+	TTL       int        `mapstructure:"TTL"`        // second
+	MaxTTL    int        `mapstructure:"MAX_TTL"`    // second
+	Keys      []TokenKey `mapstructure:"keys"`       // 校验使用全部 key
+	// 吊销查询结果缓存时间 (单位秒), 0 不缓存
+	RevocationCacheTTL int `mapstructure:"REVOCATION_CACHE_TTL"`
+	// 吊销记录查询失败时, open: 视为未吊销, closed: 拒绝请求
+	RevocationFailPolicy string `mapstructure:"REVOCATION_FAIL_POLICY"`
+}
+
+// token signing key
+type TokenKey struct {
+	Kid    string `mapstructure:"KID"`
+	Secret string `mapstructure:"SECRET"`
+}
+
+// signing key by kid
+func (tokens *TokenConfig) Key(kid string) (TokenKey, bool) {
+	for _, key := range tokens.Keys {
+		if key.Kid == kid {
+			return key, true
+		}
+	}
+	return TokenKey{}, false
+}
+
+// RouteConfig.yaml
+type RouteConfig struct {
+	Route     []Route   `mapstructure:"route"`
+	Transcode Transcode `mapstructure:"transcode"`
+}
+
+// http route
+type Route struct {
+	Path         string       `mapstructure:"path"`
+	Method       string       `mapstructure:"method"`
+	To           string       `mapstructure:"to"`
+	Cache        bool         `mapstructure:"cache"`
+	CacheTime    int          `mapstructure:"cache_time"`    // second
+	CacheHeaders []string     `mapstructure:"cache_headers"` // 参与缓存 key 的请求头
+	Headers      RouteHeaders `mapstructure:"headers"`
+	WebSocket    bool         `mapstructure:"websocket"`
+	IdleTimeout  int          `mapstructure:"idle_timeout"` // second
+}
+
+// route header policy
+type RouteHeaders struct {
+	Request struct {
+		Allow []string          `mapstructure:"allow"`
+		Deny  []string          `mapstructure:"deny"`
+		Add   map[string]string `mapstructure:"add"`
+	} `mapstructure:"request"`
+	Response struct {
+		Set    map[string]string `mapstructure:"set"`
+		Remove []string          `mapstructure:"remove"`
+		Rename map[string]string `mapstructure:"rename"`
+	} `mapstructure:"response"`
+}
+
+// http/json to grpc transcode
+type Transcode struct {
+	Enabled        bool               `mapstructure:"ENABLED"`
+	DescriptorSet  string             `mapstructure:"DESCRIPTOR_SET"`
+	StreamFormat   string             `mapstructure:"STREAM_FORMAT"`
+	RequestTimeout int                `mapstructure:"REQUEST_TIMEOUT"` // second
+	Services       []TranscodeService `mapstructure:"services"`
+}
+
+// transcode engine services
+type TranscodeService struct {
+	EngineName string          `mapstructure:"ENGINE_NAME"`
+	Services   []string        `mapstructure:"SERVICES"`
+	Rules      []TranscodeRule `mapstructure:"rules"`
+}
+
+// transcode http rule
+type TranscodeRule struct {
+	Selector     string `mapstructure:"selector"`
+	Get          string `mapstructure:"get"`
+	Put          string `mapstructure:"put"`
+	Post         string `mapstructure:"post"`
+	Delete       string `mapstructure:"delete"`
+	Patch        string `mapstructure:"patch"`
+	Body         string `mapstructure:"body"`
+	ResponseBody string `mapstructure:"response_body"`
+}
+
+// http method and path of the rule
+func (rule TranscodeRule) Pattern() (string, string) {
+	for _, p := range [][2]string{
+		{"GET", rule.Get}, {"PUT", rule.Put}, {"POST", rule.Post},
+		{"DELETE", rule.Delete}, {"PATCH", rule.Patch},
+	} {
+		if p[1] != "" {
+			return p[0], p[1]
+		}
+	}
+	return "", ""
+}
+
+// VSConfig.yaml, vs section
+type VSConfig struct {
+	Kvs KvsConfig `mapstructure:"kvs"`
+	Dvs DvsConfig `mapstructure:"dvs"`
+}
+
+// k8s vs setting
+type KvsConfig struct {
+	Enabled        bool   `mapstructure:"ENABLED"`
+	Namespace      string `mapstructure:"NAMESPACE"`
+	DeployFilePath string `mapstructure:"DEPLOY_FILE_PATH"`
+	MinCpu         string `mapstructure:"MIN_CPU"`
+	MinMemory      string `mapstructure:"MIN_MEMORY"`
+}
+
+// docker vs setting
+type DvsConfig struct {
+	Enabled      bool   `mapstructure:"ENABLED"`
+	Host         string `mapstructure:"HOST"`          // unix:///var/run/docker.sock, tcp://host:2375 或 host
+	APIVersion   string `mapstructure:"API_VERSION"`   // docker engine api version, e.g. v1.40
+	TemplatePath string `mapstructure:"TEMPLATE_PATH"` // 容器模板目录, <engine type>.yaml
+	Network      string `mapstructure:"NETWORK"`       // 读取容器 ip 的网络, 为空时使用第一个
+	AddressMode  string `mapstructure:"ADDRESS_MODE"`  // container: 容器 ip, host: docker host 映射端口
+}
@@ -0,0 +1,486 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// config error with file and key path
+type FieldError struct {
+	File    string
+	Key     string
+	Message string
+}
+
+func (e *FieldError) Error() string {
+	if e.Key == "" {
+		return fmt.Sprintf("%s: %s", e.File, e.Message)
+	}
+	return fmt.Sprintf("%s: %s: %s", e.File, e.Key, e.Message)
+}
+
+// config errors
+type Errors []error
+
+func (errs Errors) Error() string {
+	msgs := make([]string, 0, len(errs))
+	for _, err := range errs {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// validator of one config file
+type validator struct {
+	file string
+	errs Errors
+}
+
+func (v *validator) addf(key, format string, args ...interface{}) {
+	v.errs = append(v.errs, &FieldError{File: v.file, Key: key, Message: fmt.Sprintf(format, args...)})
+}
+
+func (v *validator) required(key, value string) bool {
+	if strings.TrimSpace(value) == "" {
+		v.addf(key, "is required")
+		return false
+	}
+	return true
+}
+
+func (v *validator) port(key, value string) {
+	if !v.required(key, value) {
+		return
+	}
+	if p, err := strconv.Atoi(value); err != nil || p <= 0 || p > 65535 {
+		v.addf(key, "invalid port %q", value)
+	}
+}
+
+func (v *validator) positive(key string, value int) {
+	if value <= 0 {
+		v.addf(key, "must be greater than 0, got %d", value)
+	}
+}
+
+func (v *validator) nonNegative(key string, value int) {
+	if value < 0 {
+		v.addf(key, "must not be negative, got %d", value)
+	}
+}
+
+func (v *validator) oneOf(key, value string, options ...string) {
+	for _, option := range options {
+		if strings.EqualFold(value, option) {
+			return
+		}
+	}
+	v.addf(key, "invalid value %q, expected one of %s", value, strings.Join(options, ", "))
+}
+
+// k8s resource quantity, e.g. 1, 500m, 8000Mi, empty means no minimum
+func (v *validator) quantity(key, value string) {
+	if value == "" {
+		return
+	}
+	if _, err := resource.ParseQuantity(value); err != nil {
+		v.addf(key, "invalid quantity %q", value)
+	}
+}
+
+func (v *validator) fileExists(key, path string) {
+	if !v.required(key, path) {
+		return
+	}
+	if info, err := os.Stat(path); err != nil {
+		v.addf(key, "%v", err)
+	} else if info.IsDir() {
+		v.addf(key, "%s is a directory", path)
+	}
+}
+
+func (v *validator) secret(key, value string, minLength int) {
+	if reason := weakSecret(value, minLength); reason != "" {
+		v.addf(key, "%s, default or weak secrets are not allowed in release mode", reason)
+	}
+}
+
+// validate Config.yaml
+func validateApp(app *AppConfig) Errors {
+	v := &validator{file: APP_CONFIG}
+	v.oneOf("HTTP_DEBUG_MODE", app.HttpDebugMode, "debug", "release", "test")
+	v.positive("HTTP_TIME_DURATION", app.HttpTimeDuration)
+	v.positive("PAGE_SIZE", app.PageSize)
+	v.port("HTTP_SERVER_PORT", app.HttpServerPort)
+	if v.required("ADMIN_SERVER_ADDR", app.AdminServerAddr) {
+		_, port, err := net.SplitHostPort(app.AdminServerAddr)
+		switch {
+		case err != nil:
+			v.addf("ADMIN_SERVER_ADDR", "invalid address %q, expected host:port", app.AdminServerAddr)
+		case port == app.HttpServerPort:
+			v.addf("ADMIN_SERVER_ADDR", "port %s already used by HTTP_SERVER_PORT", port)
+		default:
+			v.port("ADMIN_SERVER_ADDR", port)
+		}
+	}
+	if app.GrpcPort != "" {
+		v.port("GRPC_PORT", app.GrpcPort)
+	}
+	if app.DBEnabled {
+		v.required("DB_HOST", app.DBHost)
+		v.oneOf("DB_DRIVER", app.DBDriver, "mysql", "postgresql", "sqlite", "sqlserver")
+		v.port("DB_PORT", app.DBPort)
+		v.required("DB_NAME", app.DBName)
+		v.nonNegative("DB_MAX_IDLE_CONNS", app.DBMaxIdleConns)
+		v.nonNegative("DB_MAX_OPEN_CONNS", app.DBMaxOpenConns)
+		v.nonNegative("DB_CONN_MAX_LIFETIME", app.DBConnMaxLifetime)
+		v.oneOf("DB_LOGMODE", app.DBLogMode, "silent", "error", "warn", "info")
+		v.positive("DB_SYNC_INTERVAL", app.DBSyncInterval)
+	}
+	if app.CacheEnabled {
+		v.oneOf("CACHE_CONNECT_MODE", app.CacheConnectMode, "single", "cluster")
+		v.required("CACHE_ADDRESS", app.CacheAddress)
+		v.positive("CACHE_POOL_SIZE", app.CachePoolSize)
+		v.nonNegative("CACHE_DB", app.CacheDB)
+	}
+	v.nonNegative("HTTP_CACHE_LRU_SIZE", app.HttpCacheLruSize)
+	v.oneOf("LOG_LEVEL", app.LogLevel, "debug", "info", "error")
+	v.positive("LOG_MAX_AGE", app.LogMaxAge)
+	v.positive("LOG_ROTATION_TIME", app.LogRotationTime)
+	names, keys := make(map[string]string), make(map[string]string)
+	for i, apiKey := range app.APIKeys {
+		prefix := fmt.Sprintf("API_KEYS[%d].", i)
+		if v.required(prefix+"NAME", apiKey.Name) {
+			if other, ok := names[apiKey.Name]; ok {
+				v.addf(prefix+"NAME", "duplicate name %s, already used by %s", apiKey.Name, other)
+			} else {
+				names[apiKey.Name] = prefix + "NAME"
+			}
+		}
+		if v.required(prefix+"KEY", apiKey.Key) {
+			if other, ok := keys[apiKey.Key]; ok {
+				v.addf(prefix+"KEY", "duplicate key, already used by %s", other)
+			} else {
+				keys[apiKey.Key] = prefix + "KEY"
+			}
+		}
+		v.oneOf(prefix+"ROLE", apiKey.Role, "viewer", "operator", "admin")
+	}
+	return v.errs
+}
+
+// validate Config.yaml secrets in release mode
+func validateAppSecrets(app *AppConfig) Errors {
+	v := &validator{file: APP_CONFIG}
+	if app.DBEnabled {
+		v.secret("DB_PASSWD", app.DBPasswd, MIN_SECRET_LENGTH)
+	}
+	if app.CacheEnabled {
+		v.secret("CACHE_PASSWD", app.CachePasswd, MIN_SECRET_LENGTH)
+	}
+	v.secret("SESSION_SECRET", app.SessionSecret, MIN_SESSION_SECRET_LENGTH)
+	for i, apiKey := range app.APIKeys {
+		v.secret(fmt.Sprintf("API_KEYS[%d].KEY", i), apiKey.Key, MIN_TOKEN_LENGTH)
+	}
+	return v.errs
+}
+
+// validate PoolConfig.yaml
+func validatePool(pool *PoolConfig) Errors {
+	v := &validator{file: POOL_CONFIG}
+	setting := pool.Setting
+	if !setting.Enabled {
+		return nil
+	}
+	if setting.ClusterEnabled {
+		v.oneOf("pool.setting.CLUSTER_MEMBERSHIP", setting.ClusterMembership, "static", "redis", "lease")
+		if strings.EqualFold(setting.ClusterMembership, "static") {
+			v.positive("pool.setting.CLUSTER_NODE_NUM", setting.ClusterNodeNum)
+		} else {
+			v.positive("pool.setting.CLUSTER_HEARTBEAT", setting.ClusterHeartbeat)
+		}
+	}
+	v.positive("pool.setting.DIAL_TIMEOUT", setting.DialTimeout)
+	v.positive("pool.setting.BACKOFF_MAX_DELAY", setting.BackoffMaxDelay)
+	v.positive("pool.setting.KEEPALIVE_TIME", setting.KeepaliveTime)
+	v.positive("pool.setting.KEEPALIVE_TIMEOUT", setting.KeepaliveTimeout)
+	v.nonNegative("pool.setting.READY_MIN_HEALTHY_POOLS", setting.ReadyMinHealthyPools)
+	if setting.NetworkMode != 1 && setting.NetworkMode != 2 {
+		v.addf("pool.setting.NETWORK_MODE", "invalid value %d, expected 1 or 2", setting.NetworkMode)
+	}
+	v.required("pool.setting.GATEWAY_PROXY_ADDR", setting.GatewayProxyAddr)
+	if setting.OMPEnabled {
+		v.required("pool.setting.ENGINE_SERVICE_SELECTOR_KEY", setting.EngineServiceSelectorKey)
+		v.required("pool.setting.ASR_ENGINE_SERVICE_SELECTOR_VALUE", setting.AsrEngineServiceSelectorValue)
+		v.required("pool.setting.TTS_ENGINE_SERVICE_SELECTOR_VALUE", setting.TtsEngineServiceSelectorValue)
+	}
+	if setting.TLS.Enabled {
+		v.fileExists("pool.setting.tls.CERT_FILE", setting.TLS.CertFile)
+		v.fileExists("pool.setting.tls.KEY_FILE", setting.TLS.KeyFile)
+		v.oneOf("pool.setting.tls.CLIENT_AUTH", setting.TLS.ClientAuth, "none", "request", "require")
+		// 校验客户端证书需要 CA
+		if !strings.EqualFold(setting.TLS.ClientAuth, "none") || setting.TLS.ClientCAFile != "" {
+			v.fileExists("pool.setting.tls.CLIENT_CA_FILE", setting.TLS.ClientCAFile)
+		}
+	}
+	if setting.Limiter.Enabled {
+		v.positive("pool.setting.limiter.LEASE", setting.Limiter.Lease)
+		v.oneOf("pool.setting.limiter.FAIL_POLICY", setting.Limiter.FailPolicy, "open", "closed")
+	}
+
+	ports := make(map[string]string)
+	checkPort := func(key, port string) {
+		if other, ok := ports[port]; ok {
+			v.addf(key, "port %s already used by %s", port, other)
+			return
+		}
+		ports[port] = key
+	}
+	for i, engine := range pool.Engine {
+		prefix := fmt.Sprintf("pool.engine[%d].", i)
+		v.oneOf(prefix+"ENGINE_NAME", engine.EngineName, "ASR", "TTS")
+		if !engine.PoolEnabled {
+			continue
+		}
+		v.port(prefix+"GATEWAY_PROXY_PORT", engine.GatewayProxyPort)
+		checkPort(prefix+"GATEWAY_PROXY_PORT", engine.GatewayProxyPort)
+		if engine.GrpcWebPort != "" {
+			v.port(prefix+"GRPC_WEB_PORT", engine.GrpcWebPort)
+			checkPort(prefix+"GRPC_WEB_PORT", engine.GrpcWebPort)
+		}
+		if engine.PoolModel != 0 && engine.PoolModel != 1 {
+			v.addf(prefix+"POOL_MODEL", "invalid value %d, expected 0 or 1", engine.PoolModel)
+		}
+		v.positive(prefix+"REQUEST_IDLE_TIME", engine.RequestIdleTime)
+		v.positive(prefix+"REQUEST_MAX_LIFE", engine.RequestMaxLife)
+		v.positive(prefix+"REQUEST_TIMEOUT", engine.RequestTimeout)
+		v.port(prefix+"ENGINE_SERVER_PORT", engine.EngineServerPort)
+		if engine.TLS.Enabled {
+			if engine.TLS.CAFile != "" {
+				v.fileExists(prefix+"tls.CA_FILE", engine.TLS.CAFile)
+			}
+			if engine.TLS.CertFile != "" || engine.TLS.KeyFile != "" {
+				v.fileExists(prefix+"tls.CERT_FILE", engine.TLS.CertFile)
+				v.fileExists(prefix+"tls.KEY_FILE", engine.TLS.KeyFile)
+			}
+		}
+		// 开启 OMP 时不读取 ENGINE_LIST
+		if setting.OMPEnabled {
+			continue
+		}
+		discovery := engine.Discovery
+		v.oneOf(prefix+"discovery.TYPE", discovery.Type, "static", "dns", "file", "http", "docker")
+		if !strings.EqualFold(discovery.Type, "static") {
+			// 多租户连接分配依赖启动时的连接池
+			if setting.TenantEnabled {
+				v.addf(prefix+"discovery.TYPE", "must be static when TENANT_ENABLED is true")
+			}
+			v.positive(prefix+"discovery.POOL_SIZE", discovery.PoolSize)
+			v.positive(prefix+"discovery.REFRESH_INTERVAL", discovery.RefreshInterval)
+			switch strings.ToLower(discovery.Type) {
+			case "dns":
+				v.required(prefix+"discovery.DNS_NAME", discovery.DNSName)
+				v.oneOf(prefix+"discovery.DNS_TYPE", discovery.DNSType, "A", "SRV")
+			case "file":
+				v.required(prefix+"discovery.FILE", discovery.File)
+			case "http":
+				if v.required(prefix+"discovery.URL", discovery.URL) {
+					if u, err := url.Parse(discovery.URL); err != nil || u.Host == "" {
+						v.addf(prefix+"discovery.URL", "invalid url %q", discovery.URL)
+					} else {
+						v.oneOf(prefix+"discovery.URL", u.Scheme, "http", "https")
+					}
+				}
+			}
+			continue
+		}
+		if len(engine.EngineList) == 0 {
+			v.addf(prefix+"ENGINE_LIST", "is required when OMP_ENABLED is false")
+		}
+		for j, server := range engine.EngineList {
+			serverPrefix := fmt.Sprintf("%sENGINE_LIST[%d].", prefix, j)
+			v.required(serverPrefix+"SERVER_HOST", server.ServerHost)
+			v.positive(serverPrefix+"ENGINE_GRPC_POOL_SIZE", server.EngineGrpcPoolSize)
+		}
+	}
+	return v.errs
+}
+
+// validate TenantConfig.yaml tenants, also tenants of db inventory
+func ValidateTenants(tenants []TenantConfig) Errors {
+	v := &validator{file: TENANT_CONFIG}
+	sceneCodes := make(map[string]string)
+	identities := make(map[string]string)
+	for i, tenant := range tenants {
+		prefix := fmt.Sprintf("tenants[%d].", i)
+		v.required(prefix+"TENANT_NAME", tenant.TenantName)
+		v.oneOf(prefix+"ENGINE_NAME", tenant.EngineName, "ASR", "TTS")
+		v.positive(prefix+"ENGINE_POOL_SIZE", tenant.EnginePoolSize)
+		if v.required(prefix+"SCENE_CODE", tenant.SceneCode) {
+			key := strings.ToLower(tenant.EngineName) + "/" + tenant.SceneCode
+			if other, ok := sceneCodes[key]; ok {
+				v.addf(prefix+"SCENE_CODE", "duplicate scene code %s, already used by %s", tenant.SceneCode, other)
+			} else {
+				sceneCodes[key] = prefix + "SCENE_CODE"
+			}
+		}
+		// 同一引擎的证书身份只能对应一个租户
+		for j, identity := range tenant.ClientIdentities {
+			identityKey := fmt.Sprintf("%sCLIENT_IDENTITIES[%d]", prefix, j)
+			if !v.required(identityKey, identity) {
+				continue
+			}
+			key := strings.ToLower(tenant.EngineName) + "/" + identity
+			if other, ok := identities[key]; ok {
+				v.addf(identityKey, "duplicate client identity %s, already used by %s", identity, other)
+			} else {
+				identities[key] = identityKey
+			}
+		}
+	}
+	return v.errs
+}
+
+// validate TenantConfig.yaml token signing keys
+func validateTokens(tokens *TokenConfig, required bool) Errors {
+	v := &validator{file: TENANT_CONFIG}
+	if len(tokens.Keys) == 0 {
+		if required {
+			v.addf("tokens.keys", "is required when OMP_ENABLED or TENANT_ENABLED is true")
+		}
+		return v.errs
+	}
+	v.positive("tokens.TTL", tokens.TTL)
+	v.positive("tokens.MAX_TTL", tokens.MaxTTL)
+	if tokens.TTL > tokens.MaxTTL {
+		v.addf("tokens.TTL", "must not be greater than MAX_TTL %d, got %d", tokens.MaxTTL, tokens.TTL)
+	}
+	v.nonNegative("tokens.REVOCATION_CACHE_TTL", tokens.RevocationCacheTTL)
+	v.oneOf("tokens.REVOCATION_FAIL_POLICY", tokens.RevocationFailPolicy, "open", "closed")
+	kids := make(map[string]string)
+	for i, key := range tokens.Keys {
+		prefix := fmt.Sprintf("tokens.keys[%d].", i)
+		if v.required(prefix+"KID", key.Kid) {
+			if other, ok := kids[key.Kid]; ok {
+				v.addf(prefix+"KID", "duplicate kid %s, already used by %s", key.Kid, other)
+			} else {
+				kids[key.Kid] = prefix + "KID"
+			}
+		}
+		v.required(prefix+"SECRET", key.Secret)
+	}
+	if v.required("tokens.ACTIVE_KID", tokens.ActiveKid) {
+		if _, ok := tokens.Key(tokens.ActiveKid); !ok {
+			v.addf("tokens.ACTIVE_KID", "kid %s not found in tokens.keys", tokens.ActiveKid)
+		}
+	}
+	return v.errs
+}
+
+// validate TenantConfig.yaml secrets in release mode
+func validateTenantSecrets(tenants []TenantConfig, tokens *TokenConfig) Errors {
+	v := &validator{file: TENANT_CONFIG}
+	for i, tenant := range tenants {
+		if tenant.Token != "" {
+			v.secret(fmt.Sprintf("tenants[%d].TOKEN", i), tenant.Token, MIN_TOKEN_LENGTH)
+		}
+	}
+	for i, key := range tokens.Keys {
+		v.secret(fmt.Sprintf("tokens.keys[%d].SECRET", i), key.Secret, MIN_SIGNING_KEY_LENGTH)
+	}
+	return v.errs
+}
+
+// validate routes of db inventory
+func ValidateRoutes(routes []Route) Errors {
+	return validateRoute(&RouteConfig{Route: routes})
+}
+
+// validate RouteConfig.yaml
+func validateRoute(route *RouteConfig) Errors {
+	v := &validator{file: ROUTE_CONFIG}
+	paths := make(map[string]string)
+	for i, r := range route.Route {
+		prefix := fmt.Sprintf("route[%d].", i)
+		if v.required(prefix+"path", r.Path) {
+			if !strings.HasPrefix(r.Path, "/") {
+				v.addf(prefix+"path", "must begin with '/', got %q", r.Path)
+			}
+			if other, ok := paths[r.Path]; ok {
+				v.addf(prefix+"path", "duplicate path %s, already defined by %s", r.Path, other)
+			} else {
+				paths[r.Path] = prefix + "path"
+			}
+		}
+		schemes := []string{"http", "https"}
+		if r.WebSocket {
+			schemes = []string{"ws", "wss", "http", "https"}
+			if r.Method != "" {
+				v.oneOf(prefix+"method", r.Method, "get")
+			}
+		} else {
+			v.oneOf(prefix+"method", r.Method, "get", "post")
+		}
+		if v.required(prefix+"to", r.To) {
+			if u, err := url.Parse(r.To); err != nil || u.Host == "" {
+				v.addf(prefix+"to", "invalid url %q", r.To)
+			} else {
+				v.oneOf(prefix+"to", u.Scheme, schemes...)
+			}
+		}
+		v.nonNegative(prefix+"cache_time", r.CacheTime)
+		v.nonNegative(prefix+"idle_timeout", r.IdleTimeout)
+	}
+
+	// transcode
+	transcode := route.Transcode
+	if !transcode.Enabled {
+		return v.errs
+	}
+	v.oneOf("transcode.STREAM_FORMAT", transcode.StreamFormat, "ndjson", "sse")
+	v.positive("transcode.REQUEST_TIMEOUT", transcode.RequestTimeout)
+	for i, svc := range transcode.Services {
+		prefix := fmt.Sprintf("transcode.services[%d].", i)
+		v.oneOf(prefix+"ENGINE_NAME", svc.EngineName, "ASR", "TTS")
+		for j, rule := range svc.Rules {
+			rulePrefix := fmt.Sprintf("%srules[%d].", prefix, j)
+			v.required(rulePrefix+"selector", rule.Selector)
+			if method, path := rule.Pattern(); method == "" {
+				v.addf(rulePrefix+"get", "one of get, put, post, delete, patch is required")
+			} else if !strings.HasPrefix(path, "/") {
+				v.addf(rulePrefix+strings.ToLower(method), "must begin with '/', got %q", path)
+			}
+		}
+	}
+	return v.errs
+}
+
+// validate VSConfig.yaml
+func validateVS(vs *VSConfig) Errors {
+	v := &validator{file: VS_CONFIG}
+	if vs.Kvs.Enabled {
+		v.required("vs.kvs.NAMESPACE", vs.Kvs.Namespace)
+		v.required("vs.kvs.DEPLOY_FILE_PATH", vs.Kvs.DeployFilePath)
+		v.quantity("vs.kvs.MIN_CPU", vs.Kvs.MinCpu)
+		v.quantity("vs.kvs.MIN_MEMORY", vs.Kvs.MinMemory)
+	}
+	if vs.Dvs.Enabled {
+		if v.required("vs.dvs.HOST", vs.Dvs.Host) && strings.Contains(vs.Dvs.Host, "://") {
+			if u, err := url.Parse(vs.Dvs.Host); err != nil {
+				v.addf("vs.dvs.HOST", "invalid host %q", vs.Dvs.Host)
+			} else {
+				v.oneOf("vs.dvs.HOST", u.Scheme, "unix", "tcp", "http", "https")
+			}
+		}
+		v.required("vs.dvs.API_VERSION", vs.Dvs.APIVersion)
+		v.required("vs.dvs.TEMPLATE_PATH", vs.Dvs.TemplatePath)
+		v.oneOf("vs.dvs.ADDRESS_MODE", vs.Dvs.AddressMode, "container", "host")
+	}
+	return v.errs
+}
@@ -0,0 +1,381 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// keys of field errors, sorted
+func errorKeys(t *testing.T, file string, errs Errors) []string {
+	t.Helper()
+	keys := []string{}
+	for _, err := range errs {
+		fieldErr, ok := err.(*FieldError)
+		if !ok {
+			t.Fatalf("error %v is not a field error", err)
+		}
+		if fieldErr.File != file {
+			t.Errorf("error %v of file %s, want %s", err, fieldErr.File, file)
+		}
+		keys = append(keys, fieldErr.Key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func validPool() *PoolConfig {
+	return &PoolConfig{
+		Setting: PoolSetting{
+			Enabled:          true,
+			DialTimeout:      5,
+			BackoffMaxDelay:  3,
+			KeepaliveTime:    10,
+			KeepaliveTimeout: 3,
+			NetworkMode:      1,
+			GatewayProxyAddr: "127.0.0.1",
+		},
+		Engine: []PoolEngine{{
+			EngineName:       "ASR",
+			PoolEnabled:      true,
+			GatewayProxyPort: "8080",
+			RequestIdleTime:  60,
+			RequestMaxLife:   600,
+			RequestTimeout:   30,
+			EngineServerPort: "9000",
+			Discovery:        EngineDiscovery{Type: "static", PoolSize: 1, RefreshInterval: 30},
+			EngineList:       []EngineServer{{ServerHost: "10.0.0.1", EngineGrpcPoolSize: 2}},
+		}},
+	}
+}
+
+func TestValidatePool(t *testing.T) {
+	dir, err := ioutil.TempDir("", "validate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	certFile := filepath.Join(dir, "tls.crt")
+	if err := ioutil.WriteFile(certFile, []byte("cert"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	missing := filepath.Join(dir, "missing.pem")
+
+	cases := []struct {
+		name   string
+		modify func(pool *PoolConfig)
+		want   []string
+	}{
+		{"valid", func(pool *PoolConfig) {}, []string{}},
+		{"disabled", func(pool *PoolConfig) {
+			pool.Setting = PoolSetting{}
+			pool.Engine[0] = PoolEngine{EngineName: "NLP"}
+		}, []string{}},
+		{"cluster membership", func(pool *PoolConfig) {
+			pool.Setting.ClusterEnabled = true
+			pool.Setting.ClusterMembership = "etcd"
+			pool.Setting.ClusterHeartbeat = 3
+		}, []string{"pool.setting.CLUSTER_MEMBERSHIP"}},
+		{"static cluster node num", func(pool *PoolConfig) {
+			pool.Setting.ClusterEnabled = true
+			pool.Setting.ClusterMembership = "static"
+		}, []string{"pool.setting.CLUSTER_NODE_NUM"}},
+		{"lease cluster heartbeat", func(pool *PoolConfig) {
+			pool.Setting.ClusterEnabled = true
+			pool.Setting.ClusterMembership = "lease"
+		}, []string{"pool.setting.CLUSTER_HEARTBEAT"}},
+		{"timeouts", func(pool *PoolConfig) {
+			pool.Setting.DialTimeout = 0
+			pool.Setting.BackoffMaxDelay = -1
+			pool.Setting.KeepaliveTime = 0
+			pool.Setting.KeepaliveTimeout = 0
+		}, []string{
+			"pool.setting.BACKOFF_MAX_DELAY", "pool.setting.DIAL_TIMEOUT",
+			"pool.setting.KEEPALIVE_TIME", "pool.setting.KEEPALIVE_TIMEOUT",
+		}},
+		{"ready min healthy pools", func(pool *PoolConfig) {
+			pool.Setting.ReadyMinHealthyPools = -1
+		}, []string{"pool.setting.READY_MIN_HEALTHY_POOLS"}},
+		{"network mode", func(pool *PoolConfig) {
+			pool.Setting.NetworkMode = 3
+		}, []string{"pool.setting.NETWORK_MODE"}},
+		{"gateway proxy addr", func(pool *PoolConfig) {
+			pool.Setting.GatewayProxyAddr = " "
+		}, []string{"pool.setting.GATEWAY_PROXY_ADDR"}},
+		{"omp selectors", func(pool *PoolConfig) {
+			pool.Setting.OMPEnabled = true
+			// 开启 OMP 时不校验 ENGINE_LIST
+			pool.Engine[0].EngineList = nil
+		}, []string{
+			"pool.setting.ASR_ENGINE_SERVICE_SELECTOR_VALUE", "pool.setting.ENGINE_SERVICE_SELECTOR_KEY",
+			"pool.setting.TTS_ENGINE_SERVICE_SELECTOR_VALUE",
+		}},
+		{"tls missing files", func(pool *PoolConfig) {
+			pool.Setting.TLS = ServerTLS{Enabled: true, KeyFile: missing, ClientAuth: "optional"}
+		}, []string{
+			"pool.setting.tls.CERT_FILE", "pool.setting.tls.CLIENT_AUTH",
+			"pool.setting.tls.CLIENT_CA_FILE", "pool.setting.tls.KEY_FILE",
+		}},
+		{"tls directory", func(pool *PoolConfig) {
+			pool.Setting.TLS = ServerTLS{Enabled: true, CertFile: dir, KeyFile: certFile, ClientAuth: "none"}
+		}, []string{"pool.setting.tls.CERT_FILE"}},
+		{"tls client ca", func(pool *PoolConfig) {
+			pool.Setting.TLS = ServerTLS{Enabled: true, CertFile: certFile, KeyFile: certFile, ClientAuth: "require"}
+		}, []string{"pool.setting.tls.CLIENT_CA_FILE"}},
+		{"limiter", func(pool *PoolConfig) {
+			pool.Setting.Limiter = Limiter{Enabled: true, FailPolicy: "retry"}
+		}, []string{"pool.setting.limiter.FAIL_POLICY", "pool.setting.limiter.LEASE"}},
+		{"engine name", func(pool *PoolConfig) {
+			pool.Engine[0].EngineName = "NLP"
+		}, []string{"pool.engine[0].ENGINE_NAME"}},
+		{"engine pool disabled", func(pool *PoolConfig) {
+			pool.Engine[0] = PoolEngine{EngineName: "TTS"}
+		}, []string{}},
+		{"invalid ports", func(pool *PoolConfig) {
+			pool.Engine[0].GatewayProxyPort = "abc"
+			pool.Engine[0].GrpcWebPort = "0"
+			pool.Engine[0].EngineServerPort = "70000"
+		}, []string{
+			"pool.engine[0].ENGINE_SERVER_PORT", "pool.engine[0].GATEWAY_PROXY_PORT", "pool.engine[0].GRPC_WEB_PORT",
+		}},
+		{"duplicate ports", func(pool *PoolConfig) {
+			pool.Engine[0].GrpcWebPort = "8080"
+			tts := pool.Engine[0]
+			tts.EngineName = "TTS"
+			tts.GrpcWebPort = ""
+			pool.Engine = append(pool.Engine, tts)
+		}, []string{"pool.engine[0].GRPC_WEB_PORT", "pool.engine[1].GATEWAY_PROXY_PORT"}},
+		{"pool model", func(pool *PoolConfig) {
+			pool.Engine[0].PoolModel = 2
+		}, []string{"pool.engine[0].POOL_MODEL"}},
+		{"request times", func(pool *PoolConfig) {
+			pool.Engine[0].RequestIdleTime = 0
+			pool.Engine[0].RequestMaxLife = 0
+			pool.Engine[0].RequestTimeout = -1
+		}, []string{
+			"pool.engine[0].REQUEST_IDLE_TIME", "pool.engine[0].REQUEST_MAX_LIFE", "pool.engine[0].REQUEST_TIMEOUT",
+		}},
+		{"engine tls", func(pool *PoolConfig) {
+			pool.Engine[0].TLS = EngineTLS{Enabled: true, CAFile: missing, CertFile: certFile}
+		}, []string{"pool.engine[0].tls.CA_FILE", "pool.engine[0].tls.KEY_FILE"}},
+		{"engine tls system ca", func(pool *PoolConfig) {
+			pool.Engine[0].TLS = EngineTLS{Enabled: true}
+		}, []string{}},
+		{"discovery type", func(pool *PoolConfig) {
+			pool.Engine[0].Discovery.Type = "consul"
+		}, []string{"pool.engine[0].discovery.TYPE"}},
+		{"tenant requires static discovery", func(pool *PoolConfig) {
+			pool.Setting.TenantEnabled = true
+			pool.Engine[0].Discovery.Type = "file"
+			pool.Engine[0].Discovery.File = "endpoints.yaml"
+		}, []string{"pool.engine[0].discovery.TYPE"}},
+		{"discovery sizes", func(pool *PoolConfig) {
+			pool.Engine[0].Discovery = EngineDiscovery{Type: "file", File: "endpoints.yaml"}
+		}, []string{"pool.engine[0].discovery.POOL_SIZE", "pool.engine[0].discovery.REFRESH_INTERVAL"}},
+		{"dns discovery", func(pool *PoolConfig) {
+			pool.Engine[0].Discovery.Type = "dns"
+			pool.Engine[0].Discovery.DNSType = "MX"
+		}, []string{"pool.engine[0].discovery.DNS_NAME", "pool.engine[0].discovery.DNS_TYPE"}},
+		{"file discovery", func(pool *PoolConfig) {
+			pool.Engine[0].Discovery.Type = "file"
+		}, []string{"pool.engine[0].discovery.FILE"}},
+		{"http discovery url required", func(pool *PoolConfig) {
+			pool.Engine[0].Discovery.Type = "http"
+		}, []string{"pool.engine[0].discovery.URL"}},
+		{"http discovery url host", func(pool *PoolConfig) {
+			pool.Engine[0].Discovery.Type = "http"
+			pool.Engine[0].Discovery.URL = "consul/v1/health"
+		}, []string{"pool.engine[0].discovery.URL"}},
+		{"http discovery url scheme", func(pool *PoolConfig) {
+			pool.Engine[0].Discovery.Type = "http"
+			pool.Engine[0].Discovery.URL = "ftp://consul/v1/health"
+		}, []string{"pool.engine[0].discovery.URL"}},
+		{"engine list required", func(pool *PoolConfig) {
+			pool.Engine[0].EngineList = nil
+		}, []string{"pool.engine[0].ENGINE_LIST"}},
+		{"engine list server", func(pool *PoolConfig) {
+			pool.Engine[0].EngineList = append(pool.Engine[0].EngineList, EngineServer{})
+		}, []string{"pool.engine[0].ENGINE_LIST[1].ENGINE_GRPC_POOL_SIZE", "pool.engine[0].ENGINE_LIST[1].SERVER_HOST"}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			pool := validPool()
+			tc.modify(pool)
+			if got := errorKeys(t, POOL_CONFIG, validatePool(pool)); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("validatePool keys = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestValidateRoute(t *testing.T) {
+	rule := TranscodeRule{Selector: "asr.Recognizer.Recognize", Post: "/v1/recognize"}
+	cases := []struct {
+		name   string
+		modify func(route *RouteConfig)
+		want   []string
+	}{
+		{"valid", func(route *RouteConfig) {}, []string{}},
+		{"path required", func(route *RouteConfig) {
+			route.Route[0].Path = ""
+		}, []string{"route[0].path"}},
+		{"path prefix", func(route *RouteConfig) {
+			route.Route[0].Path = "asr"
+		}, []string{"route[0].path"}},
+		{"duplicate path", func(route *RouteConfig) {
+			route.Route = append(route.Route, route.Route[0])
+		}, []string{"route[1].path"}},
+		{"method", func(route *RouteConfig) {
+			route.Route[0].Method = "put"
+		}, []string{"route[0].method"}},
+		{"websocket method", func(route *RouteConfig) {
+			route.Route[0].WebSocket = true
+		}, []string{"route[0].method"}},
+		{"websocket", func(route *RouteConfig) {
+			route.Route[0].WebSocket = true
+			route.Route[0].Method = ""
+			route.Route[0].To = "ws://engine:8080/stream"
+		}, []string{}},
+		{"websocket scheme", func(route *RouteConfig) {
+			route.Route[0].To = "wss://engine:8080/stream"
+		}, []string{"route[0].to"}},
+		{"to required", func(route *RouteConfig) {
+			route.Route[0].To = ""
+		}, []string{"route[0].to"}},
+		{"to host", func(route *RouteConfig) {
+			route.Route[0].To = "engine:8080"
+		}, []string{"route[0].to"}},
+		{"negative times", func(route *RouteConfig) {
+			route.Route[0].CacheTime = -1
+			route.Route[0].IdleTimeout = -1
+		}, []string{"route[0].cache_time", "route[0].idle_timeout"}},
+		{"transcode disabled", func(route *RouteConfig) {
+			route.Transcode = Transcode{Services: []TranscodeService{{EngineName: "NLP"}}}
+		}, []string{}},
+		{"transcode setting", func(route *RouteConfig) {
+			route.Transcode.StreamFormat = "xml"
+			route.Transcode.RequestTimeout = 0
+		}, []string{"transcode.REQUEST_TIMEOUT", "transcode.STREAM_FORMAT"}},
+		{"transcode engine name", func(route *RouteConfig) {
+			route.Transcode.Services[0].EngineName = "NLP"
+		}, []string{"transcode.services[0].ENGINE_NAME"}},
+		{"transcode rule selector", func(route *RouteConfig) {
+			route.Transcode.Services[0].Rules[0].Selector = ""
+		}, []string{"transcode.services[0].rules[0].selector"}},
+		{"transcode rule method", func(route *RouteConfig) {
+			route.Transcode.Services[0].Rules = []TranscodeRule{{Selector: rule.Selector}}
+		}, []string{"transcode.services[0].rules[0].get"}},
+		{"transcode rule path", func(route *RouteConfig) {
+			route.Transcode.Services[0].Rules[0].Post = "v1/recognize"
+		}, []string{"transcode.services[0].rules[0].post"}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			route := &RouteConfig{
+				Route: []Route{{Path: "/asr", Method: "post", To: "http://engine:8080/asr"}},
+				Transcode: Transcode{
+					Enabled:        true,
+					StreamFormat:   "ndjson",
+					RequestTimeout: 30,
+					Services:       []TranscodeService{{EngineName: "ASR", Rules: []TranscodeRule{rule}}},
+				},
+			}
+			tc.modify(route)
+			if got := errorKeys(t, ROUTE_CONFIG, validateRoute(route)); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("validateRoute keys = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestValidateTenants(t *testing.T) {
+	tenant := TenantConfig{TenantName: "t1", EngineName: "ASR", EnginePoolSize: 2, SceneCode: "s1", ClientIdentities: []string{"client-a"}}
+	cases := []struct {
+		name   string
+		modify func(tenants []TenantConfig) []TenantConfig
+		want   []string
+	}{
+		{"valid", func(tenants []TenantConfig) []TenantConfig { return tenants }, []string{}},
+		{"required", func(tenants []TenantConfig) []TenantConfig {
+			return []TenantConfig{{EngineName: "NLP", ClientIdentities: []string{" "}}}
+		}, []string{
+			"tenants[0].CLIENT_IDENTITIES[0]", "tenants[0].ENGINE_NAME", "tenants[0].ENGINE_POOL_SIZE",
+			"tenants[0].SCENE_CODE", "tenants[0].TENANT_NAME",
+		}},
+		{"duplicate scene code and identity", func(tenants []TenantConfig) []TenantConfig {
+			other := tenant
+			other.TenantName = "t2"
+			return append(tenants, other)
+		}, []string{"tenants[1].CLIENT_IDENTITIES[0]", "tenants[1].SCENE_CODE"}},
+		{"same scene code and identity of other engine", func(tenants []TenantConfig) []TenantConfig {
+			other := tenant
+			other.TenantName = "t2"
+			other.EngineName = "tts"
+			return append(tenants, other)
+		}, []string{}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			tenants := tc.modify([]TenantConfig{tenant})
+			if got := errorKeys(t, TENANT_CONFIG, ValidateTenants(tenants)); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("ValidateTenants keys = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestValidateTokens(t *testing.T) {
+	cases := []struct {
+		name     string
+		modify   func(tokens *TokenConfig)
+		required bool
+		want     []string
+	}{
+		{"valid", func(tokens *TokenConfig) {}, true, []string{}},
+		{"no keys", func(tokens *TokenConfig) { *tokens = TokenConfig{} }, false, []string{}},
+		{"keys required", func(tokens *TokenConfig) { *tokens = TokenConfig{} }, true, []string{"tokens.keys"}},
+		{"ttl", func(tokens *TokenConfig) {
+			tokens.TTL = 0
+		}, false, []string{"tokens.TTL"}},
+		{"max ttl", func(tokens *TokenConfig) {
+			tokens.MaxTTL = 0
+		}, false, []string{"tokens.MAX_TTL", "tokens.TTL"}},
+		{"ttl greater than max ttl", func(tokens *TokenConfig) {
+			tokens.TTL = tokens.MaxTTL + 1
+		}, false, []string{"tokens.TTL"}},
+		{"revocation", func(tokens *TokenConfig) {
+			tokens.RevocationCacheTTL = -1
+			tokens.RevocationFailPolicy = "retry"
+		}, false, []string{"tokens.REVOCATION_CACHE_TTL", "tokens.REVOCATION_FAIL_POLICY"}},
+		{"key required", func(tokens *TokenConfig) {
+			tokens.Keys = append(tokens.Keys, TokenKey{})
+		}, false, []string{"tokens.keys[1].KID", "tokens.keys[1].SECRET"}},
+		{"duplicate kid", func(tokens *TokenConfig) {
+			tokens.Keys = append(tokens.Keys, tokens.Keys[0])
+		}, false, []string{"tokens.keys[1].KID"}},
+		{"active kid required", func(tokens *TokenConfig) {
+			tokens.ActiveKid = ""
+		}, false, []string{"tokens.ACTIVE_KID"}},
+		{"active kid not found", func(tokens *TokenConfig) {
+			tokens.ActiveKid = "k2"
+		}, false, []string{"tokens.ACTIVE_KID"}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			tokens := &TokenConfig{
+				ActiveKid:            "k1",
+				TTL:                  3600,
+				MaxTTL:               86400,
+				Keys:                 []TokenKey{{Kid: "k1", Secret: "signing-key"}},
+				RevocationFailPolicy: "open",
+			}
+			tc.modify(tokens)
+			if got := errorKeys(t, TENANT_CONFIG, validateTokens(tokens, tc.required)); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("validateTokens keys = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
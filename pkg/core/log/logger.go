@@ -3,14 +3,12 @@ package log
 import (
 	"io"
 	"os"
-	"strconv"
 	"strings"
 	"time"
 
-	"rpc-gateway/pkg/plugins/httpserver/util"
+	"rpc-gateway/pkg/core/config"
 
 	rotatelogs "github.com/lestrrat-go/file-rotatelogs"
-	"github.com/spf13/viper"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 )
@@ -19,11 +17,24 @@ const (
 	DAY_ROTATION = 1140
 )
 
-var Log *zap.SugaredLogger
+// stdout logger until Init is called
+var Log = zap.New(zapcore.NewCore(newEncoder(), zapcore.AddSync(os.Stdout), zapcore.InfoLevel), zap.AddCaller()).Sugar()
 
-func init() {
-	util.InitConfig()
-	encoder := zapcore.NewConsoleEncoder(zapcore.EncoderConfig{
+// audit logger, json lines to stdout until Init is called
+var Audit = zap.New(zapcore.NewCore(newAuditEncoder(), zapcore.AddSync(os.Stdout), zapcore.InfoLevel)).Sugar()
+
+// audit log encoder
+func newAuditEncoder() zapcore.Encoder {
+	return zapcore.NewJSONEncoder(zapcore.EncoderConfig{
+		MessageKey: "msg",
+		TimeKey:    "ts",
+		EncodeTime: zapcore.ISO8601TimeEncoder,
+	})
+}
+
+// log encoder
+func newEncoder() zapcore.Encoder {
+	return zapcore.NewConsoleEncoder(zapcore.EncoderConfig{
 		MessageKey:  "msg",
 		LevelKey:    "level",
 		EncodeLevel: zapcore.CapitalLevelEncoder,
@@ -39,12 +50,15 @@ func init() {
 			enc.AppendInt64(int64(d) / 1000000)
 		},
 	})
+}
+
+// init logger from config, called on startup
+func Init() {
+	app := config.Get().App
+	encoder := newEncoder()
 
 	// get info、error logger's io.Writer
-	logLevel := os.Getenv("LOG_LEVEL")
-	if logLevel == "" {
-		logLevel = viper.GetString("LOG_LEVEL")
-	}
+	logLevel := strings.ToLower(app.LogLevel)
 	var levelEnabler zapcore.LevelEnabler
 	var logWriter io.Writer
 
@@ -53,17 +67,17 @@ func init() {
 		levelEnabler = zap.LevelEnablerFunc(func(lvl zapcore.Level) bool {
 			return lvl >= zapcore.InfoLevel
 		})
-		logWriter = getWriter("./logs/log_info.log")
+		logWriter = getWriter("./logs/log_info.log", app)
 	case "debug":
 		levelEnabler = zap.LevelEnablerFunc(func(lvl zapcore.Level) bool {
 			return lvl >= zapcore.DebugLevel
 		})
-		logWriter = getWriter("./logs/log_debug.log")
+		logWriter = getWriter("./logs/log_debug.log", app)
 	case "error":
 		levelEnabler = zap.LevelEnablerFunc(func(lvl zapcore.Level) bool {
 			return lvl >= zapcore.ErrorLevel
 		})
-		logWriter = getWriter("./logs/log_error.log")
+		logWriter = getWriter("./logs/log_error.log", app)
 	}
 
 	// create Logger
@@ -81,25 +95,13 @@ func init() {
 	// set init fields
 	// field := zap.Fields(zap.String("appName", "go-skeleton"))
 	Log = zap.New(core, caller, development).Sugar()
+	// audit log
+	Audit = zap.New(zapcore.NewCore(newAuditEncoder(), zapcore.AddSync(getWriter("./logs/audit.log", app)), zapcore.InfoLevel)).Sugar()
 }
 
-func getWriter(filename string) io.Writer {
-	var logMaxAgeNum, logRotationTimeNum time.Duration
-	logMaxAge := os.Getenv("LOG_MAX_AGE")
-	if logMaxAge == "" {
-		logMaxAgeNum = (time.Duration)(viper.GetInt64("LOG_MAX_AGE"))
-	} else {
-		logMaxAgeInt64, _ := strconv.ParseInt(logMaxAge, 10, 64)
-		logMaxAgeNum = (time.Duration)(logMaxAgeInt64)
-	}
-
-	logRotationTime := os.Getenv("LOG_ROTATION_TIME")
-	if logRotationTime == "" {
-		logRotationTimeNum = (time.Duration)(viper.GetInt64("LOG_ROTATION_TIME"))
-	} else {
-		logRotationTimeInt64, _ := strconv.ParseInt(logRotationTime, 10, 64)
-		logRotationTimeNum = (time.Duration)(logRotationTimeInt64)
-	}
+func getWriter(filename string, app config.AppConfig) io.Writer {
+	logMaxAgeNum := time.Duration(app.LogMaxAge)
+	logRotationTimeNum := time.Duration(app.LogRotationTime)
 
 	// set log format
 	logFormartStr := "%Y%m%d%H%M"
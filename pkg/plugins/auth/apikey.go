@@ -0,0 +1,92 @@
+package auth
+
+import (
+	"crypto/subtle"
+	"errors"
+	"rpc-gateway/pkg/core/config"
+)
+
+const (
+	// management api roles, token scopes with the same name grant the role
+	ROLE_VIEWER   = "viewer"   // 查询
+	ROLE_OPERATOR = "operator" // 引擎和缓存管理
+	ROLE_ADMIN    = "admin"    // 签发和吊销 token
+	// api key header
+	API_KEY_HEADER = "X-API-Key"
+	// auth methods
+	AUTH_METHOD_API_KEY = "api_key"
+	AUTH_METHOD_TOKEN   = "token"
+)
+
+// role levels, higher role includes lower ones
+var roleLevels = map[string]int{
+	ROLE_VIEWER:   1,
+	ROLE_OPERATOR: 2,
+	ROLE_ADMIN:    3,
+}
+
+var (
+	ErrUnauthenticated = errors.New("api key or token is required")
+	ErrInvalidAPIKey   = errors.New("invalid api key")
+)
+
+// authenticated caller of management api
+type Principal struct {
+	Name   string `json:"name"`
+	Role   string `json:"role"`
+	Method string `json:"method"`
+}
+
+// role allowed
+func (principal *Principal) Allows(role string) bool {
+	level, ok := roleLevels[role]
+	return ok && roleLevels[principal.Role] >= level
+}
+
+// authenticate by api key header, or bearer api key or token
+func Authenticate(apiKey, authorization string) (*Principal, error) {
+	if apiKey != "" {
+		principal, ok := lookupAPIKey(apiKey)
+		if !ok {
+			return nil, ErrInvalidAPIKey
+		}
+		return principal, nil
+	}
+	bearer := BearerToken(authorization)
+	if bearer == "" {
+		return nil, ErrUnauthenticated
+	}
+	if principal, ok := lookupAPIKey(bearer); ok {
+		return principal, nil
+	}
+	claims, err := Verify(bearer)
+	if err != nil {
+		return nil, err
+	}
+	return &Principal{Name: "token:" + claims.Id, Role: claimsRole(claims), Method: AUTH_METHOD_TOKEN}, nil
+}
+
+// api key by value, compared in constant time
+func lookupAPIKey(key string) (*Principal, bool) {
+	var found *Principal
+	for _, apiKey := range config.Get().App.APIKeys {
+		if apiKey.Key == "" {
+			continue
+		}
+		if subtle.ConstantTimeCompare([]byte(apiKey.Key), []byte(key)) == 1 && found == nil {
+			found = &Principal{Name: apiKey.Name, Role: apiKey.Role, Method: AUTH_METHOD_API_KEY}
+		}
+	}
+	return found, found != nil
+}
+
+// highest role in token scopes
+func claimsRole(claims *Claims) string {
+	role := ""
+	for _, scope := range claims.Scopes {
+		if roleLevels[scope] > roleLevels[role] {
+			role = scope
+		}
+	}
+	return role
+}
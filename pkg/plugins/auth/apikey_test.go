@@ -0,0 +1,62 @@
+package auth
+
+import "testing"
+
+func TestBearerToken(t *testing.T) {
+	cases := []struct {
+		value string
+		want  string
+	}{
+		{"Bearer abc", "abc"},
+		{"bearer  abc ", "abc"},
+		{"BEARER abc", "abc"},
+		{"abc", "abc"},
+		{" abc ", "abc"},
+		{"Bearer ", "Bearer"},
+		{"", ""},
+	}
+	for _, c := range cases {
+		if got := BearerToken(c.value); got != c.want {
+			t.Errorf("BearerToken(%q) = %q, want %q", c.value, got, c.want)
+		}
+	}
+}
+
+func TestClaimsRole(t *testing.T) {
+	cases := []struct {
+		scopes []string
+		role   string
+	}{
+		{nil, ""},
+		{[]string{SCOPE_INVOKE}, ""},
+		{[]string{ROLE_VIEWER}, ROLE_VIEWER},
+		{[]string{ROLE_ADMIN, ROLE_VIEWER}, ROLE_ADMIN},
+		{[]string{SCOPE_INVOKE, ROLE_OPERATOR, ROLE_VIEWER}, ROLE_OPERATOR},
+	}
+	for _, c := range cases {
+		if got := claimsRole(&Claims{Scopes: c.scopes}); got != c.role {
+			t.Errorf("claimsRole(%v) = %q, want %q", c.scopes, got, c.role)
+		}
+	}
+}
+
+func TestPrincipalAllows(t *testing.T) {
+	cases := []struct {
+		role    string
+		require string
+		allowed bool
+	}{
+		{ROLE_ADMIN, ROLE_VIEWER, true},
+		{ROLE_ADMIN, ROLE_ADMIN, true},
+		{ROLE_OPERATOR, ROLE_VIEWER, true},
+		{ROLE_OPERATOR, ROLE_ADMIN, false},
+		{ROLE_VIEWER, ROLE_OPERATOR, false},
+		{"", ROLE_VIEWER, false},
+		{ROLE_ADMIN, "unknown", false},
+	}
+	for _, c := range cases {
+		if got := (&Principal{Role: c.role}).Allows(c.require); got != c.allowed {
+			t.Errorf("%q allows %q = %v, want %v", c.role, c.require, got, c.allowed)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package auth
+
+import (
+	"rpc-gateway/pkg/core/config"
+	logging "rpc-gateway/pkg/core/log"
+	"strings"
+	"time"
+)
+
+// audit record of a management api call
+type AuditEntry struct {
+	Action    string
+	Principal *Principal // 认证失败时为空
+	Method    string
+	Path      string
+	ClientIP  string
+	Params    map[string][]string
+	Status    int
+	Latency   time.Duration
+	Error     string
+}
+
+// write audit entry to audit log, secret params are redacted
+func WriteAudit(entry AuditEntry) {
+	name, role, method := "", "", ""
+	if entry.Principal != nil {
+		name, role, method = entry.Principal.Name, entry.Principal.Role, entry.Principal.Method
+	}
+	params := make(map[string]string, len(entry.Params))
+	for k, v := range entry.Params {
+		if config.IsSecretKey(k) {
+			params[k] = config.REDACTED
+			continue
+		}
+		params[k] = strings.Join(v, ",")
+	}
+	logging.Audit.Infow("audit",
+		"action", entry.Action,
+		"principal", name,
+		"role", role,
+		"auth_method", method,
+		"method", entry.Method,
+		"path", entry.Path,
+		"client_ip", entry.ClientIP,
+		"params", params,
+		"status", entry.Status,
+		"latency_ms", entry.Latency.Milliseconds(),
+		"error", entry.Error,
+	)
+}
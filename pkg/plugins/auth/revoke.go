@@ -0,0 +1,219 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"rpc-gateway/pkg/core/config"
+	logging "rpc-gateway/pkg/core/log"
+	"rpc-gateway/pkg/plugins/httpserver/db"
+	"rpc-gateway/pkg/plugins/httpserver/model"
+
+	"github.com/go-redis/redis"
+)
+
+// revoked token key namespace
+const REVOKED_KEY_PREFIX = "pigeon:token:revoked:"
+
+const (
+	// tokens.REVOCATION_FAIL_POLICY
+	FAIL_OPEN   = "open"
+	FAIL_CLOSED = "closed"
+)
+
+var ErrRevocationUnavailable = errors.New("token revocation lookup failed")
+
+// revoked token store
+type RevocationStore interface {
+	Revoke(id string, expireAt time.Time) error
+	IsRevoked(id string) (bool, error)
+}
+
+var revocationStore RevocationStore
+var revocationOnce sync.Once
+
+// revocation store, redis when CACHE_ENABLED, then db when DB_ENABLED, so revocations are shared by gateways
+func revocations() RevocationStore {
+	revocationOnce.Do(func() {
+		if db.Cache != nil {
+			revocationStore = &redisRevocationStore{client: db.Cache}
+			logging.Log.Info("token revocation use redis store")
+			return
+		}
+		if db.Enabled() {
+			revocationStore = &dbRevocationStore{}
+			logging.Log.Info("token revocation use db store")
+			return
+		}
+		revocationStore = &memoryRevocationStore{revoked: make(map[string]time.Time)}
+		logging.Log.Info("token revocation use memory store")
+	})
+	return revocationStore
+}
+
+// revoke token, kept until it expires
+func Revoke(claims *Claims) error {
+	return revoke(claims.Id, claims.ExpireTime())
+}
+
+// revoke token by id, kept for tokens.MAX_TTL
+func RevokeId(id string) error {
+	maxTTL := time.Duration(config.Get().Tokens.MaxTTL) * time.Second
+	return revoke(id, time.Now().Add(maxTTL))
+}
+
+// revoke in store, also recorded in db token inventory
+func revoke(id string, expireAt time.Time) error {
+	store := revocations()
+	if err := store.Revoke(id, expireAt); err != nil {
+		return err
+	}
+	// 本节点立即生效, 其他节点最迟 REVOCATION_CACHE_TTL 后生效
+	revokedCache.set(id, true, time.Until(expireAt))
+	if _, ok := store.(*dbRevocationStore); !ok && db.Enabled() {
+		return db.Tokens.Revoke(id, expireAt)
+	}
+	return nil
+}
+
+// revoked of token, cached for REVOCATION_CACHE_TTL
+// 查询失败时 REVOCATION_FAIL_POLICY 为 open 视为未吊销, 为 closed 返回 ErrRevocationUnavailable
+func isRevoked(id string) (bool, error) {
+	if revoked, ok := revokedCache.get(id); ok {
+		return revoked, nil
+	}
+	tokens := config.Get().Tokens
+	revoked, err := revocations().IsRevoked(id)
+	if err != nil {
+		if !strings.EqualFold(tokens.RevocationFailPolicy, FAIL_OPEN) {
+			return false, fmt.Errorf("%w: %v", ErrRevocationUnavailable, err)
+		}
+		logging.Log.Warn("token revocation lookup failed, treat as not revoked: ", err)
+		return false, nil
+	}
+	revokedCache.set(id, revoked, time.Duration(tokens.RevocationCacheTTL)*time.Second)
+	return revoked, nil
+}
+
+// revocation lookup results
+type revocationCache struct {
+	lock    sync.Mutex
+	entries map[string]revocationEntry
+	sweepAt time.Time
+}
+
+type revocationEntry struct {
+	revoked  bool
+	expireAt time.Time
+}
+
+var revokedCache = &revocationCache{entries: make(map[string]revocationEntry)}
+
+func (c *revocationCache) get(id string) (bool, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	entry, ok := c.entries[id]
+	if !ok || !time.Now().Before(entry.expireAt) {
+		return false, false
+	}
+	return entry.revoked, true
+}
+
+func (c *revocationCache) set(id string, revoked bool, ttl time.Duration) {
+	if ttl <= 0 {
+		return
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	// 最多每秒清理一次过期记录
+	now := time.Now()
+	if now.After(c.sweepAt) {
+		for k, entry := range c.entries {
+			if !now.Before(entry.expireAt) {
+				delete(c.entries, k)
+			}
+		}
+		c.sweepAt = now.Add(time.Second)
+	}
+	c.entries[id] = revocationEntry{revoked: revoked, expireAt: now.Add(ttl)}
+}
+
+// redis store
+type redisRevocationStore struct {
+	client redis.Cmdable
+}
+
+func (s *redisRevocationStore) Revoke(id string, expireAt time.Time) error {
+	ttl := time.Until(expireAt)
+	if ttl <= 0 {
+		return nil
+	}
+	return s.client.Set(REVOKED_KEY_PREFIX+id, 1, ttl).Err()
+}
+
+func (s *redisRevocationStore) IsRevoked(id string) (bool, error) {
+	n, err := s.client.Exists(REVOKED_KEY_PREFIX + id).Result()
+	return n > 0, err
+}
+
+// db store, revoked flag of token inventory
+type dbRevocationStore struct{}
+
+func (s *dbRevocationStore) Revoke(id string, expireAt time.Time) error {
+	return db.Tokens.Revoke(id, expireAt)
+}
+
+func (s *dbRevocationStore) IsRevoked(id string) (bool, error) {
+	return db.Tokens.IsRevoked(id)
+}
+
+// memory store
+type memoryRevocationStore struct {
+	lock    sync.RWMutex
+	revoked map[string]time.Time
+}
+
+func (s *memoryRevocationStore) Revoke(id string, expireAt time.Time) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	// 清理已过期的记录
+	now := time.Now()
+	for k, t := range s.revoked {
+		if now.After(t) {
+			delete(s.revoked, k)
+		}
+	}
+	if now.Before(expireAt) {
+		s.revoked[id] = expireAt
+	}
+	return nil
+}
+
+func (s *memoryRevocationStore) IsRevoked(id string) (bool, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	_, ok := s.revoked[id]
+	return ok, nil
+}
+
+// record minted token in db token inventory, no-op when db is not enabled
+func Record(claims *Claims) error {
+	if !db.Enabled() {
+		return nil
+	}
+	return db.Tokens.Save(&model.Token{
+		Jti:       claims.Id,
+		TenantId:  claims.TenantId,
+		Engine:    claims.Engine,
+		SceneCode: claims.SceneCode,
+		Scopes:    strings.Join(claims.Scopes, ","),
+		ExpiresAt: claims.ExpireTime(),
+	})
+}
@@ -0,0 +1,185 @@
+package auth
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"rpc-gateway/pkg/core/config"
+	logging "rpc-gateway/pkg/core/log"
+
+	"go.uber.org/zap"
+)
+
+// revocation store of test, lookups are counted
+type fakeRevocationStore struct {
+	lock    sync.Mutex
+	revoked map[string]bool
+	err     error
+	lookups int
+}
+
+func (s *fakeRevocationStore) Revoke(id string, expireAt time.Time) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.revoked[id] = true
+	return nil
+}
+
+func (s *fakeRevocationStore) IsRevoked(id string) (bool, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.lookups++
+	return s.revoked[id], s.err
+}
+
+func (s *fakeRevocationStore) count() int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.lookups
+}
+
+func (s *fakeRevocationStore) fail(err error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.err = err
+}
+
+// signing key, revocation policy and store of test
+func setupRevocation(t *testing.T, cacheTTL int, failPolicy string) *fakeRevocationStore {
+	t.Helper()
+	logging.Log = zap.NewNop().Sugar()
+	cfg := &config.Configs{}
+	cfg.Tokens = config.TokenConfig{
+		ActiveKid:            "k1",
+		TTL:                  3600,
+		MaxTTL:               86400,
+		Keys:                 []config.TokenKey{{Kid: "k1", Secret: "0123456789abcdef0123456789abcdef"}},
+		RevocationCacheTTL:   cacheTTL,
+		RevocationFailPolicy: failPolicy,
+	}
+	config.Set(cfg)
+
+	store := &fakeRevocationStore{revoked: make(map[string]bool)}
+	revocationOnce.Do(func() {})
+	oldStore := revocationStore
+	revocationStore = store
+	revokedCache = &revocationCache{entries: make(map[string]revocationEntry)}
+	t.Cleanup(func() {
+		revocationStore = oldStore
+		revokedCache = &revocationCache{entries: make(map[string]revocationEntry)}
+	})
+	return store
+}
+
+func mintTest(t *testing.T) (string, *Claims) {
+	t.Helper()
+	token, claims, err := Mint(Claims{TenantId: "t1", Engine: "ASR", Scopes: []string{SCOPE_INVOKE}}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token, claims
+}
+
+func TestVerifyRevocationCached(t *testing.T) {
+	store := setupRevocation(t, 60, FAIL_CLOSED)
+	token, claims := mintTest(t)
+	for i := 0; i < 3; i++ {
+		if _, err := Verify(token); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := store.count(); n != 1 {
+		t.Errorf("store lookups = %d, want 1", n)
+	}
+
+	// 本节点吊销立即生效
+	if err := Revoke(claims); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Verify(token); err != ErrTokenRevoked {
+		t.Errorf("Verify revoked token = %v, want %v", err, ErrTokenRevoked)
+	}
+
+	// 其他节点的吊销在缓存过期后生效
+	other, otherClaims := mintTest(t)
+	if _, err := Verify(other); err != nil {
+		t.Fatal(err)
+	}
+	store.Revoke(otherClaims.Id, otherClaims.ExpireTime())
+	if _, err := Verify(other); err != nil {
+		t.Errorf("Verify within cache ttl = %v, want cached result", err)
+	}
+	revokedCache.lock.Lock()
+	entry := revokedCache.entries[otherClaims.Id]
+	entry.expireAt = time.Now().Add(-time.Second)
+	revokedCache.entries[otherClaims.Id] = entry
+	revokedCache.lock.Unlock()
+	if _, err := Verify(other); err != ErrTokenRevoked {
+		t.Errorf("Verify after cache ttl = %v, want %v", err, ErrTokenRevoked)
+	}
+}
+
+func TestVerifyRevocationNotCached(t *testing.T) {
+	store := setupRevocation(t, 0, FAIL_CLOSED)
+	token, _ := mintTest(t)
+	for i := 0; i < 3; i++ {
+		if _, err := Verify(token); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := store.count(); n != 3 {
+		t.Errorf("store lookups = %d, want 3", n)
+	}
+}
+
+func TestVerifyRevocationFailPolicy(t *testing.T) {
+	cases := []struct {
+		policy string
+		want   error
+	}{
+		{FAIL_CLOSED, ErrRevocationUnavailable},
+		{"CLOSED", ErrRevocationUnavailable},
+		{FAIL_OPEN, nil},
+	}
+	for _, tc := range cases {
+		t.Run(tc.policy, func(t *testing.T) {
+			store := setupRevocation(t, 60, tc.policy)
+			store.fail(errors.New("connection refused"))
+			token, _ := mintTest(t)
+			if _, err := Verify(token); !errors.Is(err, tc.want) || (tc.want == nil && err != nil) {
+				t.Errorf("Verify = %v, want %v", err, tc.want)
+			}
+			// 查询失败的结果不缓存
+			store.fail(nil)
+			if _, err := Verify(token); err != nil {
+				t.Errorf("Verify after store recovered = %v", err)
+			}
+			if n := store.count(); n != 2 {
+				t.Errorf("store lookups = %d, want 2", n)
+			}
+		})
+	}
+}
+
+func TestRevocationCacheSweep(t *testing.T) {
+	c := &revocationCache{entries: make(map[string]revocationEntry)}
+	c.set("a", true, time.Hour)
+	c.set("b", false, 0)
+	if _, ok := c.get("b"); ok {
+		t.Error("zero ttl result cached")
+	}
+	c.entries["a"] = revocationEntry{revoked: true, expireAt: time.Now().Add(-time.Second)}
+	if _, ok := c.get("a"); ok {
+		t.Error("expired result returned")
+	}
+	c.sweepAt = time.Time{}
+	c.set("c", false, time.Hour)
+	if _, ok := c.entries["a"]; ok {
+		t.Error("expired result not swept")
+	}
+	if revoked, ok := c.get("c"); !ok || revoked {
+		t.Errorf("get(c) = (%v, %v), want (false, true)", revoked, ok)
+	}
+}
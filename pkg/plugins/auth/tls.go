@@ -0,0 +1,247 @@
+package auth
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"rpc-gateway/pkg/core/config"
+	logging "rpc-gateway/pkg/core/log"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+)
+
+// cert files check interval, rotated files are used by next handshake
+var CERT_RELOAD_INTERVAL = 10 * time.Second
+
+// certificate and ca files, reloaded when modified
+type CertReloader struct {
+	certFile  string
+	keyFile   string
+	caFile    string
+	lock      sync.Mutex
+	cert      *tls.Certificate
+	ca        *x509.CertPool
+	modTime   time.Time // 最后修改时间
+	checkedAt time.Time
+}
+
+// new cert reloader, empty files are skipped
+func NewCertReloader(certFile, keyFile, caFile string) (*CertReloader, error) {
+	r := &CertReloader{certFile: certFile, keyFile: keyFile, caFile: caFile}
+	modTime, err := r.latestModTime()
+	if err != nil {
+		return nil, err
+	}
+	if err := r.load(modTime); err != nil {
+		return nil, err
+	}
+	r.checkedAt = time.Now()
+	return r, nil
+}
+
+func (r *CertReloader) files() []string {
+	var files []string
+	for _, file := range []string{r.certFile, r.keyFile, r.caFile} {
+		if file != "" {
+			files = append(files, file)
+		}
+	}
+	return files
+}
+
+func (r *CertReloader) latestModTime() (time.Time, error) {
+	var latest time.Time
+	for _, file := range r.files() {
+		// k8s secret 通过软链接切换, Stat 取目标文件
+		info, err := os.Stat(file)
+		if err != nil {
+			return latest, err
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
+
+func (r *CertReloader) load(modTime time.Time) error {
+	var cert *tls.Certificate
+	if r.certFile != "" || r.keyFile != "" {
+		pair, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+		if err != nil {
+			return fmt.Errorf("load certificate %s: %v", r.certFile, err)
+		}
+		cert = &pair
+	}
+	var ca *x509.CertPool
+	if r.caFile != "" {
+		data, err := ioutil.ReadFile(r.caFile)
+		if err != nil {
+			return fmt.Errorf("load ca %s: %v", r.caFile, err)
+		}
+		ca = x509.NewCertPool()
+		if !ca.AppendCertsFromPEM(data) {
+			return fmt.Errorf("load ca %s: no certificate found", r.caFile)
+		}
+	}
+	r.cert, r.ca, r.modTime = cert, ca, modTime
+	return nil
+}
+
+// current certificate and ca, files are checked at most once per CERT_RELOAD_INTERVAL
+func (r *CertReloader) Current() (*tls.Certificate, *x509.CertPool) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if time.Since(r.checkedAt) < CERT_RELOAD_INTERVAL {
+		return r.cert, r.ca
+	}
+	r.checkedAt = time.Now()
+	modTime, err := r.latestModTime()
+	if err != nil {
+		logging.Log.Warn("check certificate files failed, keep current: ", err)
+		return r.cert, r.ca
+	}
+	if modTime.Equal(r.modTime) {
+		return r.cert, r.ca
+	}
+	// 证书和私钥可能未同时写完, 失败时下次检查重试
+	if err := r.load(modTime); err != nil {
+		logging.Log.Error("reload certificate failed, keep current: ", err)
+		return r.cert, r.ca
+	}
+	logging.Log.Info("certificate reloaded ", strings.Join(r.files(), ", "))
+	return r.cert, r.ca
+}
+
+// client auth type from CLIENT_AUTH
+func clientAuthType(clientAuth string) tls.ClientAuthType {
+	switch strings.ToLower(clientAuth) {
+	case "request":
+		return tls.VerifyClientCertIfGiven
+	case "require":
+		return tls.RequireAndVerifyClientCert
+	}
+	return tls.NoClientCert
+}
+
+// listener tls config, certificate and client ca are reloaded per handshake
+func ServerTLSConfig(cfg config.ServerTLS, nextProtos ...string) (*tls.Config, error) {
+	reloader, err := NewCertReloader(cfg.CertFile, cfg.KeyFile, cfg.ClientCAFile)
+	if err != nil {
+		return nil, err
+	}
+	clientAuth := clientAuthType(cfg.ClientAuth)
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		NextProtos: nextProtos,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			cert, ca := reloader.Current()
+			return &tls.Config{
+				MinVersion:   tls.VersionTLS12,
+				NextProtos:   nextProtos,
+				Certificates: []tls.Certificate{*cert},
+				ClientAuth:   clientAuth,
+				ClientCAs:    ca,
+			}, nil
+		},
+	}, nil
+}
+
+// engine credentials by cert files, shared by pools of the same engine
+var engineReloaders = make(map[string]*CertReloader)
+var engineReloadersLock sync.Mutex
+
+// engine tls credentials, certificate and ca are reloaded per connection
+func EngineCredentials(cfg config.EngineTLS) (credentials.TransportCredentials, error) {
+	key := cfg.CertFile + "|" + cfg.KeyFile + "|" + cfg.CAFile
+	engineReloadersLock.Lock()
+	defer engineReloadersLock.Unlock()
+
+	reloader, ok := engineReloaders[key]
+	if !ok {
+		var err error
+		reloader, err = NewCertReloader(cfg.CertFile, cfg.KeyFile, cfg.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		engineReloaders[key] = reloader
+	}
+	return &engineCredentials{
+		reloader:           reloader,
+		serverName:         cfg.ServerName,
+		insecureSkipVerify: cfg.InsecureSkipVerify,
+	}, nil
+}
+
+// client transport credentials with reloaded certificates
+type engineCredentials struct {
+	reloader           *CertReloader
+	serverName         string
+	insecureSkipVerify bool
+}
+
+func (c *engineCredentials) ClientHandshake(ctx context.Context, authority string, conn net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	cert, ca := c.reloader.Current()
+	cfg := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         c.serverName,
+		RootCAs:            ca, // 为空时使用系统 CA
+		InsecureSkipVerify: c.insecureSkipVerify,
+	}
+	if cert != nil {
+		cfg.Certificates = []tls.Certificate{*cert}
+	}
+	return credentials.NewTLS(cfg).ClientHandshake(ctx, authority, conn)
+}
+
+func (c *engineCredentials) ServerHandshake(conn net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	return nil, nil, errors.New("engine credentials are client only")
+}
+
+func (c *engineCredentials) Info() credentials.ProtocolInfo {
+	return credentials.ProtocolInfo{SecurityProtocol: "tls", SecurityVersion: "1.2", ServerName: c.serverName}
+}
+
+func (c *engineCredentials) Clone() credentials.TransportCredentials {
+	clone := *c
+	return &clone
+}
+
+func (c *engineCredentials) OverrideServerName(serverName string) error {
+	c.serverName = serverName
+	return nil
+}
+
+// verified client certificate identities, CN, DNS/URI SAN and email
+func PeerIdentities(ctx context.Context) []string {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return nil
+	}
+	info, ok := p.AuthInfo.(credentials.TLSInfo)
+	// 只信任校验通过的证书
+	if !ok || len(info.State.VerifiedChains) == 0 || len(info.State.VerifiedChains[0]) == 0 {
+		return nil
+	}
+	cert := info.State.VerifiedChains[0][0]
+	var identities []string
+	if cert.Subject.CommonName != "" {
+		identities = append(identities, cert.Subject.CommonName)
+	}
+	identities = append(identities, cert.DNSNames...)
+	for _, uri := range cert.URIs {
+		identities = append(identities, uri.String())
+	}
+	identities = append(identities, cert.EmailAddresses...)
+	return identities
+}
@@ -0,0 +1,167 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"rpc-gateway/pkg/core/common"
+	"rpc-gateway/pkg/core/config"
+	"strings"
+	"time"
+)
+
+const (
+	// token scopes
+	SCOPE_INVOKE = "invoke" // 调用引擎
+	SCOPE_ADMIN  = "admin"  // 签发和吊销 token
+	// token signing algorithm
+	TOKEN_ALG  = "HS256"
+	TOKEN_TYPE = "JWT"
+)
+
+var (
+	ErrInvalidToken = errors.New("invalid token")
+	ErrTokenExpired = errors.New("token expired")
+	ErrTokenRevoked = errors.New("token revoked")
+	ErrUnknownKey   = errors.New("unknown token key")
+	ErrNoSigningKey = errors.New("token signing key is not configured")
+)
+
+// token claims
+type Claims struct {
+	Id        string   `json:"jti"`
+	TenantId  string   `json:"tid,omitempty"`
+	Engine    string   `json:"eng,omitempty"`
+	SceneCode string   `json:"scene,omitempty"`
+	Scopes    []string `json:"scope,omitempty"`
+	IssuedAt  int64    `json:"iat"`
+	ExpiresAt int64    `json:"exp"`
+}
+
+// token header
+type header struct {
+	Alg string `json:"alg"`
+	Typ string `json:"typ"`
+	Kid string `json:"kid"`
+}
+
+// has scope
+func (claims *Claims) HasScope(scope string) bool {
+	for _, s := range claims.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// expire time
+func (claims *Claims) ExpireTime() time.Time {
+	return time.Unix(claims.ExpiresAt, 0)
+}
+
+// mint token signed by active key, ttl <= 0 uses tokens.TTL
+func Mint(claims Claims, ttl time.Duration) (string, *Claims, error) {
+	tokens := config.Get().Tokens
+	key, ok := tokens.Key(tokens.ActiveKid)
+	if !ok || key.Secret == "" {
+		return "", nil, ErrNoSigningKey
+	}
+	maxTTL := time.Duration(tokens.MaxTTL) * time.Second
+	if ttl <= 0 {
+		ttl = time.Duration(tokens.TTL) * time.Second
+	}
+	if maxTTL > 0 && ttl > maxTTL {
+		return "", nil, fmt.Errorf("ttl must not be greater than %s", maxTTL)
+	}
+
+	now := time.Now()
+	claims.Id = common.GenXid()
+	claims.Engine = strings.ToLower(claims.Engine)
+	claims.IssuedAt = now.Unix()
+	claims.ExpiresAt = now.Add(ttl).Unix()
+
+	headerData, err := json.Marshal(header{Alg: TOKEN_ALG, Typ: TOKEN_TYPE, Kid: key.Kid})
+	if err != nil {
+		return "", nil, err
+	}
+	claimsData, err := json.Marshal(claims)
+	if err != nil {
+		return "", nil, err
+	}
+	payload := encodeSegment(headerData) + "." + encodeSegment(claimsData)
+	return payload + "." + encodeSegment(sign(payload, key.Secret)), &claims, nil
+}
+
+// verify token signature, expiry and revocation
+func Verify(raw string) (*Claims, error) {
+	parts := strings.Split(strings.TrimSpace(raw), ".")
+	if len(parts) != 3 {
+		return nil, ErrInvalidToken
+	}
+	headerData, err := decodeSegment(parts[0])
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	h := header{}
+	if err := json.Unmarshal(headerData, &h); err != nil || h.Alg != TOKEN_ALG {
+		return nil, ErrInvalidToken
+	}
+	// 轮换期间旧 key 仍可校验
+	tokens := config.Get().Tokens
+	key, ok := tokens.Key(h.Kid)
+	if !ok || key.Secret == "" {
+		return nil, ErrUnknownKey
+	}
+	signature, err := decodeSegment(parts[2])
+	if err != nil || !hmac.Equal(signature, sign(parts[0]+"."+parts[1], key.Secret)) {
+		return nil, ErrInvalidToken
+	}
+
+	claimsData, err := decodeSegment(parts[1])
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	claims := &Claims{}
+	if err := json.Unmarshal(claimsData, claims); err != nil || claims.Id == "" {
+		return nil, ErrInvalidToken
+	}
+	if time.Now().Unix() >= claims.ExpiresAt {
+		return nil, ErrTokenExpired
+	}
+	revoked, err := isRevoked(claims.Id)
+	if err != nil {
+		return nil, err
+	}
+	if revoked {
+		return nil, ErrTokenRevoked
+	}
+	return claims, nil
+}
+
+// token from authorization header value, "Bearer " prefix is optional
+func BearerToken(value string) string {
+	value = strings.TrimSpace(value)
+	if len(value) > 7 && strings.EqualFold(value[:7], "bearer ") {
+		return strings.TrimSpace(value[7:])
+	}
+	return value
+}
+
+// hmac sha256 signature
+func sign(payload, secret string) []byte {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(payload))
+	return mac.Sum(nil)
+}
+
+func encodeSegment(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeSegment(segment string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(segment)
+}
@@ -0,0 +1,163 @@
+package cluster
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"rpc-gateway/pkg/core/common"
+	"rpc-gateway/pkg/core/config"
+	logging "rpc-gateway/pkg/core/log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// pool.setting.CLUSTER_MEMBERSHIP
+	MEMBERSHIP_STATIC = "static"
+	MEMBERSHIP_REDIS  = "redis"
+	MEMBERSHIP_LEASE  = "lease"
+	// 连续 3 次未续约视为节点离开
+	MISSED_HEARTBEATS = 3
+)
+
+var ErrMembershipDisabled = errors.New("cluster membership is static")
+
+// gateway replicas registry
+type Membership interface {
+	// register or renew node
+	Heartbeat(id string, ttl time.Duration) error
+	// alive nodes, expired nodes are removed
+	Members(ttl time.Duration) ([]string, error)
+}
+
+var (
+	nodeId     = newNodeId()
+	members    []string
+	membersMux sync.RWMutex
+	startOnce  sync.Once
+)
+
+// hostname and pid, unique of replicas
+func newNodeId() string {
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		return common.GenXid()
+	}
+	return fmt.Sprintf("%s-%d", strings.ToLower(hostname), os.Getpid())
+}
+
+// current node id
+func NodeId() string {
+	return nodeId
+}
+
+// membership of CLUSTER_MEMBERSHIP
+func newMembership(setting config.PoolSetting) (Membership, error) {
+	switch strings.ToLower(setting.ClusterMembership) {
+	case MEMBERSHIP_REDIS:
+		return newRedisMembership()
+	case MEMBERSHIP_LEASE:
+		return newLeaseMembership()
+	}
+	return nil, ErrMembershipDisabled
+}
+
+// register node and refresh members every CLUSTER_HEARTBEAT, onChange is called when members changed
+// 节点列表未知时 (如 kvs 尚未初始化) Dynamic 为 false, 由调用方按 CLUSTER_NODE_NUM 静态划分
+func Start(setting config.PoolSetting, onChange func()) (err error) {
+	err = ErrMembershipDisabled
+	startOnce.Do(func() {
+		var m Membership
+		m, err = newMembership(setting)
+		if err != nil {
+			return
+		}
+		interval := time.Duration(setting.ClusterHeartbeat) * time.Second
+		ttl := interval * MISSED_HEARTBEATS
+		if err := refresh(m, ttl); err != nil {
+			logging.Log.Error("cluster membership refresh error: ", err)
+		} else {
+			logging.Log.Info("cluster node ", nodeId, " joined by ", setting.ClusterMembership, ", members ", Members())
+		}
+		go func() {
+			for range time.Tick(interval) {
+				old := Members()
+				if err := refresh(m, ttl); err != nil {
+					// 保留上次的节点列表
+					logging.Log.Error("cluster membership refresh error: ", err)
+					continue
+				}
+				current := Members()
+				if strings.Join(old, ",") != strings.Join(current, ",") {
+					logging.Log.Info("cluster members changed ", old, " -> ", current)
+					onChange()
+				}
+			}
+		}()
+	})
+	return err
+}
+
+// heartbeat and update members
+func refresh(m Membership, ttl time.Duration) error {
+	if err := m.Heartbeat(nodeId, ttl); err != nil {
+		return err
+	}
+	list, err := m.Members(ttl)
+	if err != nil {
+		return err
+	}
+	// 续约成功后自身一定存活
+	found := false
+	for _, id := range list {
+		if id == nodeId {
+			found = true
+			break
+		}
+	}
+	if !found {
+		list = append(list, nodeId)
+	}
+	sort.Strings(list)
+	membersMux.Lock()
+	members = list
+	membersMux.Unlock()
+	return nil
+}
+
+// alive nodes snapshot, empty before Start
+func Members() []string {
+	membersMux.RLock()
+	defer membersMux.RUnlock()
+	return append([]string(nil), members...)
+}
+
+// members are known from redis or lease
+func Dynamic() bool {
+	membersMux.RLock()
+	defer membersMux.RUnlock()
+	return len(members) > 0
+}
+
+// share of size on current node, shares of all members sum to size
+// 余数按 key 轮转分配, 避免余数总落在同一节点
+func Share(size int, key string) (int, bool) {
+	membersMux.RLock()
+	defer membersMux.RUnlock()
+	n := len(members)
+	if n == 0 {
+		return size, false
+	}
+	idx := sort.SearchStrings(members, nodeId)
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	pos := (idx + int(h.Sum32()%uint32(n))) % n
+	share := size / n
+	if pos < size%n {
+		share++
+	}
+	return share, true
+}
@@ -0,0 +1,87 @@
+package cluster
+
+import (
+	"fmt"
+	"sort"
+	"testing"
+)
+
+// set members of test, current node is members[self]
+func setMembers(t *testing.T, list []string, self int) {
+	t.Helper()
+	oldMembers, oldNode := members, nodeId
+	t.Cleanup(func() {
+		members, nodeId = oldMembers, oldNode
+	})
+	members = append([]string(nil), list...)
+	sort.Strings(members)
+	if self >= 0 {
+		nodeId = members[self]
+	}
+}
+
+func TestShareStatic(t *testing.T) {
+	setMembers(t, nil, -1)
+	share, ok := Share(7, "asr")
+	if ok || share != 7 {
+		t.Errorf("Share without members = (%d, %v), want (7, false)", share, ok)
+	}
+}
+
+func TestShare(t *testing.T) {
+	cases := []struct {
+		name  string
+		nodes int
+		size  int
+	}{
+		{"single", 1, 5},
+		{"even", 2, 4},
+		{"remainder", 3, 7},
+		{"less than nodes", 4, 2},
+		{"zero", 3, 0},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			list := make([]string, tc.nodes)
+			for i := range list {
+				list[i] = fmt.Sprintf("node-%d", i)
+			}
+			for _, key := range []string{"asr", "tts", "asr-4-scene-a"} {
+				total := 0
+				for self := range list {
+					setMembers(t, list, self)
+					share, ok := Share(tc.size, key)
+					if !ok {
+						t.Fatalf("Share(%d, %q) not dynamic", tc.size, key)
+					}
+					if share != tc.size/tc.nodes && share != tc.size/tc.nodes+1 {
+						t.Errorf("Share(%d, %q) on %s = %d", tc.size, key, nodeId, share)
+					}
+					total += share
+				}
+				// 各节点份额之和为 size
+				if total != tc.size {
+					t.Errorf("shares of %q sum to %d, want %d", key, total, tc.size)
+				}
+			}
+		})
+	}
+}
+
+// 余数按 key 分散到不同节点
+func TestShareRemainderRotates(t *testing.T) {
+	list := []string{"node-0", "node-1", "node-2"}
+	owners := map[string]bool{}
+	for i := 0; i < 32; i++ {
+		key := fmt.Sprintf("engine-%d", i)
+		for self := range list {
+			setMembers(t, list, self)
+			if share, _ := Share(1, key); share == 1 {
+				owners[nodeId] = true
+			}
+		}
+	}
+	if len(owners) < 2 {
+		t.Errorf("remainder of 32 keys always on %v", owners)
+	}
+}
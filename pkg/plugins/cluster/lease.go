@@ -0,0 +1,102 @@
+package cluster
+
+import (
+	"errors"
+	"time"
+
+	"rpc-gateway/pkg/core/config"
+	logging "rpc-gateway/pkg/core/log"
+	"rpc-gateway/pkg/plugins/httpserver/db"
+	"rpc-gateway/pkg/plugins/vs/kvs"
+
+	"github.com/go-redis/redis"
+)
+
+const (
+	// lease name prefix and label of gateway members
+	LEASE_PREFIX      = "pigeon-member-"
+	LEASE_LABEL_KEY   = "zhuiyi.ai/cluster"
+	LEASE_LABEL_VALUE = "rpc-gateway"
+)
+
+// membership on coordination.k8s.io leases of vs.kvs.NAMESPACE
+type leaseMembership struct {
+	selector map[string]string
+	// clock of renew time and expiry
+	now func() (time.Time, error)
+}
+
+func newLeaseMembership() (*leaseMembership, error) {
+	if !config.Get().VS.Kvs.Enabled {
+		return nil, errors.New("cluster membership lease requires vs.kvs.ENABLED")
+	}
+	return &leaseMembership{selector: map[string]string{LEASE_LABEL_KEY: LEASE_LABEL_VALUE}, now: leaseClock(db.Cache)}, nil
+}
+
+// redis time is used as the clock of all gateways when cache is enabled, 节点本地时钟偏差不影响过期
+func leaseClock(cache redis.Cmdable) func() (time.Time, error) {
+	if cache == nil {
+		return func() (time.Time, error) {
+			return time.Now(), nil
+		}
+	}
+	return func() (time.Time, error) {
+		return cache.Time().Result()
+	}
+}
+
+// kvs client is initialized by vs plugin
+func (m *leaseMembership) client() (*kvs.Client, error) {
+	client := kvs.Default()
+	if client == nil {
+		return nil, kvs.ErrNotInitialized
+	}
+	return client, nil
+}
+
+func (m *leaseMembership) Heartbeat(id string, ttl time.Duration) error {
+	client, err := m.client()
+	if err != nil {
+		return err
+	}
+	now, err := m.now()
+	if err != nil {
+		return err
+	}
+	return client.RenewLease(LEASE_PREFIX+id, id, now, ttl, m.selector)
+}
+
+func (m *leaseMembership) Members(ttl time.Duration) ([]string, error) {
+	client, err := m.client()
+	if err != nil {
+		return nil, err
+	}
+	leases, err := client.ListLeases(m.selector)
+	if err != nil {
+		return nil, err
+	}
+	now, err := m.now()
+	if err != nil {
+		return nil, err
+	}
+	list := make([]string, 0, len(leases.Items))
+	for _, lease := range leases.Items {
+		spec := lease.Spec
+		if spec.HolderIdentity == nil || spec.RenewTime == nil {
+			continue
+		}
+		duration := ttl
+		if spec.LeaseDurationSeconds != nil {
+			duration = time.Duration(*spec.LeaseDurationSeconds) * time.Second
+		}
+		if spec.RenewTime.Add(duration).Before(now) {
+			// 过期 lease 由存活节点回收
+			if err := client.DeleteLease(lease.Name); err != nil {
+				logging.Log.Warn("delete expired lease ", lease.Name, " error: ", err)
+			}
+			continue
+		}
+		list = append(list, *spec.HolderIdentity)
+	}
+	return list, nil
+}
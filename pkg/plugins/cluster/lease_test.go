@@ -0,0 +1,72 @@
+package cluster
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"rpc-gateway/pkg/core/config"
+	logging "rpc-gateway/pkg/core/log"
+	"rpc-gateway/pkg/plugins/vs/kvs"
+
+	"go.uber.org/zap"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// lease membership of fake clientset, clock is set by test
+func testLeaseMembership(t *testing.T, now *time.Time) *leaseMembership {
+	t.Helper()
+	logging.Log = zap.NewNop().Sugar()
+	client, err := kvs.NewClient(fake.NewSimpleClientset(), config.KvsConfig{Namespace: "gateway"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	kvs.SetDefault(client)
+	t.Cleanup(func() { kvs.SetDefault(nil) })
+	return &leaseMembership{
+		selector: map[string]string{LEASE_LABEL_KEY: LEASE_LABEL_VALUE},
+		now: func() (time.Time, error) {
+			return *now, nil
+		},
+	}
+}
+
+func TestLeaseMembersSharedClock(t *testing.T) {
+	// 共享时钟与本地时钟相差一天, 过期只取决于共享时钟
+	now := time.Now().Add(-24 * time.Hour)
+	m := testLeaseMembership(t, &now)
+	ttl := 30 * time.Second
+
+	for _, id := range []string{"node-a", "node-b"} {
+		if err := m.Heartbeat(id, ttl); err != nil {
+			t.Fatal(err)
+		}
+	}
+	now = now.Add(20 * time.Second)
+	if err := m.Heartbeat("node-b", ttl); err != nil {
+		t.Fatal(err)
+	}
+	list, err := m.Members(ttl)
+	sort.Strings(list)
+	if err != nil || !reflect.DeepEqual(list, []string{"node-a", "node-b"}) {
+		t.Fatalf("Members = %v, %v", list, err)
+	}
+
+	now = now.Add(15 * time.Second)
+	if list, err := m.Members(ttl); err != nil || !reflect.DeepEqual(list, []string{"node-b"}) {
+		t.Errorf("Members after node-a expired = %v, %v", list, err)
+	}
+	// 过期 lease 被回收
+	leases, err := kvs.Default().ListLeases(m.selector)
+	if err != nil || len(leases.Items) != 1 || leases.Items[0].Name != LEASE_PREFIX+"node-b" {
+		t.Errorf("leases = %v, %v", leases, err)
+	}
+}
+
+func TestLeaseClock(t *testing.T) {
+	now, err := leaseClock(nil)()
+	if err != nil || time.Since(now) > time.Second {
+		t.Errorf("local clock = %v, %v", now, err)
+	}
+}
@@ -0,0 +1,66 @@
+package cluster
+
+import (
+	"errors"
+	"time"
+
+	"rpc-gateway/pkg/plugins/httpserver/db"
+
+	"github.com/go-redis/redis"
+)
+
+// members sorted set, score is the last heartbeat in ms of redis time
+const MEMBERS_KEY = "pigeon:cluster:members"
+
+// redis time is used as the clock of all gateways, 节点本地时钟偏差不影响过期
+var heartbeatScript = redis.NewScript(`
+redis.replicate_commands()
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+redis.call('ZADD', KEYS[1], now, ARGV[1])
+redis.call('PEXPIRE', KEYS[1], ARGV[2])
+return now
+`)
+
+var membersScript = redis.NewScript(`
+redis.replicate_commands()
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', '(' .. (now - tonumber(ARGV[1])))
+return redis.call('ZRANGE', KEYS[1], 0, -1)
+`)
+
+// membership on db.Cache, single or cluster mode
+type redisMembership struct {
+	client redis.Cmdable
+}
+
+func newRedisMembership() (*redisMembership, error) {
+	if db.Cache == nil {
+		return nil, errors.New("cluster membership redis requires app.CACHE_ENABLED")
+	}
+	return &redisMembership{client: db.Cache}, nil
+}
+
+// 所有节点停止后集合自动过期
+func (m *redisMembership) Heartbeat(id string, ttl time.Duration) error {
+	return heartbeatScript.Run(m.client, []string{MEMBERS_KEY}, id, ttl.Milliseconds()).Err()
+}
+
+func (m *redisMembership) Members(ttl time.Duration) ([]string, error) {
+	members, err := membersScript.Run(m.client, []string{MEMBERS_KEY}, ttl.Milliseconds()).Result()
+	if err != nil {
+		return nil, err
+	}
+	list, ok := members.([]interface{})
+	if !ok {
+		return nil, errors.New("unexpected cluster members reply")
+	}
+	ids := make([]string, 0, len(list))
+	for _, member := range list {
+		if id, ok := member.(string); ok {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
@@ -0,0 +1,115 @@
+package discovery
+
+import (
+	"fmt"
+	"net"
+	"reflect"
+	"rpc-gateway/pkg/core/config"
+	logging "rpc-gateway/pkg/core/log"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	TYPE_STATIC = "static"
+	TYPE_DNS    = "dns"
+	TYPE_FILE   = "file"
+	TYPE_HTTP   = "http"
+	TYPE_DOCKER = "docker"
+)
+
+// discovered engine endpoint
+type Endpoint struct {
+	Addr      string `json:"addr"`       // host:port, 未指定端口时使用 ENGINE_SERVER_PORT
+	SceneCode string `json:"scene_code"` // 场景码
+	PoolSize  int    `json:"pool_size"`  // 连接池大小
+	Tenant    string `json:"tenant"`     // 租户
+}
+
+// engine endpoints source, updates are full endpoint sets
+type Discoverer interface {
+	// discoverer type
+	Type() string
+	// blocks until stopCh closed, update is called with changed endpoints
+	Watch(stopCh <-chan struct{}, update func([]Endpoint)) error
+}
+
+// discoverer of engine config
+func New(engine config.PoolEngine) (Discoverer, error) {
+	cfg := engine.Discovery
+	defaults := Endpoint{SceneCode: cfg.SceneCode, PoolSize: cfg.PoolSize}
+	interval := time.Duration(cfg.RefreshInterval) * time.Second
+	switch strings.ToLower(cfg.Type) {
+	case "", TYPE_STATIC:
+		return NewStatic(engine), nil
+	case TYPE_DNS:
+		return NewDNS(cfg.DNSName, cfg.DNSType, engine.EngineServerPort, defaults, interval), nil
+	case TYPE_FILE:
+		return NewFile(cfg.File, engine.EngineServerPort, defaults, interval), nil
+	case TYPE_HTTP:
+		return NewHTTP(cfg.URL, cfg.Token, engine.EngineServerPort, defaults, interval), nil
+	case TYPE_DOCKER:
+		return NewDocker(engine.EngineName, engine.EngineServerPort, defaults, interval), nil
+	}
+	return nil, fmt.Errorf("unknown discovery type %q", cfg.Type)
+}
+
+// fill defaults and port, invalid endpoints are skipped, sorted by addr
+func normalize(endpoints []Endpoint, port string, defaults Endpoint) []Endpoint {
+	seen := make(map[string]bool)
+	list := make([]Endpoint, 0, len(endpoints))
+	for _, endpoint := range endpoints {
+		addr := strings.TrimSpace(endpoint.Addr)
+		if addr == "" {
+			continue
+		}
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			addr = net.JoinHostPort(addr, port)
+		}
+		if seen[addr] {
+			logging.Log.Warn("duplicate engine endpoint ", addr, " ignored")
+			continue
+		}
+		seen[addr] = true
+		endpoint.Addr = addr
+		if endpoint.SceneCode == "" {
+			endpoint.SceneCode = defaults.SceneCode
+		}
+		if endpoint.PoolSize <= 0 {
+			endpoint.PoolSize = defaults.PoolSize
+		}
+		list = append(list, endpoint)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Addr < list[j].Addr })
+	return list
+}
+
+// poll endpoints every interval, errors keep last endpoints
+func poll(stopCh <-chan struct{}, name string, interval time.Duration, fetch func() ([]Endpoint, error), update func([]Endpoint)) error {
+	return pollOn(stopCh, nil, name, interval, fetch, update)
+}
+
+// poll endpoints every interval and on trigger, nil trigger polls only
+func pollOn(stopCh <-chan struct{}, trigger <-chan struct{}, name string, interval time.Duration,
+	fetch func() ([]Endpoint, error), update func([]Endpoint)) error {
+	var last []Endpoint
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for first := true; ; first = false {
+		endpoints, err := fetch()
+		switch {
+		case err != nil:
+			logging.Log.Error("discover engines from ", name, " error, keep last endpoints: ", err)
+		case first || !reflect.DeepEqual(endpoints, last):
+			last = endpoints
+			update(endpoints)
+		}
+		select {
+		case <-stopCh:
+			return nil
+		case <-ticker.C:
+		case <-trigger:
+		}
+	}
+}
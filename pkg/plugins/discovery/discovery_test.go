@@ -0,0 +1,67 @@
+package discovery
+
+import (
+	"reflect"
+	"testing"
+
+	logging "rpc-gateway/pkg/core/log"
+
+	"go.uber.org/zap"
+)
+
+func TestNormalize(t *testing.T) {
+	logging.Log = zap.NewNop().Sugar()
+	defaults := Endpoint{SceneCode: "default", PoolSize: 10}
+	cases := []struct {
+		name      string
+		endpoints []Endpoint
+		want      []Endpoint
+	}{
+		{"empty", nil, []Endpoint{}},
+		{
+			"default port",
+			[]Endpoint{{Addr: " 10.0.0.1 "}, {Addr: "engine"}},
+			[]Endpoint{
+				{Addr: "10.0.0.1:8080", SceneCode: "default", PoolSize: 10},
+				{Addr: "engine:8080", SceneCode: "default", PoolSize: 10},
+			},
+		},
+		{
+			"ipv6",
+			[]Endpoint{{Addr: "::1"}, {Addr: "[::2]:9090"}},
+			[]Endpoint{
+				{Addr: "[::1]:8080", SceneCode: "default", PoolSize: 10},
+				{Addr: "[::2]:9090", SceneCode: "default", PoolSize: 10},
+			},
+		},
+		{
+			"blank and duplicate",
+			[]Endpoint{{Addr: ""}, {Addr: "10.0.0.1:8080", PoolSize: 3}, {Addr: "10.0.0.1", PoolSize: 5}},
+			[]Endpoint{{Addr: "10.0.0.1:8080", SceneCode: "default", PoolSize: 3}},
+		},
+		{
+			"keep own settings",
+			[]Endpoint{{Addr: "10.0.0.1", SceneCode: "a", PoolSize: 2, Tenant: "t1"}, {Addr: "10.0.0.2", PoolSize: -1}},
+			[]Endpoint{
+				{Addr: "10.0.0.1:8080", SceneCode: "a", PoolSize: 2, Tenant: "t1"},
+				{Addr: "10.0.0.2:8080", SceneCode: "default", PoolSize: 10},
+			},
+		},
+		{
+			"sorted",
+			[]Endpoint{{Addr: "10.0.0.3"}, {Addr: "10.0.0.1"}, {Addr: "10.0.0.2"}},
+			[]Endpoint{
+				{Addr: "10.0.0.1:8080", SceneCode: "default", PoolSize: 10},
+				{Addr: "10.0.0.2:8080", SceneCode: "default", PoolSize: 10},
+				{Addr: "10.0.0.3:8080", SceneCode: "default", PoolSize: 10},
+			},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := normalize(tc.endpoints, "8080", defaults); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("normalize = %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
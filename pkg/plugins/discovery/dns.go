@@ -0,0 +1,64 @@
+package discovery
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// dns A/SRV endpoints, re-resolved every interval
+type DNS struct {
+	name     string
+	srv      bool
+	port     string
+	defaults Endpoint
+	interval time.Duration
+	resolver *net.Resolver
+}
+
+func NewDNS(name, recordType, port string, defaults Endpoint, interval time.Duration) *DNS {
+	return &DNS{
+		name:     name,
+		srv:      strings.EqualFold(recordType, "SRV"),
+		port:     port,
+		defaults: defaults,
+		interval: interval,
+		resolver: net.DefaultResolver,
+	}
+}
+
+func (d *DNS) Type() string {
+	return TYPE_DNS
+}
+
+func (d *DNS) Watch(stopCh <-chan struct{}, update func([]Endpoint)) error {
+	return poll(stopCh, "dns "+d.name, d.interval, d.resolve, update)
+}
+
+// resolve endpoints, SRV records carry port
+func (d *DNS) resolve() ([]Endpoint, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), d.interval)
+	defer cancel()
+	var endpoints []Endpoint
+	if d.srv {
+		_, records, err := d.resolver.LookupSRV(ctx, "", "", d.name)
+		if err != nil {
+			return nil, err
+		}
+		for _, record := range records {
+			host := strings.TrimSuffix(record.Target, ".")
+			endpoints = append(endpoints, Endpoint{Addr: net.JoinHostPort(host, strconv.Itoa(int(record.Port)))})
+		}
+	} else {
+		addrs, err := d.resolver.LookupHost(ctx, d.name)
+		if err != nil {
+			return nil, err
+		}
+		for _, addr := range addrs {
+			endpoints = append(endpoints, Endpoint{Addr: addr})
+		}
+	}
+	return normalize(endpoints, d.port, d.defaults), nil
+}
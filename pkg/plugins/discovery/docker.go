@@ -0,0 +1,68 @@
+package discovery
+
+import (
+	"errors"
+	"rpc-gateway/pkg/core/config"
+	"rpc-gateway/pkg/plugins/vs/dvs"
+	"strings"
+	"time"
+)
+
+// docker engine containers of dvs, polled every interval
+// 容器通过 zhuiyi.ai/engine-type 等 label 描述, 只使用 running 状态的容器
+type Docker struct {
+	engineType string
+	port       string
+	defaults   Endpoint
+	interval   time.Duration
+}
+
+func NewDocker(engineType, port string, defaults Endpoint, interval time.Duration) *Docker {
+	return &Docker{
+		engineType: strings.ToLower(engineType),
+		port:       port,
+		defaults:   defaults,
+		interval:   interval,
+	}
+}
+
+func (d *Docker) Type() string {
+	return TYPE_DOCKER
+}
+
+// waits for dvs client, vs.dvs.ENABLED is required
+func (d *Docker) Watch(stopCh <-chan struct{}, update func([]Endpoint)) error {
+	if !config.Get().VS.Dvs.Enabled {
+		return errors.New("vs.dvs.ENABLED is false")
+	}
+	select {
+	case <-stopCh:
+		return nil
+	case <-dvs.Ready():
+	}
+	client := dvs.Default()
+	return poll(stopCh, "docker "+d.engineType, d.interval, func() ([]Endpoint, error) {
+		return d.fetch(client)
+	}, update)
+}
+
+func (d *Docker) fetch(client *dvs.Client) ([]Endpoint, error) {
+	containers, err := client.ListEngines(d.engineType)
+	if err != nil {
+		return nil, err
+	}
+	endpoints := make([]Endpoint, 0, len(containers))
+	for _, container := range containers {
+		addr, ok := client.EngineAddr(container, d.port)
+		if !ok {
+			continue
+		}
+		endpoints = append(endpoints, Endpoint{
+			Addr:      addr,
+			SceneCode: container.Meta.SceneCode,
+			PoolSize:  container.Meta.Concurrency,
+			Tenant:    container.Meta.Tenant,
+		})
+	}
+	return normalize(endpoints, d.port, d.defaults), nil
+}
@@ -0,0 +1,127 @@
+package discovery
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	logging "rpc-gateway/pkg/core/log"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/ghodss/yaml"
+)
+
+// wait for editors and configmap updates to finish writing
+var FILE_DEBOUNCE = 100 * time.Millisecond
+
+// endpoints document of file and http catalog, json or yaml
+//
+//	endpoints:
+//	  - addr: 10.0.0.1:31502
+//	    scene_code: s1
+//	    pool_size: 10
+type endpointsDocument struct {
+	Endpoints []Endpoint `json:"endpoints"`
+}
+
+// endpoints of json or yaml file, reloaded on change, polling as fallback
+type File struct {
+	path     string
+	port     string
+	defaults Endpoint
+	interval time.Duration
+	modTime  time.Time
+	last     []Endpoint
+	changed  int32 // 收到变更通知, 不比较修改时间
+}
+
+func NewFile(path, port string, defaults Endpoint, interval time.Duration) *File {
+	return &File{path: path, port: port, defaults: defaults, interval: interval}
+}
+
+func (f *File) Type() string {
+	return TYPE_FILE
+}
+
+func (f *File) Watch(stopCh <-chan struct{}, update func([]Endpoint)) error {
+	trigger, err := f.notify(stopCh)
+	if err != nil {
+		logging.Log.Warn("watch file ", f.path, " error, poll every ", f.interval, ": ", err)
+	}
+	return pollOn(stopCh, trigger, "file "+f.path, f.interval, f.read, update)
+}
+
+// changes of file, directory is watched for atomic rename and configmap symlink updates
+func (f *File) notify(stopCh <-chan struct{}) (<-chan struct{}, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	if err := watcher.Add(filepath.Dir(f.path)); err != nil {
+		watcher.Close()
+		return nil, err
+	}
+	trigger := make(chan struct{}, 1)
+	go func() {
+		defer watcher.Close()
+		debounce := time.NewTimer(FILE_DEBOUNCE)
+		debounce.Stop()
+		defer debounce.Stop()
+		for {
+			select {
+			case <-stopCh:
+				return
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if f.related(event.Name) {
+					debounce.Reset(FILE_DEBOUNCE)
+				}
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				logging.Log.Warn("watch file ", f.path, " error: ", err)
+			case <-debounce.C:
+				atomic.StoreInt32(&f.changed, 1)
+				select {
+				case trigger <- struct{}{}:
+				default:
+				}
+			}
+		}
+	}()
+	return trigger, nil
+}
+
+// event of file, or of configmap ..data symlink
+func (f *File) related(name string) bool {
+	base := filepath.Base(name)
+	return base == filepath.Base(f.path) || strings.HasPrefix(base, "..")
+}
+
+// read file when modified or changed
+func (f *File) read() ([]Endpoint, error) {
+	info, err := os.Stat(f.path)
+	if err != nil {
+		return nil, err
+	}
+	changed := atomic.SwapInt32(&f.changed, 0) == 1
+	if f.last != nil && !changed && info.ModTime().Equal(f.modTime) {
+		return f.last, nil
+	}
+	data, err := ioutil.ReadFile(f.path)
+	if err != nil {
+		return nil, err
+	}
+	var doc endpointsDocument
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	f.modTime = info.ModTime()
+	f.last = normalize(doc.Endpoints, f.port, f.defaults)
+	return f.last, nil
+}
@@ -0,0 +1,98 @@
+package discovery
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	logging "rpc-gateway/pkg/core/log"
+
+	"go.uber.org/zap"
+)
+
+func writeEndpoints(t *testing.T, path string, content string) {
+	t.Helper()
+	// 原子替换, 同编辑器和 configmap 更新
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func waitUpdate(t *testing.T, updates <-chan []Endpoint, want []Endpoint) {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case endpoints := <-updates:
+			if len(endpoints) == len(want) && (len(want) == 0 || endpoints[0] == want[0]) {
+				return
+			}
+		case <-timeout:
+			t.Fatalf("timeout waiting for %v", want)
+		}
+	}
+}
+
+func TestFileWatch(t *testing.T) {
+	logging.Log = zap.NewNop().Sugar()
+	dir, err := ioutil.TempDir("", "discovery")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "asr.yaml")
+	writeEndpoints(t, path, "endpoints:\n  - addr: 10.0.0.1\n")
+
+	// 轮询间隔足够长, 变更只能来自文件通知
+	f := NewFile(path, "31502", Endpoint{SceneCode: "s", PoolSize: 2}, time.Hour)
+	updates := make(chan []Endpoint, 10)
+	stopCh := make(chan struct{})
+	done := make(chan error, 1)
+	go func() {
+		done <- f.Watch(stopCh, func(endpoints []Endpoint) { updates <- endpoints })
+	}()
+	waitUpdate(t, updates, []Endpoint{{Addr: "10.0.0.1:31502", SceneCode: "s", PoolSize: 2}})
+
+	writeEndpoints(t, path, "endpoints:\n  - addr: 10.0.0.2:9000\n    scene_code: s2\n    pool_size: 4\n")
+	waitUpdate(t, updates, []Endpoint{{Addr: "10.0.0.2:9000", SceneCode: "s2", PoolSize: 4}})
+
+	// 修改时间不变的写入
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeEndpoints(t, path, "endpoints:\n  - addr: 10.0.0.3:9000\n")
+	if err := os.Chtimes(path, info.ModTime(), info.ModTime()); err != nil {
+		t.Fatal(err)
+	}
+	waitUpdate(t, updates, []Endpoint{{Addr: "10.0.0.3:9000", SceneCode: "s", PoolSize: 2}})
+
+	// 写入中途的无效内容, 以最终内容为准
+	writeEndpoints(t, path, "endpoints: [")
+	writeEndpoints(t, path, "endpoints: []\n")
+	waitUpdate(t, updates, []Endpoint{})
+
+	close(stopCh)
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Error(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("watch not stopped")
+	}
+}
+
+func TestFileWatchFallback(t *testing.T) {
+	logging.Log = zap.NewNop().Sugar()
+	f := NewFile("/nonexistent/dir/asr.yaml", "31502", Endpoint{}, time.Hour)
+	if _, err := f.notify(make(chan struct{})); err == nil {
+		t.Error("watch of missing directory succeeded")
+	}
+}
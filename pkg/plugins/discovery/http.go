@@ -0,0 +1,121 @@
+package discovery
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// consul service meta keys
+const (
+	META_SCENE_CODE = "scene_code"
+	META_POOL_SIZE  = "pool_size"
+	META_TENANT     = "tenant"
+)
+
+// consul health api entry, /v1/health/service/<name>?passing
+type consulEntry struct {
+	Node struct {
+		Address string
+	}
+	Service struct {
+		Address string
+		Port    int
+		Meta    map[string]string
+	}
+}
+
+// http catalog endpoints, polled every interval
+// 响应为 consul health api 数组或 endpoints 文档
+type HTTP struct {
+	url      string
+	token    string
+	port     string
+	defaults Endpoint
+	interval time.Duration
+	client   *http.Client
+}
+
+func NewHTTP(url, token, port string, defaults Endpoint, interval time.Duration) *HTTP {
+	return &HTTP{
+		url:      url,
+		token:    token,
+		port:     port,
+		defaults: defaults,
+		interval: interval,
+		client:   &http.Client{Timeout: interval},
+	}
+}
+
+func (h *HTTP) Type() string {
+	return TYPE_HTTP
+}
+
+func (h *HTTP) Watch(stopCh <-chan struct{}, update func([]Endpoint)) error {
+	return poll(stopCh, "http "+h.url, h.interval, h.fetch, update)
+}
+
+func (h *HTTP) fetch() ([]Endpoint, error) {
+	req, err := http.NewRequest(http.MethodGet, h.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if h.token != "" {
+		req.Header.Set("Authorization", "Bearer "+h.token)
+		req.Header.Set("X-Consul-Token", h.token)
+	}
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	endpoints, err := parseCatalog(body)
+	if err != nil {
+		return nil, err
+	}
+	return normalize(endpoints, h.port, h.defaults), nil
+}
+
+// consul health entries or endpoints document
+func parseCatalog(body []byte) ([]Endpoint, error) {
+	if strings.HasPrefix(strings.TrimSpace(string(body)), "[") {
+		var entries []consulEntry
+		if err := json.Unmarshal(body, &entries); err != nil {
+			return nil, err
+		}
+		endpoints := make([]Endpoint, 0, len(entries))
+		for _, entry := range entries {
+			host := entry.Service.Address
+			if host == "" {
+				host = entry.Node.Address
+			}
+			if host == "" {
+				continue
+			}
+			endpoint := Endpoint{Addr: host, SceneCode: entry.Service.Meta[META_SCENE_CODE], Tenant: entry.Service.Meta[META_TENANT]}
+			if entry.Service.Port > 0 {
+				endpoint.Addr = net.JoinHostPort(host, strconv.Itoa(entry.Service.Port))
+			}
+			endpoint.PoolSize, _ = strconv.Atoi(entry.Service.Meta[META_POOL_SIZE])
+			endpoints = append(endpoints, endpoint)
+		}
+		return endpoints, nil
+	}
+	var doc endpointsDocument
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return nil, err
+	}
+	return doc.Endpoints, nil
+}
@@ -0,0 +1,47 @@
+package discovery
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseCatalog(t *testing.T) {
+	cases := []struct {
+		name    string
+		body    string
+		want    []Endpoint
+		wantErr bool
+	}{
+		{
+			name: "consul health entries",
+			body: `[
+				{"Node": {"Address": "10.0.0.1"}, "Service": {"Address": "", "Port": 8080, "Meta": {"scene_code": "a", "pool_size": "5", "tenant": "t1"}}},
+				{"Node": {"Address": "10.0.0.1"}, "Service": {"Address": "10.0.1.2", "Port": 0, "Meta": {"pool_size": "x"}}},
+				{"Node": {"Address": ""}, "Service": {"Address": ""}}
+			]`,
+			want: []Endpoint{
+				{Addr: "10.0.0.1:8080", SceneCode: "a", PoolSize: 5, Tenant: "t1"},
+				{Addr: "10.0.1.2"},
+			},
+		},
+		{
+			name: "endpoints document",
+			body: ` {"endpoints": [{"addr": "10.0.0.1:8080", "scene_code": "a", "pool_size": 3}]}`,
+			want: []Endpoint{{Addr: "10.0.0.1:8080", SceneCode: "a", PoolSize: 3}},
+		},
+		{name: "empty entries", body: `[]`, want: []Endpoint{}},
+		{name: "invalid entries", body: `[{"Service": 1}]`, wantErr: true},
+		{name: "invalid document", body: `endpoints`, wantErr: true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := parseCatalog([]byte(tc.body))
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("parseCatalog error = %v, want error %v", err, tc.wantErr)
+			}
+			if !tc.wantErr && !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("parseCatalog = %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
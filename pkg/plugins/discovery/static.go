@@ -0,0 +1,31 @@
+package discovery
+
+import "rpc-gateway/pkg/core/config"
+
+// static endpoints of ENGINE_LIST, changes are applied by pool config hot reload
+type Static struct {
+	endpoints []Endpoint
+}
+
+func NewStatic(engine config.PoolEngine) *Static {
+	endpoints := make([]Endpoint, 0, len(engine.EngineList))
+	for _, server := range engine.EngineList {
+		endpoints = append(endpoints, Endpoint{Addr: server.ServerHost, PoolSize: server.EngineGrpcPoolSize})
+	}
+	return &Static{endpoints: normalize(endpoints, engine.EngineServerPort, Endpoint{})}
+}
+
+func (s *Static) Type() string {
+	return TYPE_STATIC
+}
+
+// ENGINE_LIST endpoints
+func (s *Static) Endpoints() []Endpoint {
+	return s.endpoints
+}
+
+func (s *Static) Watch(stopCh <-chan struct{}, update func([]Endpoint)) error {
+	update(s.endpoints)
+	<-stopCh
+	return nil
+}
@@ -0,0 +1,202 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"rpc-gateway/pkg/core/config"
+	"rpc-gateway/pkg/plugins/httpserver/db"
+	grpcPool "rpc-gateway/pkg/plugins/pool/grpc"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	STATUS_OK   = "ok"
+	STATUS_FAIL = "fail"
+	// db and cache ping timeout
+	PING_TIMEOUT = 2 * time.Second
+)
+
+// process start time
+var startTime = time.Now()
+
+// check result
+type Check struct {
+	Status  string                 `json:"status"`
+	Message string                 `json:"message,omitempty"`
+	Details map[string]interface{} `json:"details,omitempty"`
+}
+
+// health report, ok when all checks are ok
+type Report struct {
+	Status string           `json:"status"`
+	Checks map[string]Check `json:"checks"`
+}
+
+// listener state
+type listener struct {
+	addr  string
+	bound bool
+	err   string
+}
+
+var listeners = make(map[string]*listener)
+var listenersLock sync.RWMutex
+
+// listener bound, e.g. http, admin, grpc:asr, grpc-web:asr
+func ListenerBound(name, addr string) {
+	listenersLock.Lock()
+	defer listenersLock.Unlock()
+	listeners[name] = &listener{addr: addr, bound: true}
+}
+
+// listener failed to bind or stopped serving
+func ListenerFailed(name, addr string, err error) {
+	listenersLock.Lock()
+	defer listenersLock.Unlock()
+	listeners[name] = &listener{addr: addr, err: err.Error()}
+}
+
+// liveness, process is alive
+func Live() Report {
+	return newReport(map[string]Check{
+		"process": {
+			Status: STATUS_OK,
+			Details: map[string]interface{}{
+				"uptime":     time.Since(startTime).Round(time.Second).String(),
+				"goroutines": runtime.NumGoroutine(),
+			},
+		},
+	})
+}
+
+// readiness, configs loaded, listeners bound, engine pools healthy, db and cache reachable
+func Ready() Report {
+	checks := make(map[string]Check)
+	if !config.Loaded() {
+		checks["config"] = Check{Status: STATUS_FAIL, Message: "config not loaded"}
+		return newReport(checks)
+	}
+	cfg := config.Get()
+	checks["config"] = Check{Status: STATUS_OK}
+	for _, name := range expectedListeners(cfg) {
+		checks["listener:"+name] = listenerCheck(name)
+	}
+	for name, check := range poolChecks(cfg.Pool) {
+		checks[name] = check
+	}
+	if cfg.App.DBEnabled {
+		checks["db"] = dbCheck()
+	}
+	if cfg.App.CacheEnabled {
+		checks["cache"] = cacheCheck()
+	}
+	return newReport(checks)
+}
+
+func newReport(checks map[string]Check) Report {
+	status := STATUS_OK
+	for _, check := range checks {
+		if check.Status != STATUS_OK {
+			status = STATUS_FAIL
+		}
+	}
+	return Report{Status: status, Checks: checks}
+}
+
+// listeners started by config
+func expectedListeners(cfg *config.Configs) []string {
+	names := []string{"http", "admin"}
+	if cfg.Pool.Setting.Enabled {
+		for _, engine := range cfg.Pool.Engine {
+			if !engine.PoolEnabled {
+				continue
+			}
+			engineType := strings.ToLower(engine.EngineName)
+			names = append(names, "grpc:"+engineType)
+			if engine.GrpcWebPort != "" {
+				names = append(names, "grpc-web:"+engineType)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func listenerCheck(name string) Check {
+	listenersLock.RLock()
+	l, ok := listeners[name]
+	listenersLock.RUnlock()
+	switch {
+	case !ok:
+		return Check{Status: STATUS_FAIL, Message: "not bound yet"}
+	case !l.bound:
+		return Check{Status: STATUS_FAIL, Message: l.err, Details: map[string]interface{}{"addr": l.addr}}
+	}
+	return Check{Status: STATUS_OK, Details: map[string]interface{}{"addr": l.addr}}
+}
+
+// at least READY_MIN_HEALTHY_POOLS healthy pools per enabled engine
+func poolChecks(pool config.PoolConfig) map[string]Check {
+	checks := make(map[string]Check)
+	if !pool.Setting.Enabled {
+		return checks
+	}
+	if !grpcPool.PoolInitialized() {
+		checks["pool"] = Check{Status: STATUS_FAIL, Message: "pool init not finished"}
+		return checks
+	}
+	required := pool.Setting.ReadyMinHealthyPools
+	for _, engine := range pool.Engine {
+		if !engine.PoolEnabled {
+			continue
+		}
+		engineType := strings.ToLower(engine.EngineName)
+		healthy, total := grpcPool.PoolHealth(engineType)
+		check := Check{
+			Status: STATUS_OK,
+			Details: map[string]interface{}{
+				"healthy":  healthy,
+				"total":    total,
+				"required": required,
+			},
+		}
+		if healthy < required {
+			check.Status = STATUS_FAIL
+			check.Message = fmt.Sprintf("%d healthy pools, %d required", healthy, required)
+		}
+		checks["pool:"+engineType] = check
+	}
+	return checks
+}
+
+func dbCheck() Check {
+	if db.Conn == nil {
+		return Check{Status: STATUS_FAIL, Message: "db not connected"}
+	}
+	sqlDB, err := db.Conn.DB()
+	if err != nil {
+		return Check{Status: STATUS_FAIL, Message: err.Error()}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), PING_TIMEOUT)
+	defer cancel()
+	start := time.Now()
+	if err := sqlDB.PingContext(ctx); err != nil {
+		return Check{Status: STATUS_FAIL, Message: err.Error()}
+	}
+	return Check{Status: STATUS_OK, Details: map[string]interface{}{"latency_ms": time.Since(start).Milliseconds()}}
+}
+
+func cacheCheck() Check {
+	if db.Cache == nil {
+		return Check{Status: STATUS_FAIL, Message: "cache not connected"}
+	}
+	start := time.Now()
+	if err := db.Cache.Ping().Err(); err != nil {
+		return Check{Status: STATUS_FAIL, Message: err.Error()}
+	}
+	return Check{Status: STATUS_OK, Details: map[string]interface{}{"latency_ms": time.Since(start).Milliseconds()}}
+}
@@ -0,0 +1,162 @@
+package health
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+
+	"rpc-gateway/pkg/core/config"
+)
+
+// reset listeners and configs of test
+func setup(t *testing.T, cfg *config.Configs) {
+	t.Helper()
+	listenersLock.Lock()
+	listeners = make(map[string]*listener)
+	listenersLock.Unlock()
+	config.Set(cfg)
+	t.Cleanup(func() { config.Set(nil) })
+}
+
+func checkNames(report Report) []string {
+	names := make([]string, 0, len(report.Checks))
+	for name := range report.Checks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestReadyNotLoaded(t *testing.T) {
+	setup(t, nil)
+	report := Ready()
+	if report.Status != STATUS_FAIL || report.Checks["config"].Message != "config not loaded" || len(report.Checks) != 1 {
+		t.Errorf("Ready = %+v", report)
+	}
+}
+
+func TestReady(t *testing.T) {
+	cases := []struct {
+		name   string
+		modify func(cfg *config.Configs)
+		bound  []string
+		status string
+		checks []string
+		failed []string
+	}{
+		{
+			name:   "all bound",
+			modify: func(cfg *config.Configs) {},
+			bound:  []string{"http", "admin"},
+			status: STATUS_OK,
+			checks: []string{"config", "listener:admin", "listener:http"},
+		},
+		{
+			name:   "listener not bound",
+			modify: func(cfg *config.Configs) {},
+			bound:  []string{"admin"},
+			status: STATUS_FAIL,
+			checks: []string{"config", "listener:admin", "listener:http"},
+			failed: []string{"listener:http"},
+		},
+		{
+			name: "engine listeners and pool init",
+			modify: func(cfg *config.Configs) {
+				cfg.Pool.Setting.Enabled = true
+				cfg.Pool.Engine = []config.PoolEngine{
+					{EngineName: "ASR", PoolEnabled: true, GrpcWebPort: "8081"},
+					{EngineName: "TTS", PoolEnabled: false},
+				}
+			},
+			bound:  []string{"http", "admin", "grpc:asr", "grpc-web:asr"},
+			status: STATUS_FAIL,
+			checks: []string{"config", "listener:admin", "listener:grpc-web:asr", "listener:grpc:asr", "listener:http", "pool"},
+			failed: []string{"pool"},
+		},
+		{
+			name: "pool disabled",
+			modify: func(cfg *config.Configs) {
+				cfg.Pool.Engine = []config.PoolEngine{{EngineName: "ASR", PoolEnabled: true}}
+			},
+			bound:  []string{"http", "admin"},
+			status: STATUS_OK,
+			checks: []string{"config", "listener:admin", "listener:http"},
+		},
+		{
+			name: "db and cache not connected",
+			modify: func(cfg *config.Configs) {
+				cfg.App.DBEnabled = true
+				cfg.App.CacheEnabled = true
+			},
+			bound:  []string{"http", "admin"},
+			status: STATUS_FAIL,
+			checks: []string{"cache", "config", "db", "listener:admin", "listener:http"},
+			failed: []string{"cache", "db"},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := &config.Configs{}
+			tc.modify(cfg)
+			setup(t, cfg)
+			for _, name := range tc.bound {
+				ListenerBound(name, ":0")
+			}
+			report := Ready()
+			if report.Status != tc.status {
+				t.Errorf("status = %s, want %s", report.Status, tc.status)
+			}
+			if got := checkNames(report); !reflect.DeepEqual(got, tc.checks) {
+				t.Errorf("checks = %v, want %v", got, tc.checks)
+			}
+			failed := []string{}
+			for _, name := range checkNames(report) {
+				if report.Checks[name].Status != STATUS_OK {
+					failed = append(failed, name)
+				}
+			}
+			if tc.failed == nil {
+				tc.failed = []string{}
+			}
+			if !reflect.DeepEqual(failed, tc.failed) {
+				t.Errorf("failed checks = %v, want %v", failed, tc.failed)
+			}
+		})
+	}
+}
+
+func TestListenerFailed(t *testing.T) {
+	setup(t, &config.Configs{})
+	ListenerBound("http", ":9800")
+	ListenerBound("admin", "127.0.0.1:9801")
+	if report := Ready(); report.Status != STATUS_OK {
+		t.Fatalf("Ready = %+v", report)
+	}
+
+	// 监听停止后不再就绪
+	ListenerFailed("http", ":9800", errors.New("address already in use"))
+	report := Ready()
+	check := report.Checks["listener:http"]
+	if report.Status != STATUS_FAIL || check.Status != STATUS_FAIL || check.Message != "address already in use" {
+		t.Errorf("Ready after failed = %+v", report)
+	}
+	if check.Details["addr"] != ":9800" {
+		t.Errorf("failed listener details = %v", check.Details)
+	}
+	if report.Checks["listener:admin"].Status != STATUS_OK {
+		t.Errorf("admin listener = %+v", report.Checks["listener:admin"])
+	}
+
+	ListenerBound("http", ":9800")
+	if report := Ready(); report.Status != STATUS_OK {
+		t.Errorf("Ready after rebound = %+v", report)
+	}
+}
+
+func TestLive(t *testing.T) {
+	report := Live()
+	if report.Status != STATUS_OK || report.Checks["process"].Details["goroutines"].(int) <= 0 {
+		t.Errorf("Live = %+v", report)
+	}
+}
@@ -0,0 +1,42 @@
+package controller
+
+import (
+	"errors"
+	"rpc-gateway/pkg/plugins/httpserver/util"
+	"rpc-gateway/pkg/plugins/proxy/cache"
+
+	"github.com/gin-gonic/gin"
+)
+
+// cache controller
+type CacheController struct{}
+
+// purge http response cache by path prefix
+func (ctl *CacheController) PurgeCache(c *gin.Context) {
+	prefix := c.PostForm("prefix")
+	if prefix == "" {
+		util.SendMessage(c, util.Message{
+			Code: -1,
+			Err:  errors.New("prefix is required"),
+		})
+		return
+	}
+
+	count, err := cache.PurgePrefix(prefix)
+	if err != nil {
+		util.SendMessage(c, util.Message{
+			Code: -1,
+			Err:  err,
+		})
+		return
+	}
+
+	util.SendMessage(c, util.Message{
+		Code:    0,
+		Message: "purge cache success",
+		Data: map[string]interface{}{
+			"prefix": prefix,
+			"count":  count,
+		},
+	})
+}
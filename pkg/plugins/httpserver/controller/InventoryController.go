@@ -0,0 +1,216 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"rpc-gateway/pkg/core/config"
+	"rpc-gateway/pkg/plugins/httpserver/db"
+	"rpc-gateway/pkg/plugins/httpserver/model"
+	"rpc-gateway/pkg/plugins/httpserver/util"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// inventory controller, engines, pools, tenants, tokens and routes in db, shared by gateway replicas
+type InventoryController struct{}
+
+// engines, filter by engine_type
+func (ctl *InventoryController) ListEngines(c *gin.Context) {
+	engines, err := db.Engines.List(strings.ToLower(c.Query("engine_type")))
+	sendInventory(c, "engines", engines, err)
+}
+
+// pools of engines deployed by vs, filter by engine_type or engine_name
+func (ctl *InventoryController) ListPools(c *gin.Context) {
+	if engineName := c.Query("engine_name"); engineName != "" {
+		pools, err := db.Pools.ListByEngine(engineName)
+		sendInventory(c, "pools", pools, err)
+		return
+	}
+	pools, err := db.Pools.List(strings.ToLower(c.Query("engine_type")))
+	sendInventory(c, "pools", pools, err)
+}
+
+// tenants shared by gateway replicas
+func (ctl *InventoryController) ListTenants(c *gin.Context) {
+	tenants, err := db.Tenants.List()
+	sendInventory(c, "tenants", tenants, err)
+}
+
+// minted and revoked tokens, filter by tenant_id
+func (ctl *InventoryController) ListTokens(c *gin.Context) {
+	tokens, err := db.Tokens.List(c.Query("tenant_id"))
+	sendInventory(c, "tokens", tokens, err)
+}
+
+// routes shared by gateway replicas
+func (ctl *InventoryController) ListRoutes(c *gin.Context) {
+	routes, err := db.Routes.List()
+	sendInventory(c, "routes", routes, err)
+}
+
+// create or update tenant by tenant_id, applied by all replicas
+func (ctl *InventoryController) SaveTenant(c *gin.Context) {
+	tenant := config.TenantConfig{
+		TenantId:         c.PostForm("tenant_id"),
+		TenantName:       c.PostForm("tenant_name"),
+		EngineName:       strings.ToUpper(c.PostForm("engine_name")),
+		SceneCode:        c.PostForm("scene_code"),
+		ClientIdentities: splitForm(c.PostForm("client_identities")),
+	}
+	err := errors.New("tenant_id is required")
+	if tenant.TenantId != "" {
+		tenant.EnginePoolSize, err = strconv.Atoi(c.PostForm("engine_pool_size"))
+		if err != nil {
+			err = errors.New("engine_pool_size must be a number")
+		}
+	}
+	if err == nil {
+		err = validateTenant(tenant)
+	}
+	if err == nil {
+		saved := db.TenantModel(tenant)
+		if err = db.Tenants.Save(&saved); err == nil {
+			util.SendMessage(c, util.Message{Code: 0, Message: "save tenant success", Data: saved})
+			return
+		}
+	}
+	util.SendMessage(c, util.Message{Code: -1, Err: err})
+}
+
+// delete tenant by tenant_id
+func (ctl *InventoryController) DeleteTenant(c *gin.Context) {
+	tenantId := c.PostForm("tenant_id")
+	if tenantId == "" {
+		util.SendMessage(c, util.Message{Code: -1, Err: errors.New("tenant_id is required")})
+		return
+	}
+	sendDeleted(c, "tenant "+tenantId, db.Tenants.Delete(tenantId))
+}
+
+// create or replace route by path, applied by all replicas
+func (ctl *InventoryController) SaveRoute(c *gin.Context) {
+	route := config.Route{
+		Path:         c.PostForm("path"),
+		Method:       strings.ToLower(c.DefaultPostForm("method", "get")),
+		To:           c.PostForm("to"),
+		Cache:        c.PostForm("cache") == "true",
+		CacheHeaders: splitForm(c.PostForm("cache_headers")),
+		WebSocket:    c.PostForm("websocket") == "true",
+	}
+	var err error
+	if route.CacheTime, err = formInt(c, "cache_time"); err == nil {
+		route.IdleTimeout, err = formInt(c, "idle_timeout")
+	}
+	// header policy json, e.g. {"request": {"deny": ["Cookie"]}}
+	if headers := c.PostForm("headers"); err == nil && headers != "" {
+		if json.Unmarshal([]byte(headers), &route.Headers) != nil {
+			err = errors.New("headers must be a json header policy")
+		}
+	}
+	if err == nil {
+		err = validateRoute(route)
+	}
+	if err == nil {
+		var saved model.Route
+		if saved, err = db.RouteModel(route); err == nil {
+			if err = db.Routes.Save(&saved); err == nil {
+				util.SendMessage(c, util.Message{Code: 0, Message: "save route success", Data: saved})
+				return
+			}
+		}
+	}
+	util.SendMessage(c, util.Message{Code: -1, Err: err})
+}
+
+// delete route by path
+func (ctl *InventoryController) DeleteRoute(c *gin.Context) {
+	path := c.PostForm("path")
+	if path == "" {
+		util.SendMessage(c, util.Message{Code: -1, Err: errors.New("path is required")})
+		return
+	}
+	sendDeleted(c, "route "+path, db.Routes.Delete(path))
+}
+
+// tenant with other db tenants, scene code and client identity are unique
+func validateTenant(tenant config.TenantConfig) error {
+	tenants, err := db.TenantConfigs()
+	if err != nil {
+		return err
+	}
+	list := []config.TenantConfig{tenant}
+	for _, other := range tenants {
+		if other.TenantId != tenant.TenantId {
+			list = append(list, other)
+		}
+	}
+	if errs := config.ValidateTenants(list); len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// route with other db routes, path is unique
+func validateRoute(route config.Route) error {
+	routes, err := db.RouteConfigs()
+	if err != nil {
+		return err
+	}
+	list := []config.Route{route}
+	for _, other := range routes {
+		if other.Path != route.Path {
+			list = append(list, other)
+		}
+	}
+	if errs := config.ValidateRoutes(list); len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// comma separated form value
+func splitForm(value string) []string {
+	list := make([]string, 0)
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// int form value, 0 when empty
+func formInt(c *gin.Context, key string) (int, error) {
+	value := c.PostForm(key)
+	if value == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, errors.New(key + " must be a number")
+	}
+	return n, nil
+}
+
+func sendDeleted(c *gin.Context, name string, err error) {
+	if err != nil {
+		util.SendMessage(c, util.Message{Code: -1, Err: err})
+		return
+	}
+	util.SendMessage(c, util.Message{Code: 0, Message: "delete " + name + " success"})
+}
+
+func sendInventory(c *gin.Context, name string, data interface{}, err error) {
+	if err != nil {
+		util.SendMessage(c, util.Message{Code: -1, Err: err})
+		return
+	}
+	util.SendMessage(c, util.Message{
+		Code:    0,
+		Message: "get " + name + " success",
+		Data:    data,
+	})
+}
@@ -0,0 +1,377 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+
+	"rpc-gateway/pkg/plugins/httpserver/db"
+	"rpc-gateway/pkg/plugins/httpserver/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+// response of util.SendMessage
+type response struct {
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data"`
+}
+
+// serve request of handler, form is posted when not nil
+func serve(t *testing.T, handler gin.HandlerFunc, target string, form url.Values) response {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	method := http.MethodGet
+	var req *http.Request
+	if form != nil {
+		method = http.MethodPost
+		req = httptest.NewRequest(method, target, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	} else {
+		req = httptest.NewRequest(method, target, nil)
+	}
+	router.Handle(method, strings.SplitN(target, "?", 2)[0], handler)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("%s %s status = %d", method, target, w.Code)
+	}
+	var resp response
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("%s %s body %q: %v", method, target, w.Body.String(), err)
+	}
+	return resp
+}
+
+type fakeEngineDAO struct {
+	model.EngineDAO
+	engines []model.Engine
+}
+
+func (dao *fakeEngineDAO) List(engineType string) ([]model.Engine, error) {
+	list := make([]model.Engine, 0)
+	for _, engine := range dao.engines {
+		if engineType == "" || engine.EngineType == engineType {
+			list = append(list, engine)
+		}
+	}
+	return list, nil
+}
+
+type fakePoolDAO struct {
+	pools []model.Pool
+}
+
+func (dao *fakePoolDAO) List(engineType string) ([]model.Pool, error) {
+	list := make([]model.Pool, 0)
+	for _, pool := range dao.pools {
+		if engineType == "" || pool.EngineType == engineType {
+			list = append(list, pool)
+		}
+	}
+	return list, nil
+}
+
+func (dao *fakePoolDAO) ListByEngine(engineName string) ([]model.Pool, error) {
+	list := make([]model.Pool, 0)
+	for _, pool := range dao.pools {
+		if pool.EngineName == engineName {
+			list = append(list, pool)
+		}
+	}
+	return list, nil
+}
+
+func (dao *fakePoolDAO) Replace(engineName string, pools []model.Pool) error {
+	return errors.New("not implemented")
+}
+
+type fakeTenantDAO struct {
+	model.TenantDAO
+	lock    sync.Mutex
+	tenants map[string]model.Tenant
+	err     error
+}
+
+func (dao *fakeTenantDAO) List() ([]model.Tenant, error) {
+	dao.lock.Lock()
+	defer dao.lock.Unlock()
+	list := make([]model.Tenant, 0, len(dao.tenants))
+	for _, tenant := range dao.tenants {
+		list = append(list, tenant)
+	}
+	return list, dao.err
+}
+
+func (dao *fakeTenantDAO) Save(tenant *model.Tenant) error {
+	dao.lock.Lock()
+	defer dao.lock.Unlock()
+	dao.tenants[tenant.TenantId] = *tenant
+	return nil
+}
+
+func (dao *fakeTenantDAO) Delete(tenantId string) error {
+	dao.lock.Lock()
+	defer dao.lock.Unlock()
+	if _, ok := dao.tenants[tenantId]; !ok {
+		return errors.New("tenant " + tenantId + " not found")
+	}
+	delete(dao.tenants, tenantId)
+	return nil
+}
+
+type fakeTokenDAO struct {
+	model.TokenDAO
+	tokens []model.Token
+}
+
+func (dao *fakeTokenDAO) List(tenantId string) ([]model.Token, error) {
+	list := make([]model.Token, 0)
+	for _, token := range dao.tokens {
+		if tenantId == "" || token.TenantId == tenantId {
+			list = append(list, token)
+		}
+	}
+	return list, nil
+}
+
+type fakeRouteDAO struct {
+	model.RouteDAO
+	lock   sync.Mutex
+	routes map[string]model.Route
+}
+
+func (dao *fakeRouteDAO) List() ([]model.Route, error) {
+	dao.lock.Lock()
+	defer dao.lock.Unlock()
+	list := make([]model.Route, 0, len(dao.routes))
+	for _, route := range dao.routes {
+		list = append(list, route)
+	}
+	return list, nil
+}
+
+func (dao *fakeRouteDAO) Save(route *model.Route) error {
+	dao.lock.Lock()
+	defer dao.lock.Unlock()
+	dao.routes[route.Path] = *route
+	return nil
+}
+
+func (dao *fakeRouteDAO) Delete(path string) error {
+	dao.lock.Lock()
+	defer dao.lock.Unlock()
+	delete(dao.routes, path)
+	return nil
+}
+
+// inventory DAOs of test
+type inventory struct {
+	engines *fakeEngineDAO
+	pools   *fakePoolDAO
+	tenants *fakeTenantDAO
+	tokens  *fakeTokenDAO
+	routes  *fakeRouteDAO
+}
+
+func setupInventory(t *testing.T) *inventory {
+	t.Helper()
+	inv := &inventory{
+		engines: &fakeEngineDAO{engines: []model.Engine{
+			{Name: "asr-2-s1", EngineType: "asr", SceneCode: "s1"},
+			{Name: "tts-1-s2", EngineType: "tts", SceneCode: "s2"},
+		}},
+		pools: &fakePoolDAO{pools: []model.Pool{
+			{Name: "asr-2-s1/10.0.0.1:9000", EngineType: "asr", EngineName: "asr-2-s1"},
+			{Name: "asr-2-s1/10.0.0.2:9000", EngineType: "asr", EngineName: "asr-2-s1"},
+			{Name: "tts-1-s2/10.0.0.3:9000", EngineType: "tts", EngineName: "tts-1-s2"},
+		}},
+		tenants: &fakeTenantDAO{tenants: map[string]model.Tenant{
+			"t1": {TenantId: "t1", TenantName: "tenant one", EngineName: "asr", EnginePoolSize: 2, SceneCode: "s1"},
+		}},
+		tokens: &fakeTokenDAO{tokens: []model.Token{{Jti: "j1", TenantId: "t1"}, {Jti: "j2", TenantId: "t2"}}},
+		routes: &fakeRouteDAO{routes: map[string]model.Route{
+			"/api/v1/a": {Path: "/api/v1/a", Method: "GET", To: "http://a:8080"},
+		}},
+	}
+	engines, pools, tenants, tokens, routes := db.Engines, db.Pools, db.Tenants, db.Tokens, db.Routes
+	db.Engines, db.Pools, db.Tenants, db.Tokens, db.Routes = inv.engines, inv.pools, inv.tenants, inv.tokens, inv.routes
+	t.Cleanup(func() {
+		db.Engines, db.Pools, db.Tenants, db.Tokens, db.Routes = engines, pools, tenants, tokens, routes
+	})
+	return inv
+}
+
+func TestInventoryList(t *testing.T) {
+	setupInventory(t)
+	var ctl *InventoryController
+	cases := []struct {
+		name    string
+		handler gin.HandlerFunc
+		target  string
+		message string
+		size    int
+	}{
+		{"engines", ctl.ListEngines, "/engines", "get engines success", 2},
+		{"engines of type", ctl.ListEngines, "/engines?engine_type=ASR", "get engines success", 1},
+		{"pools", ctl.ListPools, "/pools", "get pools success", 3},
+		{"pools of type", ctl.ListPools, "/pools?engine_type=tts", "get pools success", 1},
+		{"pools of engine", ctl.ListPools, "/pools?engine_name=asr-2-s1&engine_type=tts", "get pools success", 2},
+		{"tenants", ctl.ListTenants, "/tenants", "get tenants success", 1},
+		{"tokens", ctl.ListTokens, "/tokens", "get tokens success", 2},
+		{"tokens of tenant", ctl.ListTokens, "/tokens?tenant_id=t2", "get tokens success", 1},
+		{"routes", ctl.ListRoutes, "/routes", "get routes success", 1},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp := serve(t, tc.handler, tc.target, nil)
+			var data []json.RawMessage
+			if err := json.Unmarshal(resp.Data, &data); err != nil {
+				t.Fatal(err)
+			}
+			if resp.Code != 0 || resp.Message != tc.message || len(data) != tc.size {
+				t.Errorf("response = %d %q with %d items, want %q with %d", resp.Code, resp.Message, len(data), tc.message, tc.size)
+			}
+		})
+	}
+}
+
+func TestInventoryListError(t *testing.T) {
+	inv := setupInventory(t)
+	inv.tenants.err = errors.New("connection refused")
+	var ctl *InventoryController
+	if resp := serve(t, ctl.ListTenants, "/tenants", nil); resp.Code != -1 || resp.Message != "connection refused" {
+		t.Errorf("response = %+v", resp)
+	}
+}
+
+func TestSaveTenant(t *testing.T) {
+	inv := setupInventory(t)
+	var ctl *InventoryController
+	valid := url.Values{
+		"tenant_id":         {"t2"},
+		"tenant_name":       {"tenant two"},
+		"engine_name":       {"asr"},
+		"engine_pool_size":  {"4"},
+		"scene_code":        {"s2"},
+		"client_identities": {" client-a, ,spiffe://gw/b "},
+	}
+	cases := []struct {
+		name    string
+		modify  func(form url.Values)
+		message string
+	}{
+		{"tenant id", func(form url.Values) { form.Del("tenant_id") }, "tenant_id is required"},
+		{"pool size", func(form url.Values) { form.Set("engine_pool_size", "four") }, "engine_pool_size must be a number"},
+		{"engine", func(form url.Values) { form.Set("engine_name", "nlp") }, "ENGINE_NAME"},
+		// 场景码已被 t1 使用
+		{"duplicate scene code", func(form url.Values) { form.Set("scene_code", "s1") }, "duplicate scene code s1"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			form := url.Values{}
+			for key, values := range valid {
+				form[key] = values
+			}
+			tc.modify(form)
+			resp := serve(t, ctl.SaveTenant, "/tenants/save", form)
+			if resp.Code != -1 || !strings.Contains(resp.Message, tc.message) {
+				t.Errorf("response = %+v, want error %q", resp, tc.message)
+			}
+		})
+	}
+	if len(inv.tenants.tenants) != 1 {
+		t.Fatalf("invalid tenant saved: %v", inv.tenants.tenants)
+	}
+
+	if resp := serve(t, ctl.SaveTenant, "/tenants/save", valid); resp.Code != 0 || resp.Message != "save tenant success" {
+		t.Fatalf("response = %+v", resp)
+	}
+	saved := inv.tenants.tenants["t2"]
+	if saved.EngineName != "asr" || saved.EnginePoolSize != 4 || saved.ClientIdentities != "client-a,spiffe://gw/b" {
+		t.Errorf("saved tenant = %+v", saved)
+	}
+	// 更新自身不算重复
+	valid.Set("tenant_id", "t1")
+	valid.Set("scene_code", "s1")
+	valid.Del("client_identities")
+	if resp := serve(t, ctl.SaveTenant, "/tenants/save", valid); resp.Code != 0 {
+		t.Errorf("update tenant response = %+v", resp)
+	}
+}
+
+func TestDeleteTenant(t *testing.T) {
+	inv := setupInventory(t)
+	var ctl *InventoryController
+	if resp := serve(t, ctl.DeleteTenant, "/tenants/delete", url.Values{}); resp.Code != -1 || resp.Message != "tenant_id is required" {
+		t.Errorf("response = %+v", resp)
+	}
+	if resp := serve(t, ctl.DeleteTenant, "/tenants/delete", url.Values{"tenant_id": {"t9"}}); resp.Code != -1 || resp.Message != "tenant t9 not found" {
+		t.Errorf("response = %+v", resp)
+	}
+	if resp := serve(t, ctl.DeleteTenant, "/tenants/delete", url.Values{"tenant_id": {"t1"}}); resp.Code != 0 || resp.Message != "delete tenant t1 success" {
+		t.Errorf("response = %+v", resp)
+	}
+	if len(inv.tenants.tenants) != 0 {
+		t.Errorf("tenants after delete = %v", inv.tenants.tenants)
+	}
+}
+
+func TestSaveRoute(t *testing.T) {
+	inv := setupInventory(t)
+	var ctl *InventoryController
+	cases := []struct {
+		name    string
+		form    url.Values
+		message string
+	}{
+		{"cache time", url.Values{"path": {"/b"}, "to": {"http://b"}, "cache_time": {"1m"}}, "cache_time must be a number"},
+		{"idle timeout", url.Values{"path": {"/b"}, "to": {"http://b"}, "idle_timeout": {"x"}}, "idle_timeout must be a number"},
+		{"headers", url.Values{"path": {"/b"}, "to": {"http://b"}, "headers": {"deny"}}, "headers must be a json header policy"},
+		{"path", url.Values{"path": {"b"}, "to": {"http://b"}}, "must begin with '/'"},
+		{"method", url.Values{"path": {"/b"}, "to": {"http://b"}, "method": {"PUT"}}, "method"},
+		{"websocket url", url.Values{"path": {"/b"}, "to": {"ftp://b"}, "websocket": {"true"}}, "to"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp := serve(t, ctl.SaveRoute, "/routes/save", tc.form)
+			if resp.Code != -1 || !strings.Contains(resp.Message, tc.message) {
+				t.Errorf("response = %+v, want error %q", resp, tc.message)
+			}
+		})
+	}
+
+	form := url.Values{
+		"path":          {"/api/v1/b"},
+		"method":        {"POST"},
+		"to":            {"http://b:8080"},
+		"cache":         {"true"},
+		"cache_time":    {"30"},
+		"cache_headers": {"X-Tenant, Accept-Language"},
+		"headers":       {`{"request": {"deny": ["Cookie"]}}`},
+	}
+	if resp := serve(t, ctl.SaveRoute, "/routes/save", form); resp.Code != 0 || resp.Message != "save route success" {
+		t.Fatalf("response = %+v", resp)
+	}
+	saved := inv.routes.routes["/api/v1/b"]
+	if saved.Method != "POST" || !saved.Cache || saved.CacheTime != 30 || saved.CacheHeaders != "X-Tenant,Accept-Language" || !strings.Contains(saved.Headers, "Cookie") {
+		t.Errorf("saved route = %+v", saved)
+	}
+
+	if resp := serve(t, ctl.DeleteRoute, "/routes/delete", url.Values{}); resp.Code != -1 || resp.Message != "path is required" {
+		t.Errorf("response = %+v", resp)
+	}
+	if resp := serve(t, ctl.DeleteRoute, "/routes/delete", url.Values{"path": {"/api/v1/b"}}); resp.Code != 0 || resp.Message != "delete route /api/v1/b success" {
+		t.Errorf("response = %+v", resp)
+	}
+	if _, ok := inv.routes.routes["/api/v1/b"]; ok {
+		t.Error("route not deleted")
+	}
+}
@@ -0,0 +1,37 @@
+package controller
+
+import (
+	"errors"
+	"rpc-gateway/pkg/plugins/httpserver/util"
+	"rpc-gateway/pkg/plugins/metrics"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// metrics controller
+type MetricsController struct{}
+
+// engine and websocket metrics data, module 为 asr、tts 或 websocket, 为空返回全部
+func (ctl *MetricsController) GetEngineMetricsData(c *gin.Context) {
+	var data []metrics.MetricsData
+	switch module := strings.ToLower(c.Query("module")); module {
+	case "":
+		data = append(metrics.ASRMetrics(), metrics.TTSMetrics()...)
+		data = append(data, metrics.WebSocketMetrics()...)
+	case "asr":
+		data = metrics.ASRMetrics()
+	case "tts":
+		data = metrics.TTSMetrics()
+	case "websocket":
+		data = metrics.WebSocketMetrics()
+	default:
+		util.SendMessage(c, util.Message{Code: -1, Err: errors.New("module must be asr, tts or websocket")})
+		return
+	}
+	util.SendMessage(c, util.Message{
+		Code:    0,
+		Message: "get metrics data success",
+		Data:    data,
+	})
+}
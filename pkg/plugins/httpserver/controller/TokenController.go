@@ -0,0 +1,99 @@
+package controller
+
+import (
+	"errors"
+	"rpc-gateway/pkg/plugins/auth"
+	"rpc-gateway/pkg/plugins/httpserver/util"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// token controller
+type TokenController struct{}
+
+// mint tenant token
+func (ctl *TokenController) MintToken(c *gin.Context) {
+	claims := auth.Claims{
+		TenantId:  c.PostForm("tenant_id"),
+		Engine:    strings.ToLower(c.PostForm("engine")),
+		SceneCode: c.PostForm("scene_code"),
+		Scopes:    splitScopes(c.DefaultPostForm("scope", auth.SCOPE_INVOKE)),
+	}
+	if claims.HasScope(auth.SCOPE_INVOKE) {
+		if claims.Engine != "asr" && claims.Engine != "tts" {
+			util.SendMessage(c, util.Message{Code: -1, Err: errors.New("engine must be asr or tts")})
+			return
+		}
+		if claims.SceneCode == "" {
+			util.SendMessage(c, util.Message{Code: -1, Err: errors.New("scene_code is required")})
+			return
+		}
+	}
+	// ttl 单位秒, 默认 tokens.TTL
+	var ttl time.Duration
+	if ttlForm := c.PostForm("ttl"); ttlForm != "" {
+		seconds, err := strconv.Atoi(ttlForm)
+		if err != nil || seconds <= 0 {
+			util.SendMessage(c, util.Message{Code: -1, Err: errors.New("ttl must be a positive number of seconds")})
+			return
+		}
+		ttl = time.Duration(seconds) * time.Second
+	}
+
+	token, minted, err := auth.Mint(claims, ttl)
+	if err == nil {
+		err = auth.Record(minted)
+	}
+	if err != nil {
+		util.SendMessage(c, util.Message{Code: -1, Err: err})
+		return
+	}
+	util.SendMessage(c, util.Message{
+		Code:    0,
+		Message: "mint token success",
+		Data: map[string]interface{}{
+			"token":  token,
+			"claims": minted,
+		},
+	})
+}
+
+// revoke token by token or id
+func (ctl *TokenController) RevokeToken(c *gin.Context) {
+	var err error
+	id := c.PostForm("jti")
+	if token := c.PostForm("token"); token != "" {
+		var claims *auth.Claims
+		if claims, err = auth.Verify(token); err == nil {
+			id = claims.Id
+			err = auth.Revoke(claims)
+		}
+	} else if id != "" {
+		err = auth.RevokeId(id)
+	} else {
+		err = errors.New("token or jti is required")
+	}
+	if err != nil {
+		util.SendMessage(c, util.Message{Code: -1, Err: err})
+		return
+	}
+	util.SendMessage(c, util.Message{
+		Code:    0,
+		Message: "revoke token success",
+		Data:    map[string]interface{}{"jti": id},
+	})
+}
+
+// split comma separated scopes
+func splitScopes(scope string) []string {
+	var scopes []string
+	for _, s := range strings.Split(scope, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			scopes = append(scopes, s)
+		}
+	}
+	return scopes
+}
@@ -0,0 +1,98 @@
+package controller
+
+import (
+	"errors"
+	"rpc-gateway/pkg/plugins/httpserver/util"
+	"rpc-gateway/pkg/plugins/vs"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// vs controller, engine lifecycle jobs
+type VSController struct{}
+
+// create engine, returns job
+func (ctl *VSController) CreateEngine(c *gin.Context) {
+	engine, err := engineForm(c)
+	if err == nil {
+		var job vs.Job
+		if job, err = vs.CreateEngine(engine); err == nil {
+			sendJob(c, "create engine job submitted", job)
+			return
+		}
+	}
+	util.SendMessage(c, util.Message{Code: -1, Err: err})
+}
+
+// update engine replicas, resources or tenant, returns job
+func (ctl *VSController) UpdateEngine(c *gin.Context) {
+	engine, err := engineForm(c)
+	if err == nil {
+		var job vs.Job
+		if job, err = vs.UpdateEngine(engine); err == nil {
+			sendJob(c, "update engine job submitted", job)
+			return
+		}
+	}
+	util.SendMessage(c, util.Message{Code: -1, Err: err})
+}
+
+// delete engine, returns job
+func (ctl *VSController) DeleteEngine(c *gin.Context) {
+	engine, err := engineForm(c)
+	if err == nil {
+		var job vs.Job
+		if job, err = vs.DeleteEngine(engine); err == nil {
+			sendJob(c, "delete engine job submitted", job)
+			return
+		}
+	}
+	util.SendMessage(c, util.Message{Code: -1, Err: err})
+}
+
+// engine job status by job_id
+func (ctl *VSController) CheckEngine(c *gin.Context) {
+	id := c.PostForm("job_id")
+	if id == "" {
+		util.SendMessage(c, util.Message{Code: -1, Err: errors.New("job_id is required")})
+		return
+	}
+	job, ok := vs.GetJob(id)
+	if !ok {
+		util.SendMessage(c, util.Message{Code: -1, Err: errors.New("job " + id + " not found")})
+		return
+	}
+	sendJob(c, "job "+job.Status, job)
+}
+
+// engine of form, replicas 默认 1
+func engineForm(c *gin.Context) (vs.Engine, error) {
+	engine := vs.Engine{
+		EngineType: strings.ToLower(c.PostForm("engine_type")),
+		SceneCode:  c.PostForm("scene_code"),
+		Tenant:     c.PostForm("tenant"),
+		Cpu:        c.PostForm("cpu"),
+		Memory:     c.PostForm("memory"),
+	}
+	concurrency, err := strconv.Atoi(c.PostForm("concurrency"))
+	if err != nil || concurrency <= 0 {
+		return engine, errors.New("concurrency must be a positive number")
+	}
+	engine.Concurrency = concurrency
+	replicas, err := strconv.ParseInt(c.DefaultPostForm("replicas", "1"), 10, 32)
+	if err != nil || replicas <= 0 {
+		return engine, errors.New("replicas must be a positive number")
+	}
+	engine.Replicas = int32(replicas)
+	return engine, nil
+}
+
+func sendJob(c *gin.Context, message string, job vs.Job) {
+	util.SendMessage(c, util.Message{
+		Code:    0,
+		Message: message,
+		Data:    job,
+	})
+}
@@ -0,0 +1,217 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"rpc-gateway/pkg/core/config"
+	logging "rpc-gateway/pkg/core/log"
+	"rpc-gateway/pkg/plugins/vs"
+	"rpc-gateway/pkg/plugins/vs/kvs"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+var testTemplates = map[string]string{
+	"1-deployment.yaml": `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: ${ENGINE_NAME}
+spec:
+  replicas: 1
+  template:
+    spec:
+      containers:
+      - name: engine-server
+        image: engine/${ENGINE_TYPE}:latest
+`,
+	"2-service.yaml": `apiVersion: v1
+kind: Service
+metadata:
+  name: ${ENGINE_NAME}
+spec:
+  ports:
+  - name: grpc
+    port: 9000
+`,
+}
+
+// asr engine pool enabled, kvs backend of fake clientset, db disabled
+func setupVS(t *testing.T) *fake.Clientset {
+	t.Helper()
+	logging.Log = zap.NewNop().Sugar()
+	cfg := &config.Configs{}
+	cfg.Pool.Setting.EngineServiceSelectorKey = "engine"
+	cfg.Pool.Setting.AsrEngineServiceSelectorValue = "zhuiyi.ai.asr"
+	cfg.Pool.Setting.TtsEngineServiceSelectorValue = "zhuiyi.ai.tts"
+	cfg.Pool.Engine = []config.PoolEngine{{EngineName: "ASR", PoolEnabled: true, EngineServerPort: "9000", RequestIdleTime: 10}}
+	config.Set(cfg)
+
+	dir, err := ioutil.TempDir("", "vs-controller")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "asr"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range testTemplates {
+		if err := ioutil.WriteFile(filepath.Join(dir, "asr", name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	clientset := fake.NewSimpleClientset()
+	client, err := kvs.NewClient(clientset, config.KvsConfig{Namespace: "engines", DeployFilePath: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	kvs.SetDefault(client)
+
+	readyTimeout, readyInterval := vs.READY_TIMEOUT, vs.READY_INTERVAL
+	vs.READY_TIMEOUT, vs.READY_INTERVAL = 5*time.Second, 5*time.Millisecond
+	t.Cleanup(func() {
+		os.RemoveAll(dir)
+		kvs.SetDefault(nil)
+		config.Set(nil)
+		vs.READY_TIMEOUT, vs.READY_INTERVAL = readyTimeout, readyInterval
+	})
+	return clientset
+}
+
+func jobOf(t *testing.T, resp response) vs.Job {
+	t.Helper()
+	var job vs.Job
+	if err := json.Unmarshal(resp.Data, &job); err != nil {
+		t.Fatalf("job of %+v: %v", resp, err)
+	}
+	return job
+}
+
+// check job until finished
+func waitCheck(t *testing.T, id string) response {
+	t.Helper()
+	var ctl *VSController
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		resp := serve(t, ctl.CheckEngine, "/engine/check", url.Values{"job_id": {id}})
+		if resp.Message == "job "+vs.JOB_SUCCESS || resp.Message == "job "+vs.JOB_FAILED {
+			return resp
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("job %s not finished: %+v", id, resp)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestEngineForm(t *testing.T) {
+	setupVS(t)
+	var ctl *VSController
+	cases := []struct {
+		name    string
+		form    url.Values
+		message string
+	}{
+		{"concurrency missing", url.Values{"engine_type": {"asr"}, "scene_code": {"s1"}}, "concurrency must be a positive number"},
+		{"concurrency zero", url.Values{"engine_type": {"asr"}, "scene_code": {"s1"}, "concurrency": {"0"}}, "concurrency must be a positive number"},
+		{"replicas", url.Values{"engine_type": {"asr"}, "scene_code": {"s1"}, "concurrency": {"2"}, "replicas": {"two"}}, "replicas must be a positive number"},
+		{"engine type", url.Values{"engine_type": {"nlp"}, "scene_code": {"s1"}, "concurrency": {"2"}}, "engine_type must be asr or tts"},
+		{"pool not enabled", url.Values{"engine_type": {"TTS"}, "scene_code": {"s1"}, "concurrency": {"2"}}, "tts engine pool is not enabled"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			for _, handler := range []gin.HandlerFunc{ctl.CreateEngine, ctl.UpdateEngine, ctl.DeleteEngine} {
+				resp := serve(t, handler, "/engine", tc.form)
+				if resp.Code != -1 || resp.Message != tc.message {
+					t.Errorf("response = %+v, want error %q", resp, tc.message)
+				}
+			}
+		})
+	}
+}
+
+func TestCheckEngine(t *testing.T) {
+	var ctl *VSController
+	if resp := serve(t, ctl.CheckEngine, "/engine/check", url.Values{}); resp.Code != -1 || resp.Message != "job_id is required" {
+		t.Errorf("response = %+v", resp)
+	}
+	if resp := serve(t, ctl.CheckEngine, "/engine/check", url.Values{"job_id": {"missing"}}); resp.Code != -1 || resp.Message != "job missing not found" {
+		t.Errorf("response = %+v", resp)
+	}
+}
+
+func TestEngineJobs(t *testing.T) {
+	clientset := setupVS(t)
+	var ctl *VSController
+	form := url.Values{"engine_type": {"ASR"}, "scene_code": {"scene-b"}, "concurrency": {"2"}, "tenant": {"t1"}}
+
+	if resp := serve(t, ctl.UpdateEngine, "/engine/update", form); resp.Code != -1 || !strings.Contains(resp.Message, "not found") {
+		t.Errorf("update before create = %+v", resp)
+	}
+	resp := serve(t, ctl.CreateEngine, "/engine/create", form)
+	job := jobOf(t, resp)
+	if resp.Code != 0 || resp.Message != "create engine job submitted" || job.EngineName != "asr-2-scene-b" || job.Engine.Replicas != 1 {
+		t.Fatalf("create response = %+v, job %+v", resp, job)
+	}
+	deploy, err := waitDeployment(clientset, job.EngineName)
+	if err != nil || deploy.Spec.Template.Spec.Containers[0].Image != "engine/asr:latest" {
+		t.Fatalf("deployment = %v, %v", deploy, err)
+	}
+	endpoints := &corev1.Endpoints{
+		ObjectMeta: metav1.ObjectMeta{Name: job.EngineName, Namespace: "engines"},
+		Subsets:    []corev1.EndpointSubset{{Addresses: []corev1.EndpointAddress{{IP: "10.0.0.7"}}}},
+	}
+	if _, err := clientset.CoreV1().Endpoints("engines").Create(context.Background(), endpoints, metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	resp = waitCheck(t, job.ID)
+	if job = jobOf(t, resp); job.Status != vs.JOB_SUCCESS || len(job.Endpoints) != 1 || job.Endpoints[0] != "10.0.0.7:9000" {
+		t.Fatalf("create job = %+v", job)
+	}
+	if resp := serve(t, ctl.CreateEngine, "/engine/create", form); resp.Code != -1 || !strings.Contains(resp.Message, "already exists") {
+		t.Errorf("create existing = %+v", resp)
+	}
+
+	form.Set("cpu", "2")
+	resp = serve(t, ctl.UpdateEngine, "/engine/update", form)
+	if resp.Code != 0 || resp.Message != "update engine job submitted" {
+		t.Fatalf("update response = %+v", resp)
+	}
+	if job = jobOf(t, waitCheck(t, jobOf(t, resp).ID)); job.Status != vs.JOB_SUCCESS || job.Engine.Cpu != "2" {
+		t.Errorf("update job = %+v", job)
+	}
+
+	resp = serve(t, ctl.DeleteEngine, "/engine/delete", form)
+	if resp.Code != 0 || resp.Message != "delete engine job submitted" {
+		t.Fatalf("delete response = %+v", resp)
+	}
+	if job = jobOf(t, waitCheck(t, jobOf(t, resp).ID)); job.Status != vs.JOB_SUCCESS || job.Type != vs.JOB_DELETE {
+		t.Errorf("delete job = %+v", job)
+	}
+	if _, err := clientset.AppsV1().Deployments("engines").Get(context.Background(), job.EngineName, metav1.GetOptions{}); err == nil {
+		t.Error("deployment not deleted")
+	}
+}
+
+// deployment is created by job goroutine
+func waitDeployment(clientset *fake.Clientset, name string) (*appsv1.Deployment, error) {
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		deploy, err := clientset.AppsV1().Deployments("engines").Get(context.Background(), name, metav1.GetOptions{})
+		if err == nil || time.Now().After(deadline) {
+			return deploy, err
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
@@ -0,0 +1,331 @@
+package db
+
+import (
+	"errors"
+	"rpc-gateway/pkg/plugins/httpserver/model"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrDisabled = errors.New("db is not enabled")
+
+// gorm DAO of inventory
+var (
+	Engines model.EngineDAO = &engineDAO{}
+	Pools   model.PoolDAO   = &poolDAO{}
+	Tenants model.TenantDAO = &tenantDAO{}
+	Tokens  model.TokenDAO  = &tokenDAO{}
+	Routes  model.RouteDAO  = &routeDAO{}
+	Jobs    model.JobDAO    = &jobDAO{}
+)
+
+// db connected
+func Enabled() bool {
+	return Conn != nil
+}
+
+func conn() (*gorm.DB, error) {
+	if Conn == nil {
+		return nil, ErrDisabled
+	}
+	return Conn, nil
+}
+
+// upsert by unique column, update all columns except primary key and create time
+func upsert(tx *gorm.DB, column string, value interface{}, columns ...string) error {
+	return tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: column}},
+		DoUpdates: clause.AssignmentColumns(columns),
+	}).Create(value).Error
+}
+
+// insert records when table is empty, replicas seeding at the same time are ignored by unique index
+func seed(tx *gorm.DB, table interface{}, records interface{}, size int) error {
+	if size == 0 {
+		return nil
+	}
+	var count int64
+	if err := tx.Model(table).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+	return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(records).Error
+}
+
+// nil when record not found
+func notFound(err error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	return err
+}
+
+type engineDAO struct{}
+
+func (dao *engineDAO) List(engineType string) ([]model.Engine, error) {
+	tx, err := conn()
+	if err != nil {
+		return nil, err
+	}
+	engines := make([]model.Engine, 0)
+	if engineType != "" {
+		tx = tx.Where("engine_type = ?", engineType)
+	}
+	return engines, tx.Order("name").Find(&engines).Error
+}
+
+func (dao *engineDAO) Get(name string) (*model.Engine, error) {
+	tx, err := conn()
+	if err != nil {
+		return nil, err
+	}
+	engine := &model.Engine{}
+	if err := tx.Where("name = ?", name).First(engine).Error; err != nil {
+		return nil, notFound(err)
+	}
+	return engine, nil
+}
+
+func (dao *engineDAO) Save(engine *model.Engine) error {
+	tx, err := conn()
+	if err != nil {
+		return err
+	}
+	return upsert(tx, "name", engine, "engine_type", "scene_code", "concurrency", "replicas", "tenant",
+		"cpu", "memory", "backend", "status", "update_time")
+}
+
+func (dao *engineDAO) SetStatus(name, status string) error {
+	tx, err := conn()
+	if err != nil {
+		return err
+	}
+	return tx.Model(&model.Engine{}).Where("name = ?", name).Update("status", status).Error
+}
+
+func (dao *engineDAO) Delete(name string) error {
+	tx, err := conn()
+	if err != nil {
+		return err
+	}
+	return tx.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("engine_name = ?", name).Delete(&model.Pool{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("name = ?", name).Delete(&model.Engine{}).Error
+	})
+}
+
+type poolDAO struct{}
+
+func (dao *poolDAO) List(engineType string) ([]model.Pool, error) {
+	tx, err := conn()
+	if err != nil {
+		return nil, err
+	}
+	pools := make([]model.Pool, 0)
+	if engineType != "" {
+		tx = tx.Where("engine_type = ?", engineType)
+	}
+	return pools, tx.Order("name").Find(&pools).Error
+}
+
+func (dao *poolDAO) ListByEngine(engineName string) ([]model.Pool, error) {
+	tx, err := conn()
+	if err != nil {
+		return nil, err
+	}
+	pools := make([]model.Pool, 0)
+	return pools, tx.Where("engine_name = ?", engineName).Order("name").Find(&pools).Error
+}
+
+func (dao *poolDAO) Replace(engineName string, pools []model.Pool) error {
+	tx, err := conn()
+	if err != nil {
+		return err
+	}
+	return tx.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("engine_name = ?", engineName).Delete(&model.Pool{}).Error; err != nil {
+			return err
+		}
+		if len(pools) == 0 {
+			return nil
+		}
+		return tx.Create(&pools).Error
+	})
+}
+
+type tenantDAO struct{}
+
+func (dao *tenantDAO) List() ([]model.Tenant, error) {
+	tx, err := conn()
+	if err != nil {
+		return nil, err
+	}
+	tenants := make([]model.Tenant, 0)
+	return tenants, tx.Order("tenant_id").Find(&tenants).Error
+}
+
+func (dao *tenantDAO) Save(tenant *model.Tenant) error {
+	tx, err := conn()
+	if err != nil {
+		return err
+	}
+	err = upsert(tx, "tenant_id", tenant, "tenant_name", "engine_name", "engine_pool_size", "scene_code",
+		"client_identities", "update_time")
+	if err == nil {
+		notifyChange()
+	}
+	return err
+}
+
+func (dao *tenantDAO) Delete(tenantId string) error {
+	tx, err := conn()
+	if err != nil {
+		return err
+	}
+	err = tx.Where("tenant_id = ?", tenantId).Delete(&model.Tenant{}).Error
+	if err == nil {
+		notifyChange()
+	}
+	return err
+}
+
+func (dao *tenantDAO) Seed(tenants []model.Tenant) error {
+	tx, err := conn()
+	if err != nil {
+		return err
+	}
+	return seed(tx, &model.Tenant{}, &tenants, len(tenants))
+}
+
+type tokenDAO struct{}
+
+func (dao *tokenDAO) List(tenantId string) ([]model.Token, error) {
+	tx, err := conn()
+	if err != nil {
+		return nil, err
+	}
+	tokens := make([]model.Token, 0)
+	if tenantId != "" {
+		tx = tx.Where("tenant_id = ?", tenantId)
+	}
+	return tokens, tx.Order("id desc").Find(&tokens).Error
+}
+
+func (dao *tokenDAO) Save(token *model.Token) error {
+	tx, err := conn()
+	if err != nil {
+		return err
+	}
+	return upsert(tx, "jti", token, "tenant_id", "engine", "scene_code", "scopes", "expires_at", "update_time")
+}
+
+func (dao *tokenDAO) Revoke(jti string, expiresAt time.Time) error {
+	tx, err := conn()
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	return upsert(tx, "jti", &model.Token{Jti: jti, ExpiresAt: expiresAt, Revoked: true, RevokedAt: &now},
+		"revoked", "revoked_at", "update_time")
+}
+
+func (dao *tokenDAO) IsRevoked(jti string) (bool, error) {
+	tx, err := conn()
+	if err != nil {
+		return false, err
+	}
+	var count int64
+	err = tx.Model(&model.Token{}).Where("jti = ? AND revoked = ?", jti, true).Count(&count).Error
+	return count > 0, err
+}
+
+type routeDAO struct{}
+
+func (dao *routeDAO) List() ([]model.Route, error) {
+	tx, err := conn()
+	if err != nil {
+		return nil, err
+	}
+	routes := make([]model.Route, 0)
+	return routes, tx.Order("path, method").Find(&routes).Error
+}
+
+func (dao *routeDAO) Save(route *model.Route) error {
+	tx, err := conn()
+	if err != nil {
+		return err
+	}
+	// path 唯一, method 可变
+	err = tx.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("path = ?", route.Path).Delete(&model.Route{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(route).Error
+	})
+	if err == nil {
+		notifyChange()
+	}
+	return err
+}
+
+func (dao *routeDAO) Delete(path string) error {
+	tx, err := conn()
+	if err != nil {
+		return err
+	}
+	err = tx.Where("path = ?", path).Delete(&model.Route{}).Error
+	if err == nil {
+		notifyChange()
+	}
+	return err
+}
+
+func (dao *routeDAO) Seed(routes []model.Route) error {
+	tx, err := conn()
+	if err != nil {
+		return err
+	}
+	return seed(tx, &model.Route{}, &routes, len(routes))
+}
+
+type jobDAO struct{}
+
+func (dao *jobDAO) Get(jobId string) (*model.Job, error) {
+	tx, err := conn()
+	if err != nil {
+		return nil, err
+	}
+	job := &model.Job{}
+	if err := tx.Where("job_id = ?", jobId).First(job).Error; err != nil {
+		return nil, notFound(err)
+	}
+	return job, nil
+}
+
+func (dao *jobDAO) Save(job *model.Job) error {
+	tx, err := conn()
+	if err != nil {
+		return err
+	}
+	return upsert(tx, "job_id", job, "status", "message", "endpoints", "update_time")
+}
+
+func (dao *jobDAO) Unfinished(engineName string) (*model.Job, error) {
+	tx, err := conn()
+	if err != nil {
+		return nil, err
+	}
+	job := &model.Job{}
+	err = tx.Where("engine_name = ? AND status IN ?", engineName, []string{model.JOB_PENDING, model.JOB_RUNNING}).
+		Order("id desc").First(job).Error
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return job, nil
+}
@@ -4,164 +4,80 @@ import (
 	"log"
 	"net"
 	"os"
-	"rpc-gateway/pkg/plugins/httpserver/util"
-	"strconv"
+	"rpc-gateway/pkg/core/config"
 	"strings"
 	"time"
 
 	"github.com/go-redis/redis"
-	"github.com/spf13/viper"
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
+	"gorm.io/gorm/schema"
 )
 
 // DBConn
 var Conn *gorm.DB
 var Cache redis.Cmdable
 
-// init
-func init() {
-	// init viper config
-	util.InitConfig()
-	if runMode := os.Getenv("RUN_MODE"); runMode == "testing" {
+// init db and cache, called on startup
+func Init() {
+	app := config.Get().App
+	if app.RunMode == "testing" {
 		// TO-DO
 	} else {
-		dbInit()
-		cacheInit()
-	}
-}
-
-// db config init
-func dbConfigInit() map[string]interface{} {
-	dbMap := make(map[string]interface{})
-	// get db config
-	dbHost := os.Getenv("DB_HOST")
-	if dbHost == "" {
-		dbHost = viper.GetString("DB_HOST")
-	}
-
-	// get db port
-	dbPort := os.Getenv("DB_PORT")
-	if dbPort == "" {
-		dbPort = viper.GetString("DB_PORT")
-	}
-
-	// get db name
-	dbName := os.Getenv("DB_NAME")
-	if dbName == "" {
-		dbName = viper.GetString("DB_NAME")
-	}
-
-	// get db user
-	dbUser := os.Getenv("DB_USER")
-	if dbUser == "" {
-		dbUser = viper.GetString("DB_USER")
-	}
-
-	// get db passwd
-	dbPasswd := os.Getenv("DB_PASSWD")
-	if dbPasswd == "" {
-		dbPasswd = viper.GetString("DB_PASSWD")
-	}
-
-	// get db driver
-	dbDriver := os.Getenv("DB_DRIVER")
-	if dbDriver == "" {
-		dbDriver = viper.GetString("DB_DRIVER")
-	}
-
-	// get db pool config
-	var dbMaxIdleConnsNum, dbMaxOpenConnsNum, dbConnMaxLifeTimeNum int
-	dbMaxIdleConns := os.Getenv("DB_MAX_IDLE_CONNS")
-	if dbMaxIdleConns == "" {
-		dbMaxIdleConnsNum = viper.GetInt("DB_MAX_IDLE_CONNS")
-	} else {
-		dbMaxIdleConnsNum, _ = strconv.Atoi(dbMaxIdleConns)
-	}
-
-	dbMaxOpenConns := os.Getenv("DB_MAX_OPEN_CONNS")
-	if dbMaxOpenConns == "" {
-		dbMaxOpenConnsNum = viper.GetInt("DB_MAX_OPEN_CONNS")
-	} else {
-		dbMaxOpenConnsNum, _ = strconv.Atoi(dbMaxOpenConns)
-	}
-
-	dbConnMaxLifeTime := os.Getenv("DB_CONN_MAX_LIFETIME")
-	if dbConnMaxLifeTime == "" {
-		dbConnMaxLifeTimeNum = viper.GetInt("DB_CONN_MAX_LIFETIME")
-	} else {
-		dbConnMaxLifeTimeNum, _ = strconv.Atoi(dbConnMaxLifeTime)
+		if app.DBEnabled {
+			dbInit(app)
+		}
+		if app.CacheEnabled {
+			redisInit(app)
+		}
 	}
-
-	// db dsn
-	dsn := dbUser + ":" + dbPasswd + "@tcp(" + dbHost + ":" + dbPort + ")"
-	dbMap["dbName"] = dbName
-	dbMap["dbDriver"] = dbDriver
-	dbMap["dbMaxIdleConns"] = dbMaxIdleConnsNum
-	dbMap["dbMaxOpenConns"] = dbMaxOpenConnsNum
-	dbMap["dbConnMaxLifeTime"] = dbConnMaxLifeTimeNum
-	dbMap["dsn"] = dsn
-	return dbMap
 }
 
 // db init
-func dbInit() {
-	//db config init
-	dbMap := dbConfigInit()
-	dsn, dbName, dbDriver := dbMap["dsn"].(string), dbMap["dbName"].(string), dbMap["dbDriver"].(string)
-	// db dsn
+func dbInit(app config.AppConfig) {
+	// db dsn, passwd secret references resolved by config
+	dsn := app.DBUser + ":" + app.DBPasswd + "@tcp(" + app.DBHost + ":" + app.DBPort + ")"
+	dbName := app.DBName
 	var err error
 	// set db log level
 	logLevel := logger.Info
-	dbLogMode := os.Getenv("DB_LOGMODE")
-	if dbLogMode == "" {
-		dbLogMode = viper.GetString("DB_LOGMODE")
-		switch strings.ToLower(dbLogMode) {
-		case "silent":
-			logLevel = logger.Silent
-		case "warn":
-			logLevel = logger.Warn
-		case "error":
-			logLevel = logger.Error
-		case "info":
-			logLevel = logger.Info
-		}
+	switch strings.ToLower(app.DBLogMode) {
+	case "silent":
+		logLevel = logger.Silent
+	case "warn":
+		logLevel = logger.Warn
+	case "error":
+		logLevel = logger.Error
+	case "info":
+		logLevel = logger.Info
 	}
 	//db logger
-
-	var slowThresholdNum time.Duration
-	slowThreshold := os.Getenv("DB_SLOWTHRESHOLD")
-	if slowThreshold == "" {
-		slowThresholdNum = (time.Duration)(viper.GetInt64("DB_SLOWTHRESHOLD"))
-	} else {
-		slowThresholdInt64, _ := strconv.ParseInt(slowThreshold, 10, 64)
-		slowThresholdNum = (time.Duration)(slowThresholdInt64)
-	}
-
 	DBLogger := logger.New(
 		log.New(os.Stdout, "\r\n", log.LstdFlags), // io writer
 		logger.Config{
-			SlowThreshold:             slowThresholdNum * time.Second, // Slow SQL threshold
-			LogLevel:                  logLevel,                       // Log level
-			IgnoreRecordNotFoundError: true,                           // Ignore ErrRecordNotFound error for logger
-			Colorful:                  false,                          // Disable color
+			SlowThreshold:             time.Duration(app.DBSlowThreshold) * time.Second, // Slow SQL threshold
+			LogLevel:                  logLevel,                                         // Log level
+			IgnoreRecordNotFoundError: true,                                             // Ignore ErrRecordNotFound error for logger
+			Colorful:                  false,                                            // Disable color
 		},
 	)
 
 	//select db driver
-	switch strings.ToLower(dbDriver) {
+	switch strings.ToLower(app.DBDriver) {
 	// 支持 mysql
 	case "mysql":
 		//get conn
 		//check db
 		status := checkDB(dbName, dsn+"/?charset=utf8mb4&parseTime=True&loc=Local")
 		if !status {
+			Conn = nil
 			return
 		}
 
 		Conn, err = gorm.Open(mysql.Open(dsn+"/"+dbName+"?charset=utf8mb4&parseTime=True&loc=Local"), &gorm.Config{
-			Logger: DBLogger,
+			Logger:         DBLogger,
+			NamingStrategy: schema.NamingStrategy{TablePrefix: TABLE_PREFIX},
 		})
 
 		// 支持 oracle
@@ -187,11 +103,20 @@ func dbInit() {
 	}
 	// db setting
 	// SetMaxIdleConns 设置空闲连接池中连接的最大数量
-	sqlDB.SetMaxIdleConns(10)
+	sqlDB.SetMaxIdleConns(app.DBMaxIdleConns)
 	// SetMaxOpenConns 设置打开数据库连接的最大数量。
-	sqlDB.SetMaxOpenConns(100)
+	sqlDB.SetMaxOpenConns(app.DBMaxOpenConns)
 	// SetConnMaxLifetime 设置了连接可复用的最大时间。
-	sqlDB.SetConnMaxLifetime(time.Hour)
+	sqlDB.SetConnMaxLifetime(time.Duration(app.DBConnMaxLifetime) * time.Minute)
+
+	// inventory tables, 失败时关闭 db 相关功能, 同连接失败
+	if err := Migrate(); err != nil {
+		log.Println("db: migrate failed, db disabled ! " + err.Error())
+		sqlDB.Close()
+		Conn = nil
+		return
+	}
+	log.Println("db: migrate successed !")
 }
 
 // check db
@@ -221,62 +146,14 @@ func checkDB(dbName, dsn string) bool {
 	return true
 }
 
-// cache init
-func cacheInit() {
-	cacheEnabledBool := false
-	cacheEnabled := os.Getenv("CACHE_ENABLED")
-	if cacheEnabled == "" {
-		cacheEnabledBool = viper.GetBool("CACHE_ENABLED")
-	} else {
-		cacheEnabledBool, _ = strconv.ParseBool(cacheEnabled)
-	}
-
-	if cacheEnabledBool {
-		redisInit()
-	}
-}
-
 // redis init
-func redisInit() {
+func redisInit(app config.AppConfig) {
 	// get cache config
-	// cache connect mode
-	cacheConnectMode := os.Getenv("CACHE_CONNECT_MODE")
-	if cacheConnectMode == "" {
-		cacheConnectMode = viper.GetString("CACHE_CONNECT_MODE")
-	}
-	// cache address
-	cacheAddress := os.Getenv("CACHE_ADDRESS")
-	if cacheAddress == "" {
-		cacheAddress = viper.GetString("CACHE_ADDRESS")
-	}
-	cacheAddressList := strings.Split(cacheAddress, ",")
-	// cache passwd
-	cachePasswd := os.Getenv("CACHE_PASSWD")
-	if cachePasswd == "" {
-		cachePasswd = viper.GetString("CACHE_PASSWD")
-	}
-	// cache db num
-	var cacheDBNum, cachePoolSizeNum, cacheMinidleConnsNum int
-	cacheDB := os.Getenv("CACHE_DB")
-	if cacheDB == "" {
-		cacheDBNum = viper.GetInt("CACHE_DB")
-	} else {
-		cacheDBNum, _ = strconv.Atoi(cacheDB)
-	}
-	// cache pool size
-	cachePoolSize := os.Getenv("CACHE_POOL_SIZE")
-	if cacheDB == "" {
-		cachePoolSizeNum = viper.GetInt("CACHE_POOL_SIZE")
-	} else {
-		cachePoolSizeNum, _ = strconv.Atoi(cachePoolSize)
-	}
-	// cache minidle conns
-	cacheMinidleConns := os.Getenv("CACHE_MINIDLE_CONNS")
-	if cacheDB == "" {
-		cacheMinidleConnsNum = viper.GetInt("CACHE_MINIDLE_CONNS")
-	} else {
-		cacheMinidleConnsNum, _ = strconv.Atoi(cacheMinidleConns)
-	}
+	cacheConnectMode := app.CacheConnectMode
+	cacheAddressList := strings.Split(app.CacheAddress, ",")
+	// cache passwd, secret references resolved by config
+	cachePasswd := app.CachePasswd
+	cacheDBNum, cachePoolSizeNum, cacheMinidleConnsNum := app.CacheDB, app.CachePoolSize, app.CacheMinidleConns
 
 	// redis client
 	if "cluster" == cacheConnectMode {
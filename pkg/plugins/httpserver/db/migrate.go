@@ -0,0 +1,26 @@
+package db
+
+import (
+	"rpc-gateway/pkg/plugins/httpserver/model"
+)
+
+// table name prefix, e.g. pigeon_engines
+const TABLE_PREFIX = "pigeon_"
+
+// models of gateway inventory
+var models = []interface{}{
+	&model.Engine{},
+	&model.Pool{},
+	&model.Tenant{},
+	&model.Token{},
+	&model.Route{},
+	&model.Job{},
+}
+
+// create or alter inventory tables, columns are never dropped
+func Migrate() error {
+	if Conn == nil {
+		return ErrDisabled
+	}
+	return Conn.AutoMigrate(models...)
+}
@@ -0,0 +1,173 @@
+package db
+
+import (
+	"encoding/json"
+	"rpc-gateway/pkg/core/config"
+	"rpc-gateway/pkg/plugins/httpserver/model"
+	"strings"
+	"sync"
+	"time"
+)
+
+// closed and renewed when tenants or routes are saved by this replica
+var changeCh = make(chan struct{})
+var changeLock sync.Mutex
+
+func notifyChange() {
+	changeLock.Lock()
+	close(changeCh)
+	changeCh = make(chan struct{})
+	changeLock.Unlock()
+}
+
+func changes() <-chan struct{} {
+	changeLock.Lock()
+	defer changeLock.Unlock()
+	return changeCh
+}
+
+// poll interval of tenants, routes and engines
+func SyncInterval() time.Duration {
+	if interval := config.Get().App.DBSyncInterval; interval > 0 {
+		return time.Duration(interval) * time.Second
+	}
+	return 10 * time.Second
+}
+
+// call apply every SyncInterval and after tenants or routes saved by this replica, blocks forever
+func Poll(apply func()) {
+	ticker := time.NewTicker(SyncInterval())
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-changes():
+		}
+		apply()
+	}
+}
+
+func splitList(value string) []string {
+	list := make([]string, 0)
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// tenant entity of config
+func TenantModel(tenant config.TenantConfig) model.Tenant {
+	return model.Tenant{
+		TenantId:         tenant.TenantId,
+		TenantName:       tenant.TenantName,
+		EngineName:       strings.ToLower(tenant.EngineName),
+		EnginePoolSize:   tenant.EnginePoolSize,
+		SceneCode:        tenant.SceneCode,
+		ClientIdentities: strings.Join(tenant.ClientIdentities, ","),
+	}
+}
+
+// tenant config of entity, token is not stored
+func TenantConfig(tenant model.Tenant) config.TenantConfig {
+	return config.TenantConfig{
+		TenantId:         tenant.TenantId,
+		TenantName:       tenant.TenantName,
+		EngineName:       strings.ToUpper(tenant.EngineName),
+		EnginePoolSize:   tenant.EnginePoolSize,
+		SceneCode:        tenant.SceneCode,
+		ClientIdentities: splitList(tenant.ClientIdentities),
+	}
+}
+
+// seed tenants of TenantConfig.yaml, db tenants are kept once there is any
+func SeedTenants(tenants []config.TenantConfig) error {
+	list := make([]model.Tenant, 0, len(tenants))
+	for _, tenant := range tenants {
+		list = append(list, TenantModel(tenant))
+	}
+	return Tenants.Seed(list)
+}
+
+// tenants of db as config
+func TenantConfigs() ([]config.TenantConfig, error) {
+	tenants, err := Tenants.List()
+	if err != nil {
+		return nil, err
+	}
+	list := make([]config.TenantConfig, 0, len(tenants))
+	for _, tenant := range tenants {
+		list = append(list, TenantConfig(tenant))
+	}
+	return list, nil
+}
+
+// route entity of config
+func RouteModel(route config.Route) (model.Route, error) {
+	headers, err := json.Marshal(route.Headers)
+	if err != nil {
+		return model.Route{}, err
+	}
+	return model.Route{
+		Path:         route.Path,
+		Method:       strings.ToUpper(route.Method),
+		To:           route.To,
+		Cache:        route.Cache,
+		CacheTime:    route.CacheTime,
+		CacheHeaders: strings.Join(route.CacheHeaders, ","),
+		Headers:      string(headers),
+		WebSocket:    route.WebSocket,
+		IdleTimeout:  route.IdleTimeout,
+	}, nil
+}
+
+// route config of entity
+func RouteConfig(route model.Route) (config.Route, error) {
+	cfg := config.Route{
+		Path:         route.Path,
+		Method:       strings.ToLower(route.Method),
+		To:           route.To,
+		Cache:        route.Cache,
+		CacheTime:    route.CacheTime,
+		CacheHeaders: splitList(route.CacheHeaders),
+		WebSocket:    route.WebSocket,
+		IdleTimeout:  route.IdleTimeout,
+	}
+	if route.Headers != "" {
+		if err := json.Unmarshal([]byte(route.Headers), &cfg.Headers); err != nil {
+			return cfg, err
+		}
+	}
+	return cfg, nil
+}
+
+// seed routes of RouteConfig.yaml, db routes are kept once there is any
+func SeedRoutes(routes []config.Route) error {
+	list := make([]model.Route, 0, len(routes))
+	for _, route := range routes {
+		r, err := RouteModel(route)
+		if err != nil {
+			return err
+		}
+		list = append(list, r)
+	}
+	return Routes.Seed(list)
+}
+
+// routes of db as config
+func RouteConfigs() ([]config.Route, error) {
+	routes, err := Routes.List()
+	if err != nil {
+		return nil, err
+	}
+	list := make([]config.Route, 0, len(routes))
+	for _, route := range routes {
+		r, err := RouteConfig(route)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, r)
+	}
+	return list, nil
+}
@@ -0,0 +1,110 @@
+package db
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"rpc-gateway/pkg/core/config"
+)
+
+func TestTenantModel(t *testing.T) {
+	cases := []config.TenantConfig{
+		{TenantId: "t1", TenantName: "tenant", EngineName: "ASR", EnginePoolSize: 2, SceneCode: "s1",
+			ClientIdentities: []string{"client-a", "spiffe://gw/b"}},
+		{TenantId: "t2", TenantName: "tenant", EngineName: "TTS", EnginePoolSize: 1, SceneCode: "s2",
+			ClientIdentities: []string{}},
+	}
+	for _, tenant := range cases {
+		entity := TenantModel(tenant)
+		if entity.EngineName != strings.ToLower(tenant.EngineName) {
+			t.Errorf("engine name = %q", entity.EngineName)
+		}
+		if got := TenantConfig(entity); !reflect.DeepEqual(got, tenant) {
+			t.Errorf("TenantConfig(TenantModel(%+v)) = %+v", tenant, got)
+		}
+	}
+	// token 不保存
+	entity := TenantModel(config.TenantConfig{TenantId: "t3", Token: "secret-token"})
+	if got := TenantConfig(entity); got.Token != "" {
+		t.Errorf("token restored: %q", got.Token)
+	}
+}
+
+func TestRouteModel(t *testing.T) {
+	route := config.Route{
+		Path:         "/api/v1/asr",
+		Method:       "post",
+		To:           "http://asr:8080/v1",
+		Cache:        true,
+		CacheTime:    30,
+		CacheHeaders: []string{"X-Tenant", "Accept-Language"},
+		IdleTimeout:  60,
+	}
+	route.Headers.Request.Deny = []string{"Cookie"}
+	route.Headers.Request.Add = map[string]string{"X-Gateway": "pigeon"}
+	route.Headers.Response.Rename = map[string]string{"X-Old": "X-New"}
+	entity, err := RouteModel(route)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entity.Method != "POST" || entity.CacheHeaders != "X-Tenant,Accept-Language" {
+		t.Errorf("route entity = %+v", entity)
+	}
+	got, err := RouteConfig(entity)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, route) {
+		t.Errorf("RouteConfig(RouteModel(route)) = %+v, want %+v", got, route)
+	}
+
+	// 旧数据没有 headers
+	entity.Headers = ""
+	entity.CacheHeaders = ""
+	got, err = RouteConfig(entity)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got.CacheHeaders) != 0 || got.Headers.Request.Deny != nil {
+		t.Errorf("route without headers = %+v", got)
+	}
+	entity.Headers = "{"
+	if _, err := RouteConfig(entity); err == nil {
+		t.Error("invalid headers json accepted")
+	}
+}
+
+func TestPollOnChange(t *testing.T) {
+	cfg := &config.Configs{}
+	cfg.App.DBSyncInterval = 3600
+	config.Set(cfg)
+	applied := make(chan struct{}, 1)
+	go Poll(func() { applied <- struct{}{} })
+	// Poll 开始等待前的通知会丢失, 重复通知
+	deadline := time.After(5 * time.Second)
+	for {
+		notifyChange()
+		select {
+		case <-applied:
+			return
+		case <-time.After(10 * time.Millisecond):
+		case <-deadline:
+			t.Fatal("change not applied")
+		}
+	}
+}
+
+func TestDisabled(t *testing.T) {
+	Conn = nil
+	if _, err := TenantConfigs(); err != ErrDisabled {
+		t.Errorf("TenantConfigs error = %v, want %v", err, ErrDisabled)
+	}
+	if err := SeedRoutes([]config.Route{{Path: "/a"}}); err != ErrDisabled {
+		t.Errorf("SeedRoutes error = %v, want %v", err, ErrDisabled)
+	}
+	if err := Migrate(); err != ErrDisabled {
+		t.Errorf("Migrate error = %v, want %v", err, ErrDisabled)
+	}
+}
@@ -0,0 +1,69 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"rpc-gateway/pkg/plugins/auth"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// principal context key
+const PRINCIPAL_KEY = "principal"
+
+// management api auth 中间件, 需要 X-API-Key 或 Authorization: Bearer <api key 或 token> 且角色满足
+func Authorize(role string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, err := auth.Authenticate(c.GetHeader(auth.API_KEY_HEADER), c.GetHeader("Authorization"))
+		if err != nil {
+			c.Error(err)
+			code := http.StatusUnauthorized
+			// 吊销记录查询失败
+			if errors.Is(err, auth.ErrRevocationUnavailable) {
+				code = http.StatusServiceUnavailable
+			}
+			c.AbortWithStatusJSON(code, gin.H{
+				"code":    -1,
+				"message": err.Error(),
+			})
+			return
+		}
+		c.Set(PRINCIPAL_KEY, principal)
+		if !principal.Allows(role) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"code":    -1,
+				"message": "role " + role + " is required",
+			})
+			return
+		}
+		c.Next()
+	}
+}
+
+// audit 中间件, 放在 Authorize 之前以记录被拒绝的调用
+func Audit(action string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Request.ParseForm()
+		params := c.Request.Form
+		c.Next()
+
+		entry := auth.AuditEntry{
+			Action:   action,
+			Method:   c.Request.Method,
+			Path:     c.Request.URL.Path,
+			ClientIP: c.ClientIP(),
+			Params:   params,
+			Status:   c.Writer.Status(),
+			Latency:  time.Since(start),
+		}
+		if err := c.Errors.Last(); err != nil {
+			entry.Error = err.Error()
+		}
+		if principal, ok := c.Get(PRINCIPAL_KEY); ok {
+			entry.Principal = principal.(*auth.Principal)
+		}
+		auth.WriteAudit(entry)
+	}
+}
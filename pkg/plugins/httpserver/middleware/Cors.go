@@ -2,11 +2,40 @@ package middleware
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
 
 	"github.com/gin-gonic/gin"
 )
 
+// cors config
+type CorsConfig struct {
+	AllowOrigins     []string // 允许的 Origin, "*" 允许全部
+	AllowMethods     []string
+	AllowHeaders     []string
+	ExposeHeaders    []string
+	AllowCredentials bool
+	MaxAge           int // 缓存请求信息 单位为秒
+}
+
+// default cors config
+func DefaultCorsConfig() CorsConfig {
+	return CorsConfig{
+		AllowOrigins: []string{"*"},
+		AllowMethods: []string{"POST", "GET", "OPTIONS", "PUT", "DELETE", "UPDATE"},
+		AllowHeaders: []string{"Authorization", "Content-Length", "X-CSRF-Token",
+			"Token", "session", "X_Requested_With", "Accept", "Origin", "Host",
+			"Connection", "Accept-Encoding", "Accept-Language", "DNT",
+			"X-CustomHeader", "Keep-Alive", "User-Agent", "X-Requested-With",
+			"If-Modified-Since", "Cache-Control", "Content-Type", "Pragma"},
+		ExposeHeaders: []string{"Content-Length", "Access-Control-Allow-Origin",
+			"Access-Control-Allow-Headers", "Cache-Control", "Content-Language",
+			"Content-Type", "Expires", "Last-Modified", "Pragma", "FooBar"},
+		AllowCredentials: false,
+		MaxAge:           172800,
+	}
+}
+
 func Cors() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		c.Header("Access-Control-Allow-Origin", "*")
@@ -15,6 +44,20 @@ func Cors() gin.HandlerFunc {
 }
 
 func CorsAll() gin.HandlerFunc {
+	return CorsWithConfig(DefaultCorsConfig())
+}
+
+// cors with config
+func CorsWithConfig(config CorsConfig) gin.HandlerFunc {
+	allowAll := false
+	allowOrigins := make(map[string]bool)
+	for _, origin := range config.AllowOrigins {
+		if origin == "*" {
+			allowAll = true
+		}
+		allowOrigins[origin] = true
+	}
+
 	return func(c *gin.Context) {
 		method := c.Request.Method
 		origin := c.Request.Header.Get("Origin")
@@ -28,24 +71,21 @@ func CorsAll() gin.HandlerFunc {
 		} else {
 			headerStr = "access-control-allow-origin, access-control-allow-headers"
 		}
-		if origin != "" {
-			c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
-			c.Header("Access-Control-Allow-Origin", "*")
-			c.Header("Access-Control-Allow-Methods", "POST, GET, OPTIONS, PUT, DELETE,UPDATE")
+		if origin != "" && (allowAll || allowOrigins[origin]) {
+			allowOrigin := "*"
+			if !allowAll || config.AllowCredentials {
+				// credentials not allowed with wildcard origin
+				allowOrigin = origin
+				c.Header("Vary", "Origin")
+			}
+			c.Header("Access-Control-Allow-Origin", allowOrigin)
+			c.Header("Access-Control-Allow-Methods", strings.Join(config.AllowMethods, ", "))
 			//  header的类型
-			c.Header("Access-Control-Allow-Headers",
-				"Authorization, Content-Length, X-CSRF-Token, "+
-					"Token,session,X_Requested_With,Accept, Origin, Host, "+
-					"Connection, Accept-Encoding, Accept-Language,DNT, "+
-					"X-CustomHeader, Keep-Alive, User-Agent, X-Requested-With, "+
-					"If-Modified-Since, Cache-Control, Content-Type, Pragma")
-			c.Header("Access-Control-Expose-Headers",
-				"Content-Length, Access-Control-Allow-Origin, "+
-					"Access-Control-Allow-Headers,Cache-Control,Content-Language,"+
-					"Content-Type,Expires,Last-Modified,Pragma,FooBar") // 跨域关键设置 让浏览器可以解析
-			c.Header("Access-Control-Max-Age", "172800")          // 缓存请求信息 单位为秒
-			c.Header("Access-Control-Allow-Credentials", "false") //  跨域请求是否需要带cookie信息 默认设置为true
-			c.Set("content-type", "application/json")             // 设置返回格式是json
+			c.Header("Access-Control-Allow-Headers", strings.Join(config.AllowHeaders, ", "))
+			c.Header("Access-Control-Expose-Headers", strings.Join(config.ExposeHeaders, ", "))       // 跨域关键设置 让浏览器可以解析
+			c.Header("Access-Control-Max-Age", strconv.Itoa(config.MaxAge))                           // 缓存请求信息 单位为秒
+			c.Header("Access-Control-Allow-Credentials", strconv.FormatBool(config.AllowCredentials)) //  跨域请求是否需要带cookie信息 默认设置为true
+			c.Set("content-type", "application/json")                                                 // 设置返回格式是json
 		}
 
 		//放行所有OPTIONS方法
@@ -19,9 +19,21 @@ func (w GinWriter) Write(b []byte) (int, error) {
 	return w.body.Write(b)
 }
 
+// gin context key, set before TimeoutHandler by routes whose responses are streamed
+const STREAMING_KEY = "streaming"
+
+// gin context key, set before TimeoutHandler by matched websocket routes
+const WEBSOCKET_KEY = "websocket"
+
 // time out mid
 func TimeoutHandler(t time.Duration) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		// websocket connections are long lived and hijacked,
+		// streaming responses must be flushed as they come
+		if c.GetBool(WEBSOCKET_KEY) || c.GetBool(STREAMING_KEY) {
+			c.Next()
+			return
+		}
 		buffer := buffpool.GetBuff()
 		blw := &GinWriter{body: buffer, ResponseWriter: c.Writer}
 		c.Writer = blw
@@ -0,0 +1,50 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestTimeoutHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cases := []struct {
+		name   string
+		key    string
+		header http.Header
+		want   int
+	}{
+		{"buffered", "", nil, http.StatusGatewayTimeout},
+		// 请求头不决定是否跳过超时
+		{"accept event-stream", "", http.Header{"Accept": {"text/event-stream"}}, http.StatusGatewayTimeout},
+		{"upgrade websocket", "", http.Header{"Connection": {"Upgrade"}, "Upgrade": {"websocket"}}, http.StatusGatewayTimeout},
+		{"streaming route", STREAMING_KEY, nil, http.StatusOK},
+		{"websocket route", WEBSOCKET_KEY, nil, http.StatusOK},
+	}
+	for _, c := range cases {
+		router := gin.New()
+		key := c.key
+		router.Use(func(ctx *gin.Context) {
+			if key != "" {
+				ctx.Set(key, true)
+			}
+		})
+		router.Use(TimeoutHandler(20 * time.Millisecond))
+		router.GET("/stream", func(ctx *gin.Context) {
+			time.Sleep(60 * time.Millisecond)
+			ctx.String(http.StatusOK, "done")
+		})
+		req := httptest.NewRequest(http.MethodGet, "/stream", nil)
+		for k, v := range c.header {
+			req.Header[k] = v
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != c.want {
+			t.Errorf("%s: status %d, want %d", c.name, w.Code, c.want)
+		}
+	}
+}
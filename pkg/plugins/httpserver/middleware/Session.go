@@ -2,16 +2,20 @@ package middleware
 
 import (
 	"net/http"
+	"rpc-gateway/pkg/core/config"
 
 	"github.com/gin-contrib/sessions"
 	"github.com/gin-contrib/sessions/cookie"
 	"github.com/gin-gonic/gin"
 )
 
+// session cookie name
+const SESSION_COOKIE_NAME = "TICKET"
+
 // 使用 Cookie 保存 session
 func UseCookieSession() gin.HandlerFunc {
-	store := cookie.NewStore([]byte("secret"))
-	return sessions.Sessions("TICKET", store)
+	store := cookie.NewStore([]byte(config.Get().App.SessionSecret))
+	return sessions.Sessions(SESSION_COOKIE_NAME, store)
 }
 
 // auth 中间件
@@ -0,0 +1,61 @@
+package model
+
+// engine status
+const (
+	ENGINE_CREATING = "creating"
+	ENGINE_UPDATING = "updating"
+	ENGINE_READY    = "ready"
+	ENGINE_FAILED   = "failed"
+	ENGINE_DELETING = "deleting"
+)
+
+// engine entity, deployed by engine lifecycle api
+type Engine struct {
+	ID          uint   `gorm:"primaryKey" json:"id"`
+	Name        string `gorm:"size:128;uniqueIndex" json:"name"` // <engine type>-<concurrency>-<scene code>
+	EngineType  string `gorm:"size:16;index" json:"engineType"`
+	SceneCode   string `gorm:"size:64" json:"sceneCode"`
+	Concurrency int    `json:"concurrency"`
+	Replicas    int32  `json:"replicas"`
+	Tenant      string `gorm:"size:64" json:"tenant"`
+	Cpu         string `gorm:"size:32" json:"cpu"`
+	Memory      string `gorm:"size:32" json:"memory"`
+	Backend     string `gorm:"size:16" json:"backend"` // kvs, dvs
+	Status      string `gorm:"size:16" json:"status"`
+	BaseModel
+}
+
+// pool entity, one pool per ready engine address
+type Pool struct {
+	ID         uint   `gorm:"primaryKey" json:"id"`
+	Name       string `gorm:"size:255;uniqueIndex" json:"name"` // <engine name>/<addr>
+	EngineType string `gorm:"size:16;index" json:"engineType"`
+	EngineName string `gorm:"size:128;index" json:"engineName"`
+	Addr       string `gorm:"size:128" json:"addr"`
+	SceneCode  string `gorm:"size:64" json:"sceneCode"`
+	Tenant     string `gorm:"size:64" json:"tenant"`
+	Size       int    `json:"size"`
+	BaseModel
+}
+
+// engine DAO
+type EngineDAO interface {
+	// engines of type, all when engine type is empty
+	List(engineType string) ([]Engine, error)
+	// nil when not found
+	Get(name string) (*Engine, error)
+	// create or update by name
+	Save(engine *Engine) error
+	SetStatus(name, status string) error
+	// delete engine and its pools
+	Delete(name string) error
+}
+
+// pool DAO
+type PoolDAO interface {
+	// pools of type, all when engine type is empty
+	List(engineType string) ([]Pool, error)
+	ListByEngine(engineName string) ([]Pool, error)
+	// replace pools of engine
+	Replace(engineName string, pools []Pool) error
+}
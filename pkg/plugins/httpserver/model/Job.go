@@ -0,0 +1,33 @@
+package model
+
+// job status
+const (
+	JOB_PENDING = "pending"
+	JOB_RUNNING = "running"
+	JOB_SUCCESS = "success"
+	JOB_FAILED  = "failed"
+)
+
+// engine lifecycle job entity, shared by gateways
+type Job struct {
+	ID         uint   `gorm:"primaryKey" json:"id"`
+	JobId      string `gorm:"size:32;uniqueIndex" json:"jobId"`
+	Type       string `gorm:"size:16" json:"type"`
+	Backend    string `gorm:"size:16" json:"backend"`
+	EngineName string `gorm:"size:128;index" json:"engineName"`
+	Engine     string `gorm:"type:text" json:"engine"` // engine json
+	Status     string `gorm:"size:16" json:"status"`
+	Message    string `gorm:"type:text" json:"message"`
+	Endpoints  string `gorm:"size:1024" json:"endpoints"` // 逗号分隔
+	BaseModel
+}
+
+// job DAO
+type JobDAO interface {
+	// nil when not found
+	Get(jobId string) (*Job, error)
+	// create or update by job id
+	Save(job *Job) error
+	// pending or running job of engine, nil when none
+	Unfinished(engineName string) (*Job, error)
+}
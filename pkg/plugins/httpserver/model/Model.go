@@ -6,6 +6,6 @@ import (
 
 // base model
 type BaseModel struct {
-	CreateTime time.Time
-	UpdateTime time.Time
+	CreateTime time.Time `gorm:"autoCreateTime" json:"createTime"`
+	UpdateTime time.Time `gorm:"autoUpdateTime" json:"updateTime"`
 }
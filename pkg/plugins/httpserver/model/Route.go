@@ -0,0 +1,26 @@
+package model
+
+// route entity, shared by gateway replicas, seeded from RouteConfig.yaml
+type Route struct {
+	ID           uint   `gorm:"primaryKey" json:"id"`
+	Path         string `gorm:"size:255;uniqueIndex:idx_route" json:"path"`
+	Method       string `gorm:"size:16;uniqueIndex:idx_route" json:"method"`
+	To           string `gorm:"size:255" json:"to"`
+	Cache        bool   `json:"cache"`
+	CacheTime    int    `json:"cacheTime"`
+	CacheHeaders string `gorm:"size:1024" json:"cacheHeaders"` // 逗号分隔
+	Headers      string `gorm:"type:text" json:"headers"`      // header policy json
+	WebSocket    bool   `json:"websocket"`
+	IdleTimeout  int    `json:"idleTimeout"`
+	BaseModel
+}
+
+// route DAO
+type RouteDAO interface {
+	List() ([]Route, error)
+	// create or replace route of path
+	Save(route *Route) error
+	Delete(path string) error
+	// insert routes when there is no route
+	Seed(routes []Route) error
+}
@@ -0,0 +1,23 @@
+package model
+
+// tenant entity, shared by gateway replicas, seeded from TenantConfig.yaml, token is not stored
+type Tenant struct {
+	ID               uint   `gorm:"primaryKey" json:"id"`
+	TenantId         string `gorm:"size:64;uniqueIndex" json:"tenantId"`
+	TenantName       string `gorm:"size:128" json:"tenantName"`
+	EngineName       string `gorm:"size:16" json:"engineName"`
+	EnginePoolSize   int    `json:"enginePoolSize"`
+	SceneCode        string `gorm:"size:64" json:"sceneCode"`
+	ClientIdentities string `gorm:"size:1024" json:"clientIdentities"` // 逗号分隔
+	BaseModel
+}
+
+// tenant DAO
+type TenantDAO interface {
+	List() ([]Tenant, error)
+	// create or update by tenant id
+	Save(tenant *Tenant) error
+	Delete(tenantId string) error
+	// insert tenants when there is no tenant
+	Seed(tenants []Tenant) error
+}
@@ -0,0 +1,27 @@
+package model
+
+import "time"
+
+// token entity, minted token claims and revocation, token itself is not stored
+type Token struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	Jti       string     `gorm:"size:64;uniqueIndex" json:"jti"`
+	TenantId  string     `gorm:"size:64;index" json:"tenantId"`
+	Engine    string     `gorm:"size:16" json:"engine"`
+	SceneCode string     `gorm:"size:64" json:"sceneCode"`
+	Scopes    string     `gorm:"size:255" json:"scopes"` // 逗号分隔
+	ExpiresAt time.Time  `json:"expiresAt"`
+	Revoked   bool       `json:"revoked"`
+	RevokedAt *time.Time `json:"revokedAt"`
+	BaseModel
+}
+
+// token DAO
+type TokenDAO interface {
+	// tokens of tenant, all when tenant id is empty
+	List(tenantId string) ([]Token, error)
+	Save(token *Token) error
+	// revoke by jti, unknown jti is recorded
+	Revoke(jti string, expiresAt time.Time) error
+	IsRevoked(jti string) (bool, error)
+}
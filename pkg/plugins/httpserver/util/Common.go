@@ -1,11 +1,9 @@
 package util
 
 import (
-	"fmt"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
-	"github.com/spf13/viper"
 )
 
 const (
@@ -15,16 +13,6 @@ const (
 	JOB_ON       = 2
 )
 
-func InitConfig() {
-	viper.SetConfigName("Config")
-	viper.SetConfigType("yaml")
-	viper.AddConfigPath("config/")
-	err := viper.ReadInConfig()
-	if err != nil {
-		panic(fmt.Errorf("fatal error config file: %s", err))
-	}
-}
-
 // Pagination
 type Pagination struct {
 	PageSize        int   `form:"pagesize" json:"pagesize"`
@@ -47,6 +35,8 @@ type Message struct {
 func SendMessage(c *gin.Context, msg Message) {
 	// err return
 	if msg.Err != nil {
+		// 记录到 context, 供审计日志使用
+		c.Error(msg.Err)
 		c.JSON(http.StatusOK, gin.H{
 			"code":    msg.Code,
 			"message": msg.Err.Error(),
@@ -2,14 +2,12 @@ package metrics
 
 import (
 	"encoding/json"
+	"rpc-gateway/pkg/core/config"
 	logging "rpc-gateway/pkg/core/log"
 	"rpc-gateway/pkg/plugins"
-	"rpc-gateway/pkg/plugins/httpserver/util"
 	"rpc-gateway/pkg/plugins/pool/grpc"
 	"strings"
 	"time"
-
-	"github.com/spf13/viper"
 )
 
 type Metricser interface {
@@ -38,6 +36,8 @@ func ASRMetrics() []MetricsData {
 		// json data
 		dataBytes, _ := json.Marshal(map[string]interface{}{
 			"engineName":      mData["engineName"],
+			"sceneCode":       mData["sceneCode"],
+			"tenant":          mData["tenant"],
 			"connCurrent":     mData["connCurrent"],
 			"poolCurrentSize": mData["poolCurrentSize"],
 			"poolSize":        mData["poolSize"],
@@ -85,6 +85,8 @@ func TTSMetrics() []MetricsData {
 		// json data
 		dataBytes, _ := json.Marshal(map[string]interface{}{
 			"engineName":      mData["engineName"],
+			"sceneCode":       mData["sceneCode"],
+			"tenant":          mData["tenant"],
 			"connCurrent":     mData["connCurrent"],
 			"poolCurrentSize": mData["poolCurrentSize"],
 			"poolSize":        mData["poolSize"],
@@ -127,11 +129,9 @@ func (plugin *Plugin) ShowMetrics() {
 		plugin.Status <- false
 	}()
 
-	util.InitConfig()
-
-	if strings.ToLower(viper.GetString("RUN_MODE")) == "dev" {
+	if strings.ToLower(config.Get().App.RunMode) == "dev" {
 		for {
-			logging.Log.Info(ASRMetrics(), TTSMetrics())
+			logging.Log.Info(ASRMetrics(), TTSMetrics(), WebSocketMetrics())
 			time.Sleep(1 * time.Second)
 		}
 	}
@@ -0,0 +1,52 @@
+package metrics
+
+import (
+	"encoding/json"
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+// websocket connections counter
+var wsConnActive, wsConnTotal int64
+
+// websocket close codes counter
+var wsCloseCodes = make(map[int]int64)
+var wsCloseCodesLock sync.Mutex
+
+// websocket connection opened
+func WebSocketConnOpen() {
+	atomic.AddInt64(&wsConnActive, 1)
+	atomic.AddInt64(&wsConnTotal, 1)
+}
+
+// websocket connection closed
+func WebSocketConnClose(code int) {
+	atomic.AddInt64(&wsConnActive, -1)
+	wsCloseCodesLock.Lock()
+	wsCloseCodes[code]++
+	wsCloseCodesLock.Unlock()
+}
+
+// websocket metrics
+func WebSocketMetrics() []MetricsData {
+	closeCodes := make(map[string]int64)
+	wsCloseCodesLock.Lock()
+	for code, count := range wsCloseCodes {
+		closeCodes[strconv.Itoa(code)] = count
+	}
+	wsCloseCodesLock.Unlock()
+
+	dataBytes, _ := json.Marshal(map[string]interface{}{
+		"connCurrent": atomic.LoadInt64(&wsConnActive),
+		"connTotal":   atomic.LoadInt64(&wsConnTotal),
+		"closeCodes":  closeCodes,
+	})
+
+	return []MetricsData{{
+		MetricsName:   "websocketData",
+		MetricsModule: "HTTP",
+		MetricsStatus: true,
+		MetricsData:   string(dataBytes),
+	}}
+}
@@ -0,0 +1,213 @@
+package grpc
+
+import (
+	"net"
+	"rpc-gateway/pkg/core/config"
+	logging "rpc-gateway/pkg/core/log"
+	"rpc-gateway/pkg/plugins/discovery"
+	"rpc-gateway/pkg/plugins/vs/kvs"
+	"strings"
+	"sync"
+	"time"
+)
+
+// endpoint updates are applied one at a time
+var discoveryLock sync.Mutex
+
+// discovered endpoints by engine and source, pools are resized by them when cluster members changed
+var discoveredEndpoints = map[string]map[string][]discovery.Endpoint{"asr": {}, "tts": {}}
+
+// discover engine pools, one pool per ready endpoint of engine services
+func discoverEngines(engineType string, selector map[string]string) {
+	if _, ok := engineSvcPort[engineType]; !ok {
+		return
+	}
+	resync := ENGINE_INIT_POOL_INTERVAL_TIME
+	if interval := enginePoolInitTime[engineType]; interval > 0 {
+		resync = time.Duration(interval) * time.Second
+	}
+	// 等待 kvs 初始化
+	client := kvs.WaitDefault()
+	logging.Log.Info("watching ", engineType, " engine services ", selector)
+	err := client.WatchEngineEndpoints(make(chan struct{}), selector, resync, func(endpoints kvs.ServiceEndpoints) {
+		syncPools(engineType, endpoints.Name, serviceEndpoints(engineType, endpoints))
+	})
+	if err != nil {
+		logging.Log.Error("watch ", engineType, " engine services error: ", err)
+	}
+}
+
+// watch engine endpoints of discoverer
+func watchDiscoverer(engineType string, d discovery.Discoverer) {
+	logging.Log.Info("discovering ", engineType, " engines by ", d.Type())
+	err := d.Watch(make(chan struct{}), func(endpoints []discovery.Endpoint) {
+		syncPools(engineType, d.Type(), endpoints)
+	})
+	if err != nil {
+		logging.Log.Error("discover ", engineType, " engines by ", d.Type(), " error: ", err)
+	}
+}
+
+// ready endpoints of engine service, pool size is the engine concurrency
+func serviceEndpoints(engineType string, endpoints kvs.ServiceEndpoints) []discovery.Endpoint {
+	if endpoints.Service == nil {
+		return nil
+	}
+	meta, err := kvs.ParseEngineService(endpoints.Service, engineType)
+	if err != nil {
+		logging.Log.Warn("discovered ", engineType, " engine service ", endpoints.Name, " skipped: ", err)
+		return nil
+	}
+	if meta.EngineType != engineType {
+		logging.Log.Warn("discovered ", engineType, " engine service ", endpoints.Name, " skipped: engine type is ", meta.EngineType)
+		return nil
+	}
+	list := make([]discovery.Endpoint, 0, len(endpoints.Addresses))
+	for _, ip := range endpoints.Addresses {
+		list = append(list, discovery.Endpoint{
+			Addr:      net.JoinHostPort(ip, engineSvcPort[engineType]),
+			SceneCode: meta.SceneCode,
+			PoolSize:  meta.Concurrency,
+			Tenant:    meta.Tenant,
+		})
+	}
+	return list
+}
+
+// create, resize or drain pools of discovery source by its endpoints
+func syncPools(engineType, source string, endpoints []discovery.Endpoint) {
+	discoveryLock.Lock()
+	defer discoveryLock.Unlock()
+
+	if len(endpoints) == 0 {
+		delete(discoveredEndpoints[engineType], source)
+	} else {
+		discoveredEndpoints[engineType][source] = endpoints
+	}
+	applyEndpoints(engineType, source, endpoints)
+}
+
+// pools of discovery source, pool size is split by cluster node, caller holds discoveryLock
+func applyEndpoints(engineType, source string, endpoints []discovery.Endpoint) {
+	desired := make(map[string]discovery.Endpoint)
+	limits := make(map[string]int)
+	for _, endpoint := range endpoints {
+		limits[endpoint.Addr] = endpoint.PoolSize
+		endpoint.PoolSize = clusterPoolSize(endpoint.PoolSize, endpoint.Addr)
+		// 当前节点未分到并发
+		if endpoint.PoolSize <= 0 {
+			continue
+		}
+		desired[endpoint.Addr] = endpoint
+	}
+
+	// current pools of source, changed pools are drained
+	var drained []*Pool
+	current := make(map[string]*Pool)
+	for _, pool := range poolList(engineType) {
+		if pool.source != source {
+			continue
+		}
+		endpoint, ok := desired[pool.poolRemoteAddr]
+		if ok && pool.capacity == int32(endpoint.PoolSize) && pool.limit == int32(limits[pool.poolRemoteAddr]) &&
+			pool.sceneCode == endpoint.SceneCode && pool.tenant == endpoint.Tenant {
+			current[pool.poolRemoteAddr] = pool
+			continue
+		}
+		drained = append(drained, pool)
+	}
+
+	// new pools
+	engineConfig := discoveryEngineConfig(engineType)
+	created := make([]*Pool, 0)
+	for addr, endpoint := range desired {
+		if _, ok := current[addr]; ok {
+			continue
+		}
+		op := newOptions(engineConfig)
+		op.MaxIdle = endpoint.PoolSize
+		op.MaxActive = endpoint.PoolSize
+		op.GatewayProxyAddr = addr
+		p, err := newGrpcPool(addr, op)
+		if err != nil {
+			logging.Log.Error("new ", engineType, " pool ", addr, " error: ", err)
+			continue
+		}
+		p.poolRemoteAddr = addr
+		p.limit = int32(limits[addr])
+		p.name = source + "/" + addr
+		p.sceneCode = endpoint.SceneCode
+		p.tenant = endpoint.Tenant
+		p.source = source
+		created = append(created, p)
+	}
+	if len(drained) == 0 && len(created) == 0 {
+		return
+	}
+
+	poolsLock.Lock()
+	pools := enginePools(engineType)
+	for _, pool := range drained {
+		delete(pools, pool.name)
+		removeGroupMember(engineType, pool)
+	}
+	for _, pool := range created {
+		pools[pool.name] = pool
+		addGroupMember(engineType, pool)
+	}
+	if len(created) > 0 {
+		setEngineOptions(engineType, newOptions(engineConfig))
+	}
+	poolsLock.Unlock()
+
+	// 正在使用的连接归还时销毁
+	for _, pool := range drained {
+		pool.Close()
+		logging.Log.Info("drain ", engineType, " pool ", pool.name)
+	}
+	for _, pool := range created {
+		logging.Log.Info("create ", engineType, " pool ", pool.name, ", size ", pool.capacity)
+	}
+}
+
+// current engine config, reloaded options apply to new pools
+func discoveryEngineConfig(engineType string) config.PoolEngine {
+	for _, engineConfig := range config.Get().Pool.Engine {
+		if strings.ToLower(engineConfig.EngineName) == engineType {
+			return engineConfig
+		}
+	}
+	return config.PoolEngine{}
+}
+
+// init pools of engine deployed by vs, source is the engine name as service discovery
+// 已由其他来源发现的地址不重复创建连接池, endpoints 为空时回收该引擎的连接池
+func InitPoolFromExistEngineForUpdate(engineType, engineName string, endpoints []discovery.Endpoint) {
+	engineType = strings.ToLower(engineType)
+	discovered := make(map[string]bool)
+	for _, pool := range poolList(engineType) {
+		if pool.source != engineName {
+			discovered[pool.poolRemoteAddr] = true
+		}
+	}
+	list := make([]discovery.Endpoint, 0, len(endpoints))
+	for _, endpoint := range endpoints {
+		if discovered[endpoint.Addr] {
+			logging.Log.Info(engineType, " engine ", endpoint.Addr, " already discovered, skip pool of ", engineName)
+			continue
+		}
+		list = append(list, endpoint)
+	}
+	syncPools(engineType, engineName, list)
+}
+
+// pool names of engine deployed by vs
+func EnginePoolNames(engineType, engineName string) []string {
+	names := make([]string, 0)
+	for _, pool := range poolList(strings.ToLower(engineType)) {
+		if pool.source == engineName {
+			names = append(names, pool.name)
+		}
+	}
+	return names
+}
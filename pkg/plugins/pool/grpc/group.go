@@ -0,0 +1,77 @@
+package grpc
+
+import "math/rand"
+
+// pool group of scene code, one pool per ready engine endpoint
+type PoolGroup struct {
+	sceneCode string
+	members   map[string]*Pool // pool name -> pool
+}
+
+// scene code groups by engine, guarded by poolsLock
+var poolGroups = map[string]map[string]*PoolGroup{"asr": {}, "tts": {}}
+
+func newPoolGroup(sceneCode string) *PoolGroup {
+	return &PoolGroup{sceneCode: sceneCode, members: make(map[string]*Pool)}
+}
+
+// add member, caller holds poolsLock
+func addGroupMember(engineType string, pool *Pool) {
+	groups := poolGroups[engineType]
+	if groups == nil {
+		return
+	}
+	group, ok := groups[pool.sceneCode]
+	if !ok {
+		group = newPoolGroup(pool.sceneCode)
+		groups[pool.sceneCode] = group
+	}
+	group.members[pool.name] = pool
+}
+
+// remove member, empty group is removed, caller holds poolsLock
+func removeGroupMember(engineType string, pool *Pool) {
+	group, ok := poolGroups[engineType][pool.sceneCode]
+	if !ok {
+		return
+	}
+	delete(group.members, pool.name)
+	if len(group.members) == 0 {
+		delete(poolGroups[engineType], pool.sceneCode)
+	}
+}
+
+// balanced member pool of scene code group, false when group not exists
+func acquireGroupPool(engineType, sceneCode string) (*Pool, bool) {
+	poolsLock.RLock()
+	defer poolsLock.RUnlock()
+
+	group, ok := poolGroups[engineType][sceneCode]
+	if !ok {
+		return nil, false
+	}
+	return group.balance(), true
+}
+
+// 负载均衡, 使用率 (连接数/容量) 最低的可用连接池, 相同时随机
+func (group *PoolGroup) balance() *Pool {
+	var candidates []*Pool
+	minUsage := 0.0
+	for _, pool := range group.members {
+		if !pool.status || pool.capacity <= 0 {
+			continue
+		}
+		usage := float64(pool.GetConnCurrent()) / float64(pool.capacity)
+		switch {
+		case len(candidates) == 0 || usage < minUsage:
+			candidates = []*Pool{pool}
+			minUsage = usage
+		case usage == minUsage:
+			candidates = append(candidates, pool)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+	return candidates[rand.Intn(len(candidates))]
+}
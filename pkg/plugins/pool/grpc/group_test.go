@@ -0,0 +1,177 @@
+package grpc
+
+import (
+	"context"
+	"testing"
+
+	"rpc-gateway/pkg/core/config"
+	logging "rpc-gateway/pkg/core/log"
+	"rpc-gateway/pkg/plugins/auth"
+	"rpc-gateway/pkg/plugins/discovery"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// member pool of test, idle clients without connections
+func groupPool(name string, capacity, idle int, ready bool) *Pool {
+	pool := &Pool{name: name, sceneCode: "s1", clients: make(chan *Client, capacity), capacity: int32(capacity), status: ready}
+	for i := 0; i < idle; i++ {
+		pool.clients <- &Client{pool: pool}
+	}
+	return pool
+}
+
+func testGroup(pools ...*Pool) *PoolGroup {
+	group := newPoolGroup("s1")
+	for _, pool := range pools {
+		group.members[pool.name] = pool
+	}
+	return group
+}
+
+// replace pool groups of test
+func resetPoolGroups(t *testing.T) {
+	old := poolGroups
+	poolGroups = map[string]map[string]*PoolGroup{"asr": {}, "tts": {}}
+	t.Cleanup(func() { poolGroups = old })
+}
+
+func TestPoolGroupBalance(t *testing.T) {
+	cases := []struct {
+		name    string
+		members []*Pool
+		want    string
+	}{
+		{"empty", nil, ""},
+		{"lowest usage", []*Pool{groupPool("a", 4, 1, true), groupPool("b", 2, 2, true), groupPool("c", 10, 5, true)}, "b"},
+		{"usage by capacity", []*Pool{groupPool("a", 4, 2, true), groupPool("b", 10, 6, true)}, "b"},
+		{"skip not ready", []*Pool{groupPool("a", 4, 1, true), groupPool("b", 2, 2, false)}, "a"},
+		{"skip zero capacity", []*Pool{groupPool("a", 4, 1, true), groupPool("b", 0, 0, true)}, "a"},
+		{"all not ready", []*Pool{groupPool("a", 4, 4, false), groupPool("b", 2, 2, false)}, ""},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := testGroup(tc.members...).balance()
+			if tc.want == "" {
+				if got != nil {
+					t.Errorf("balance = %s, want nil", got.name)
+				}
+				return
+			}
+			if got == nil || got.name != tc.want {
+				t.Errorf("balance = %v, want %s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestPoolGroupBalanceTie(t *testing.T) {
+	group := testGroup(groupPool("a", 4, 2, true), groupPool("b", 2, 1, true), groupPool("c", 4, 0, true))
+	picked := make(map[string]int)
+	for i := 0; i < 200; i++ {
+		picked[group.balance().name]++
+	}
+	// 使用率相同时随机选择
+	if picked["a"] == 0 || picked["b"] == 0 || picked["c"] != 0 {
+		t.Errorf("picked %v, want a and b", picked)
+	}
+}
+
+func TestRemoveGroupMember(t *testing.T) {
+	resetPoolGroups(t)
+	a, b := groupPool("a", 2, 2, true), groupPool("b", 2, 2, true)
+	other := groupPool("c", 2, 2, true)
+	other.sceneCode = "s2"
+	for _, pool := range []*Pool{a, b, other} {
+		addGroupMember("asr", pool)
+	}
+	addGroupMember("nlp", a)
+	if len(poolGroups["asr"]) != 2 || len(poolGroups["asr"]["s1"].members) != 2 {
+		t.Fatalf("groups = %v", poolGroups["asr"])
+	}
+
+	removeGroupMember("asr", a)
+	if pool, ok := acquireGroupPool("asr", "s1"); !ok || pool != b {
+		t.Errorf("acquireGroupPool after remove = %v %v, want b", pool, ok)
+	}
+	// 非成员和未知引擎
+	removeGroupMember("asr", a)
+	removeGroupMember("tts", b)
+	removeGroupMember("asr", b)
+	if _, ok := poolGroups["asr"]["s1"]; ok {
+		t.Error("empty group not removed")
+	}
+	if _, ok := acquireGroupPool("asr", "s1"); ok {
+		t.Error("removed group acquired")
+	}
+	if _, ok := acquireGroupPool("asr", "s2"); !ok {
+		t.Error("group of other scene code removed")
+	}
+}
+
+func TestNotReadyEndpointLeavesGroup(t *testing.T) {
+	logging.Log = zap.NewNop().Sugar()
+	resetPoolGroups(t)
+	cfg := &config.Configs{}
+	cfg.Pool.Engine = []config.PoolEngine{{EngineName: "ASR", RequestIdleTime: 10}}
+	config.Set(cfg)
+	t.Cleanup(func() { syncPools("asr", "engine-a", nil) })
+
+	ready := []discovery.Endpoint{
+		{Addr: "127.0.0.1:9", SceneCode: "s1", PoolSize: 1},
+		{Addr: "127.0.0.2:9", SceneCode: "s1", PoolSize: 1},
+	}
+	syncPools("asr", "engine-a", ready)
+	if group := poolGroups["asr"]["s1"]; group == nil || len(group.members) != 2 {
+		t.Fatalf("group = %+v, want 2 members", group)
+	}
+	drained := poolGroups["asr"]["s1"].members["engine-a/127.0.0.2:9"]
+
+	// 127.0.0.2 NotReady, 不在 ready endpoints 中
+	syncPools("asr", "engine-a", ready[:1])
+	group := poolGroups["asr"]["s1"]
+	if len(group.members) != 1 || group.members["engine-a/127.0.0.1:9"] == nil {
+		t.Errorf("members = %v, want 127.0.0.1 only", group.members)
+	}
+	if drained == nil || !drained.IsClose() {
+		t.Error("not ready pool not closed")
+	}
+
+	syncPools("asr", "engine-a", nil)
+	if _, ok := acquireGroupPool("asr", "s1"); ok {
+		t.Error("group of no ready endpoints not removed")
+	}
+}
+
+func TestAcquireEngineClientEmptyGroup(t *testing.T) {
+	logging.Log = zap.NewNop().Sugar()
+	resetPoolGroups(t)
+	cfg := &config.Configs{}
+	cfg.Tokens = config.TokenConfig{
+		ActiveKid: "k1",
+		TTL:       3600,
+		MaxTTL:    86400,
+		Keys:      []config.TokenKey{{Kid: "k1", Secret: "0123456789abcdef0123456789abcdef"}},
+	}
+	config.Set(cfg)
+	OMPEnabled = true
+	t.Cleanup(func() { OMPEnabled = false })
+
+	token, _, err := auth.Mint(auth.Claims{Engine: "asr", SceneCode: "s1", Scopes: []string{auth.SCOPE_INVOKE}}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	md := metadata.Pairs("token", token)
+	if _, err := AcquireEngineClient(context.Background(), "asr", md); status.Code(err) != codes.Unimplemented {
+		t.Errorf("unknown group error = %v, want Unimplemented", err)
+	}
+
+	// 组内没有可用成员
+	addGroupMember("asr", groupPool("a", 2, 2, false))
+	if _, err := AcquireEngineClient(context.Background(), "asr", md); status.Code(err) != codes.Unavailable {
+		t.Errorf("empty group error = %v, want Unavailable", err)
+	}
+}
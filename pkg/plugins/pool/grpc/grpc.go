@@ -2,19 +2,21 @@ package grpc
 
 import (
 	"context"
-	"encoding/base64"
-	"fmt"
 	"log"
 	"net"
 	"rpc-gateway/pkg/core/common"
+	"rpc-gateway/pkg/core/config"
 	logging "rpc-gateway/pkg/core/log"
 	"rpc-gateway/pkg/plugins"
-	"rpc-gateway/pkg/plugins/vs/kvs"
-	"strconv"
+	"rpc-gateway/pkg/plugins/auth"
+	"rpc-gateway/pkg/plugins/cluster"
+	"rpc-gateway/pkg/plugins/discovery"
+	"rpc-gateway/pkg/plugins/semaphore"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
-	"github.com/spf13/viper"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/keepalive"
 	"google.golang.org/grpc/reflection"
@@ -23,9 +25,6 @@ import (
 // pool
 type Plugin plugins.Plugin
 
-// config viper
-var routerViper *viper.Viper
-
 // pools map
 var asrPools, ttsPools = make(map[string]*Pool), make(map[string]*Pool)
 
@@ -35,13 +34,22 @@ var asrOptions, ttsOptions Options
 // tencants pools map
 var tenants map[string]map[string]*Tenant
 
+// pools and tenants lock, maps are swapped on reload
+var poolsLock sync.RWMutex
+
 // failed pool status
 var failedPoolStatus bool = false
 
+// InitGrpcPool finished
+var poolInitialized int32
+
+// cluster members changed before InitGrpcPool finished
+var resizePending int32
+
 // engines service port map
 var engineSvcPort = make(map[string]string)
 
-// init engine from exist interval time
+// engine discovery resync interval
 var enginePoolInitTime = make(map[string]int)
 
 // engine label keys
@@ -75,98 +83,80 @@ var (
 // init grpc pool
 func InitGrpcPool() {
 	// get config
-	routerViper = viper.New()
-	routerViper.SetConfigName("PoolConfig")
-	routerViper.SetConfigType("yaml")
-	routerViper.AddConfigPath("config/")
-	err := routerViper.ReadInConfig()
-	if err != nil {
-		panic(fmt.Errorf("fatal error get pool config file: %s", err))
-	}
-
-	rvRoot := routerViper.AllSettings()["pool"]
-	engines := rvRoot.(map[string]interface{})["engine"]
-	setting := rvRoot.(map[string]interface{})["setting"].(map[string]interface{})
+	poolConfig := config.Get().Pool
+	setting := poolConfig.Setting
 	// enabled
-	if !setting[strings.ToLower("ENABLED")].(bool) {
+	if !setting.Enabled {
 		return
 	}
 
-	DialTimeout = time.Duration(setting[strings.ToLower("DIAL_TIMEOUT")].(int)) * time.Second
-	BackoffMaxDelay = time.Duration(setting[strings.ToLower("BACKOFF_MAX_DELAY")].(int)) * time.Second
-	KeepAliveTime = time.Duration(setting[strings.ToLower("KEEPALIVE_TIME")].(int)) * time.Second
-	KeepAliveTimeout = time.Duration(setting[strings.ToLower("KEEPALIVE_TIMEOUT")].(int)) * time.Second
-	OMPEnabled = setting[strings.ToLower("OMP_ENABLED")].(bool)
-	TenantEnabled = setting[strings.ToLower("TENANT_ENABLED")].(bool)
-	NetWorkMode = setting[strings.ToLower("NETWORK_MODE")].(int)
-	GatewayProxyAddr = setting[strings.ToLower("GATEWAY_PROXY_ADDR")].(string)
-	engineSvcSelectorKey = setting[strings.ToLower("ENGINE_SERVICE_SELECTOR_KEY")].(string)
-	asrEngineSvcSelectorVal = setting[strings.ToLower("ASR_ENGINE_SERVICE_SELECTOR_VALUE")].(string)
-	ttsEngineSvcSelectorVal = setting[strings.ToLower("TTS_ENGINE_SERVICE_SELECTOR_VALUE")].(string)
-	engineClusterEnabled = setting[strings.ToLower("CLUSTER_ENABLED")].(bool)
-	engineClusterNodeNum = setting[strings.ToLower("CLUSTER_NODE_NUM")].(int)
-	// engine init
-	for _, v := range engines.([]interface{}) {
-		vMap := v.(map[interface{}]interface{})
-		op := Options{
-			Dial:      grpcDial,
-			PoolModel: vMap["POOL_MODEL"].(int),
-			// MaxIdle:              vMap["ENGINE_GRPC_POOL_SIZE"].(int),
-			// MaxActive:            vMap["ENGINE_GRPC_POOL_SIZE"].(int),
-			MaxConcurrentStreams: 0,
-			Reusable:             vMap["GRPC_REQUEST_REUSABLE"].(bool),
-			RequestIdleTime:      vMap["REQUEST_IDLE_TIME"].(int),
-			RequestMaxLife:       vMap["REQUEST_MAX_LIFE"].(int),
-			RequestTimeOut:       vMap["REQUEST_TIMEOUT"].(int),
-			GatewayProxyAddr:     GatewayProxyAddr,
-			GatewayProxyPort:     vMap["GATEWAY_PROXY_PORT"].(string),
-			PoolStatus:           vMap["POOL_ENABLED"].(bool),
+	DialTimeout = time.Duration(setting.DialTimeout) * time.Second
+	BackoffMaxDelay = time.Duration(setting.BackoffMaxDelay) * time.Second
+	KeepAliveTime = time.Duration(setting.KeepaliveTime) * time.Second
+	KeepAliveTimeout = time.Duration(setting.KeepaliveTimeout) * time.Second
+	OMPEnabled = setting.OMPEnabled
+	TenantEnabled = setting.TenantEnabled
+	NetWorkMode = setting.NetworkMode
+	GatewayProxyAddr = setting.GatewayProxyAddr
+	engineSvcSelectorKey = setting.EngineServiceSelectorKey
+	asrEngineSvcSelectorVal = setting.AsrEngineServiceSelectorValue
+	ttsEngineSvcSelectorVal = setting.TtsEngineServiceSelectorValue
+	engineClusterEnabled = setting.ClusterEnabled
+	engineClusterNodeNum = setting.ClusterNodeNum
+	// 节点注册, 节点变化时重新划分连接池大小
+	if setting.ClusterEnabled && !strings.EqualFold(setting.ClusterMembership, cluster.MEMBERSHIP_STATIC) {
+		if err := cluster.Start(setting, resizePools); err != nil {
+			logging.Log.Error("cluster membership error, pool size is split by CLUSTER_NODE_NUM: ", err)
 		}
+	}
+	// engine init
+	for _, engineConfig := range poolConfig.Engine {
+		op := newOptions(engineConfig)
+		op.GatewayProxyAddr = GatewayProxyAddr
 
 		// get pool enabl
-		if !vMap["POOL_ENABLED"].(bool) {
+		if !engineConfig.PoolEnabled {
 			continue
 		}
-		// engine server port
-		serverPort := vMap["ENGINE_SERVER_PORT"].(string)
-		// engine init pool interval time
-		poolInitIntervalTime := vMap["ENGINE_POOL_INIT_INTERVAL_TIME"].(int)
+		engineName := strings.ToLower(engineConfig.EngineName)
 		// init server port map
-		if strings.ToLower(vMap["ENGINE_NAME"].(string)) == "asr" {
-			engineSvcPort["asr"] = serverPort
-			enginePoolInitTime["asr"] = poolInitIntervalTime
-		} else if strings.ToLower(vMap["ENGINE_NAME"].(string)) == "tts" {
-			engineSvcPort["tts"] = serverPort
-			enginePoolInitTime["tts"] = poolInitIntervalTime
-		}
+		engineSvcPort[engineName] = engineConfig.EngineServerPort
+		enginePoolInitTime[engineName] = engineConfig.EnginePoolInitIntervalTime
 		// 如果开启 OMP 在线配置则不会读取 config.engine 中的配置
 		if OMPEnabled {
 			continue
 		}
+		d, err := discovery.New(engineConfig)
+		if err != nil {
+			logging.Log.Error("engine discovery error: ", err)
+			continue
+		}
+		static, ok := d.(*discovery.Static)
+		// dns, file, http, docker 发现的连接池异步创建
+		if !ok {
+			poolsLock.Lock()
+			setEngineOptions(engineName, op)
+			poolsLock.Unlock()
+			go watchDiscoverer(engineName, d)
+			continue
+		}
 		// engine list
-		for _, engine := range vMap["ENGINE_LIST"].([]interface{}) {
+		for _, engine := range static.Endpoints() {
 			xid := common.GenXid()
-			eMap := engine.(map[interface{}]interface{})
 			// asr server address
-			serverAddr := eMap["SERVER_HOST"].(string) + ":" + serverPort
+			serverAddr := engine.Addr
+			// setting pool size
+			op.MaxIdle = clusterPoolSize(engine.PoolSize, serverAddr)
+			op.MaxActive = op.MaxIdle
+			if op.MaxIdle <= 0 {
+				logging.Log.Info(engineName, " engine ", serverAddr, " has no share on this node, pool skipped")
+				continue
+			}
 			// check grpc server status
 			checkSerStatus := checkGRPCSerer(serverAddr)
 			if !checkSerStatus {
 				logging.Log.Error("grpc server connect failed !")
 			}
-			// setting pool size
-			op.MaxIdle = eMap["ENGINE_GRPC_POOL_SIZE"].(int)
-			op.MaxActive = eMap["ENGINE_GRPC_POOL_SIZE"].(int)
-			// cluster setting
-			if engineClusterEnabled {
-				if op.MaxIdle%2 == 0 {
-					op.MaxIdle = eMap["ENGINE_GRPC_POOL_SIZE"].(int) / engineClusterNodeNum
-					op.MaxActive = eMap["ENGINE_GRPC_POOL_SIZE"].(int) / engineClusterNodeNum
-				} else {
-					op.MaxIdle = eMap["ENGINE_GRPC_POOL_SIZE"].(int)/engineClusterNodeNum + 1
-					op.MaxActive = eMap["ENGINE_GRPC_POOL_SIZE"].(int)/engineClusterNodeNum + 1
-				}
-			}
 			// new pool
 			p, err := newGrpcPool(serverAddr, op)
 			if err != nil {
@@ -174,44 +164,22 @@ func InitGrpcPool() {
 			}
 			// setting remote addr
 			p.poolRemoteAddr = serverAddr
+			p.limit = int32(engine.PoolSize)
 			p.name = xid
 			// new pool by engine
-			if strings.ToLower(vMap["ENGINE_NAME"].(string)) == "asr" {
-				asrOptions = op
-				if NetWorkMode == STRICT_NETWORK_MODE {
-					ASRGatewayProxyAddr = GatewayProxyAddr + ":" + asrOptions.GatewayProxyPort
-				} else if NetWorkMode == GLOBAL_NETWORK_MODE {
-					ASRGatewayProxyAddr = asrOptions.GatewayProxyPort
-				}
-				// asrPools = append(asrPools, p)
-				asrPools[xid] = p
-			} else if strings.ToLower(vMap["ENGINE_NAME"].(string)) == "tts" {
-				ttsOptions = op
-				if NetWorkMode == STRICT_NETWORK_MODE {
-					TTSGatewayProxyAddr = GatewayProxyAddr + ":" + ttsOptions.GatewayProxyPort
-				} else if NetWorkMode == GLOBAL_NETWORK_MODE {
-					TTSGatewayProxyAddr = ttsOptions.GatewayProxyPort
-				}
-				// ttsPools = append(ttsPools, p)
-				ttsPools[xid] = p
+			poolsLock.Lock()
+			setEngineOptions(engineName, op)
+			if pools := enginePools(engineName); pools != nil {
+				pools[xid] = p
 			}
+			poolsLock.Unlock()
 		}
 	} // end init pool
-	// init pool from exist engine
+	// discover engine pools from k8s services and endpoint slices
 	if OMPEnabled {
 		logging.Log.Info("starting engine discovering ...")
-		go initPoolFromExistEngine(map[string]interface{}{
-			"engineType":          "asr",
-			"engineSelectorKey":   engineSvcSelectorKey,
-			"engineSelectorValue": asrEngineSvcSelectorVal,
-		})
-
-		go initPoolFromExistEngine(map[string]interface{}{
-			"engineType":          "tts",
-			"engineSelectorKey":   engineSvcSelectorKey,
-			"engineSelectorValue": ttsEngineSvcSelectorVal,
-		})
-
+		go discoverEngines("asr", map[string]string{engineSvcSelectorKey: asrEngineSvcSelectorVal})
+		go discoverEngines("tts", map[string]string{engineSvcSelectorKey: ttsEngineSvcSelectorVal})
 	} else if TenantEnabled {
 		// multi tenant support
 		initPoolForTenant()
@@ -220,297 +188,135 @@ func InitGrpcPool() {
 	// check server pool healthz
 	go checkAsrGRPCSererHealthTask()
 	go checkTtsGRPCSererHealthTask()
+	// hot reload pool and tenant config
+	watchPoolConfig()
+	// tenants shared by replicas
+	watchTenantInventory()
+	// tenant and engine concurrency shared by gateways
+	semaphore.Init(setting.Limiter)
+	atomic.StoreInt32(&poolInitialized, 1)
+	// 初始化期间节点变化
+	if atomic.CompareAndSwapInt32(&resizePending, 1, 0) {
+		go resizePools()
+	}
 }
 
-// init grpc pool by omp
-func InitGrpcPoolByOmp(data map[string]interface{}) {
-	rvRoot := routerViper.AllSettings()["pool"]
-	engines := rvRoot.(map[string]interface{})["engine"]
-	eType := data["engineType"].(string)
-	// init pool
-	for _, v := range engines.([]interface{}) {
-		vMap := v.(map[interface{}]interface{})
-		engineType := strings.ToLower(vMap["ENGINE_NAME"].(string))
-		if engineType != eType {
-			continue
-		}
-		// server port
-		serverPort := vMap["ENGINE_SERVER_PORT"].(string)
-		// server addr
-		serverAddr := data["serverHost"].(string) + ":" + serverPort
-		// get engine concurrent num
-		engineCCNum := data["engineCCNum"].(int)
-		if engineClusterEnabled {
-			if engineCCNum%2 == 0 {
-				engineCCNum = engineCCNum / engineClusterNodeNum
-			} else {
-				engineCCNum = engineCCNum/engineClusterNodeNum + 1
-			}
-		}
-		// option setting
-		op := Options{
-			Dial:                 grpcDial,
-			PoolModel:            vMap["POOL_MODEL"].(int),
-			MaxIdle:              data["engineCCNum"].(int),
-			MaxActive:            data["engineCCNum"].(int),
-			MaxConcurrentStreams: 0,
-			Reusable:             vMap["GRPC_REQUEST_REUSABLE"].(bool),
-			RequestIdleTime:      vMap["REQUEST_IDLE_TIME"].(int),
-			RequestMaxLife:       vMap["REQUEST_MAX_LIFE"].(int),
-			RequestTimeOut:       vMap["REQUEST_TIMEOUT"].(int),
-			GatewayProxyAddr:     serverAddr,
-			GatewayProxyPort:     vMap["GATEWAY_PROXY_PORT"].(string),
-			PoolStatus:           vMap["POOL_ENABLED"].(bool),
-		}
-		// create pool
-		p, err := newGrpcPool(serverAddr, op)
-		if err != nil {
-			log.Fatalf("failed to new pool: %v", err)
-		}
-		// setting pool remote addr
-		p.poolRemoteAddr = serverAddr
-		p.name = data["sceneCode"].(string)
-		// append pools
-		if engineType == "asr" {
-			asrOptions = op
-			if NetWorkMode == STRICT_NETWORK_MODE {
-				ASRGatewayProxyAddr = GatewayProxyAddr + ":" + asrOptions.GatewayProxyPort
-			} else if NetWorkMode == GLOBAL_NETWORK_MODE {
-				ASRGatewayProxyAddr = asrOptions.GatewayProxyPort
-			}
-			// asrPools = append(asrPools, p)
-			asrPools[p.name] = p
-		} else if engineType == "tts" {
-			ttsOptions = op
-			if NetWorkMode == STRICT_NETWORK_MODE {
-				TTSGatewayProxyAddr = GatewayProxyAddr + ":" + ttsOptions.GatewayProxyPort
-			} else if NetWorkMode == GLOBAL_NETWORK_MODE {
-				TTSGatewayProxyAddr = ttsOptions.GatewayProxyPort
-			}
-			// ttsPools = append(ttsPools, p)
-			ttsPools[p.name] = p
+// pool init finished, omp engine pools are discovered later
+func PoolInitialized() bool {
+	return atomic.LoadInt32(&poolInitialized) == 1
+}
+
+// healthy and total pools of engine
+func PoolHealth(engineType string) (int, int) {
+	pools := poolList(engineType)
+	healthy := 0
+	for _, pool := range pools {
+		if pool.status {
+			healthy++
 		}
 	}
+	return healthy, len(pools)
 }
 
-// init pool form tencent
-func initPoolForTenant() {
-	// multi tenant support
-	if TenantEnabled {
-		tenants = make(map[string]map[string]*Tenant)
-		tenants = initTenantPool(map[string]interface{}{
-			"asr": asrPools,
-			"tts": ttsPools,
-		})
+// pool size of one node when cluster enabled, key is the engine address
+// 节点由 redis 或 lease 注册时按节点数划分, 所有节点之和等于引擎并发, 否则按 CLUSTER_NODE_NUM 划分
+func clusterPoolSize(size int, key string) int {
+	if !engineClusterEnabled {
+		return size
+	}
+	if share, ok := cluster.Share(size, key); ok {
+		return share
+	}
+	if engineClusterNodeNum <= 0 {
+		return size
 	}
+	if size%2 == 0 {
+		return size / engineClusterNodeNum
+	}
+	return size/engineClusterNodeNum + 1
 }
 
-// init pool from exist engine
-func initPoolFromExistEngine(data map[string]interface{}) {
-	for {
-		// get engine svcs
-		engineType := data["engineType"].(string)
-		logging.Log.Info("init " + engineType + " engine pool from exists engine ...")
-		engineSvcs := kvs.GetEngineSvcs(map[string]string{
-			data["engineSelectorKey"].(string): data["engineSelectorValue"].(string),
-		})
-		// range svcs
-		for _, svc := range engineSvcs.Items {
-			liveSvcStatus := checkGRPCSerer(svc.Name + ":" + engineSvcPort[engineType])
-			if liveSvcStatus {
-				data := strings.Split(svc.Name, "-")
-				if len(data) == 3 {
-					engineType := data[0]
-					engineCCNum := data[1]
-					sceneCode := data[2]
-					engineName := sceneCode
-					eCCNum, errECCNum := strconv.Atoi(engineCCNum)
-					if errECCNum != nil {
-						continue
-					}
-					// if engine exists
-					switch engineType {
-					case "asr":
-						if _, ok := asrPools[engineName]; ok {
-							if asrPools[engineName].capacity == int32(eCCNum) {
-								// if eq continue or release pool
-								continue
-							} else {
-								ReleaseGrpcPool(engineName, "asr")
-							}
-
-						}
-					case "tts":
-						if _, ok := ttsPools[engineName]; ok {
-							if ttsPools[engineName].capacity == int32(eCCNum) {
-								// if eq continue or release pool
-								continue
-							} else {
-								ReleaseGrpcPool(engineName, "tts")
-							}
-						}
-					}
-					// get engine
-					serverName := strings.ToLower(engineType) + "-" + engineCCNum + "-" + sceneCode
-					ecNum, errEcNum := strconv.Atoi(engineCCNum)
-					if errEcNum != nil {
-						logging.Log.Error("init pool from exist engine strconv error:", errEcNum)
-						return
-					}
-
-					podIp := ""
-					endPoints, _ := kvs.GetEndpointsByName(serverName)
-					for _, endPoint := range endPoints.Subsets {
-						for _, ip := range endPoint.Addresses {
-							podIp = ip.IP
-						}
-					}
+// pools of engine, caller holds poolsLock
+func enginePools(engineType string) map[string]*Pool {
+	switch engineType {
+	case "asr":
+		return asrPools
+	case "tts":
+		return ttsPools
+	}
+	return nil
+}
 
-					if podIp == "" {
-						continue
-					}
+// pools snapshot of engine
+func poolList(engineType string) []*Pool {
+	poolsLock.RLock()
+	defer poolsLock.RUnlock()
 
-					// get engine pods
-					// pods := kvs.GetEnginePods(map[string]string{
-					// 	"engineName": engineName,
-					// })
-
-					// for _, pod := range pods.Items {
-					// podReadyStats := false
-					realServerHost := podIp + ":" + engineSvcPort[engineType]
-					livePodStatus := checkGRPCSerer(realServerHost)
-					// podStatus := string(pod.Status.Phase)
-					// for _, condition := range pod.Status.Conditions {
-					// 	if condition.Type == "Ready" {
-					// 		if condition.Status == "True" && podStatus == "Running" {
-					// 			podReadyStats = true
-					// 		}
-					// 	}
-					// }
-					if livePodStatus {
-						// logging.Log.Info("discovered " + engineType + " engine pod (" + pod.Name + "), will init pool later ...")
-						// init pool
-						InitGrpcPoolByOmp(map[string]interface{}{
-							"engineType":  engineType,
-							"engineCCNum": ecNum,
-							"sceneCode":   sceneCode,
-							"serverHost":  podIp,
-							"serverName":  serverName,
-						})
-						logging.Log.Info("init pool ", svc.Name, " finish ...")
-					}
-					// }
-				}
-			} else {
-				logging.Log.Info("discovered " + engineType + " engine svc, but svc is unavailable ...")
-			}
-		}
-		// setting sleep inverval time
-		if time.Duration(enginePoolInitTime[engineType]) == 0 {
-			time.Sleep(ENGINE_INIT_POOL_INTERVAL_TIME)
-		} else {
-			time.Sleep(time.Duration(enginePoolInitTime[engineType]) * time.Second)
-		}
+	pools := make([]*Pool, 0, len(enginePools(engineType)))
+	for _, pool := range enginePools(engineType) {
+		pools = append(pools, pool)
 	}
+	return pools
 }
 
-// init pool from exist engine
-func InitPoolFromExistEngineForUpdate(data map[string]interface{}) {
-	// for {
-	// get engine svcs
-	engineType := data["engineType"].(string)
-	logging.Log.Info("init " + engineType + " engine pool from exists engine ...")
-	if engineType == "asr" {
-		data["engineSelectorKey"] = engineSvcSelectorKey
-		data["engineSelectorValue"] = asrEngineSvcSelectorVal
-	} else if engineType == "tts" {
-		data["engineSelectorKey"] = engineSvcSelectorKey
-		data["engineSelectorValue"] = ttsEngineSvcSelectorVal
-	}
-	engineSvcs := kvs.GetEngineSvcs(map[string]string{
-		data["engineSelectorKey"].(string): data["engineSelectorValue"].(string),
-	})
-	// range svcs
-	for _, svc := range engineSvcs.Items {
-		liveSvcStatus := checkGRPCSerer(svc.Name + ":" + engineSvcPort[engineType])
-		if liveSvcStatus {
-			data := strings.Split(svc.Name, "-")
-			if len(data) == 3 {
-				engineType := data[0]
-				engineCCNum := data[1]
-				sceneCode := data[2]
-				engineName := sceneCode
-				eCCNum, errECCNum := strconv.Atoi(engineCCNum)
-				if errECCNum != nil {
-					continue
-				}
-				// if engine exists
-				switch engineType {
-				case "asr":
-					if _, ok := asrPools[engineName]; ok {
-						if asrPools[engineName].capacity == int32(eCCNum) {
-							// release pool
-							ReleaseGrpcPool(engineName, "asr")
-						}
-					}
-				case "tts":
-					if _, ok := ttsPools[engineName]; ok {
-						if ttsPools[engineName].capacity == int32(eCCNum) {
-							// release pool
-							ReleaseGrpcPool(engineName, "tts")
-						}
-					}
-				}
-				// get engine
-				serverName := strings.ToLower(engineType) + "-" + engineCCNum + "-" + sceneCode
-				ecNum, errEcNum := strconv.Atoi(engineCCNum)
-				if errEcNum != nil {
-					logging.Log.Error("init pool from exist engine strconv error:", errEcNum)
-					return
-				}
-				// get engine pods
-				// pods := kvs.GetEnginePods(map[string]string{
-				// 	"engineName": engineName,
-				// })
-
-				podIp := ""
-				endPoints, _ := kvs.GetEndpointsByName(serverName)
-				for _, endPoint := range endPoints.Subsets {
-					for _, ip := range endPoint.Addresses {
-						podIp = ip.IP
-					}
-				}
+// tenants snapshot of engine
+func tenantList(engineType string) []*Tenant {
+	poolsLock.RLock()
+	defer poolsLock.RUnlock()
 
-				if podIp == "" {
-					continue
-				}
+	list := make([]*Tenant, 0, len(tenants[engineType]))
+	for _, tenant := range tenants[engineType] {
+		list = append(list, tenant)
+	}
+	return list
+}
 
-				realServerHost := podIp + ":" + engineSvcPort[engineType]
-				livePodStatus := checkGRPCSerer(realServerHost)
-				if livePodStatus {
-					// logging.Log.Info("discovered " + engineType + " engine pod (" + pod.Name + "), will init pool later ...")
-					// init pool
-					InitGrpcPoolByOmp(map[string]interface{}{
-						"engineType":  engineType,
-						"engineCCNum": ecNum,
-						"sceneCode":   sceneCode,
-						"serverHost":  podIp,
-						"serverName":  serverName,
-					})
-					logging.Log.Info("init pool ", svc.Name, " finish ...")
-				}
-			}
-		} else {
-			logging.Log.Info("discovered " + engineType + " engine svc, but svc is unavailable ...")
-		}
+// setting engine options and gateway proxy addr, caller holds poolsLock
+func setEngineOptions(engineType string, op Options) {
+	proxyAddr := ""
+	if NetWorkMode == STRICT_NETWORK_MODE {
+		proxyAddr = GatewayProxyAddr + ":" + op.GatewayProxyPort
+	} else if NetWorkMode == GLOBAL_NETWORK_MODE {
+		proxyAddr = op.GatewayProxyPort
+	}
+	switch engineType {
+	case "asr":
+		asrOptions = op
+		ASRGatewayProxyAddr = proxyAddr
+	case "tts":
+		ttsOptions = op
+		TTSGatewayProxyAddr = proxyAddr
+	}
+}
+
+// pool options from engine config
+func newOptions(engineConfig config.PoolEngine) Options {
+	return Options{
+		Dial:                 engineDial(engineConfig.TLS),
+		PoolModel:            engineConfig.PoolModel,
+		MaxConcurrentStreams: 0,
+		Reusable:             engineConfig.GrpcRequestReusable,
+		RequestIdleTime:      engineConfig.RequestIdleTime,
+		RequestMaxLife:       engineConfig.RequestMaxLife,
+		RequestTimeOut:       engineConfig.RequestTimeout,
+		GatewayProxyPort:     engineConfig.GatewayProxyPort,
+		PoolStatus:           engineConfig.PoolEnabled,
+		TLS:                  engineConfig.TLS,
 	}
-	// setting sleep inverval time
-	if time.Duration(enginePoolInitTime[engineType]) == 0 {
-		time.Sleep(ENGINE_INIT_POOL_INTERVAL_TIME)
-	} else {
-		time.Sleep(time.Duration(enginePoolInitTime[engineType]) * time.Second)
+}
+
+// init pool form tencent
+func initPoolForTenant() {
+	// multi tenant support
+	if TenantEnabled {
+		poolsLock.Lock()
+		defer poolsLock.Unlock()
+		tenants = initTenantPool(config.Get().Tenants, map[string]interface{}{
+			"asr": asrPools,
+			"tts": ttsPools,
+		})
+		setTenantLent(map[string]map[string]*Pool{"asr": asrPools, "tts": ttsPools}, tenants)
 	}
-	// }
 }
 
 // rpc server
@@ -548,6 +354,9 @@ func (plugin *Plugin) GRPCServer() {
 // new grpc pool
 func newGrpcPool(address string, option Options) (*Pool, error) {
 	dial := func() (*grpc.ClientConn, error) {
+		if option.Dial != nil {
+			return option.Dial(address)
+		}
 		return grpcDial(address)
 	}
 
@@ -561,34 +370,56 @@ func newGrpcPool(address string, option Options) (*Pool, error) {
 		time.Duration(option.RequestTimeOut)*time.Second,
 		option.PoolModel,
 	)
+	if gp != nil {
+		gp.tls = option.TLS
+	}
 	return gp, err
 }
 
 // release grpc pool
 func ReleaseGrpcPool(poolName, engineType string) {
-	switch strings.ToLower(engineType) {
-	case "asr":
-		if _, ok := asrPools[poolName]; ok {
-			asrPools[poolName].Close()
-			delete(asrPools, poolName)
-		}
-	case "tts":
-		if _, ok := ttsPools[poolName]; ok {
-			ttsPools[poolName].Close()
-			delete(ttsPools, poolName)
-		}
+	poolsLock.Lock()
+	pools := enginePools(strings.ToLower(engineType))
+	pool, ok := pools[poolName]
+	if ok {
+		delete(pools, poolName)
+		removeGroupMember(strings.ToLower(engineType), pool)
+	}
+	poolsLock.Unlock()
+	if ok {
+		pool.Close()
 	}
 
 	logging.Log.Info("delete ", engineType, " pool ", poolName, " success")
 }
 
+// engine dial, tls when engine tls enabled
+func engineDial(engineTLS config.EngineTLS) func(address string) (*grpc.ClientConn, error) {
+	if !engineTLS.Enabled {
+		return grpcDial
+	}
+	return func(address string) (*grpc.ClientConn, error) {
+		creds, err := auth.EngineCredentials(engineTLS)
+		if err != nil {
+			logging.Log.Error("engine tls credentials failed !", err)
+			return nil, err
+		}
+		return dial(address, grpc.WithTransportCredentials(creds))
+	}
+}
+
 // grpc dial
 func grpcDial(address string) (*grpc.ClientConn, error) {
+	return dial(address, grpc.WithInsecure())
+}
+
+// grpc dial with transport security
+func dial(address string, security grpc.DialOption) (*grpc.ClientConn, error) {
 	ctx, ctxCancel := context.WithTimeout(context.Background(), DialTimeout)
 	defer ctxCancel()
 	gcc, err := grpc.DialContext(ctx, address,
 		grpc.WithCodec(Codec()),
-		grpc.WithInsecure(),
+		security,
 		grpc.WithBackoffMaxDelay(BackoffMaxDelay),
 		grpc.WithInitialWindowSize(InitialWindowSize),
 		grpc.WithInitialConnWindowSize(InitialConnWindowSize),
@@ -634,7 +465,7 @@ func CheckEngineSererStatus(data map[string]interface{}) bool {
 func checkAsrGRPCSererHealthTask() {
 	for {
 		// asr pool check
-		for _, pool := range asrPools {
+		for _, pool := range poolList("asr") {
 			checkStatus := checkGRPCSerer(pool.poolRemoteAddr)
 			if !checkStatus {
 				for i := 0; i < 5; i++ {
@@ -668,7 +499,7 @@ func checkAsrGRPCSererHealthTask() {
 func checkTtsGRPCSererHealthTask() {
 	for {
 		// tts pool check
-		for _, pool := range ttsPools {
+		for _, pool := range poolList("tts") {
 			checkStatus := checkGRPCSerer(pool.poolRemoteAddr)
 			if !checkStatus {
 				for i := 0; i < 5; i++ {
@@ -716,6 +547,7 @@ func GetAsrPoolMetricsData() (map[string]interface{}, bool) {
 	}
 
 	// engine data
+	asrPools := poolList("asr")
 	for _, asrPool := range asrPools {
 		if !asrPool.status {
 			continue
@@ -729,6 +561,8 @@ func GetAsrPoolMetricsData() (map[string]interface{}, bool) {
 		// data map
 		mDataMap = append(mDataMap, map[string]interface{}{
 			"engineName":      asrPool.name,
+			"sceneCode":       asrPool.sceneCode,
+			"tenant":          asrPool.tenant,
 			"connCurrent":     connCurrent,
 			"poolCurrentSize": poolCurrentSize,
 			"poolSize":        int(asrPool.capacity),
@@ -740,7 +574,7 @@ func GetAsrPoolMetricsData() (map[string]interface{}, bool) {
 	// tenant data
 	if TenantEnabled {
 		mTenantDataMap := make([]map[string]interface{}, 0)
-		for _, tenantPool := range tenants["asr"] {
+		for _, tenantPool := range tenantList("asr") {
 			connCurrent := tenantPool.GetConnCurrent()
 			poolCurrentSize := tenantPool.Size()
 			if poolCurrentSize >= int(tenantPool.capacity) {
@@ -780,6 +614,7 @@ func GetTtsPoolMetricsData() (map[string]interface{}, bool) {
 	}
 
 	// engine data
+	ttsPools := poolList("tts")
 	for _, ttsPool := range ttsPools {
 		if !ttsPool.status {
 			continue
@@ -793,6 +628,8 @@ func GetTtsPoolMetricsData() (map[string]interface{}, bool) {
 		// data map
 		mDataMap = append(mDataMap, map[string]interface{}{
 			"engineName":      ttsPool.name,
+			"sceneCode":       ttsPool.sceneCode,
+			"tenant":          ttsPool.tenant,
 			"connCurrent":     int(connCurrent),
 			"poolCurrentSize": poolCurrentSize,
 			"poolSize":        int(ttsPool.capacity),
@@ -804,7 +641,7 @@ func GetTtsPoolMetricsData() (map[string]interface{}, bool) {
 	// tenant data
 	if TenantEnabled {
 		mTenantDataMap := make([]map[string]interface{}, 0)
-		for _, tenantPool := range tenants["tts"] {
+		for _, tenantPool := range tenantList("tts") {
 			connCurrent := tenantPool.GetConnCurrent()
 			poolCurrentSize := tenantPool.Size()
 			if poolCurrentSize >= int(tenantPool.capacity) {
@@ -827,13 +664,3 @@ func GetTtsPoolMetricsData() (map[string]interface{}, bool) {
 	logging.Log.Info("-------------------- engine metrics data, tts size ", len(ttsPools))
 	return metricsDataMap, ttsOptions.PoolStatus
 }
-
-// encode (support base64)
-func strEncode(str []byte) []byte {
-	return []byte(base64.StdEncoding.EncodeToString(str))
-}
-
-// decode (support base64)
-func strDecode(str []byte) ([]byte, error) {
-	return base64.StdEncoding.DecodeString(string(str))
-}
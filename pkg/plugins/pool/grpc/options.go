@@ -2,7 +2,11 @@ package grpc
 
 import (
 	"context"
+	"errors"
 	"math/rand"
+	"rpc-gateway/pkg/core/config"
+	"rpc-gateway/pkg/plugins/auth"
+	"rpc-gateway/pkg/plugins/semaphore"
 	"strings"
 
 	"google.golang.org/grpc"
@@ -14,17 +18,18 @@ import (
 // options struct
 type Options struct {
 	Dial                 func(address string) (*grpc.ClientConn, error)
-	PoolModel            int    // Pool 模型
-	MaxIdle              int    // 最大空闲数量
-	MaxActive            int    // 最大活跃连接数
-	MaxConcurrentStreams int    // 最大并发数量
-	Reusable             bool   // 是否可以复用
-	RequestIdleTime      int    // request idle 时间
-	RequestMaxLife       int    // request max life 时间
-	RequestTimeOut       int    // request timeout
-	GatewayProxyAddr     string // grpc gateway 代理地址
-	GatewayProxyPort     string // grpc gateway 代理端口
-	PoolStatus           bool   // 连接池是否开启
+	PoolModel            int              // Pool 模型
+	MaxIdle              int              // 最大空闲数量
+	MaxActive            int              // 最大活跃连接数
+	MaxConcurrentStreams int              // 最大并发数量
+	Reusable             bool             // 是否可以复用
+	RequestIdleTime      int              // request idle 时间
+	RequestMaxLife       int              // request max life 时间
+	RequestTimeOut       int              // request timeout
+	GatewayProxyAddr     string           // grpc gateway 代理地址
+	GatewayProxyPort     string           // grpc gateway 代理端口
+	PoolStatus           bool             // 连接池是否开启
+	TLS                  config.EngineTLS // 引擎 tls 配置
 }
 
 // grpc proxy director
@@ -33,8 +38,7 @@ func GrpcProxyTransport(ctx context.Context, fullMethodName string) (context.Con
 		return nil, nil, nil, status.Errorf(codes.Unimplemented, "invaild or unsupported method")
 	}
 	md, ok := metadata.FromIncomingContext(ctx)
-	outCtx, _ := context.WithCancel(ctx)
-	outCtx = metadata.NewOutgoingContext(outCtx, md.Copy())
+	outCtx := metadata.NewOutgoingContext(ctx, md.Copy())
 	var err error
 	var conn *Client
 
@@ -49,69 +53,14 @@ func GrpcProxyTransport(ctx context.Context, fullMethodName string) (context.Con
 		}
 		// token code : userid + sceneCode + rand
 		if serverAddr == ASRGatewayProxyAddr {
-			if OMPEnabled || TenantEnabled {
-				// get token
-				if token, tokenExists := md["token"]; tokenExists {
-					engineCodes, errDecode := strDecode([]byte(token[0]))
-					if errDecode != nil {
-						status.Errorf(codes.Unimplemented, "token decode failed .")
-					}
-					// get engine code
-					engineCode := strings.Split(string(engineCodes), "-")
-					if len(engineCode) < 2 {
-						return nil, nil, nil, status.Errorf(codes.Unimplemented, "unknown engine token")
-					}
-					// enabled omp
-					if OMPEnabled {
-						if _, ok := asrPools[engineCode[1]]; !ok {
-							return nil, nil, nil, status.Errorf(codes.Unimplemented, "unknown engine pool")
-						}
-						conn, err = asrPools[engineCode[1]].Acquire(ctx)
-					} else if TenantEnabled { // enabled tenant
-						if _, ok := tenants["asr"][engineCode[1]]; !ok {
-							return nil, nil, nil, status.Errorf(codes.Unimplemented, "unknown engine pool")
-						}
-						conn, err = tenants["asr"][engineCode[1]].Acquire(ctx)
-					}
-				} else {
-					conn, err = nil, status.Errorf(codes.Unimplemented, "unknown engine pool")
-				} // end token exist
-			} else { // don't support omp and tenant
-				conn, err = balancePool(asrPools).Acquire(ctx)
-			}
+			conn, err = AcquireEngineClient(ctx, "asr", md)
 		} else if serverAddr == TTSGatewayProxyAddr {
-			if OMPEnabled || TenantEnabled {
-				// get token
-				if token, tokenExists := md["token"]; tokenExists {
-					engineCodes, errDecode := strDecode([]byte(token[0]))
-					if errDecode != nil {
-						status.Errorf(codes.Unimplemented, "token decode failed .")
-					}
-					// get engine code
-					engineCode := strings.Split(string(engineCodes), "-")
-					if len(engineCode) < 2 {
-						return nil, nil, nil, status.Errorf(codes.Unimplemented, "unknown engine token")
-					}
-					// enabled omp
-					if OMPEnabled { // enabled omp
-						if _, ok := ttsPools[engineCode[1]]; !ok {
-							return nil, nil, nil, status.Errorf(codes.Unimplemented, "unknown engine pool")
-						}
-						conn, err = ttsPools[engineCode[1]].Acquire(ctx)
-					} else if TenantEnabled { // enabled tenant
-						if _, ok := tenants["tts"][engineCode[1]]; !ok {
-							return nil, nil, nil, status.Errorf(codes.Unimplemented, "unknown engine pool")
-						}
-						conn, err = tenants["tts"][engineCode[1]].Acquire(ctx)
-					}
-				} else { // end token exist
-					conn, err = nil, status.Errorf(codes.Unimplemented, "unknown engine pool")
-				}
-			} else { // don't support omp and tenant
-				conn, err = balancePool(ttsPools).Acquire(ctx)
-			}
+			conn, err = AcquireEngineClient(ctx, "tts", md)
 		}
 
+		if err != nil {
+			return nil, nil, nil, err
+		}
 		if conn != nil {
 			return outCtx, conn.ClientConn, conn, err
 		}
@@ -119,6 +68,182 @@ func GrpcProxyTransport(ctx context.Context, fullMethodName string) (context.Con
 	return nil, nil, nil, status.Errorf(codes.Unimplemented, "unknown method")
 }
 
+// acquire engine client by token metadata
+func AcquireEngineClient(ctx context.Context, engineType string, md metadata.MD) (*Client, error) {
+	if engineType != "asr" && engineType != "tts" {
+		return nil, status.Errorf(codes.Unimplemented, "unknown engine type")
+	}
+
+	// don't support omp and tenant
+	if !OMPEnabled && !TenantEnabled {
+		return AcquireBalanceClient(ctx, engineType)
+	}
+
+	// verify token or client certificate
+	sceneCode, err := authenticate(ctx, engineType, md)
+	if err != nil {
+		return nil, err
+	}
+	// enabled omp
+	if OMPEnabled {
+		pool, ok := acquireGroupPool(engineType, sceneCode)
+		if !ok {
+			return nil, status.Errorf(codes.Unimplemented, "unknown engine pool")
+		}
+		if pool == nil {
+			return nil, status.Errorf(codes.Unavailable, "no available engine of scene %s", sceneCode)
+		}
+		return acquireLimited(ctx, engineType, sceneCode, pool.Acquire)
+	}
+	// enabled tenant
+	poolsLock.RLock()
+	tenant, ok := tenants[engineType][sceneCode]
+	poolsLock.RUnlock()
+	if !ok {
+		return nil, status.Errorf(codes.Unimplemented, "unknown engine pool")
+	}
+	return acquireLimited(ctx, engineType, sceneCode, tenant.Acquire)
+}
+
+// acquire client in tenant and engine concurrency shared by gateways, slots are released when client is closed
+// 租户上限为 ENGINE_POOL_SIZE, 引擎上限为引擎并发, 超出时拒绝请求
+func acquireLimited(ctx context.Context, engineType, sceneCode string, acquire func(context.Context) (*Client, error)) (*Client, error) {
+	if !semaphore.Enabled() {
+		return acquire(ctx)
+	}
+	var permits []*semaphore.Permit
+	if sceneCode != "" {
+		permit, err := semaphore.Acquire("tenant:"+engineType+":"+sceneCode, tenantLimit(engineType, sceneCode))
+		if err != nil {
+			return nil, limitError(err, "tenant "+sceneCode)
+		}
+		permits = append(permits, permit)
+	}
+	client, err := acquire(ctx)
+	if err != nil || client == nil || client.pool == nil {
+		for _, permit := range permits {
+			permit.Release()
+		}
+		return client, err
+	}
+	pool := client.pool
+	permit, err := semaphore.Acquire("engine:"+engineType+":"+pool.poolRemoteAddr, int(pool.limit))
+	if err != nil {
+		for _, permit := range permits {
+			permit.Release()
+		}
+		client.Close()
+		return nil, limitError(err, engineType+" engine "+pool.poolRemoteAddr)
+	}
+	client.permits = append(permits, permit)
+	return client, nil
+}
+
+// ENGINE_POOL_SIZE of tenant, no limit when not configured
+func tenantLimit(engineType, sceneCode string) int {
+	for _, tenant := range config.Get().Tenants {
+		if strings.ToLower(tenant.EngineName) == engineType && tenant.SceneCode == sceneCode {
+			return tenant.EnginePoolSize
+		}
+	}
+	return 0
+}
+
+// limiter error to grpc status
+func limitError(err error, target string) error {
+	if errors.Is(err, semaphore.ErrLimitExceeded) {
+		return status.Errorf(codes.ResourceExhausted, "%s concurrency limit exceeded", target)
+	}
+	return status.Errorf(codes.Unavailable, "%s: %v", target, err)
+}
+
+// scene code of the caller, by token or verified client certificate
+func authenticate(ctx context.Context, engineType string, md metadata.MD) (string, error) {
+	tenant, identified := identityTenant(ctx, engineType)
+	raw := tokenFromMetadata(md)
+	if raw == "" {
+		if identified {
+			return tenant.SceneCode, nil
+		}
+		return "", status.Errorf(codes.Unauthenticated, "token or client certificate is required")
+	}
+	claims, err := verifyToken(raw)
+	if err != nil {
+		return "", err
+	}
+	if !claims.HasScope(auth.SCOPE_INVOKE) || claims.Engine != engineType {
+		return "", status.Errorf(codes.PermissionDenied, "token is not allowed to invoke %s engine", engineType)
+	}
+	// 证书和 token 需要属于同一租户
+	if identified && claims.SceneCode != tenant.SceneCode {
+		return "", status.Errorf(codes.PermissionDenied, "token does not match client certificate tenant %s", tenant.TenantName)
+	}
+	return claims.SceneCode, nil
+}
+
+// tenant mapped from verified client certificate identity
+func identityTenant(ctx context.Context, engineType string) (config.TenantConfig, bool) {
+	identities := auth.PeerIdentities(ctx)
+	if len(identities) == 0 {
+		return config.TenantConfig{}, false
+	}
+	for _, tenant := range config.Get().Tenants {
+		if strings.ToLower(tenant.EngineName) != engineType {
+			continue
+		}
+		for _, identity := range tenant.ClientIdentities {
+			for _, peerIdentity := range identities {
+				if identity == peerIdentity {
+					return tenant, true
+				}
+			}
+		}
+	}
+	return config.TenantConfig{}, false
+}
+
+// token in metadata, "token" or "authorization: Bearer"
+func tokenFromMetadata(md metadata.MD) string {
+	if token := md.Get("token"); len(token) > 0 {
+		return token[0]
+	}
+	if authorization := md.Get("authorization"); len(authorization) > 0 {
+		return auth.BearerToken(authorization[0])
+	}
+	return ""
+}
+
+// verify token
+func verifyToken(raw string) (*auth.Claims, error) {
+	claims, err := auth.Verify(raw)
+	switch {
+	case err == nil:
+	case errors.Is(err, auth.ErrRevocationUnavailable):
+		// REVOCATION_FAIL_POLICY 为 closed 时吊销记录查询失败
+		return nil, status.Errorf(codes.Unavailable, "verify token failed: %v", err)
+	default:
+		return nil, status.Errorf(codes.Unauthenticated, "%v", err)
+	}
+	return claims, nil
+}
+
+// acquire client from balanced engine pool
+func AcquireBalanceClient(ctx context.Context, engineType string) (*Client, error) {
+	pool := acquireBalancePool(engineType)
+	if pool == nil {
+		return nil, status.Errorf(codes.Unavailable, "engine pool is empty")
+	}
+	return acquireLimited(ctx, engineType, "", pool.Acquire)
+}
+
+// 负载均衡, 选择 engine 的连接池
+func acquireBalancePool(engineType string) *Pool {
+	poolsLock.RLock()
+	defer poolsLock.RUnlock()
+
+	return balancePool(enginePools(engineType))
+}
+
 // 负载均衡
 func balancePool(pools map[string]*Pool) *Pool {
 	var sumSize, size int
@@ -152,6 +277,10 @@ func balancePool(pools map[string]*Pool) *Pool {
 			}
 			indexRand = append(indexRand, k)
 		}
+		// 全部不可用
+		if len(indexRand) == 0 {
+			return nil
+		}
 		// 随机
 		if sumSize == 0 {
 			index = indexRand[rand.Intn(len(indexRand))]
@@ -0,0 +1,111 @@
+package grpc
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"rpc-gateway/pkg/core/config"
+	logging "rpc-gateway/pkg/core/log"
+	"rpc-gateway/pkg/plugins/semaphore"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// memory limiter, tenant s1 limit 1
+func setupLimiter(t *testing.T) {
+	t.Helper()
+	logging.Log = zap.NewNop().Sugar()
+	semaphore.Init(config.Limiter{Enabled: true, Lease: 60, FailPolicy: semaphore.FAIL_CLOSED})
+	cfg := &config.Configs{}
+	cfg.Tenants = []config.TenantConfig{{TenantId: "t1", EngineName: "ASR", SceneCode: "s1", EnginePoolSize: 1}}
+	config.Set(cfg)
+	t.Cleanup(func() { config.Set(nil) })
+}
+
+// tenant slot is free when it can be acquired again
+func tenantReleased(t *testing.T) bool {
+	t.Helper()
+	permit, err := semaphore.Acquire("tenant:asr:s1", 1)
+	if err != nil {
+		return false
+	}
+	permit.Release()
+	return true
+}
+
+func TestAcquireLimitedPoolError(t *testing.T) {
+	setupLimiter(t)
+	cases := []struct {
+		name    string
+		acquire func(context.Context) (*Client, error)
+		code    codes.Code
+	}{
+		{"acquire error", func(context.Context) (*Client, error) {
+			return nil, status.Errorf(codes.Unavailable, "no idle client")
+		}, codes.Unavailable},
+		{"no client", func(context.Context) (*Client, error) { return nil, nil }, codes.OK},
+		{"client without pool", func(context.Context) (*Client, error) { return &Client{}, nil }, codes.OK},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			client, err := acquireLimited(context.Background(), "asr", "s1", tc.acquire)
+			if status.Code(err) != tc.code || (client != nil && len(client.permits) != 0) {
+				t.Errorf("acquireLimited = %+v, %v", client, err)
+			}
+			if !tenantReleased(t) {
+				t.Error("tenant permit not released")
+			}
+		})
+	}
+}
+
+func TestAcquireLimitedEngineExceeded(t *testing.T) {
+	setupLimiter(t)
+	pool := groupPool("engine-a/10.0.0.9:9000", 1, 0, true)
+	pool.poolRemoteAddr = "10.0.0.9:9000"
+	pool.limit = 1
+	// 其他节点占满引擎并发
+	held, err := semaphore.Acquire("engine:asr:10.0.0.9:9000", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer held.Release()
+
+	acquire := func(context.Context) (*Client, error) { return &Client{pool: pool}, nil }
+	client, err := acquireLimited(context.Background(), "asr", "s1", acquire)
+	if client != nil || status.Code(err) != codes.ResourceExhausted || !strings.Contains(err.Error(), "asr engine 10.0.0.9:9000") {
+		t.Errorf("acquireLimited = %v, %v, want engine ResourceExhausted", client, err)
+	}
+	if !tenantReleased(t) {
+		t.Error("tenant permit not released")
+	}
+}
+
+func TestAcquireLimited(t *testing.T) {
+	setupLimiter(t)
+	pool := groupPool("engine-a/10.0.0.10:9000", 2, 0, true)
+	pool.poolRemoteAddr = "10.0.0.10:9000"
+	pool.limit = 2
+	acquire := func(context.Context) (*Client, error) { return &Client{pool: pool}, nil }
+
+	client, err := acquireLimited(context.Background(), "asr", "s1", acquire)
+	if err != nil || len(client.permits) != 2 {
+		t.Fatalf("acquireLimited = %+v, %v", client, err)
+	}
+	if _, err := acquireLimited(context.Background(), "asr", "s1", acquire); status.Code(err) != codes.ResourceExhausted || !strings.Contains(err.Error(), "tenant s1") {
+		t.Errorf("acquireLimited over tenant limit error = %v", err)
+	}
+	// 连接关闭时异步释放
+	client.Close()
+	deadline := time.Now().Add(5 * time.Second)
+	for !tenantReleased(t) {
+		if time.Now().After(deadline) {
+			t.Fatal("tenant permit not released after close")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -3,8 +3,11 @@ package grpc
 import (
 	"context"
 	"errors"
+	"rpc-gateway/pkg/core/config"
 	logging "rpc-gateway/pkg/core/log"
+	"rpc-gateway/pkg/plugins/semaphore"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"google.golang.org/grpc"
@@ -13,6 +16,9 @@ import (
 // 连接池初始化出错
 var ErrPoolInit = errors.New("Pool init error")
 
+// 连接池已关闭
+var ErrPoolClosed = errors.New("Pool is closed")
+
 // 连接池模型
 const (
 	STRICT_MODE = iota
@@ -25,17 +31,23 @@ const (
 type Pool struct {
 	name           string // 连接池名称
 	clients        chan *Client
-	connCurrent    int32         // 当前连接数
-	capacity       int32         // 容量
-	size           int32         // 容量大小 (动态变化)
-	idleDur        time.Duration // 空闲时间
-	maxLifeDur     time.Duration // 最大连接时间
-	timeout        time.Duration // Pool 的关闭超时时间
-	factor         Factory       // gRPC 工厂函数
-	lock           sync.RWMutex  // 读写锁
-	mode           int           // 连接池 模型
-	poolRemoteAddr string        // 远程连接地址
-	status         bool          // 是否可用
+	connCurrent    int32            // 当前连接数
+	capacity       int32            // 容量
+	limit          int32            // 引擎并发, 集群所有节点共享, 开启 limiter 时限制
+	lent           int32            // 分给 tenant 的连接数, 空闲连接不超过 capacity - lent
+	size           int32            // 容量大小 (动态变化)
+	idleDur        time.Duration    // 空闲时间
+	maxLifeDur     time.Duration    // 最大连接时间
+	timeout        time.Duration    // Pool 的关闭超时时间
+	factor         Factory          // gRPC 工厂函数
+	lock           sync.RWMutex     // 读写锁
+	mode           int              // 连接池 模型
+	poolRemoteAddr string           // 远程连接地址
+	status         bool             // 是否可用
+	tls            config.EngineTLS // 引擎 tls 配置
+	sceneCode      string           // 场景码, 引擎发现创建
+	tenant         string           // 租户, 引擎发现创建
+	source         string           // 发现来源, k8s service 名称或 discovery 类型, 静态配置为空
 }
 
 // Client 封装的 grpc.ClientConn
@@ -45,6 +57,7 @@ type Client struct {
 	timeInit time.Time
 	pool     *Pool
 	tenant   *Tenant
+	permits  []*semaphore.Permit // 租户和引擎的并发 slot, 连接关闭时释放
 }
 
 // gRPC 连接工厂方法
@@ -112,8 +125,15 @@ func (pool *Pool) createClient() (*Client, error) {
 
 // 从连接池取出一个连接
 func (pool *Pool) Acquire(ctx context.Context) (*Client, error) {
-	if pool.IsClose() {
-		return nil, errors.New("Pool is closed")
+	if pool == nil {
+		return nil, ErrPoolClosed
+	}
+	// Close 并发置空 clients, 读取快照
+	pool.lock.RLock()
+	clients := pool.clients
+	pool.lock.RUnlock()
+	if clients == nil {
+		return nil, ErrPoolClosed
 	}
 
 	// defer func() {
@@ -131,11 +151,11 @@ func (pool *Pool) Acquire(ctx context.Context) (*Client, error) {
 			var err error
 			if pool.GetConnCurrent() > int32(pool.capacity) && pool.GetConnCurrent() <= 5*int32(pool.capacity) {
 				client, err = pool.createClient()
-				pool.clients <- client
+				clients <- client
 			}
-			return <-pool.clients, err
+			return <-clients, err
 		}
-	case client = <-pool.clients:
+	case client = <-clients:
 		if client != nil && pool.idleDur > 0 && client.timeUsed.Add(pool.idleDur).After(now) {
 			client.timeUsed = now
 			return client, nil
@@ -158,7 +178,7 @@ func (pool *Pool) Close() {
 	pool.lock.Lock()
 	defer pool.lock.Unlock()
 
-	if pool.IsClose() {
+	if pool.clients == nil {
 		return
 	}
 
@@ -178,9 +198,40 @@ func (pool *Pool) Close() {
 	pool.status = false
 }
 
+// 归还连接, 连接池关闭或已满时销毁
+func (pool *Pool) put(client *Client) {
+	if pool == nil {
+		client.Destory()
+		return
+	}
+	pool.lock.RLock()
+	defer pool.lock.RUnlock()
+
+	if pool.clients == nil {
+		client.Destory()
+		return
+	}
+	// tenant 重建后旧 tenant 归还的连接
+	if int32(len(pool.clients))+atomic.LoadInt32(&pool.lent) >= pool.capacity {
+		client.Destory()
+		return
+	}
+	select {
+	case pool.clients <- client:
+	default:
+		client.Destory()
+	}
+}
+
 // 连接池是否关闭
 func (pool *Pool) IsClose() bool {
-	return pool == nil || pool.clients == nil
+	if pool == nil {
+		return true
+	}
+	pool.lock.RLock()
+	defer pool.lock.RUnlock()
+
+	return pool.clients == nil
 }
 
 // 连接池中连接数
@@ -198,7 +249,12 @@ func (pool *Pool) GetConnCurrent() int32 {
 
 // 连接关闭
 func (client *Client) Close() {
+	permits := client.permits
+	client.permits = nil
 	go func() {
+		for _, permit := range permits {
+			permit.Release()
+		}
 		pool := client.pool
 		now := time.Now()
 		// 连接池关闭了直接销毁
@@ -216,11 +272,12 @@ func (client *Client) Close() {
 			return
 		}
 		client.timeUsed = now
-		if client.tenant != nil {
-			client.tenant.clients <- client
-		} else {
-			client.pool.clients <- client
+		// tenant 重建后归还到连接池
+		if client.tenant != nil && client.tenant.put(client) {
+			return
 		}
+		client.tenant = nil
+		pool.put(client)
 	}()
 }
 
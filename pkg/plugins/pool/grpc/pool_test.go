@@ -0,0 +1,85 @@
+package grpc
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	logging "rpc-gateway/pkg/core/log"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+)
+
+func newTestPool(t *testing.T, size int32) *Pool {
+	t.Helper()
+	factory := func() (*grpc.ClientConn, error) {
+		return grpc.Dial("127.0.0.1:9", grpc.WithInsecure())
+	}
+	pool, err := NewPool(factory, size, size, time.Minute, time.Hour, time.Second, STRICT_MODE)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pool
+}
+
+func TestAcquireClosedPool(t *testing.T) {
+	pool := newTestPool(t, 1)
+	pool.Close()
+	// 不等待 ctx 结束
+	done := make(chan error, 1)
+	go func() {
+		_, err := pool.Acquire(context.Background())
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != ErrPoolClosed {
+			t.Errorf("Acquire after Close error = %v, want %v", err, ErrPoolClosed)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Acquire blocked on closed pool")
+	}
+
+	var nilPool *Pool
+	if _, err := nilPool.Acquire(context.Background()); err != ErrPoolClosed {
+		t.Errorf("Acquire of nil pool error = %v", err)
+	}
+}
+
+func TestAcquireConcurrentClose(t *testing.T) {
+	logging.Log = zap.NewNop().Sugar()
+	pool := newTestPool(t, 4)
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			for j := 0; j < 20; j++ {
+				ctx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+				client, err := pool.Acquire(ctx)
+				cancel()
+				if err == ErrPoolClosed {
+					return
+				}
+				if err != nil {
+					t.Errorf("Acquire error = %v", err)
+					return
+				}
+				if client != nil {
+					client.Close()
+				}
+			}
+		}()
+	}
+	close(start)
+	time.Sleep(time.Millisecond)
+	pool.Close()
+	wg.Wait()
+	if !pool.IsClose() || pool.Size() != 0 {
+		t.Errorf("pool closed %v with %d clients", pool.IsClose(), pool.Size())
+	}
+}
@@ -0,0 +1,433 @@
+package grpc
+
+import (
+	"fmt"
+	"reflect"
+	"rpc-gateway/pkg/core/common"
+	"rpc-gateway/pkg/core/config"
+	logging "rpc-gateway/pkg/core/log"
+	"rpc-gateway/pkg/plugins/discovery"
+	"rpc-gateway/pkg/plugins/httpserver/db"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// reload lock, one reload at a time
+var reloadLock sync.Mutex
+
+// pool to create on reload
+type poolChange struct {
+	engine  string
+	name    string
+	addr    string
+	limit   int // 引擎并发
+	options Options
+	old     *Pool // 被替换的连接池
+	pool    *Pool
+}
+
+// watch PoolConfig.yaml and TenantConfig.yaml
+func watchPoolConfig() {
+	for _, name := range []string{config.POOL_CONFIG, config.TENANT_CONFIG} {
+		v := config.NewViper(name)
+		if err := v.ReadInConfig(); err != nil {
+			logging.Log.Info(name, " not watched: ", err)
+			continue
+		}
+		v.WatchConfig()
+		v.OnConfigChange(func(e fsnotify.Event) {
+			time.Sleep(time.Second * 1)
+			logging.Log.Info("pool config reload ...", e.Name)
+			reloadPoolConfig()
+		})
+	}
+}
+
+// reload pool and tenant config, all new pools and tenants are built before applied
+func reloadPoolConfig() {
+	reloadLock.Lock()
+	defer reloadLock.Unlock()
+
+	old := config.Get()
+	cfg, err := config.LoadPool()
+	if err != nil {
+		logging.Log.Error("pool config reload failed, keep current config: \n", err)
+		return
+	}
+	keepRestartRequired(old.Pool, &cfg.Pool)
+	// 开启 db 时租户由 db 同步, TenantConfig.yaml 只用于初始化
+	if db.Enabled() {
+		cfg.Tenants = old.Tenants
+	}
+	setting := cfg.Pool.Setting
+
+	// dial setting, used by new connections
+	DialTimeout = time.Duration(setting.DialTimeout) * time.Second
+	BackoffMaxDelay = time.Duration(setting.BackoffMaxDelay) * time.Second
+	KeepAliveTime = time.Duration(setting.KeepaliveTime) * time.Second
+	KeepAliveTimeout = time.Duration(setting.KeepaliveTimeout) * time.Second
+	// 开启 OMP 时连接池由引擎发现创建
+	if OMPEnabled {
+		engineClusterEnabled = setting.ClusterEnabled
+		engineClusterNodeNum = setting.ClusterNodeNum
+		config.Set(cfg)
+		logging.Log.Info("pool config reloaded, omp enabled, new options apply to discovered engines")
+		return
+	}
+
+	if err := applyPoolConfig(old, cfg); err != nil {
+		logging.Log.Error("pool config reload failed, keep current pools: ", err)
+		return
+	}
+	config.Set(cfg)
+}
+
+// cluster members changed, static and discovered pools are resized by new share
+func resizePools() {
+	if !PoolInitialized() {
+		atomic.StoreInt32(&resizePending, 1)
+		return
+	}
+	reloadLock.Lock()
+	defer reloadLock.Unlock()
+
+	// 开启 OMP 时没有静态连接池
+	if !OMPEnabled {
+		cfg := config.Get()
+		if err := applyPoolConfig(cfg, cfg); err != nil {
+			logging.Log.Error("resize pools failed, keep current pools: ", err)
+		}
+	}
+	discoveryLock.Lock()
+	defer discoveryLock.Unlock()
+	for engineType, sources := range discoveredEndpoints {
+		for source, endpoints := range sources {
+			applyEndpoints(engineType, source, endpoints)
+		}
+	}
+}
+
+// poll tenants shared by replicas in db
+func watchTenantInventory() {
+	if !db.Enabled() {
+		return
+	}
+	go db.Poll(syncTenantInventory)
+}
+
+// apply db tenants when changed by admin api of any replica
+func syncTenantInventory() {
+	tenants, err := db.TenantConfigs()
+	if err != nil {
+		logging.Log.Error("load tenants from db error: ", err)
+		return
+	}
+	if reflect.DeepEqual(tenants, config.Get().Tenants) {
+		return
+	}
+	if errs := config.ValidateTenants(tenants); len(errs) > 0 {
+		logging.Log.Error("db tenants invalid, keep current tenants: \n", errs)
+		return
+	}
+	reloadTenants(tenants)
+}
+
+// replace tenants, pools are kept
+func reloadTenants(tenantConfigs []config.TenantConfig) {
+	reloadLock.Lock()
+	defer reloadLock.Unlock()
+
+	old := config.Get()
+	cfg := *old
+	cfg.Tenants = tenantConfigs
+	// 开启 OMP 时没有租户连接池
+	if !OMPEnabled {
+		if err := applyPoolConfig(old, &cfg); err != nil {
+			logging.Log.Error("tenant reload failed, keep current tenants: ", err)
+			return
+		}
+	}
+	config.Set(&cfg)
+	logging.Log.Info("tenants reloaded from db, ", len(tenantConfigs), " tenants")
+}
+
+// diff and apply pools and tenants
+func applyPoolConfig(old, cfg *config.Configs) (err error) {
+	// 动态发现的连接池在替换期间不变
+	discoveryLock.Lock()
+	defer discoveryLock.Unlock()
+
+	oldEngines := make(map[string]config.PoolEngine)
+	for _, engineConfig := range old.Pool.Engine {
+		oldEngines[strings.ToLower(engineConfig.EngineName)] = engineConfig
+	}
+
+	clusterEnabled, clusterNodeNum := engineClusterEnabled, engineClusterNodeNum
+	engineClusterEnabled = cfg.Pool.Setting.ClusterEnabled
+	engineClusterNodeNum = cfg.Pool.Setting.ClusterNodeNum
+	defer func() {
+		if err != nil {
+			engineClusterEnabled, engineClusterNodeNum = clusterEnabled, clusterNodeNum
+		}
+	}()
+
+	// new pools by engine
+	newPools := map[string]map[string]*Pool{"asr": {}, "tts": {}}
+	engineOptions := make(map[string]Options)
+	var changes, drained []*poolChange
+	discovered := make(map[string][]*Pool)
+	for _, engineType := range []string{"asr", "tts"} {
+		// 引擎开关需要重启生效
+		oldEngine, ok := oldEngines[engineType]
+		if !ok || !oldEngine.PoolEnabled {
+			continue
+		}
+		current := make(map[string]*Pool)
+		for _, pool := range poolList(engineType) {
+			// 动态发现的连接池保留
+			if pool.source != "" {
+				discovered[engineType] = append(discovered[engineType], pool)
+				continue
+			}
+			if _, ok := current[pool.poolRemoteAddr]; ok {
+				drained = append(drained, &poolChange{name: pool.name, addr: pool.poolRemoteAddr, old: pool})
+				continue
+			}
+			current[pool.poolRemoteAddr] = pool
+		}
+
+		for _, engineConfig := range cfg.Pool.Engine {
+			if strings.ToLower(engineConfig.EngineName) != engineType || !engineConfig.PoolEnabled {
+				continue
+			}
+			op := newOptions(engineConfig)
+			op.GatewayProxyAddr = GatewayProxyAddr
+			op.GatewayProxyPort = oldEngine.GatewayProxyPort
+			engineOptions[engineType] = op
+			// 仅静态配置支持热更新, discovery 类型变化需重启
+			if !strings.EqualFold(oldEngine.Discovery.Type, discovery.TYPE_STATIC) ||
+				!strings.EqualFold(engineConfig.Discovery.Type, discovery.TYPE_STATIC) {
+				for addr, pool := range current {
+					newPools[engineType][addr] = pool
+				}
+				current = make(map[string]*Pool)
+				continue
+			}
+			for _, engine := range discovery.NewStatic(engineConfig).Endpoints() {
+				serverAddr := engine.Addr
+				poolOp := op
+				poolOp.MaxIdle = clusterPoolSize(engine.PoolSize, serverAddr)
+				poolOp.MaxActive = poolOp.MaxIdle
+				// 当前节点未分到并发, 已有连接池回收
+				if poolOp.MaxIdle <= 0 {
+					continue
+				}
+				pool, ok := current[serverAddr]
+				if !ok {
+					if _, exists := newPools[engineType][serverAddr]; exists {
+						logging.Log.Warn("duplicate ", engineType, " engine ", serverAddr, " ignored")
+						continue
+					}
+					// 新增连接池
+					changes = append(changes, &poolChange{engine: engineType, name: common.GenXid(), addr: serverAddr, limit: engine.PoolSize, options: poolOp})
+					newPools[engineType][serverAddr] = nil
+					continue
+				}
+				delete(current, serverAddr)
+				if poolChanged(pool, poolOp) || pool.limit != int32(engine.PoolSize) {
+					// 替换连接池, 保留原名称
+					changes = append(changes, &poolChange{engine: engineType, name: pool.name, addr: serverAddr, limit: engine.PoolSize, options: poolOp, old: pool})
+					newPools[engineType][serverAddr] = nil
+					continue
+				}
+				newPools[engineType][serverAddr] = pool
+			}
+		}
+		// 移除的连接池
+		for addr, pool := range current {
+			drained = append(drained, &poolChange{name: pool.name, addr: addr, old: pool})
+		}
+	}
+
+	// create pools
+	for _, change := range changes {
+		pool, err := newGrpcPool(change.addr, change.options)
+		if err != nil {
+			closeChanges(changes)
+			return fmt.Errorf("failed to new pool %s: %v", change.addr, err)
+		}
+		pool.poolRemoteAddr = change.addr
+		pool.limit = int32(change.limit)
+		pool.name = change.name
+		change.pool = pool
+		newPools[change.engine][change.addr] = pool
+	}
+
+	// pools by name
+	pools := make(map[string]map[string]*Pool)
+	for engineType, byAddr := range newPools {
+		pools[engineType] = make(map[string]*Pool)
+		for _, pool := range byAddr {
+			pools[engineType][pool.name] = pool
+		}
+		for _, pool := range discovered[engineType] {
+			pools[engineType][pool.name] = pool
+		}
+	}
+
+	// rebuild tenants
+	var newTenants map[string]map[string]*Tenant
+	if TenantEnabled {
+		newTenants, err = buildTenants(cfg.Tenants, map[string]interface{}{
+			"asr": pools["asr"],
+			"tts": pools["tts"],
+		})
+		if err != nil {
+			closeChanges(changes)
+			return err
+		}
+	}
+
+	// swap
+	poolsLock.Lock()
+	asrPools, ttsPools = pools["asr"], pools["tts"]
+	for engineType, op := range engineOptions {
+		setEngineOptions(engineType, op)
+	}
+	oldTenants := tenants
+	if TenantEnabled {
+		tenants = newTenants
+		setTenantLent(pools, newTenants)
+	}
+	poolsLock.Unlock()
+
+	// retire old tenants and pools
+	if TenantEnabled {
+		for _, engineTenants := range oldTenants {
+			for _, tenant := range engineTenants {
+				tenant.retire()
+			}
+		}
+	}
+	var created, resized int
+	for _, change := range changes {
+		if change.old == nil {
+			created++
+			logging.Log.Info("create pool ", change.name, " ", change.addr, " size ", change.options.MaxActive)
+			continue
+		}
+		resized++
+		logging.Log.Info("replace pool ", change.name, " ", change.addr, " size ", change.old.capacity, " -> ", change.options.MaxActive)
+		change.old.Close()
+	}
+	for _, change := range drained {
+		logging.Log.Info("drain pool ", change.name, " ", change.addr)
+		change.old.Close()
+	}
+	logging.Log.Infof("pool config reloaded, %d created, %d resized, %d drained, %d tenants",
+		created, resized, len(drained), len(cfg.Tenants))
+	return nil
+}
+
+// pool options changed
+func poolChanged(pool *Pool, op Options) bool {
+	return pool.capacity != int32(op.MaxActive) ||
+		pool.mode != op.PoolModel ||
+		pool.idleDur != time.Duration(op.RequestIdleTime)*time.Second ||
+		pool.maxLifeDur != time.Duration(op.RequestMaxLife)*time.Second ||
+		pool.timeout != time.Duration(op.RequestTimeOut)*time.Second ||
+		pool.tls != op.TLS
+}
+
+// close created pools on failed reload
+func closeChanges(changes []*poolChange) {
+	for _, change := range changes {
+		if change.pool != nil {
+			change.pool.Close()
+		}
+	}
+}
+
+// settings only applied on restart, current values are kept in cfg so config.Get matches runtime
+func keepRestartRequired(old config.PoolConfig, cfg *config.PoolConfig) {
+	var keys []string
+	setting := &cfg.Setting
+	if old.Setting.Enabled != setting.Enabled {
+		keys = append(keys, "ENABLED")
+		setting.Enabled = old.Setting.Enabled
+	}
+	if old.Setting.OMPEnabled != setting.OMPEnabled {
+		keys = append(keys, "OMP_ENABLED")
+		setting.OMPEnabled = old.Setting.OMPEnabled
+	}
+	if old.Setting.TenantEnabled != setting.TenantEnabled {
+		keys = append(keys, "TENANT_ENABLED")
+		setting.TenantEnabled = old.Setting.TenantEnabled
+	}
+	if old.Setting.NetworkMode != setting.NetworkMode {
+		keys = append(keys, "NETWORK_MODE")
+		setting.NetworkMode = old.Setting.NetworkMode
+	}
+	// 节点注册在启动时开启
+	if old.Setting.ClusterMembership != setting.ClusterMembership {
+		keys = append(keys, "CLUSTER_MEMBERSHIP")
+		setting.ClusterMembership = old.Setting.ClusterMembership
+	}
+	if old.Setting.ClusterHeartbeat != setting.ClusterHeartbeat {
+		keys = append(keys, "CLUSTER_HEARTBEAT")
+		setting.ClusterHeartbeat = old.Setting.ClusterHeartbeat
+	}
+	if old.Setting.Limiter != setting.Limiter {
+		keys = append(keys, "limiter")
+		setting.Limiter = old.Setting.Limiter
+	}
+	if old.Setting.GatewayProxyAddr != setting.GatewayProxyAddr {
+		keys = append(keys, "GATEWAY_PROXY_ADDR")
+		setting.GatewayProxyAddr = old.Setting.GatewayProxyAddr
+	}
+	// 证书文件轮换无需重启
+	if old.Setting.TLS != setting.TLS {
+		keys = append(keys, "tls")
+		setting.TLS = old.Setting.TLS
+	}
+	oldEngines := make(map[string]config.PoolEngine)
+	for _, engineConfig := range old.Engine {
+		oldEngines[strings.ToLower(engineConfig.EngineName)] = engineConfig
+	}
+	for i := range cfg.Engine {
+		engineConfig := &cfg.Engine[i]
+		engineType := strings.ToLower(engineConfig.EngineName)
+		oldEngine, ok := oldEngines[engineType]
+		if !ok {
+			if engineConfig.PoolEnabled {
+				keys = append(keys, engineType+".POOL_ENABLED")
+				engineConfig.PoolEnabled = false
+			}
+			continue
+		}
+		// 关闭的引擎连接池直接回收, 开启需要重启监听
+		if !oldEngine.PoolEnabled && engineConfig.PoolEnabled {
+			keys = append(keys, engineType+".POOL_ENABLED")
+			engineConfig.PoolEnabled = false
+		}
+		if oldEngine.GatewayProxyPort != engineConfig.GatewayProxyPort {
+			keys = append(keys, engineType+".GATEWAY_PROXY_PORT")
+			engineConfig.GatewayProxyPort = oldEngine.GatewayProxyPort
+		}
+		if oldEngine.GrpcWebPort != engineConfig.GrpcWebPort {
+			keys = append(keys, engineType+".GRPC_WEB_PORT")
+			engineConfig.GrpcWebPort = oldEngine.GrpcWebPort
+		}
+		if oldEngine.PoolEnabled && oldEngine.Discovery != engineConfig.Discovery {
+			keys = append(keys, engineType+".discovery")
+			engineConfig.Discovery = oldEngine.Discovery
+		}
+	}
+	if len(keys) > 0 {
+		logging.Log.Warn("pool config ", strings.Join(keys, ", "), " changed, restart required to apply, current values are kept")
+	}
+}
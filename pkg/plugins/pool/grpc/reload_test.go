@@ -0,0 +1,41 @@
+package grpc
+
+import (
+	"testing"
+
+	"rpc-gateway/pkg/core/config"
+)
+
+func TestKeepRestartRequired(t *testing.T) {
+	old := config.PoolConfig{
+		Setting: config.PoolSetting{Enabled: true, OMPEnabled: false, NetworkMode: 1, GatewayProxyAddr: "0.0.0.0", DialTimeout: 5},
+		Engine: []config.PoolEngine{
+			{EngineName: "ASR", PoolEnabled: true, GatewayProxyPort: "9001", Discovery: config.EngineDiscovery{Type: "static"}},
+			{EngineName: "TTS", PoolEnabled: false, GatewayProxyPort: "9002"},
+		},
+	}
+	cfg := config.PoolConfig{
+		Setting: config.PoolSetting{Enabled: true, OMPEnabled: true, NetworkMode: 2, GatewayProxyAddr: "127.0.0.1", DialTimeout: 10},
+		Engine: []config.PoolEngine{
+			{EngineName: "asr", PoolEnabled: true, GatewayProxyPort: "9101", Discovery: config.EngineDiscovery{Type: "dns"}, RequestTimeout: 20},
+			{EngineName: "TTS", PoolEnabled: true, GatewayProxyPort: "9002"},
+		},
+	}
+	keepRestartRequired(old, &cfg)
+
+	setting := cfg.Setting
+	if setting.OMPEnabled || setting.NetworkMode != 1 || setting.GatewayProxyAddr != "0.0.0.0" {
+		t.Errorf("restart required settings applied: %+v", setting)
+	}
+	// 热更新配置保留新值
+	if setting.DialTimeout != 10 || cfg.Engine[0].RequestTimeout != 20 {
+		t.Errorf("hot reload settings reverted: %+v", cfg)
+	}
+	asr, tts := cfg.Engine[0], cfg.Engine[1]
+	if asr.GatewayProxyPort != "9001" || asr.Discovery.Type != "static" {
+		t.Errorf("asr restart required settings applied: %+v", asr)
+	}
+	if tts.PoolEnabled {
+		t.Error("tts pool enabled without restart")
+	}
+}
@@ -4,48 +4,55 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"rpc-gateway/pkg/core/config"
 	"strings"
 	"sync"
-
-	"github.com/spf13/viper"
+	"sync/atomic"
 )
 
 type Tenant struct {
 	id             interface{}
 	clients        chan *Client
-	connCurrent    int32        // 当前连接数
-	capacity       int32        // 容量
-	size           int32        // 容量大小 (动态变化)
-	lock           sync.RWMutex // 读写锁
-	mode           int          // 连接池 模型
-	poolRemoteAddr string       // 远程连接地址
-	tenantName     string       // tenant 名称
-	engine         string       // 引擎名称
+	connCurrent    int32           // 当前连接数
+	capacity       int32           // 容量
+	size           int32           // 容量大小 (动态变化)
+	lock           sync.RWMutex    // 读写锁
+	mode           int             // 连接池 模型
+	poolRemoteAddr string          // 远程连接地址
+	tenantName     string          // tenant 名称
+	engine         string          // 引擎名称
+	done           chan struct{}   // tenant 重建后关闭
+	lent           map[*Pool]int32 // 各连接池分给 tenant 的连接数
 }
 
-var tenantViper *viper.Viper
-
 // acquire tenant pool
-func initTenantPool(pools map[string]interface{}) map[string]map[string]*Tenant {
-	tenantViper = viper.New()
-	tenantViper.SetConfigName("TenantConfig")
-	tenantViper.SetConfigType("yaml")
-	tenantViper.AddConfigPath("config/")
-	err := tenantViper.ReadInConfig()
+func initTenantPool(tenantConfigs []config.TenantConfig, pools map[string]interface{}) map[string]map[string]*Tenant {
+	tenants, err := buildTenants(tenantConfigs, pools)
 	if err != nil {
-		panic(fmt.Errorf("fatal error get tenant config file: %s", err))
+		panic(err.Error())
+	}
+	return tenants
+}
+
+// build tenants from pools, capacity checked before any client is taken
+func buildTenants(tenantConfigs []config.TenantConfig, pools map[string]interface{}) (map[string]map[string]*Tenant, error) {
+	if err := checkTenantCapacity(tenantConfigs, pools); err != nil {
+		return nil, err
 	}
 
-	// acquire pools
+	// acquire pools, 按容量分配, 不依赖旧 tenant 是否已归还连接
+	assigned := make(map[*Pool]int32)
 	tenants := make(map[string]map[string]*Tenant)
 	tenants["asr"] = make(map[string]*Tenant)
 	tenants["tts"] = make(map[string]*Tenant)
-	tvRoot := tenantViper.AllSettings()["tenants"]
-	for _, v := range tvRoot.([]interface{}) {
-		vMap := v.(map[interface{}]interface{})
-		tenantPoolSize := vMap["ENGINE_POOL_SIZE"].(int)
-		engine := strings.ToLower(vMap["ENGINE_NAME"].(string))
-		tenantId := vMap["SCENE_CODE"].(string)
+	for _, tenantConfig := range tenantConfigs {
+		tenantPoolSize := tenantConfig.EnginePoolSize
+		engine := strings.ToLower(tenantConfig.EngineName)
+		tenantId := tenantConfig.SceneCode
+		enginePools, ok := pools[engine].(map[string]*Pool)
+		if !ok {
+			continue
+		}
 
 		tenant := &Tenant{
 			id:             tenantId,
@@ -56,69 +63,148 @@ func initTenantPool(pools map[string]interface{}) map[string]map[string]*Tenant
 			lock:           sync.RWMutex{},
 			mode:           0,
 			poolRemoteAddr: "",
-			tenantName:     vMap["TENANT_NAME"].(string),
+			tenantName:     tenantConfig.TenantName,
 			engine:         engine,
+			done:           make(chan struct{}),
+			lent:           make(map[*Pool]int32),
 		}
-
-		switch engine {
-		case "asr":
-			asrPools := pools["asr"].(map[string]*Pool)
-			for i := 0; i < tenantPoolSize; i++ {
-				client, err := blanceAcquirePool(asrPools)
-				if err != nil {
-					panic("asr pool capacity is not enough")
-				}
-				client.tenant = tenant
-				tenant.clients <- client
-			}
-
-			tenants["asr"][tenantId] = tenant
-		case "tts":
-			ttsPools := pools["tts"].(map[string]*Pool)
-			for i := 0; i < tenantPoolSize; i++ {
-				client, err := blanceAcquirePool(ttsPools)
-				if err != nil {
-					panic("tts pool capacity is not enough")
+		for i := 0; i < tenantPoolSize; i++ {
+			client, err := blanceAcquirePool(enginePools, assigned)
+			if err != nil {
+				tenant.retire()
+				for _, t := range tenants[engine] {
+					t.retire()
 				}
-				client.tenant = tenant
-				tenant.clients <- client
+				return nil, fmt.Errorf("%s pool capacity is not enough", engine)
 			}
-			tenants["tts"][tenantId] = tenant
+			client.tenant = tenant
+			tenant.clients <- client
+			tenant.lent[client.pool]++
 		}
+		tenants[engine][tenantId] = tenant
 	}
 
-	return tenants
+	return tenants, nil
 }
 
-// blance acqurire chan
-func blanceAcquirePool(pools map[string]*Pool) (*Client, error) {
-	var sumSize, size int
-	var index string
-	// 最小连接数
-	for k, pool := range pools {
-		pSize := pool.Size()
-		sumSize += int(pool.Size())
-		if size < pSize {
-			size = int(pSize)
-			index = k
+// tenant 所需连接数不能超过连接池容量
+func checkTenantCapacity(tenantConfigs []config.TenantConfig, pools map[string]interface{}) error {
+	required := make(map[string]int)
+	for _, tenantConfig := range tenantConfigs {
+		required[strings.ToLower(tenantConfig.EngineName)] += tenantConfig.EnginePoolSize
+	}
+	for engine, size := range required {
+		enginePools, _ := pools[engine].(map[string]*Pool)
+		var capacity int
+		for _, pool := range enginePools {
+			capacity += int(pool.capacity)
+		}
+		if size > capacity {
+			return fmt.Errorf("%s pool capacity is not enough, tenants require %d, pools have %d", engine, size, capacity)
 		}
 	}
-	// 随机
-	if sumSize == 0 {
-		// index = rand.Intn(len(pools))
+	return nil
+}
+
+// blance acqurire chan, pool with most unassigned capacity
+// 优先使用空闲连接, 连接仍被旧 tenant 持有时新建, 旧 tenant 归还的连接超出容量时销毁
+func blanceAcquirePool(pools map[string]*Pool, assigned map[*Pool]int32) (*Client, error) {
+	var pool *Pool
+	var free int32
+	for _, p := range pools {
+		if p.IsClose() {
+			continue
+		}
+		if f := p.capacity - assigned[p]; f > free {
+			free = f
+			pool = p
+		}
+	}
+	if pool == nil {
 		return nil, errors.New(" pool is empty")
 	}
+	assigned[pool]++
 
-	return <-pools[index].clients, nil
+	pool.lock.RLock()
+	clients := pool.clients
+	pool.lock.RUnlock()
+	select {
+	case client := <-clients:
+		if client != nil {
+			return client, nil
+		}
+	default:
+	}
+	return pool.createClient()
+}
+
+// clients lent to tenants of pools, caller holds poolsLock
+func setTenantLent(pools map[string]map[string]*Pool, tenants map[string]map[string]*Tenant) {
+	lent := make(map[*Pool]int32)
+	for _, engineTenants := range tenants {
+		for _, tenant := range engineTenants {
+			for pool, n := range tenant.lent {
+				lent[pool] += n
+			}
+		}
+	}
+	for _, enginePools := range pools {
+		for _, pool := range enginePools {
+			atomic.StoreInt32(&pool.lent, lent[pool])
+		}
+	}
 }
 
 // 从连接池取出一个连接
 func (pool *Tenant) Acquire(ctx context.Context) (*Client, error) {
-	if pool.IsClose() {
-		return nil, errors.New("Pool is closed")
+	pool.lock.RLock()
+	clients := pool.clients
+	pool.lock.RUnlock()
+	if clients == nil {
+		return nil, ErrPoolClosed
 	}
 
-	return <-pool.clients, nil
+	select {
+	case client := <-clients:
+		return client, nil
+	case <-pool.done:
+		return nil, errors.New("tenant reloaded")
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// 归还连接, tenant 已重建时返回 false
+func (pool *Tenant) put(client *Client) bool {
+	pool.lock.RLock()
+	defer pool.lock.RUnlock()
+
+	if pool.clients == nil {
+		return false
+	}
+	select {
+	case pool.clients <- client:
+		return true
+	default:
+		return false
+	}
+}
+
+// tenant 重建, 空闲连接归还到连接池
+func (pool *Tenant) retire() {
+	pool.lock.Lock()
+	clients := pool.clients
+	pool.clients = nil
+	if clients != nil {
+		close(pool.done)
+	}
+	pool.lock.Unlock()
+
+	for clients != nil && len(clients) > 0 {
+		client := <-clients
+		client.tenant = nil
+		client.pool.put(client)
+	}
 }
 
 // 连接池中连接数
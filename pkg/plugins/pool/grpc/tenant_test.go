@@ -0,0 +1,101 @@
+package grpc
+
+import (
+	"testing"
+
+	"rpc-gateway/pkg/core/config"
+
+	"google.golang.org/grpc"
+)
+
+func testPool(t *testing.T, addr string, size int) *Pool {
+	t.Helper()
+	pool, err := newGrpcPool(addr, Options{
+		MaxIdle:         size,
+		MaxActive:       size,
+		RequestIdleTime: 10,
+		Dial: func(address string) (*grpc.ClientConn, error) {
+			return grpc.Dial(address, grpc.WithInsecure())
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool.poolRemoteAddr = addr
+	pool.name = addr
+	t.Cleanup(pool.Close)
+	return pool
+}
+
+func retireTenants(tenants map[string]map[string]*Tenant) {
+	for _, engineTenants := range tenants {
+		for _, tenant := range engineTenants {
+			tenant.retire()
+		}
+	}
+}
+
+func TestReloadTenantsWithFullCapacity(t *testing.T) {
+	pools := map[string]map[string]*Pool{
+		"asr": {"a": testPool(t, "127.0.0.1:9", 3), "b": testPool(t, "127.0.0.2:9", 2)},
+		"tts": {},
+	}
+	enginePools := map[string]interface{}{"asr": pools["asr"], "tts": pools["tts"]}
+	configs := []config.TenantConfig{
+		{EngineName: "ASR", SceneCode: "s1", EnginePoolSize: 3},
+		{EngineName: "ASR", SceneCode: "s2", EnginePoolSize: 2},
+	}
+	old, err := buildTenants(configs, enginePools)
+	if err != nil {
+		t.Fatal(err)
+	}
+	setTenantLent(pools, old)
+	for name, pool := range pools["asr"] {
+		if pool.Size() != 0 {
+			t.Fatalf("pool %s has %d idle clients, want 0", name, pool.Size())
+		}
+	}
+
+	// 全部容量分给 tenant 时仍可重建
+	reloaded, err := buildTenants(configs, enginePools)
+	if err != nil {
+		t.Fatalf("reload tenants: %v", err)
+	}
+	if reloaded["asr"]["s1"].Size() != 3 || reloaded["asr"]["s2"].Size() != 2 {
+		t.Fatalf("tenant sizes %d, %d", reloaded["asr"]["s1"].Size(), reloaded["asr"]["s2"].Size())
+	}
+	setTenantLent(pools, reloaded)
+	retireTenants(old)
+	// 旧 tenant 归还的连接超出容量被销毁
+	for name, pool := range pools["asr"] {
+		if pool.Size() != 0 {
+			t.Fatalf("pool %s has %d idle clients after retire, want 0", name, pool.Size())
+		}
+	}
+
+	// 缩小 tenant 后空闲连接回到连接池
+	shrunk, err := buildTenants(configs[:1], enginePools)
+	if err != nil {
+		t.Fatal(err)
+	}
+	setTenantLent(pools, shrunk)
+	retireTenants(reloaded)
+	idle := 0
+	for _, pool := range pools["asr"] {
+		idle += pool.Size()
+		if pool.Size()+int(pool.lent) > int(pool.capacity) {
+			t.Fatalf("pool %s over capacity, idle %d lent %d", pool.name, pool.Size(), pool.lent)
+		}
+	}
+	if idle != 2 {
+		t.Fatalf("idle clients %d, want 2", idle)
+	}
+}
+
+func TestBuildTenantsCapacity(t *testing.T) {
+	pools := map[string]interface{}{"asr": map[string]*Pool{"a": testPool(t, "127.0.0.1:9", 2)}}
+	_, err := buildTenants([]config.TenantConfig{{EngineName: "asr", SceneCode: "s1", EnginePoolSize: 3}}, pools)
+	if err == nil {
+		t.Fatal("tenants over pool capacity built")
+	}
+}
@@ -0,0 +1,133 @@
+package proxy
+
+import (
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"rpc-gateway/pkg/core/config"
+	logging "rpc-gateway/pkg/core/log"
+	"rpc-gateway/pkg/plugins/auth"
+	"rpc-gateway/pkg/plugins/health"
+	"rpc-gateway/pkg/plugins/httpserver/controller"
+	"rpc-gateway/pkg/plugins/httpserver/middleware"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// admin server, management, metrics, health and pprof, separated from proxy traffic
+func (plugin *Plugin) AdminServer() {
+	defer func() {
+		plugin.Status <- false
+	}()
+	app := config.Get().App
+	r := gin.New()
+	r.Use(gin.Recovery())
+	definitionAdminRoute(r, app)
+	r.NoRoute(func(c *gin.Context) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"code":  404,
+			"error": "oops, page not exists!",
+		})
+	})
+	// log server addr
+	logging.Log.Info("admin server runing " + app.AdminServerAddr)
+	lis, err := listen("admin", app.AdminServerAddr)
+	if err != nil {
+		logging.Log.Errorf("failed to listen: %v", err)
+		return
+	}
+	if err := http.Serve(lis, r); err != nil {
+		health.ListenerFailed("admin", lis.Addr().String(), err)
+		logging.Log.Errorf("failed to serve admin: %v", err)
+	}
+}
+
+// listen tcp, listener state reported to readyz
+func listen(name, addr string) (net.Listener, error) {
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		health.ListenerFailed(name, addr, err)
+		return nil, err
+	}
+	health.ListenerBound(name, lis.Addr().String())
+	return lis, nil
+}
+
+// liveness probe
+func healthz(c *gin.Context) {
+	c.JSON(http.StatusOK, health.Live())
+}
+
+// readiness probe, 503 until the gateway can serve
+func readyz(c *gin.Context) {
+	report := health.Ready()
+	code := http.StatusOK
+	if report.Status != health.STATUS_OK {
+		code = http.StatusServiceUnavailable
+	}
+	c.JSON(code, report)
+}
+
+// management api, api key or token with role, mutating calls are audited
+func definitionAdminRoute(router *gin.Engine, app config.AppConfig) {
+	viewer := middleware.Authorize(auth.ROLE_VIEWER)
+	operator := middleware.Authorize(auth.ROLE_OPERATOR)
+	admin := middleware.Authorize(auth.ROLE_ADMIN)
+	// health probes, no auth
+	router.GET("/healthz", healthz)
+	router.GET("/readyz", readyz)
+	api := router.Group("", middleware.TimeoutHandler(time.Second*time.Duration(app.HttpTimeDuration)))
+	// metrics data api
+	var metricsController *controller.MetricsController
+	// metrics api
+	api.GET("/engine/metricsdata", viewer, metricsController.GetEngineMetricsData)
+	var vsController *controller.VSController
+	// vs api
+	api.POST("/engine/create", middleware.Audit("engine.create"), operator, vsController.CreateEngine)
+	api.POST("/engine/update", middleware.Audit("engine.update"), operator, vsController.UpdateEngine)
+	api.POST("/engine/delete", middleware.Audit("engine.delete"), operator, vsController.DeleteEngine)
+	api.POST("/engine/check", viewer, vsController.CheckEngine)
+	var inventoryController *controller.InventoryController
+	// inventory api
+	api.GET("/inventory/engines", viewer, inventoryController.ListEngines)
+	api.GET("/inventory/pools", viewer, inventoryController.ListPools)
+	api.GET("/inventory/tenants", viewer, inventoryController.ListTenants)
+	api.GET("/inventory/tokens", admin, inventoryController.ListTokens)
+	api.GET("/inventory/routes", viewer, inventoryController.ListRoutes)
+	api.POST("/inventory/tenants/save", middleware.Audit("tenant.save"), admin, inventoryController.SaveTenant)
+	api.POST("/inventory/tenants/delete", middleware.Audit("tenant.delete"), admin, inventoryController.DeleteTenant)
+	api.POST("/inventory/routes/save", middleware.Audit("route.save"), operator, inventoryController.SaveRoute)
+	api.POST("/inventory/routes/delete", middleware.Audit("route.delete"), operator, inventoryController.DeleteRoute)
+	var tokenController *controller.TokenController
+	// token api
+	api.POST("/token/mint", middleware.Audit("token.mint"), admin, tokenController.MintToken)
+	api.POST("/token/revoke", middleware.Audit("token.revoke"), admin, tokenController.RevokeToken)
+	var cacheController *controller.CacheController
+	// cache api
+	api.POST("/cache/purge", middleware.Audit("cache.purge"), operator, cacheController.PurgeCache)
+	// pprof, profile 耗时较长不设置超时
+	if app.AdminPprofEnabled {
+		router.GET("/debug/pprof/*name", admin, pprofHandler)
+		router.POST("/debug/pprof/*name", admin, pprofHandler)
+	}
+}
+
+// pprof handler by name
+func pprofHandler(c *gin.Context) {
+	switch name := strings.Trim(c.Param("name"), "/"); name {
+	case "":
+		pprof.Index(c.Writer, c.Request)
+	case "cmdline":
+		pprof.Cmdline(c.Writer, c.Request)
+	case "profile":
+		pprof.Profile(c.Writer, c.Request)
+	case "symbol":
+		pprof.Symbol(c.Writer, c.Request)
+	case "trace":
+		pprof.Trace(c.Writer, c.Request)
+	default:
+		pprof.Handler(name).ServeHTTP(c.Writer, c.Request)
+	}
+}
@@ -0,0 +1,178 @@
+package cache
+
+import (
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"rpc-gateway/pkg/core/config"
+	logging "rpc-gateway/pkg/core/log"
+	"rpc-gateway/pkg/plugins/httpserver/db"
+)
+
+const (
+	// cache key namespace
+	KEY_PREFIX = "pigeon:http:cache:"
+	// default lru size
+	DEFAULT_LRU_SIZE = 1024
+)
+
+// cache store
+type Store interface {
+	Get(key string) ([]byte, bool)
+	Set(key string, value []byte, ttl time.Duration) error
+	PurgePrefix(prefix string) (int, error)
+}
+
+// cached response
+type Entry struct {
+	StatusCode int         `json:"statusCode"`
+	Header     http.Header `json:"header"`
+	Body       []byte      `json:"body"`
+}
+
+var store Store
+var storeOnce sync.Once
+
+// init cache store
+// use redis when CACHE_ENABLED, or in-memory lru
+func Init() Store {
+	storeOnce.Do(func() {
+		if db.Cache != nil {
+			store = newRedisStore(db.Cache)
+			logging.Log.Info("http response cache use redis store")
+			return
+		}
+
+		lruSize := config.Get().App.HttpCacheLruSize
+		if lruSize <= 0 {
+			lruSize = DEFAULT_LRU_SIZE
+		}
+		store = newLRUStore(lruSize)
+		logging.Log.Info("http response cache use lru store, size ", lruSize)
+	})
+	return store
+}
+
+// get cache entry
+func Get(key string) ([]byte, bool) {
+	return Init().Get(key)
+}
+
+// set cache entry
+func Set(key string, value []byte, ttl time.Duration) error {
+	if ttl <= 0 {
+		return nil
+	}
+	return Init().Set(key, value, ttl)
+}
+
+// purge entries by path prefix
+func PurgePrefix(prefix string) (int, error) {
+	return Init().PurgePrefix(KEY_PREFIX + prefix)
+}
+
+// build cache key
+// format: prefix + path # method # sorted query # selected headers
+func BuildKey(method, path string, query url.Values, header http.Header, varyHeaders []string) string {
+	var b strings.Builder
+	b.WriteString(KEY_PREFIX)
+	b.WriteString(path)
+	b.WriteString("#")
+	b.WriteString(strings.ToUpper(method))
+	b.WriteString("#")
+	// url.Values.Encode sorts by key
+	b.WriteString(query.Encode())
+	b.WriteString("#")
+
+	names := make([]string, 0, len(varyHeaders))
+	for _, name := range varyHeaders {
+		names = append(names, http.CanonicalHeaderKey(name))
+	}
+	sort.Strings(names)
+	for i, name := range names {
+		if i > 0 {
+			b.WriteString("&")
+		}
+		b.WriteString(strings.ToLower(name))
+		b.WriteString("=")
+		b.WriteString(url.QueryEscape(strings.Join(header.Values(name), ",")))
+	}
+
+	return b.String()
+}
+
+// cache control directives
+type Control struct {
+	NoStore bool
+	NoCache bool
+	Private bool
+	Public  bool
+	MaxAge  int // -1 if not set, s-maxage preferred
+	SMaxAge int // -1 if not set
+}
+
+// parse Cache-Control header
+func ParseControl(header http.Header) Control {
+	cc := Control{MaxAge: -1, SMaxAge: -1}
+	for _, value := range header.Values("Cache-Control") {
+		for _, directive := range strings.Split(value, ",") {
+			directive = strings.ToLower(strings.TrimSpace(directive))
+			switch {
+			case directive == "no-store":
+				cc.NoStore = true
+			case directive == "no-cache":
+				cc.NoCache = true
+			case directive == "private" || strings.HasPrefix(directive, "private="):
+				cc.Private = true
+			case directive == "public":
+				cc.Public = true
+			case strings.HasPrefix(directive, "max-age="):
+				if age, err := strconv.Atoi(strings.TrimPrefix(directive, "max-age=")); err == nil {
+					cc.MaxAge = age
+				}
+			case strings.HasPrefix(directive, "s-maxage="):
+				if age, err := strconv.Atoi(strings.TrimPrefix(directive, "s-maxage=")); err == nil {
+					cc.SMaxAge = age
+				}
+			}
+		}
+	}
+	// shared cache prefer s-maxage
+	if cc.SMaxAge >= 0 {
+		cc.MaxAge = cc.SMaxAge
+	}
+	// HTTP/1.0 Pragma: no-cache
+	if strings.Contains(strings.ToLower(header.Get("Pragma")), "no-cache") {
+		cc.NoCache = true
+	}
+	return cc
+}
+
+// request carries credentials, responses are personalized
+func Authenticated(header http.Header) bool {
+	return header.Get("Authorization") != "" || header.Get("Cookie") != ""
+}
+
+// response ttl of request, 0 means don't store
+// Set-Cookie 和 private 响应不缓存, 带凭证请求的响应需要 public 或 s-maxage (RFC 7234 3.2)
+func ResponseTTL(reqHeader, resHeader http.Header, defaultTTL time.Duration) time.Duration {
+	if len(resHeader.Values("Set-Cookie")) > 0 {
+		return 0
+	}
+	cc := ParseControl(resHeader)
+	if cc.NoStore || cc.NoCache || cc.Private {
+		return 0
+	}
+	if Authenticated(reqHeader) && !cc.Public && cc.SMaxAge < 0 {
+		return 0
+	}
+	if cc.MaxAge >= 0 {
+		return time.Duration(cc.MaxAge) * time.Second
+	}
+	return defaultTTL
+}
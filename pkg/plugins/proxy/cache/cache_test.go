@@ -0,0 +1,85 @@
+package cache
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestBuildKey(t *testing.T) {
+	header := http.Header{}
+	header.Set("X-Tenant", "t1")
+	header.Set("Accept-Language", "zh")
+	header.Set("Authorization", "Bearer secret")
+
+	key := BuildKey("get", "/users", url.Values{"b": {"2"}, "a": {"1"}}, header, []string{"x-tenant", "Accept-Language"})
+	want := KEY_PREFIX + "/users#GET#a=1&b=2#accept-language=zh&x-tenant=t1"
+	if key != want {
+		t.Fatalf("key = %q, want %q", key, want)
+	}
+	// vary header order does not matter
+	if other := BuildKey("GET", "/users", url.Values{"a": {"1"}, "b": {"2"}}, header, []string{"Accept-Language", "X-Tenant"}); other != key {
+		t.Fatalf("key %q != %q", other, key)
+	}
+	// unselected headers are not part of key
+	if other := BuildKey("GET", "/users", url.Values{"a": {"1"}, "b": {"2"}}, http.Header{"X-Tenant": {"t1"}, "Accept-Language": {"zh"}}, []string{"X-Tenant", "Accept-Language"}); other != key {
+		t.Fatalf("key %q != %q", other, key)
+	}
+	if other := BuildKey("GET", "/users", url.Values{"a": {"2"}}, header, nil); other == key {
+		t.Fatal("different query has same key")
+	}
+}
+
+func TestParseControl(t *testing.T) {
+	cases := []struct {
+		name   string
+		header http.Header
+		want   Control
+	}{
+		{"empty", http.Header{}, Control{MaxAge: -1, SMaxAge: -1}},
+		{"max-age", http.Header{"Cache-Control": {"max-age=60"}}, Control{MaxAge: 60, SMaxAge: -1}},
+		{"s-maxage preferred", http.Header{"Cache-Control": {"max-age=60, s-maxage=10"}}, Control{MaxAge: 10, SMaxAge: 10}},
+		{"directives", http.Header{"Cache-Control": {"No-Store, no-cache", "private, public"}}, Control{NoStore: true, NoCache: true, Private: true, Public: true, MaxAge: -1, SMaxAge: -1}},
+		{"private fields", http.Header{"Cache-Control": {`private="Set-Cookie"`}}, Control{Private: true, MaxAge: -1, SMaxAge: -1}},
+		{"pragma", http.Header{"Pragma": {"no-cache"}}, Control{NoCache: true, MaxAge: -1, SMaxAge: -1}},
+		{"invalid max-age", http.Header{"Cache-Control": {"max-age=abc"}}, Control{MaxAge: -1, SMaxAge: -1}},
+	}
+	for _, c := range cases {
+		if got := ParseControl(c.header); got != c.want {
+			t.Errorf("%s: got %+v, want %+v", c.name, got, c.want)
+		}
+	}
+}
+
+func TestResponseTTL(t *testing.T) {
+	anonymous := http.Header{}
+	authorized := http.Header{"Authorization": {"Bearer token"}}
+	cookie := http.Header{"Cookie": {"session=1"}}
+	cases := []struct {
+		name string
+		req  http.Header
+		res  http.Header
+		want time.Duration
+	}{
+		{"default", anonymous, http.Header{}, 30 * time.Second},
+		{"max-age", anonymous, http.Header{"Cache-Control": {"max-age=5"}}, 5 * time.Second},
+		{"max-age zero", anonymous, http.Header{"Cache-Control": {"max-age=0"}}, 0},
+		{"s-maxage", anonymous, http.Header{"Cache-Control": {"max-age=5, s-maxage=7"}}, 7 * time.Second},
+		{"no-store", anonymous, http.Header{"Cache-Control": {"no-store"}}, 0},
+		{"no-cache", anonymous, http.Header{"Cache-Control": {"no-cache"}}, 0},
+		{"private", anonymous, http.Header{"Cache-Control": {"private, max-age=60"}}, 0},
+		{"set-cookie", anonymous, http.Header{"Set-Cookie": {"session=1"}, "Cache-Control": {"public, max-age=60"}}, 0},
+		{"authorization", authorized, http.Header{}, 0},
+		{"authorization max-age", authorized, http.Header{"Cache-Control": {"max-age=60"}}, 0},
+		{"authorization public", authorized, http.Header{"Cache-Control": {"public, max-age=60"}}, 60 * time.Second},
+		{"authorization s-maxage", authorized, http.Header{"Cache-Control": {"s-maxage=20"}}, 20 * time.Second},
+		{"cookie", cookie, http.Header{}, 0},
+		{"cookie public", cookie, http.Header{"Cache-Control": {"public"}}, 30 * time.Second},
+	}
+	for _, c := range cases {
+		if got := ResponseTTL(c.req, c.res, 30*time.Second); got != c.want {
+			t.Errorf("%s: ttl = %v, want %v", c.name, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package cache
+
+import (
+	"container/list"
+	"strings"
+	"sync"
+	"time"
+)
+
+// lru item
+type lruItem struct {
+	key      string
+	value    []byte
+	expireAt time.Time
+}
+
+// in-memory lru store
+type lruStore struct {
+	size  int
+	ll    *list.List
+	items map[string]*list.Element
+	lock  sync.Mutex
+}
+
+// new lru store
+func newLRUStore(size int) *lruStore {
+	return &lruStore{
+		size:  size,
+		ll:    list.New(),
+		items: make(map[string]*list.Element),
+	}
+}
+
+// get
+func (s *lruStore) Get(key string) ([]byte, bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	elem, ok := s.items[key]
+	if !ok {
+		return nil, false
+	}
+	item := elem.Value.(*lruItem)
+	// expired
+	if time.Now().After(item.expireAt) {
+		s.removeElement(elem)
+		return nil, false
+	}
+	s.ll.MoveToFront(elem)
+	return item.value, true
+}
+
+// set
+func (s *lruStore) Set(key string, value []byte, ttl time.Duration) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	expireAt := time.Now().Add(ttl)
+	if elem, ok := s.items[key]; ok {
+		item := elem.Value.(*lruItem)
+		item.value = value
+		item.expireAt = expireAt
+		s.ll.MoveToFront(elem)
+		return nil
+	}
+
+	s.items[key] = s.ll.PushFront(&lruItem{key: key, value: value, expireAt: expireAt})
+	// evict oldest
+	for s.ll.Len() > s.size {
+		s.removeElement(s.ll.Back())
+	}
+	return nil
+}
+
+// purge prefix
+func (s *lruStore) PurgePrefix(prefix string) (int, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	count := 0
+	for key, elem := range s.items {
+		if strings.HasPrefix(key, prefix) {
+			s.removeElement(elem)
+			count++
+		}
+	}
+	return count, nil
+}
+
+// remove element
+func (s *lruStore) removeElement(elem *list.Element) {
+	s.ll.Remove(elem)
+	delete(s.items, elem.Value.(*lruItem).key)
+}
@@ -0,0 +1,56 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLRUEviction(t *testing.T) {
+	s := newLRUStore(2)
+	s.Set("a", []byte("1"), time.Minute)
+	s.Set("b", []byte("2"), time.Minute)
+	// a is recently used, b is evicted
+	if _, ok := s.Get("a"); !ok {
+		t.Fatal("a missing")
+	}
+	s.Set("c", []byte("3"), time.Minute)
+	if _, ok := s.Get("b"); ok {
+		t.Fatal("b not evicted")
+	}
+	for _, key := range []string{"a", "c"} {
+		if _, ok := s.Get(key); !ok {
+			t.Fatalf("%s evicted", key)
+		}
+	}
+	// update keeps size
+	s.Set("a", []byte("4"), time.Minute)
+	if value, _ := s.Get("a"); string(value) != "4" || s.ll.Len() != 2 {
+		t.Fatalf("update failed, value %s, len %d", value, s.ll.Len())
+	}
+}
+
+func TestLRUExpire(t *testing.T) {
+	s := newLRUStore(2)
+	s.Set("a", []byte("1"), time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+	if _, ok := s.Get("a"); ok {
+		t.Fatal("expired entry returned")
+	}
+	if len(s.items) != 0 || s.ll.Len() != 0 {
+		t.Fatal("expired entry not removed")
+	}
+}
+
+func TestLRUPurgePrefix(t *testing.T) {
+	s := newLRUStore(10)
+	s.Set(KEY_PREFIX+"/users#GET", []byte("1"), time.Minute)
+	s.Set(KEY_PREFIX+"/users/1#GET", []byte("2"), time.Minute)
+	s.Set(KEY_PREFIX+"/orders#GET", []byte("3"), time.Minute)
+	n, err := s.PurgePrefix(KEY_PREFIX + "/users")
+	if err != nil || n != 2 {
+		t.Fatalf("purged %d, err %v", n, err)
+	}
+	if _, ok := s.Get(KEY_PREFIX + "/orders#GET"); !ok {
+		t.Fatal("unrelated entry purged")
+	}
+}
@@ -0,0 +1,85 @@
+package cache
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+// scan batch size
+const SCAN_COUNT = 500
+
+// redis store (single or cluster)
+type redisStore struct {
+	client redis.Cmdable
+}
+
+// new redis store
+func newRedisStore(client redis.Cmdable) *redisStore {
+	return &redisStore{client: client}
+}
+
+// get
+func (s *redisStore) Get(key string) ([]byte, bool) {
+	value, err := s.client.Get(key).Bytes()
+	if err != nil {
+		return nil, false
+	}
+	return value, true
+}
+
+// set
+func (s *redisStore) Set(key string, value []byte, ttl time.Duration) error {
+	return s.client.Set(key, value, ttl).Err()
+}
+
+// purge prefix
+func (s *redisStore) PurgePrefix(prefix string) (int, error) {
+	match := escapePattern(prefix) + "*"
+	// cluster mode scan every master
+	if cluster, ok := s.client.(*redis.ClusterClient); ok {
+		var count int
+		var lock sync.Mutex
+		err := cluster.ForEachMaster(func(client *redis.Client) error {
+			n, err := scanDelete(client, match)
+			lock.Lock()
+			count += n
+			lock.Unlock()
+			return err
+		})
+		return count, err
+	}
+	return scanDelete(s.client, match)
+}
+
+// scan and delete keys
+func scanDelete(client redis.Cmdable, match string) (int, error) {
+	var cursor uint64
+	count := 0
+	for {
+		keys, next, err := client.Scan(cursor, match, SCAN_COUNT).Result()
+		if err != nil {
+			return count, err
+		}
+		// del one by one, keys may be in different slots
+		for _, key := range keys {
+			n, err := client.Del(key).Result()
+			if err != nil {
+				return count, err
+			}
+			count += int(n)
+		}
+		cursor = next
+		if cursor == 0 {
+			return count, nil
+		}
+	}
+}
+
+// escape glob pattern
+func escapePattern(s string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, `*`, `\*`, `?`, `\?`, `[`, `\[`, `]`, `\]`)
+	return replacer.Replace(s)
+}
@@ -0,0 +1,23 @@
+package proxy
+
+import (
+	"rpc-gateway/pkg/core/config"
+	logging "rpc-gateway/pkg/core/log"
+	"rpc-gateway/pkg/plugins/vs/dvs"
+)
+
+// docker vs server
+func (plugin *Plugin) DvsServer() {
+	defer func() {
+		plugin.Status <- false
+	}()
+	cfg := config.Get().VS.Dvs
+	if !cfg.Enabled {
+		return
+	}
+	if err := dvs.Init(cfg); err != nil {
+		logging.Log.Error("dvs init error: ", err)
+		return
+	}
+	logging.Log.Info("dvs client initialized, docker host " + cfg.Host)
+}
@@ -1,57 +1,107 @@
 package proxy
 
 import (
-	"fmt"
-	"net"
+	"crypto/tls"
+	"net/http"
+	"rpc-gateway/pkg/core/config"
 	logging "rpc-gateway/pkg/core/log"
+	"rpc-gateway/pkg/plugins/auth"
+	"rpc-gateway/pkg/plugins/health"
+	"rpc-gateway/pkg/plugins/httpserver/middleware"
 	grpcPool "rpc-gateway/pkg/plugins/pool/grpc"
+	"rpc-gateway/pkg/plugins/proxy/grpcweb"
+	"rpc-gateway/pkg/plugins/vs"
 	"strings"
 
-	"github.com/spf13/viper"
+	"github.com/gin-gonic/gin"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/reflection"
 )
 
-var grpcViper *viper.Viper
-
-func init() {
-	// get config
-	grpcViper = viper.New()
-	grpcViper.SetConfigName("PoolConfig")
-	grpcViper.SetConfigType("yaml")
-	grpcViper.AddConfigPath("config/")
-	err := grpcViper.ReadInConfig()
-	if err != nil {
-		panic(fmt.Errorf("fatal error get pool config file: %s", err))
-	}
-}
-
 // rpc server
 func (plugin *Plugin) GRPCServer() {
 	// init config
-	rvRoot := grpcViper.AllSettings()["pool"]
-	setting := rvRoot.(map[string]interface{})["setting"].(map[string]interface{})
+	poolConfig := config.Get().Pool
+	setting := poolConfig.Setting
 	// whether pool enabled
-	if !setting[strings.ToLower("ENABLED")].(bool) {
+	if !setting.Enabled {
 		return
 	}
 	// init grpc pool
 	grpcPool.InitGrpcPool()
-	// engines map
-	engines := rvRoot.(map[string]interface{})["engine"]
-	for _, v := range engines.([]interface{}) {
-		vMap := v.(map[interface{}]interface{})
+	// pools of engines deployed by vs, engines of other replicas are polled
+	vs.RestorePools()
+	vs.WatchPools()
+	// grpc-web cors
+	corsConfig := grpcWebCorsConfig(setting)
+	// listener tls
+	var tlsConfig *tls.Config
+	if setting.TLS.Enabled {
+		var err error
+		tlsConfig, err = auth.ServerTLSConfig(setting.TLS, "h2", "http/1.1")
+		if err != nil {
+			logging.Log.Errorf("failed to load gateway tls: %v", err)
+			return
+		}
+		logging.Log.Info("gateway tls enabled, client auth ", setting.TLS.ClientAuth)
+	}
+	// engines
+	for _, engine := range poolConfig.Engine {
 		// get pool enabl
-		if !vMap["POOL_ENABLED"].(bool) {
+		if !engine.PoolEnabled {
 			continue
 		}
+		proxyAddr := setting.GatewayProxyAddr
+		// grpc-web listener
+		webAddr := ""
+		if engine.GrpcWebPort != "" {
+			webAddr = proxyAddr + ":" + engine.GrpcWebPort
+		}
 		// run grpc server
-		go plugin.grpcServer(setting[strings.ToLower("GATEWAY_PROXY_ADDR")].(string)+":"+vMap["GATEWAY_PROXY_PORT"].(string), vMap["ENGINE_NAME"].(string))
+		go plugin.grpcServer(proxyAddr+":"+engine.GatewayProxyPort, webAddr, engine.EngineName, corsConfig, tlsConfig)
+	}
+}
+
+// grpc-web cors config
+func grpcWebCorsConfig(setting config.PoolSetting) middleware.CorsConfig {
+	corsConfig := middleware.DefaultCorsConfig()
+	corsConfig.AllowHeaders = append(corsConfig.AllowHeaders, grpcweb.CorsAllowHeaders...)
+	corsConfig.ExposeHeaders = append(corsConfig.ExposeHeaders, grpcweb.CorsExposeHeaders...)
+	if len(setting.GrpcWebAllowOrigins) > 0 {
+		corsConfig.AllowOrigins = setting.GrpcWebAllowOrigins
+	}
+	return corsConfig
+}
+
+// grpc-web server
+func (plugin *Plugin) grpcWebServer(addr, authority, serverName string, srv *grpc.Server, corsConfig middleware.CorsConfig, tlsConfig *tls.Config) {
+	logging.Log.Info(serverName, " gRPC-Web Server start ...")
+	r := gin.New()
+	r.Use(gin.Recovery())
+	r.Use(middleware.CorsWithConfig(corsConfig))
+	r.NoRoute(gin.WrapH(grpcweb.Bridge(srv, authority)))
+	name := "grpc-web:" + strings.ToLower(serverName)
+	lis, err := listen(name, addr)
+	if err != nil {
+		logging.Log.Errorf("failed to listen: %v", err)
+		return
+	}
+	server := &http.Server{Handler: r, TLSConfig: tlsConfig}
+	if tlsConfig != nil {
+		// 证书由 tlsConfig 提供
+		err = server.ServeTLS(lis, "", "")
+	} else {
+		err = server.Serve(lis)
+	}
+	if err != nil {
+		health.ListenerFailed(name, lis.Addr().String(), err)
+		logging.Log.Errorf("failed to serve grpc-web: %v", err)
 	}
 }
 
 // asr grpc server
-func (plugin *Plugin) grpcServer(addr, serverName string) {
+func (plugin *Plugin) grpcServer(addr, webAddr, serverName string, corsConfig middleware.CorsConfig, tlsConfig *tls.Config) {
 	logging.Log.Info(serverName, " gRPC Server start ...")
 	// get gRPC port
 	defer func() {
@@ -59,14 +109,21 @@ func (plugin *Plugin) grpcServer(addr, serverName string) {
 	}()
 
 	// get gRPC port
-	lis, err := net.Listen("tcp", addr)
+	name := "grpc:" + strings.ToLower(serverName)
+	lis, err := listen(name, addr)
 	if err != nil {
 		logging.Log.Errorf("failed to listen: %v", err)
 		return
 	}
 	// grpc new server
-	srv := grpc.NewServer(grpc.CustomCodec(grpcPool.Codec()),
-		grpc.UnknownServiceHandler(grpcPool.TransparentHandler(grpcPool.GrpcProxyTransport)))
+	opts := []grpc.ServerOption{
+		grpc.CustomCodec(grpcPool.Codec()),
+		grpc.UnknownServiceHandler(grpcPool.TransparentHandler(grpcPool.GrpcProxyTransport)),
+	}
+	if tlsConfig != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	}
+	srv := grpc.NewServer(opts...)
 	// register service
 	grpcPool.RegisterService(srv, grpcPool.GrpcProxyTransport,
 		"PingEmpty",
@@ -75,9 +132,14 @@ func (plugin *Plugin) grpcServer(addr, serverName string) {
 		"PingList",
 	)
 	reflection.Register(srv)
+	// grpc-web bridge to the same server
+	if webAddr != "" {
+		go plugin.grpcWebServer(webAddr, addr, serverName, srv, corsConfig, tlsConfig)
+	}
 	// start ser listen
 	err = srv.Serve(lis)
 	if err != nil {
+		health.ListenerFailed(name, lis.Addr().String(), err)
 		logging.Log.Errorf("failed to serve: %v", err)
 		return
 	}
@@ -0,0 +1,257 @@
+package grpcweb
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"golang.org/x/net/http2"
+)
+
+const (
+	// grpc-web content types
+	CONTENT_TYPE_GRPC_WEB      = "application/grpc-web"
+	CONTENT_TYPE_GRPC_WEB_TEXT = "application/grpc-web-text"
+	// trailer frame flag
+	trailerFrameFlag = 0x80
+)
+
+// grpc-web request and response headers for cors
+var (
+	CorsAllowHeaders  = []string{"X-Grpc-Web", "X-User-Agent", "Grpc-Timeout", "Content-Type", "Token", "Authorization"}
+	CorsExposeHeaders = []string{"Grpc-Status", "Grpc-Message", "Grpc-Status-Details-Bin"}
+)
+
+// hop-by-hop headers dropped before handing to grpc server
+var hopHeaders = []string{"Connection", "Keep-Alive", "Upgrade", "Te", "Transfer-Encoding", "Proxy-Connection"}
+
+// is grpc-web request
+func IsGrpcWebRequest(r *http.Request) bool {
+	return r.Method == http.MethodPost && strings.HasPrefix(r.Header.Get("Content-Type"), CONTENT_TYPE_GRPC_WEB)
+}
+
+// bridge grpc-web requests to a native grpc handler
+// authority is set as :authority so the director routes to the same pools
+func Bridge(grpcHandler http.Handler, authority string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !IsGrpcWebRequest(r) {
+			http.Error(w, "grpc-web request required", http.StatusUnsupportedMediaType)
+			return
+		}
+		contentType := r.Header.Get("Content-Type")
+		isText := strings.HasPrefix(contentType, CONTENT_TYPE_GRPC_WEB_TEXT)
+
+		req := r.Clone(r.Context())
+		req.ProtoMajor, req.ProtoMinor, req.Proto = 2, 0, "HTTP/2.0"
+		req.Host = authority
+		for _, name := range hopHeaders {
+			req.Header.Del(name)
+		}
+		req.Header.Set("Content-Type", grpcContentType(contentType))
+		req.Header.Del("Content-Length")
+		req.ContentLength = -1
+		if isText {
+			body, err := ioutil.ReadAll(r.Body)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			decoded, err := decodeText(body)
+			if err != nil {
+				http.Error(w, "invalid grpc-web-text body", http.StatusBadRequest)
+				return
+			}
+			req.Body = ioutil.NopCloser(bytes.NewReader(decoded))
+		}
+
+		rw := newResponseWriter(w, contentType, isText)
+		grpcHandler.ServeHTTP(rw, req)
+		rw.finish()
+	})
+}
+
+// grpc-web content type to grpc content type
+func grpcContentType(contentType string) string {
+	subtype := ""
+	if i := strings.Index(contentType, "+"); i >= 0 {
+		subtype = contentType[i:]
+		if j := strings.Index(subtype, ";"); j >= 0 {
+			subtype = subtype[:j]
+		}
+	}
+	if subtype == "" {
+		subtype = "+proto"
+	}
+	return "application/grpc" + subtype
+}
+
+// decode concatenated base64 chunks, each may be padded
+func decodeText(body []byte) ([]byte, error) {
+	body = bytes.TrimSpace(body)
+	var out []byte
+	for len(body) > 0 {
+		// chunk ends after padding
+		end := len(body)
+		if i := bytes.IndexByte(body, '='); i >= 0 {
+			end = i
+			for end < len(body) && body[end] == '=' {
+				end++
+			}
+		}
+		decoded, err := base64.StdEncoding.DecodeString(string(body[:end]))
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, decoded...)
+		body = body[end:]
+	}
+	return out, nil
+}
+
+// grpc-web response writer
+type responseWriter struct {
+	w             http.ResponseWriter
+	header        http.Header
+	contentType   string
+	isText        bool
+	wroteHeader   bool
+	headerWritten map[string]bool
+	pending       []byte // text mode, bytes not aligned to base64 block
+}
+
+// new response writer
+func newResponseWriter(w http.ResponseWriter, contentType string, isText bool) *responseWriter {
+	return &responseWriter{
+		w:             w,
+		header:        make(http.Header),
+		contentType:   contentType,
+		isText:        isText,
+		headerWritten: make(map[string]bool),
+	}
+}
+
+// header
+func (rw *responseWriter) Header() http.Header {
+	return rw.header
+}
+
+// write header
+func (rw *responseWriter) WriteHeader(code int) {
+	if rw.wroteHeader {
+		return
+	}
+	rw.wroteHeader = true
+
+	declared := rw.declaredTrailers()
+	dst := rw.w.Header()
+	for name, values := range rw.header {
+		if name == "Trailer" || declared[name] || strings.HasPrefix(name, http2.TrailerPrefix) {
+			continue
+		}
+		dst[name] = values
+		rw.headerWritten[name] = true
+	}
+	dst.Set("Content-Type", rw.contentType)
+	dst.Del("Content-Length")
+	rw.w.WriteHeader(code)
+}
+
+// write data
+func (rw *responseWriter) Write(data []byte) (int, error) {
+	if !rw.wroteHeader {
+		rw.WriteHeader(http.StatusOK)
+	}
+	if err := rw.writeBody(data); err != nil {
+		return 0, err
+	}
+	return len(data), nil
+}
+
+// flush
+func (rw *responseWriter) Flush() {
+	if !rw.wroteHeader {
+		rw.WriteHeader(http.StatusOK)
+	}
+	if flusher, ok := rw.w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// write body, base64 in text mode
+func (rw *responseWriter) writeBody(data []byte) error {
+	if !rw.isText {
+		_, err := rw.w.Write(data)
+		return err
+	}
+	rw.pending = append(rw.pending, data...)
+	aligned := len(rw.pending) - len(rw.pending)%3
+	if aligned == 0 {
+		return nil
+	}
+	encoded := make([]byte, base64.StdEncoding.EncodedLen(aligned))
+	base64.StdEncoding.Encode(encoded, rw.pending[:aligned])
+	rw.pending = append([]byte(nil), rw.pending[aligned:]...)
+	_, err := rw.w.Write(encoded)
+	return err
+}
+
+// finish, write trailers as a frame
+func (rw *responseWriter) finish() {
+	if !rw.wroteHeader {
+		rw.WriteHeader(http.StatusOK)
+	}
+
+	declared := rw.declaredTrailers()
+	trailer := make(http.Header)
+	for name, values := range rw.header {
+		switch {
+		case strings.HasPrefix(name, http2.TrailerPrefix):
+			trailer[http.CanonicalHeaderKey(strings.TrimPrefix(name, http2.TrailerPrefix))] = values
+		case declared[name], name != "Trailer" && !rw.headerWritten[name]:
+			trailer[name] = values
+		}
+	}
+	delete(trailer, "Trailer")
+
+	var buf bytes.Buffer
+	for name, values := range trailer {
+		for _, value := range values {
+			buf.WriteString(strings.ToLower(name))
+			buf.WriteString(": ")
+			buf.WriteString(value)
+			buf.WriteString("\r\n")
+		}
+	}
+	frame := make([]byte, 5, 5+buf.Len())
+	frame[0] = trailerFrameFlag
+	binary.BigEndian.PutUint32(frame[1:], uint32(buf.Len()))
+	frame = append(frame, buf.Bytes()...)
+
+	rw.writeBody(frame)
+	// flush remaining text with padding
+	if rw.isText && len(rw.pending) > 0 {
+		encoded := base64.StdEncoding.EncodeToString(rw.pending)
+		rw.pending = nil
+		io.WriteString(rw.w, encoded)
+	}
+	if flusher, ok := rw.w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// trailers declared by Trailer header
+func (rw *responseWriter) declaredTrailers() map[string]bool {
+	declared := make(map[string]bool)
+	for _, value := range rw.header.Values("Trailer") {
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				declared[http.CanonicalHeaderKey(name)] = true
+			}
+		}
+	}
+	return declared
+}
@@ -0,0 +1,288 @@
+package grpcweb
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/http2"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// grpc server of test, serves health check
+func stubServer(t *testing.T) http.Handler {
+	t.Helper()
+	srv := grpc.NewServer()
+	hs := health.NewServer()
+	hs.SetServingStatus("engine", healthpb.HealthCheckResponse_SERVING)
+	healthpb.RegisterHealthServer(srv, hs)
+	t.Cleanup(srv.Stop)
+	return Bridge(srv, "gateway")
+}
+
+// length prefixed message frame
+func dataFrame(t *testing.T, msg proto.Message) []byte {
+	t.Helper()
+	data, err := proto.Marshal(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	frame := make([]byte, 5, 5+len(data))
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(data)))
+	return append(frame, data...)
+}
+
+type webFrame struct {
+	flag byte
+	data []byte
+}
+
+func parseFrames(t *testing.T, body []byte) []webFrame {
+	t.Helper()
+	var frames []webFrame
+	for len(body) > 0 {
+		if len(body) < 5 {
+			t.Fatalf("truncated frame header %v", body)
+		}
+		length := int(binary.BigEndian.Uint32(body[1:5]))
+		if len(body) < 5+length {
+			t.Fatalf("truncated frame, want %d bytes, got %d", length, len(body)-5)
+		}
+		frames = append(frames, webFrame{flag: body[0], data: body[5 : 5+length]})
+		body = body[5+length:]
+	}
+	return frames
+}
+
+// trailer frame lines, sorted
+func trailerLines(data []byte) []string {
+	var lines []string
+	for _, line := range strings.Split(string(data), "\r\n") {
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	sort.Strings(lines)
+	return lines
+}
+
+func healthRequest(t *testing.T, handler http.Handler, contentType, service string, text bool) *httptest.ResponseRecorder {
+	t.Helper()
+	body := dataFrame(t, &healthpb.HealthCheckRequest{Service: service})
+	if text {
+		body = []byte(base64.StdEncoding.EncodeToString(body))
+	}
+	req := httptest.NewRequest(http.MethodPost, "/grpc.health.v1.Health/Check", bytes.NewReader(body))
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("X-Grpc-Web", "1")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	return w
+}
+
+func TestBridge(t *testing.T) {
+	handler := stubServer(t)
+	cases := []struct {
+		name        string
+		contentType string
+		text        bool
+	}{
+		{"binary", CONTENT_TYPE_GRPC_WEB, false},
+		{"binary proto", CONTENT_TYPE_GRPC_WEB + "+proto", false},
+		{"text", CONTENT_TYPE_GRPC_WEB_TEXT, true},
+		{"text proto", CONTENT_TYPE_GRPC_WEB_TEXT + "+proto", true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := healthRequest(t, handler, tc.contentType, "engine", tc.text)
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d, body %q", w.Code, w.Body.String())
+			}
+			if got := w.Header().Get("Content-Type"); got != tc.contentType {
+				t.Errorf("content type = %q, want %q", got, tc.contentType)
+			}
+			body := w.Body.Bytes()
+			if tc.text {
+				decoded, err := base64.StdEncoding.DecodeString(string(body))
+				if err != nil {
+					t.Fatalf("text response is not base64: %v", err)
+				}
+				body = decoded
+			}
+			frames := parseFrames(t, body)
+			if len(frames) != 2 || frames[0].flag != 0 || frames[1].flag != trailerFrameFlag {
+				t.Fatalf("frames = %+v, want data and trailer", frames)
+			}
+			res := &healthpb.HealthCheckResponse{}
+			if err := proto.Unmarshal(frames[0].data, res); err != nil {
+				t.Fatal(err)
+			}
+			if res.Status != healthpb.HealthCheckResponse_SERVING {
+				t.Errorf("health status = %v", res.Status)
+			}
+			if lines := trailerLines(frames[1].data); !containsLine(lines, "grpc-status: 0") {
+				t.Errorf("trailers = %q, want grpc-status 0", lines)
+			}
+			// 状态只在 trailer 中
+			if w.Header().Get("Grpc-Status") != "" {
+				t.Errorf("grpc-status in headers %v", w.Header())
+			}
+		})
+	}
+}
+
+func TestBridgeErrorStatus(t *testing.T) {
+	w := healthRequest(t, stubServer(t), CONTENT_TYPE_GRPC_WEB, "missing", false)
+	frames := parseFrames(t, w.Body.Bytes())
+	if len(frames) == 0 || frames[len(frames)-1].flag != trailerFrameFlag {
+		t.Fatalf("frames = %+v, want trailer last", frames)
+	}
+	lines := trailerLines(frames[len(frames)-1].data)
+	// NotFound
+	if !containsLine(lines, "grpc-status: 5") {
+		t.Errorf("trailers = %q, want grpc-status 5", lines)
+	}
+}
+
+func TestBridgeRejects(t *testing.T) {
+	handler := stubServer(t)
+	req := httptest.NewRequest(http.MethodPost, "/grpc.health.v1.Health/Check", nil)
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusUnsupportedMediaType {
+		t.Errorf("non grpc-web status = %d", w.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/grpc.health.v1.Health/Check", strings.NewReader("!!!"))
+	req.Header.Set("Content-Type", CONTENT_TYPE_GRPC_WEB_TEXT)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("invalid text body status = %d", w.Code)
+	}
+}
+
+func containsLine(lines []string, want string) bool {
+	for _, line := range lines {
+		if line == want {
+			return true
+		}
+	}
+	return false
+}
+
+func TestDecodeText(t *testing.T) {
+	cases := []struct {
+		name    string
+		body    string
+		want    []byte
+		wantErr bool
+	}{
+		{"empty", "", nil, false},
+		{"single", "AQID", []byte{1, 2, 3}, false},
+		{"padded", "AQ==", []byte{1}, false},
+		// 每个分块可单独填充
+		{"padded chunks", "AQ==AgM=BA==", []byte{1, 2, 3, 4}, false},
+		{"aligned then padded", "AQIDBA==", []byte{1, 2, 3, 4}, false},
+		{"whitespace", " AQID\r\n", []byte{1, 2, 3}, false},
+		{"invalid", "A!==", nil, true},
+		{"truncated", "AQI", nil, true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := decodeText([]byte(tc.body))
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("decodeText(%q) error = %v, want error %v", tc.body, err, tc.wantErr)
+			}
+			if !tc.wantErr && !bytes.Equal(got, tc.want) {
+				t.Errorf("decodeText(%q) = %v, want %v", tc.body, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestGrpcContentType(t *testing.T) {
+	cases := map[string]string{
+		CONTENT_TYPE_GRPC_WEB:                          "application/grpc+proto",
+		CONTENT_TYPE_GRPC_WEB_TEXT:                     "application/grpc+proto",
+		CONTENT_TYPE_GRPC_WEB + "+json":                "application/grpc+json",
+		CONTENT_TYPE_GRPC_WEB_TEXT + "+proto; charset": "application/grpc+proto",
+	}
+	for in, want := range cases {
+		if got := grpcContentType(in); got != want {
+			t.Errorf("grpcContentType(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestTextResponseWriter(t *testing.T) {
+	w := httptest.NewRecorder()
+	rw := newResponseWriter(w, CONTENT_TYPE_GRPC_WEB_TEXT, true)
+	var written []byte
+	for _, chunk := range [][]byte{{1}, {2, 3, 4, 5}, {6}, {7, 8, 9, 10}} {
+		rw.Write(chunk)
+		written = append(written, chunk...)
+		// 只输出 3 字节对齐的部分, 中间不出现填充
+		if body := w.Body.String(); strings.Contains(body, "=") || len(body)%4 != 0 {
+			t.Fatalf("partial body %q not aligned", body)
+		}
+	}
+	if w.Body.Len() != base64.StdEncoding.EncodedLen(len(written)/3*3) {
+		t.Errorf("body length %d before finish", w.Body.Len())
+	}
+	rw.finish()
+	decoded, err := decodeText(w.Body.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(decoded, written) {
+		t.Fatalf("decoded %v, want prefix %v", decoded, written)
+	}
+	frames := parseFrames(t, decoded[len(written):])
+	if len(frames) != 1 || frames[0].flag != trailerFrameFlag || len(frames[0].data) != 0 {
+		t.Errorf("trailer frames = %+v, want one empty", frames)
+	}
+}
+
+func TestTrailerFrame(t *testing.T) {
+	w := httptest.NewRecorder()
+	rw := newResponseWriter(w, CONTENT_TYPE_GRPC_WEB, false)
+	rw.Header().Set("Trailer", "Grpc-Status, Grpc-Message")
+	rw.Header().Set("X-Engine", "e1")
+	rw.Header().Set("Grpc-Status", "header value ignored until finish")
+	rw.WriteHeader(http.StatusOK)
+	rw.Write([]byte{0, 0, 0, 0, 0})
+	// 声明的 trailer, TrailerPrefix 和写出 header 后新增的 header 都进入 trailer frame
+	rw.Header().Set("Grpc-Status", "3")
+	rw.Header().Set("Grpc-Message", "bad")
+	rw.Header().Set(http2.TrailerPrefix+"X-Cost", "12")
+	rw.Header().Add("X-Late", "a")
+	rw.Header().Add("X-Late", "b")
+	rw.finish()
+
+	if got := w.Header().Get("X-Engine"); got != "e1" {
+		t.Errorf("header X-Engine = %q", got)
+	}
+	for _, name := range []string{"Grpc-Status", "Grpc-Message", "Trailer"} {
+		if _, ok := w.Header()[name]; ok {
+			t.Errorf("%s written as header", name)
+		}
+	}
+	frames := parseFrames(t, w.Body.Bytes())
+	if len(frames) != 2 || frames[1].flag != trailerFrameFlag {
+		t.Fatalf("frames = %+v", frames)
+	}
+	want := []string{"grpc-message: bad", "grpc-status: 3", "x-cost: 12", "x-late: a", "x-late: b"}
+	if got := trailerLines(frames[1].data); strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("trailer lines = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,154 @@
+package proxy
+
+import (
+	"net/http"
+	"rpc-gateway/pkg/core/config"
+	"rpc-gateway/pkg/plugins/httpserver/middleware"
+	"strings"
+)
+
+// hop-by-hop headers, RFC 7230 section 6.1
+var hopHeaders = []string{
+	"Connection",
+	"Proxy-Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+// headers managed by client/server lib
+var managedHeaders = []string{
+	"Host",
+	"Content-Length",
+}
+
+// route header policy
+type HeaderPolicy struct {
+	RequestAllow   []string          // 透传白名单 (为空则透传全部)
+	RequestDeny    []string          // 透传黑名单
+	RequestAdd     map[string]string // 静态添加的请求头
+	ResponseSet    map[string]string // 覆盖的响应头
+	ResponseRemove []string          // 删除的响应头
+	ResponseRename map[string]string // 重命名的响应头
+}
+
+// new header policy from route config
+func newHeaderPolicy(headers config.RouteHeaders) *HeaderPolicy {
+	return &HeaderPolicy{
+		RequestAllow:   headers.Request.Allow,
+		RequestDeny:    headers.Request.Deny,
+		RequestAdd:     headers.Request.Add,
+		ResponseSet:    headers.Response.Set,
+		ResponseRemove: headers.Response.Remove,
+		ResponseRename: headers.Response.Rename,
+	}
+}
+
+// apply request policy, return upstream header
+func (policy *HeaderPolicy) applyRequest(src http.Header) http.Header {
+	dst := make(http.Header)
+	for k, v := range src {
+		dst[k] = append([]string(nil), v...)
+	}
+	removeHopHeaders(dst)
+	for _, name := range managedHeaders {
+		dst.Del(name)
+	}
+	// gateway session cookie never goes upstream
+	removeCookie(dst, middleware.SESSION_COOKIE_NAME)
+
+	if len(policy.RequestAllow) > 0 {
+		allowed := make(map[string]bool)
+		for _, name := range policy.RequestAllow {
+			allowed[http.CanonicalHeaderKey(name)] = true
+		}
+		for name := range dst {
+			if !allowed[name] {
+				delete(dst, name)
+			}
+		}
+	}
+
+	for _, name := range policy.RequestDeny {
+		dst.Del(name)
+	}
+
+	for name, value := range policy.RequestAdd {
+		dst.Set(name, value)
+	}
+
+	return dst
+}
+
+// apply response policy, return client header
+func (policy *HeaderPolicy) applyResponse(src http.Header) http.Header {
+	dst := make(http.Header)
+	for k, v := range src {
+		dst[k] = append([]string(nil), v...)
+	}
+	removeHopHeaders(dst)
+	for _, name := range managedHeaders {
+		dst.Del(name)
+	}
+
+	for from, to := range policy.ResponseRename {
+		if values := dst.Values(from); len(values) > 0 {
+			dst.Del(from)
+			for _, value := range values {
+				dst.Add(to, value)
+			}
+		}
+	}
+
+	for _, name := range policy.ResponseRemove {
+		dst.Del(name)
+	}
+
+	for name, value := range policy.ResponseSet {
+		dst.Set(name, value)
+	}
+
+	return dst
+}
+
+// remove hop-by-hop headers
+func removeHopHeaders(h http.Header) {
+	// headers listed in Connection
+	for _, value := range h.Values("Connection") {
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				h.Del(name)
+			}
+		}
+	}
+	for _, name := range hopHeaders {
+		h.Del(name)
+	}
+}
+
+// remove cookie by name
+func removeCookie(h http.Header, cookieName string) {
+	values := h.Values("Cookie")
+	if len(values) == 0 {
+		return
+	}
+	h.Del("Cookie")
+
+	var kept []string
+	for _, value := range values {
+		for _, pair := range strings.Split(value, ";") {
+			pair = strings.TrimSpace(pair)
+			if pair == "" || strings.HasPrefix(pair, cookieName+"=") {
+				continue
+			}
+			kept = append(kept, pair)
+		}
+	}
+	if len(kept) > 0 {
+		h.Set("Cookie", strings.Join(kept, "; "))
+	}
+}
@@ -0,0 +1,145 @@
+package proxy
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestApplyRequest(t *testing.T) {
+	cases := []struct {
+		name   string
+		policy HeaderPolicy
+		src    http.Header
+		want   http.Header
+	}{
+		{
+			name:   "hop and managed headers",
+			policy: HeaderPolicy{},
+			src: http.Header{
+				"Connection":        {"keep-alive, X-Hop"},
+				"X-Hop":             {"1"},
+				"Keep-Alive":        {"timeout=5"},
+				"Transfer-Encoding": {"chunked"},
+				"Upgrade":           {"h2c"},
+				"Host":              {"gateway"},
+				"Content-Length":    {"10"},
+				"Accept":            {"*/*"},
+			},
+			want: http.Header{"Accept": {"*/*"}},
+		},
+		{
+			name:   "session cookie",
+			policy: HeaderPolicy{},
+			src:    http.Header{"Cookie": {"a=1; TICKET=secret", "b=2"}},
+			want:   http.Header{"Cookie": {"a=1; b=2"}},
+		},
+		{
+			name:   "only session cookie",
+			policy: HeaderPolicy{},
+			src:    http.Header{"Cookie": {"TICKET=secret"}},
+			want:   http.Header{},
+		},
+		{
+			name:   "allow",
+			policy: HeaderPolicy{RequestAllow: []string{"x-request-id", "Accept"}},
+			src:    http.Header{"X-Request-Id": {"r1"}, "Accept": {"*/*"}, "Authorization": {"Bearer t"}},
+			want:   http.Header{"X-Request-Id": {"r1"}, "Accept": {"*/*"}},
+		},
+		{
+			name:   "deny",
+			policy: HeaderPolicy{RequestDeny: []string{"authorization"}},
+			src:    http.Header{"Accept": {"*/*"}, "Authorization": {"Bearer t"}},
+			want:   http.Header{"Accept": {"*/*"}},
+		},
+		{
+			name: "add after allow and deny",
+			policy: HeaderPolicy{
+				RequestAllow: []string{"Accept"},
+				RequestDeny:  []string{"X-Source"},
+				RequestAdd:   map[string]string{"X-Source": "gateway"},
+			},
+			src:  http.Header{"Accept": {"*/*"}, "X-Source": {"client"}},
+			want: http.Header{"Accept": {"*/*"}, "X-Source": {"gateway"}},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			src := tc.src.Clone()
+			got := tc.policy.applyRequest(tc.src)
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("applyRequest = %v, want %v", got, tc.want)
+			}
+			// 不修改原请求头
+			if !reflect.DeepEqual(tc.src, src) {
+				t.Errorf("source header modified to %v", tc.src)
+			}
+		})
+	}
+}
+
+func TestApplyResponse(t *testing.T) {
+	cases := []struct {
+		name   string
+		policy HeaderPolicy
+		src    http.Header
+		want   http.Header
+	}{
+		{
+			name:   "hop and managed headers",
+			policy: HeaderPolicy{},
+			src:    http.Header{"Trailer": {"X-Sum"}, "Content-Length": {"10"}, "Content-Type": {"text/plain"}},
+			want:   http.Header{"Content-Type": {"text/plain"}},
+		},
+		{
+			name:   "rename keeps values",
+			policy: HeaderPolicy{ResponseRename: map[string]string{"x-engine-id": "X-Backend"}},
+			src:    http.Header{"X-Engine-Id": {"e1", "e2"}},
+			want:   http.Header{"X-Backend": {"e1", "e2"}},
+		},
+		{
+			name:   "rename missing",
+			policy: HeaderPolicy{ResponseRename: map[string]string{"X-Engine-Id": "X-Backend"}},
+			src:    http.Header{"Content-Type": {"text/plain"}},
+			want:   http.Header{"Content-Type": {"text/plain"}},
+		},
+		{
+			name: "remove then set",
+			policy: HeaderPolicy{
+				ResponseRemove: []string{"server", "X-Powered-By"},
+				ResponseSet:    map[string]string{"Server": "gateway", "Cache-Control": "no-store"},
+			},
+			src:  http.Header{"Server": {"engine"}, "X-Powered-By": {"go"}, "Cache-Control": {"max-age=60"}},
+			want: http.Header{"Server": {"gateway"}, "Cache-Control": {"no-store"}},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := tc.policy.applyResponse(tc.src)
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("applyResponse = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestHeaderContainsToken(t *testing.T) {
+	cases := []struct {
+		values []string
+		token  string
+		want   bool
+	}{
+		{[]string{"Upgrade"}, "upgrade", true},
+		{[]string{"keep-alive, Upgrade"}, "upgrade", true},
+		{[]string{"keep-alive", " upgrade "}, "upgrade", true},
+		{[]string{"keep-alive"}, "upgrade", false},
+		{[]string{"upgraded"}, "upgrade", false},
+		{nil, "upgrade", false},
+	}
+	for _, tc := range cases {
+		h := http.Header{"Connection": tc.values}
+		if got := headerContainsToken(h, "Connection", tc.token); got != tc.want {
+			t.Errorf("headerContainsToken(%q, %q) = %v, want %v", tc.values, tc.token, got, tc.want)
+		}
+	}
+}
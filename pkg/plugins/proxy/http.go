@@ -1,13 +1,16 @@
 package proxy
 
 import (
+	"encoding/json"
 	"fmt"
-	"os"
+	"reflect"
+	"rpc-gateway/pkg/core/config"
 	logging "rpc-gateway/pkg/core/log"
-	"rpc-gateway/pkg/plugins/httpserver/controller"
+	"rpc-gateway/pkg/plugins/health"
+	"rpc-gateway/pkg/plugins/httpserver/db"
 	"rpc-gateway/pkg/plugins/httpserver/middleware"
-	"rpc-gateway/pkg/plugins/httpserver/util"
-	"strconv"
+	"rpc-gateway/pkg/plugins/proxy/cache"
+	"rpc-gateway/pkg/plugins/proxy/transcode"
 
 	"github.com/gin-gonic/gin"
 	"github.com/spf13/viper"
@@ -16,6 +19,7 @@ import (
 	"net/http"
 	"net/url"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/fsnotify/fsnotify"
@@ -24,11 +28,20 @@ import (
 
 const (
 	TIME_DURATION = 10
+	CACHE_TIME    = 10
 )
 
-var routeMap map[string]string
 var routerViper *viper.Viper
 
+// current routes by route key, registered handlers look up the route on every request
+var routeTable map[string]*HttpRoute
+var routeLock sync.RWMutex
+
+// routes applied and route keys registered in gin, gin routes can not be removed
+var appliedRoutes []config.Route
+var registeredRoutes = make(map[string]bool)
+var applyLock sync.Mutex
+
 // http request struct
 type HttpRequest struct {
 	Header    http.Header
@@ -39,66 +52,90 @@ type HttpRequest struct {
 	CacheTime int
 }
 
-// init
-func init() {
-	routerViper = viper.New()
-	routerViper.SetConfigName("RouteConfig")
-	routerViper.SetConfigType("yaml")
-	routerViper.AddConfigPath("config/")
-	routeMap = make(map[string]string)
+// http response struct
+type HttpResponse struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+}
+
+// http route struct
+type HttpRoute struct {
+	Path         string
+	Method       string
+	To           string
+	Cache        bool     // 是否开启响应缓存
+	CacheTime    int      // 缓存时间 (second)
+	CacheHeaders []string // 参与缓存 key 的请求头
+	Headers      *HeaderPolicy
+	WebSocket    bool // 是否 websocket 路由
+	IdleTimeout  int  // websocket 空闲超时 (second)
+}
+
+// init router config
+func initRouterConfig() {
+	routerViper = config.NewViper(config.ROUTE_CONFIG)
 	err := routerViper.ReadInConfig()
 	if err != nil {
 		panic(fmt.Errorf("fatal error config file: %s", err))
 	}
 }
 
-/*
-* http server
-* endless example :
-sysType := runtime.GOOS
-switch sysType {
-case "windows":
-	r.Run(":" + serverPort)
-default:
-	//end server
-	endless.ListenAndServe(":"+serverPort, r)
-}
-*/
+// http server
+//
+// endless example:
+//
+//	sysType := runtime.GOOS
+//	switch sysType {
+//	case "windows":
+//		r.Run(":" + serverPort)
+//	default:
+//		// end server
+//		endless.ListenAndServe(":"+serverPort, r)
+//	}
 func (plugin *Plugin) HttpServer() {
-	util.InitConfig()
 	// get gRPC port
 	defer func() {
 		plugin.Status <- false
 	}()
+	// init router config
+	initRouterConfig()
 	r := gin.Default()
+	// init response cache
+	cache.Init()
 	definitionRoute(r)
 	//get server port
-	serverPort := os.Getenv("HTTP_SERVER_PORT")
-	if serverPort == "" {
-		serverPort = viper.GetString("HTTP_SERVER_PORT")
-	}
+	serverPort := config.Get().App.HttpServerPort
 	// log server addr
 	logging.Log.Info("http server runing :" + serverPort)
-	r.Run(":" + serverPort)
+	lis, err := listen("http", ":"+serverPort)
+	if err != nil {
+		logging.Log.Errorf("failed to listen: %v", err)
+		return
+	}
+	if err := http.Serve(lis, r); err != nil {
+		health.ListenerFailed("http", lis.Addr().String(), err)
+		logging.Log.Errorf("failed to serve http: %v", err)
+	}
 }
 
 // http proxy
 // http request
-func (httpReq *HttpRequest) Request() (string, error) {
-	var body string = ""
+func (httpReq *HttpRequest) Request() (*HttpResponse, error) {
+	var res *HttpResponse
 	var err error
 
 	method := strings.ToUpper(httpReq.Method)
 	switch method {
 	case "GET":
-		body, err = getRequest(httpReq.Query.(string), httpReq.TimeOut)
+		res, err = getRequest(httpReq.Query.(string), httpReq.TimeOut, httpReq.Header)
 	case "POST":
-		body, err = postRequest(httpReq.To, httpReq.Query.(url.Values), httpReq.TimeOut, httpReq.Header)
+		res, err = postRequest(httpReq.To, httpReq.Query.(url.Values), httpReq.TimeOut, httpReq.Header)
 	default:
 		err = errors.New("http request any method")
 	}
 
-	return body, err
+	return res, err
 }
 
 // get request uri
@@ -133,7 +170,7 @@ func getRequestUrl(to string, c *gin.Context) string {
 }
 
 // http get
-func getRequest(u string, timeOut int) (string, error) {
+func getRequest(u string, timeOut int, header http.Header) (*HttpResponse, error) {
 	timeout := time.Duration(timeOut) * time.Second
 
 	cli := fasthttp.Client{
@@ -147,22 +184,27 @@ func getRequest(u string, timeOut int) (string, error) {
 		fasthttp.ReleaseResponse(res)
 	}()
 
-	req.Header.SetContentType("application/json")
+	for k, v := range header {
+		for _, value := range v {
+			req.Header.Add(k, value)
+		}
+	}
+
+	if len(req.Header.ContentType()) == 0 {
+		req.Header.SetContentType("application/json")
+	}
 	req.Header.SetMethod("GET")
 	req.SetRequestURI(u)
 
 	if err := cli.DoTimeout(req, res, timeout); err != nil {
-		return "", err
+		return nil, err
 	}
 
-	body := res.Body()
-	bodyStr := string(body)
-
-	return bodyStr, nil
+	return newHttpResponse(res), nil
 }
 
 // http post
-func postRequest(to string, param map[string][]string, timeOut int, header http.Header) (string, error) {
+func postRequest(to string, param map[string][]string, timeOut int, header http.Header) (*HttpResponse, error) {
 	timeout := time.Duration(timeOut) * time.Second
 
 	cli := fasthttp.Client{
@@ -192,50 +234,99 @@ func postRequest(to string, param map[string][]string, timeOut int, header http.
 	}
 
 	if err := cli.DoTimeout(req, res, timeout); err != nil {
-		return "", err
+		return nil, err
 	}
 
-	body := res.Body()
-	bodyStr := string(body)
+	return newHttpResponse(res), nil
+}
+
+// copy fasthttp response
+func newHttpResponse(res *fasthttp.Response) *HttpResponse {
+	header := make(http.Header)
+	res.Header.VisitAll(func(k, v []byte) {
+		header.Add(string(k), string(v))
+	})
+	// body is released with response
+	body := make([]byte, len(res.Body()))
+	copy(body, res.Body())
+
+	return &HttpResponse{
+		StatusCode: res.StatusCode(),
+		Header:     header,
+		Body:       body,
+	}
+}
 
-	return bodyStr, nil
+// write response
+func writeResponse(c *gin.Context, route *HttpRoute, res *HttpResponse) {
+	header := route.Headers.applyResponse(res.Header)
+	for k, v := range header {
+		for _, value := range v {
+			c.Writer.Header().Add(k, value)
+		}
+	}
+	c.Data(res.StatusCode, header.Get("Content-Type"), res.Body)
 }
 
 // gin
 // run
-func runProxy(c *gin.Context, to string) {
-	result := make(chan string)
-	resultErr := make(chan error)
+func runProxy(c *gin.Context, route *HttpRoute) {
+	result := make(chan *HttpResponse, 1)
+	resultErr := make(chan error, 1)
 	var queryStr interface{}
 
-	queryStr = getRequestUrl(to, c)
+	queryStr = getRequestUrl(route.To, c)
 	if strings.ToUpper(c.Request.Method) == "POST" {
 		c.Request.ParseForm()
 		queryStr = c.Request.PostForm
 	}
 
 	httpReq := HttpRequest{
-		Header:    c.Request.Header,
+		Header:    route.Headers.applyRequest(c.Request.Header),
 		Method:    c.Request.Method,
-		To:        to,
+		To:        route.To,
 		Query:     queryStr,
 		TimeOut:   10,
-		CacheTime: 10,
+		CacheTime: route.CacheTime,
+	}
+
+	// response cache
+	cacheKey, cacheStore := "", false
+	if route.Cache {
+		reqCacheControl := cache.ParseControl(c.Request.Header)
+		if !reqCacheControl.NoStore {
+			cacheStore = true
+			cacheKey = getCacheKey(c, route)
+			// no-cache or max-age=0 revalidate with upstream, credentialed requests are not served from cache
+			if !reqCacheControl.NoCache && reqCacheControl.MaxAge != 0 && !cache.Authenticated(c.Request.Header) {
+				if res, ok := getCacheResponse(cacheKey); ok {
+					c.Header("X-Cache", "HIT")
+					writeResponse(c, route, res)
+					return
+				}
+			}
+			c.Header("X-Cache", "MISS")
+		}
 	}
 
 	t := time.NewTimer(30 * time.Second)
+	defer t.Stop()
 
 	go func() {
-		bodyStr, err := httpReq.Request()
+		res, err := httpReq.Request()
 		if err != nil {
 			resultErr <- err
+			return
 		}
-		result <- bodyStr
+		result <- res
 	}()
 
 	select {
 	case res := <-result:
-		c.String(http.StatusOK, res)
+		if cacheStore && res.StatusCode == http.StatusOK {
+			setCacheResponse(cacheKey, c.Request.Header, res, time.Duration(httpReq.CacheTime)*time.Second)
+		}
+		writeResponse(c, route, res)
 	case err := <-resultErr:
 		c.String(http.StatusInternalServerError, fmt.Sprintln(err))
 	case <-t.C:
@@ -243,44 +334,73 @@ func runProxy(c *gin.Context, to string) {
 	}
 }
 
+// get cache key
+func getCacheKey(c *gin.Context, route *HttpRoute) string {
+	query := c.Request.URL.Query()
+	if strings.ToUpper(c.Request.Method) == "POST" {
+		c.Request.ParseForm()
+		query = c.Request.PostForm
+	}
+	return cache.BuildKey(c.Request.Method, route.Path, query, c.Request.Header, route.CacheHeaders)
+}
+
+// get cache response
+func getCacheResponse(key string) (*HttpResponse, bool) {
+	data, ok := cache.Get(key)
+	if !ok {
+		return nil, false
+	}
+	var entry cache.Entry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, false
+	}
+	return &HttpResponse{
+		StatusCode: entry.StatusCode,
+		Header:     entry.Header,
+		Body:       entry.Body,
+	}, true
+}
+
+// set cache response
+func setCacheResponse(key string, reqHeader http.Header, res *HttpResponse, cacheTime time.Duration) {
+	ttl := cache.ResponseTTL(reqHeader, res.Header, cacheTime)
+	if ttl <= 0 {
+		return
+	}
+	data, err := json.Marshal(cache.Entry{
+		StatusCode: res.StatusCode,
+		Header:     res.Header,
+		Body:       res.Body,
+	})
+	if err != nil {
+		return
+	}
+	if err := cache.Set(key, data, ttl); err != nil {
+		logging.Log.Error("set http response cache error: ", err)
+	}
+}
+
 // definite route
 func definitionRoute(router *gin.Engine) {
+	app := config.Get().App
 	// set run mode
-	runMode := os.Getenv("HTTP_DEBUG_MODE")
-	if runMode == "" {
-		runMode = viper.GetString("HTTP_DEBUG_MODE")
-	}
-	gin.SetMode(runMode)
+	gin.SetMode(strings.ToLower(app.HttpDebugMode))
 	// middleware
 	router.Use(gin.Recovery())
 	// router.Use(middleware.Tracing())
 	router.Use(middleware.UseCookieSession())
 	//setting time out duration
-	var timeOutNum time.Duration
-	timeOutDuration := os.Getenv("HTTP_TIME_DURATION")
-	if timeOutDuration == "" {
-		timeOutNum = (time.Duration)(viper.GetInt64("HTTP_TIME_DURATION"))
-	} else {
-		timeOutNumInt64, _ := strconv.ParseInt(timeOutDuration, 10, 64)
-		timeOutNum = (time.Duration)(timeOutNumInt64)
-	}
+	timeOutNum := time.Duration(app.HttpTimeDuration)
+	router.Use(markLongLived)
 	router.Use(middleware.TimeoutHandler(time.Second * timeOutNum))
-	// metrics data api
-	var metricsController *controller.MetricsController
-	// metrics api
-	router.GET("/engine/metricsdata", metricsController.GetEngineMetricsData)
-	var vsController *controller.VSController
-	// vs api
-	router.POST("/engine/create", vsController.CreateEngine)
-	router.POST("/engine/update", vsController.UpdateEngine)
-	router.POST("/engine/delete", vsController.DeleteEngine)
-	router.POST("/engine/check", vsController.CheckEngine)
 	// no route
 	router.NoRoute(noRouteResponse)
 	// add route fist
 	getRouterTask(router)
 	// watch route
 	watchRouter(router)
+	// routes shared by replicas
+	watchRouteInventory(router)
 }
 
 // watch router
@@ -295,51 +415,176 @@ func watchRouter(r *gin.Engine) {
 
 // get router task
 func getRouterTask(r *gin.Engine) {
-	rvRoot := routerViper.AllSettings()
-	rvMap := rvRoot["route"]
-	if rvMap == nil {
+	routeConfig, errs := config.DecodeRoute(routerViper, false)
+	if len(errs) > 0 {
+		for _, err := range errs {
+			logging.Log.Error("route config error: ", err)
+		}
 		return
 	}
+	// http/json to grpc transcode
+	transcode.Init(routeConfig.Transcode)
+	// 开启 db 时路由由 db 同步, RouteConfig.yaml 只用于初始化
+	if db.Enabled() {
+		if err := db.SeedRoutes(routeConfig.Route); err != nil {
+			logging.Log.Error("seed routes to db error: ", err)
+		}
+		// db 不可用时保留当前路由, 启动时使用 RouteConfig.yaml
+		if syncRouteInventory(r) || routesApplied() {
+			return
+		}
+		logging.Log.Warn("routes of db not loaded, use ", config.ROUTE_CONFIG)
+	}
+	applyRoutes(routeConfig.Route, r)
+}
 
-	for _, v := range rvMap.([]interface{}) {
-		addRoute(v, r)
+// poll routes shared by replicas in db
+func watchRouteInventory(r *gin.Engine) {
+	if !db.Enabled() {
+		return
 	}
+	go db.Poll(func() {
+		syncRouteInventory(r)
+	})
 }
 
-// add route
-func addRoute(v interface{}, r *gin.Engine) {
-	rmap := v.(map[interface{}]interface{})
-	rmapStr := make(map[string]string)
-	for k, v := range rmap {
-		strKey := fmt.Sprintf("%v", k)
-		strValue := fmt.Sprintf("%v", v)
-		rmapStr[strKey] = strValue
+// apply db routes when changed by admin api of any replica, false when db routes not loaded
+func syncRouteInventory(r *gin.Engine) bool {
+	routes, err := db.RouteConfigs()
+	if err != nil {
+		logging.Log.Error("load routes from db error: ", err)
+		return false
 	}
+	if errs := config.ValidateRoutes(routes); len(errs) > 0 {
+		logging.Log.Error("db routes invalid, keep current routes: \n", errs)
+		return false
+	}
+	applyRoutes(routes, r)
+	return true
+}
 
-	if _, exist := routeMap[rmapStr["path"]]; exist {
-		logging.Log.Error("error: route ", rmapStr["path"], " exist !")
+// replace routes, new route keys are registered in gin, removed routes respond as no route
+func applyRoutes(routes []config.Route, r *gin.Engine) {
+	applyLock.Lock()
+	defer applyLock.Unlock()
+	if appliedRoutes != nil && reflect.DeepEqual(routes, appliedRoutes) {
 		return
-	} else {
-		routeMap[rmapStr["path"]] = rmapStr["to"]
 	}
+	table := make(map[string]*HttpRoute, len(routes))
+	paths := make(map[string]bool, len(routes))
+	for _, routeItem := range routes {
+		if paths[routeItem.Path] {
+			logging.Log.Error("error: route ", routeItem.Path, " exist !")
+			continue
+		}
+		paths[routeItem.Path] = true
+		route := newHttpRoute(routeItem)
+		key := routeKey(route)
+		table[key] = route
+		if !registeredRoutes[key] {
+			registerRoute(r, route, key)
+			registeredRoutes[key] = true
+		}
+	}
+	routeLock.Lock()
+	routeTable = table
+	routeLock.Unlock()
+	appliedRoutes = routes
+	logging.Log.Info("routes applied, ", len(table), " routes")
+}
+
+func routesApplied() bool {
+	applyLock.Lock()
+	defer applyLock.Unlock()
+	return appliedRoutes != nil
+}
 
-	// get method
-	if rmap["method"] == "get" {
-		r.GET(rmapStr["path"], func(c *gin.Context) {
-			runProxy(c, rmapStr["to"])
-		})
+// http route of config with default setting
+func newHttpRoute(routeItem config.Route) *HttpRoute {
+	route := &HttpRoute{
+		Path:         routeItem.Path,
+		Method:       strings.ToLower(routeItem.Method),
+		To:           routeItem.To,
+		Cache:        routeItem.Cache,
+		CacheTime:    routeItem.CacheTime,
+		CacheHeaders: routeItem.CacheHeaders,
+		Headers:      newHeaderPolicy(routeItem.Headers),
+		WebSocket:    routeItem.WebSocket,
+		IdleTimeout:  routeItem.IdleTimeout,
 	}
+	// default setting
+	if route.CacheTime == 0 {
+		route.CacheTime = CACHE_TIME
+	}
+	if route.IdleTimeout == 0 {
+		route.IdleTimeout = WS_IDLE_TIMEOUT
+	}
+	return route
+}
+
+// <method> <path>, websocket route is get
+func routeKey(route *HttpRoute) string {
+	if route.WebSocket {
+		return "get " + route.Path
+	}
+	return route.Method + " " + route.Path
+}
 
+// current route of key, nil when removed
+func currentRoute(key string) *HttpRoute {
+	routeLock.RLock()
+	defer routeLock.RUnlock()
+	return routeTable[key]
+}
+
+// register route handler in gin
+func registerRoute(r *gin.Engine, route *HttpRoute, key string) {
+	handler := func(c *gin.Context) {
+		route := currentRoute(key)
+		if route == nil {
+			noRouteResponse(c)
+			return
+		}
+		// websocket route
+		if route.WebSocket {
+			runWebSocketProxy(c, route)
+			return
+		}
+		runProxy(c, route)
+	}
+	if route.WebSocket || route.Method == "get" {
+		r.GET(route.Path, handler)
+		return
+	}
 	// post method
-	if rmap["method"] == "post" {
-		r.POST(rmapStr["path"], func(c *gin.Context) {
-			runProxy(c, rmapStr["to"])
-		})
+	if route.Method == "post" {
+		r.POST(route.Path, handler)
+	}
+}
+
+// mark requests skipping TimeoutHandler, decided by the matched route, not request headers
+func markLongLived(c *gin.Context) {
+	// server streaming transcode is decided by the matched binding, not the Accept header
+	if c.FullPath() == "" {
+		if transcode.Streaming(c) {
+			c.Set(middleware.STREAMING_KEY, true)
+		}
+		return
+	}
+	// websocket 连接长期保持, 只有 websocket 路由跳过超时
+	if c.Request.Method == http.MethodGet {
+		if route := currentRoute("get " + c.FullPath()); route != nil && route.WebSocket {
+			c.Set(middleware.WEBSOCKET_KEY, true)
+		}
 	}
 }
 
 // no route
 func noRouteResponse(c *gin.Context) {
+	// try http/json to grpc transcode
+	if transcode.Handle(c) {
+		return
+	}
 	c.JSON(http.StatusNotFound, gin.H{
 		"code":  404,
 		"error": "oops, page not exists!",
@@ -0,0 +1,149 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"rpc-gateway/pkg/core/config"
+	logging "rpc-gateway/pkg/core/log"
+	"rpc-gateway/pkg/plugins/httpserver/middleware"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+func resetRoutes() {
+	routeTable = nil
+	appliedRoutes = nil
+	registeredRoutes = make(map[string]bool)
+}
+
+func TestApplyRoutes(t *testing.T) {
+	logging.Log = zap.NewNop().Sugar()
+	gin.SetMode(gin.TestMode)
+	resetRoutes()
+	r := gin.New()
+	r.NoRoute(noRouteResponse)
+
+	applyRoutes([]config.Route{
+		{Path: "/a", Method: "GET", To: "http://a:8080"},
+		{Path: "/b", Method: "post", To: "http://b:8080", CacheTime: 30},
+		{Path: "/ws", To: "ws://ws:8080", WebSocket: true},
+		// 重复 path 忽略
+		{Path: "/a", Method: "post", To: "http://other:8080"},
+	}, r)
+	cases := []struct {
+		key string
+		to  string
+	}{
+		{"get /a", "http://a:8080"},
+		{"post /b", "http://b:8080"},
+		{"get /ws", "ws://ws:8080"},
+		{"post /a", ""},
+	}
+	for _, c := range cases {
+		route := currentRoute(c.key)
+		if c.to == "" {
+			if route != nil {
+				t.Errorf("route %s = %+v, want none", c.key, route)
+			}
+			continue
+		}
+		if route == nil || route.To != c.to {
+			t.Errorf("route %s = %+v, want to %s", c.key, route, c.to)
+		}
+	}
+	if route := currentRoute("get /a"); route.CacheTime != CACHE_TIME || route.IdleTimeout != WS_IDLE_TIMEOUT {
+		t.Errorf("default setting of %+v", route)
+	}
+	if route := currentRoute("post /b"); route.CacheTime != 30 {
+		t.Errorf("cache time of %+v", route)
+	}
+
+	// /a 改为 post, /b 删除
+	applyRoutes([]config.Route{
+		{Path: "/a", Method: "post", To: "http://a2:8080"},
+		{Path: "/ws", To: "ws://ws:8080", WebSocket: true},
+	}, r)
+	if route := currentRoute("post /a"); route == nil || route.To != "http://a2:8080" {
+		t.Errorf("updated route = %+v", route)
+	}
+	for _, key := range []string{"get /a", "post /b"} {
+		if route := currentRoute(key); route != nil {
+			t.Errorf("removed route %s = %+v", key, route)
+		}
+		if !registeredRoutes[key] {
+			t.Errorf("gin route %s not registered", key)
+		}
+	}
+
+	// 已删除的路由返回 404
+	for _, req := range []*http.Request{
+		httptest.NewRequest(http.MethodGet, "/a", nil),
+		httptest.NewRequest(http.MethodPost, "/b", nil),
+	} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != http.StatusNotFound {
+			t.Errorf("%s %s = %d, want 404", req.Method, req.URL.Path, w.Code)
+		}
+	}
+}
+
+func TestApplyRoutesUnchanged(t *testing.T) {
+	logging.Log = zap.NewNop().Sugar()
+	resetRoutes()
+	r := gin.New()
+	routes := []config.Route{{Path: "/a", Method: "get", To: "http://a:8080"}}
+	applyRoutes(routes, r)
+	first := currentRoute("get /a")
+	applyRoutes([]config.Route{{Path: "/a", Method: "get", To: "http://a:8080"}}, r)
+	if currentRoute("get /a") != first {
+		t.Error("unchanged routes rebuilt")
+	}
+	if !routesApplied() {
+		t.Error("routes not applied")
+	}
+}
+
+func TestMarkLongLived(t *testing.T) {
+	logging.Log = zap.NewNop().Sugar()
+	gin.SetMode(gin.TestMode)
+	resetRoutes()
+	r := gin.New()
+	r.Use(markLongLived, func(c *gin.Context) {
+		if c.GetBool(middleware.WEBSOCKET_KEY) {
+			c.Header("X-Websocket", "true")
+		}
+		c.AbortWithStatus(http.StatusOK)
+	})
+	r.NoRoute(noRouteResponse)
+	applyRoutes([]config.Route{
+		{Path: "/ws", To: "ws://ws:8080", WebSocket: true},
+		{Path: "/a", Method: "get", To: "http://a:8080"},
+		{Path: "/b", Method: "post", To: "http://b:8080"},
+	}, r)
+
+	cases := []struct {
+		method    string
+		path      string
+		websocket bool
+	}{
+		{http.MethodGet, "/ws", true},
+		// 其他路由带 Upgrade 头也不跳过超时
+		{http.MethodGet, "/a", false},
+		{http.MethodPost, "/b", false},
+		{http.MethodGet, "/missing", false},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest(c.method, c.path, nil)
+		req.Header.Set("Connection", "Upgrade")
+		req.Header.Set("Upgrade", "websocket")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if got := w.Header().Get("X-Websocket") == "true"; got != c.websocket {
+			t.Errorf("%s %s websocket = %v, want %v", c.method, c.path, got, c.websocket)
+		}
+	}
+}
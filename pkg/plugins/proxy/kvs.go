@@ -1,8 +1,23 @@
 package proxy
 
+import (
+	"rpc-gateway/pkg/core/config"
+	logging "rpc-gateway/pkg/core/log"
+	"rpc-gateway/pkg/plugins/vs/kvs"
+)
+
 // k8s vs server
 func (plugin *Plugin) KvsServer() {
 	defer func() {
 		plugin.Status <- false
 	}()
+	cfg := config.Get().VS.Kvs
+	if !cfg.Enabled {
+		return
+	}
+	if err := kvs.Init(cfg); err != nil {
+		logging.Log.Error("kvs init error: ", err)
+		return
+	}
+	logging.Log.Info("kvs client initialized, namespace " + cfg.Namespace)
 }
@@ -0,0 +1,158 @@
+package transcode
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"google.golang.org/grpc"
+	rpb "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+
+	// register google.api.http extension
+	_ "google.golang.org/genproto/googleapis/api/annotations"
+)
+
+// load descriptor set file
+// protoc --include_imports --descriptor_set_out=xxx.pb
+func loadDescriptorSet(path string) (*protoregistry.Files, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	fdSet := &descriptorpb.FileDescriptorSet{}
+	if err := proto.Unmarshal(data, fdSet); err != nil {
+		return nil, fmt.Errorf("unmarshal descriptor set %s: %v", path, err)
+	}
+	return newFiles(fdSet.File, nil)
+}
+
+// load descriptors by server reflection
+func loadFromReflection(ctx context.Context, conn *grpc.ClientConn, services []string) (*protoregistry.Files, error) {
+	stream, err := rpb.NewServerReflectionClient(conn).ServerReflectionInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer stream.CloseSend()
+
+	// list all services
+	if len(services) == 0 {
+		res, err := reflectionRequest(stream, &rpb.ServerReflectionRequest{
+			MessageRequest: &rpb.ServerReflectionRequest_ListServices{},
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, svc := range res.GetListServicesResponse().GetService() {
+			if strings.HasPrefix(svc.Name, "grpc.reflection.") {
+				continue
+			}
+			services = append(services, svc.Name)
+		}
+	}
+
+	files := make(map[string]*descriptorpb.FileDescriptorProto)
+	addFiles := func(res *rpb.ServerReflectionResponse) error {
+		for _, raw := range res.GetFileDescriptorResponse().GetFileDescriptorProto() {
+			fd := &descriptorpb.FileDescriptorProto{}
+			if err := proto.Unmarshal(raw, fd); err != nil {
+				return err
+			}
+			files[fd.GetName()] = fd
+		}
+		return nil
+	}
+
+	for _, svc := range services {
+		res, err := reflectionRequest(stream, &rpb.ServerReflectionRequest{
+			MessageRequest: &rpb.ServerReflectionRequest_FileContainingSymbol{FileContainingSymbol: svc},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if err := addFiles(res); err != nil {
+			return nil, err
+		}
+	}
+
+	// resolve missing dependencies
+	for {
+		var missing []string
+		for _, fd := range files {
+			for _, dep := range fd.GetDependency() {
+				if _, ok := files[dep]; !ok {
+					missing = append(missing, dep)
+				}
+			}
+		}
+		if len(missing) == 0 {
+			break
+		}
+		resolved := false
+		for _, dep := range missing {
+			if _, ok := files[dep]; ok {
+				continue
+			}
+			res, err := reflectionRequest(stream, &rpb.ServerReflectionRequest{
+				MessageRequest: &rpb.ServerReflectionRequest_FileByFilename{FileByFilename: dep},
+			})
+			if err != nil {
+				// fallback to linked-in descriptors, e.g. google/api/http.proto
+				if gfd, errFind := protoregistry.GlobalFiles.FindFileByPath(dep); errFind == nil {
+					files[dep] = protodesc.ToFileDescriptorProto(gfd)
+					resolved = true
+					continue
+				}
+				return nil, fmt.Errorf("resolve dependency %s: %v", dep, err)
+			}
+			if err := addFiles(res); err != nil {
+				return nil, err
+			}
+			resolved = true
+		}
+		if !resolved {
+			break
+		}
+	}
+
+	list := make([]*descriptorpb.FileDescriptorProto, 0, len(files))
+	for _, fd := range files {
+		list = append(list, fd)
+	}
+	return newFiles(list, services)
+}
+
+// reflection request
+func reflectionRequest(stream rpb.ServerReflection_ServerReflectionInfoClient, req *rpb.ServerReflectionRequest) (*rpb.ServerReflectionResponse, error) {
+	if err := stream.Send(req); err != nil {
+		return nil, err
+	}
+	res, err := stream.Recv()
+	if err != nil {
+		return nil, err
+	}
+	if errRes := res.GetErrorResponse(); errRes != nil {
+		return nil, errors.New(errRes.GetErrorMessage())
+	}
+	return res, nil
+}
+
+// new files registry, check services exist
+func newFiles(list []*descriptorpb.FileDescriptorProto, services []string) (*protoregistry.Files, error) {
+	files, err := protodesc.NewFiles(&descriptorpb.FileDescriptorSet{File: list})
+	if err != nil {
+		return nil, err
+	}
+	for _, svc := range services {
+		if _, err := files.FindDescriptorByName(protoreflect.FullName(svc)); err != nil {
+			return nil, fmt.Errorf("service %s not found in descriptors", svc)
+		}
+	}
+	return files, nil
+}
@@ -0,0 +1,166 @@
+package transcode
+
+import (
+	"errors"
+	"strings"
+)
+
+// segment kind
+const (
+	segLiteral = iota
+	segSingle  // *
+	segMulti   // **
+)
+
+// path template segment
+type tplSegment struct {
+	kind     int
+	value    string
+	variable string // field path, empty if not captured
+}
+
+// google.api.http path template
+// e.g. /v1/{name=projects/*/engines/*}:recognize
+type pathTemplate struct {
+	raw      string
+	segments []tplSegment
+	verb     string
+}
+
+// parse path template
+func parseTemplate(tpl string) (*pathTemplate, error) {
+	if !strings.HasPrefix(tpl, "/") {
+		return nil, errors.New("path template must start with / : " + tpl)
+	}
+	t := &pathTemplate{raw: tpl}
+	path := tpl[1:]
+
+	// verb after last ':' outside of variable
+	if i := strings.LastIndex(path, ":"); i >= 0 && i > strings.LastIndex(path, "}") && i > strings.LastIndex(path, "/") {
+		t.verb = path[i+1:]
+		path = path[:i]
+	}
+
+	for len(path) > 0 {
+		var part string
+		if path[0] == '{' {
+			end := strings.Index(path, "}")
+			if end < 0 {
+				return nil, errors.New("unclosed variable in path template: " + tpl)
+			}
+			part, path = path[1:end], path[end+1:]
+			if err := t.addVariable(part); err != nil {
+				return nil, err
+			}
+		} else {
+			end := strings.Index(path, "/")
+			if end < 0 {
+				end = len(path)
+			}
+			part, path = path[:end], path[end:]
+			t.segments = append(t.segments, newSegment(part, ""))
+		}
+		if strings.HasPrefix(path, "/") {
+			path = path[1:]
+		} else if path != "" {
+			return nil, errors.New("invalid path template: " + tpl)
+		}
+	}
+	return t, nil
+}
+
+// add variable segments
+func (t *pathTemplate) addVariable(v string) error {
+	name, pattern := v, "*"
+	if i := strings.Index(v, "="); i >= 0 {
+		name, pattern = v[:i], v[i+1:]
+	}
+	if name == "" || pattern == "" {
+		return errors.New("invalid path variable: " + v)
+	}
+	for _, part := range strings.Split(pattern, "/") {
+		t.segments = append(t.segments, newSegment(part, name))
+	}
+	return nil
+}
+
+// new segment
+func newSegment(part, variable string) tplSegment {
+	switch part {
+	case "*":
+		return tplSegment{kind: segSingle, variable: variable}
+	case "**":
+		return tplSegment{kind: segMulti, variable: variable}
+	}
+	return tplSegment{kind: segLiteral, value: part, variable: variable}
+}
+
+// match request path, return variables
+func (t *pathTemplate) match(path string) (map[string]string, bool) {
+	if !strings.HasPrefix(path, "/") {
+		return nil, false
+	}
+	path = path[1:]
+	if t.verb != "" {
+		if !strings.HasSuffix(path, ":"+t.verb) {
+			return nil, false
+		}
+		path = strings.TrimSuffix(path, ":"+t.verb)
+	}
+
+	var parts []string
+	if path != "" {
+		parts = strings.Split(path, "/")
+	}
+	captured := make([][]string, len(t.segments))
+	if !t.matchSegments(0, parts, captured) {
+		return nil, false
+	}
+
+	vars := make(map[string]string)
+	var order []string
+	values := make(map[string][]string)
+	for i, seg := range t.segments {
+		if seg.variable == "" {
+			continue
+		}
+		if _, ok := values[seg.variable]; !ok {
+			order = append(order, seg.variable)
+		}
+		values[seg.variable] = append(values[seg.variable], captured[i]...)
+	}
+	for _, name := range order {
+		vars[name] = strings.Join(values[name], "/")
+	}
+	return vars, true
+}
+
+// match segments with backtracking for **
+func (t *pathTemplate) matchSegments(i int, parts []string, captured [][]string) bool {
+	if i == len(t.segments) {
+		return len(parts) == 0
+	}
+	seg := t.segments[i]
+	switch seg.kind {
+	case segLiteral:
+		if len(parts) == 0 || parts[0] != seg.value {
+			return false
+		}
+		captured[i] = parts[:1]
+		return t.matchSegments(i+1, parts[1:], captured)
+	case segSingle:
+		if len(parts) == 0 || parts[0] == "" {
+			return false
+		}
+		captured[i] = parts[:1]
+		return t.matchSegments(i+1, parts[1:], captured)
+	case segMulti:
+		for n := len(parts); n >= 0; n-- {
+			captured[i] = parts[:n]
+			if t.matchSegments(i+1, parts[n:], captured) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,551 @@
+package transcode
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"rpc-gateway/pkg/core/config"
+	logging "rpc-gateway/pkg/core/log"
+	grpcPool "rpc-gateway/pkg/plugins/pool/grpc"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"google.golang.org/genproto/googleapis/api/annotations"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+const (
+	// stream format
+	STREAM_FORMAT_NDJSON = "ndjson"
+	STREAM_FORMAT_SSE    = "sse"
+	// default request timeout (second)
+	REQUEST_TIMEOUT = 30
+	// reflection retry interval
+	RELOAD_INTERVAL = 10 * time.Second
+	// grpc metadata header prefix
+	METADATA_HEADER_PREFIX = "Grpc-Metadata-"
+)
+
+// transcode setting
+type Setting struct {
+	Enabled        bool
+	DescriptorSet  string // descriptor set 文件, 为空则使用 server reflection
+	StreamFormat   string // server streaming 输出格式 (ndjson, sse)
+	RequestTimeOut int    // unary 请求超时 (second)
+}
+
+// http rule config
+type ruleConfig struct {
+	selector     string
+	method       string
+	path         string
+	body         string
+	responseBody string
+}
+
+// engine descriptors and bindings
+type engine struct {
+	engineType string
+	services   []string
+	rules      []ruleConfig
+	bindings   []*binding
+	loaded     bool
+	loading    bool
+	lastLoad   time.Time
+	lock       sync.Mutex
+}
+
+// http binding of a grpc method
+type binding struct {
+	httpMethod   string
+	template     *pathTemplate
+	body         string
+	responseBody string
+	method       protoreflect.MethodDescriptor
+	fullMethod   string
+	engineType   string
+}
+
+var setting Setting
+var engines []*engine
+var descriptorFiles *protoregistry.Files
+var transcodeLock sync.RWMutex
+
+// init transcoder from route config
+func Init(cfg config.Transcode) {
+	transcodeLock.Lock()
+	defer transcodeLock.Unlock()
+
+	setting = Setting{
+		Enabled:        cfg.Enabled,
+		DescriptorSet:  cfg.DescriptorSet,
+		StreamFormat:   strings.ToLower(cfg.StreamFormat),
+		RequestTimeOut: cfg.RequestTimeout,
+	}
+	engines = nil
+	descriptorFiles = nil
+	if !setting.Enabled {
+		return
+	}
+	if setting.StreamFormat == "" {
+		setting.StreamFormat = STREAM_FORMAT_NDJSON
+	}
+	if setting.RequestTimeOut <= 0 {
+		setting.RequestTimeOut = REQUEST_TIMEOUT
+	}
+
+	// engine services
+	for _, svc := range cfg.Services {
+		e := &engine{engineType: strings.ToLower(svc.EngineName), services: svc.Services}
+		for _, rule := range svc.Rules {
+			method, path := rule.Pattern()
+			if method == "" {
+				continue
+			}
+			e.rules = append(e.rules, ruleConfig{
+				selector:     rule.Selector,
+				method:       method,
+				path:         path,
+				body:         rule.Body,
+				responseBody: rule.ResponseBody,
+			})
+		}
+		engines = append(engines, e)
+	}
+
+	// descriptor set file
+	if setting.DescriptorSet != "" {
+		files, err := loadDescriptorSet(setting.DescriptorSet)
+		if err != nil {
+			logging.Log.Error("transcode load descriptor set error: ", err)
+			return
+		}
+		descriptorFiles = files
+		for _, e := range engines {
+			e.build(files)
+		}
+	} else {
+		// server reflection 异步加载, 不阻塞请求
+		for _, e := range engines {
+			e.loadAsync()
+		}
+	}
+	logging.Log.Info("http/json transcode enabled, engines ", len(engines))
+}
+
+// build bindings from descriptors
+func (e *engine) build(files *protoregistry.Files) {
+	var bindings []*binding
+	serviceSet := make(map[string]bool)
+	for _, svc := range e.services {
+		serviceSet[svc] = true
+	}
+	ruleSet := make(map[string][]ruleConfig)
+	for _, rc := range e.rules {
+		ruleSet[rc.selector] = append(ruleSet[rc.selector], rc)
+	}
+
+	files.RangeFiles(func(fd protoreflect.FileDescriptor) bool {
+		for i := 0; i < fd.Services().Len(); i++ {
+			sd := fd.Services().Get(i)
+			if len(serviceSet) > 0 && !serviceSet[string(sd.FullName())] {
+				continue
+			}
+			for j := 0; j < sd.Methods().Len(); j++ {
+				md := sd.Methods().Get(j)
+				// config rules override annotations
+				rules := ruleSet[string(md.FullName())]
+				if len(rules) == 0 {
+					rules = annotationRules(md)
+				}
+				// default POST /{service}/{method}
+				if len(rules) == 0 {
+					rules = []ruleConfig{{method: http.MethodPost, path: "/" + string(sd.FullName()) + "/" + string(md.Name()), body: "*"}}
+				}
+				for _, rc := range rules {
+					tpl, err := parseTemplate(rc.path)
+					if err != nil {
+						logging.Log.Error("transcode parse path template error: ", err)
+						continue
+					}
+					bindings = append(bindings, &binding{
+						httpMethod:   rc.method,
+						template:     tpl,
+						body:         rc.body,
+						responseBody: rc.responseBody,
+						method:       md,
+						fullMethod:   "/" + string(sd.FullName()) + "/" + string(md.Name()),
+						engineType:   e.engineType,
+					})
+				}
+			}
+		}
+		return true
+	})
+
+	e.bindings = bindings
+	e.loaded = true
+	logging.Log.Info("transcode ", e.engineType, " bindings loaded: ", len(bindings))
+}
+
+// google.api.http rules
+func annotationRules(md protoreflect.MethodDescriptor) []ruleConfig {
+	opts := md.Options()
+	if opts == nil || !proto.HasExtension(opts, annotations.E_Http) {
+		return nil
+	}
+	rule, ok := proto.GetExtension(opts, annotations.E_Http).(*annotations.HttpRule)
+	if !ok || rule == nil {
+		return nil
+	}
+	rules := []*annotations.HttpRule{rule}
+	rules = append(rules, rule.GetAdditionalBindings()...)
+
+	var configs []ruleConfig
+	for _, r := range rules {
+		rc := ruleConfig{body: r.GetBody(), responseBody: r.GetResponseBody()}
+		switch p := r.GetPattern().(type) {
+		case *annotations.HttpRule_Get:
+			rc.method, rc.path = http.MethodGet, p.Get
+		case *annotations.HttpRule_Put:
+			rc.method, rc.path = http.MethodPut, p.Put
+		case *annotations.HttpRule_Post:
+			rc.method, rc.path = http.MethodPost, p.Post
+		case *annotations.HttpRule_Delete:
+			rc.method, rc.path = http.MethodDelete, p.Delete
+		case *annotations.HttpRule_Patch:
+			rc.method, rc.path = http.MethodPatch, p.Patch
+		case *annotations.HttpRule_Custom:
+			rc.method, rc.path = strings.ToUpper(p.Custom.GetKind()), p.Custom.GetPath()
+		default:
+			continue
+		}
+		configs = append(configs, rc)
+	}
+	return configs
+}
+
+// load descriptors by reflection in background if not loaded, retried every RELOAD_INTERVAL
+func (e *engine) loadAsync() {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	if e.loaded || e.loading || time.Since(e.lastLoad) < RELOAD_INTERVAL {
+		return
+	}
+	e.loading = true
+	e.lastLoad = time.Now()
+	go func() {
+		files, err := e.reflect()
+		e.lock.Lock()
+		defer e.lock.Unlock()
+		e.loading = false
+		if err != nil {
+			logging.Log.Error("transcode ", e.engineType, " server reflection error: ", err)
+			return
+		}
+		e.build(files)
+	}()
+}
+
+// descriptors by server reflection of engine
+func (e *engine) reflect() (*protoregistry.Files, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	client, err := grpcPool.AcquireBalanceClient(ctx, e.engineType)
+	if err != nil {
+		return nil, err
+	}
+	if client == nil {
+		return nil, fmt.Errorf("acquire %s client for reflection failed", e.engineType)
+	}
+	defer client.Close()
+	return loadFromReflection(ctx, client.ClientConn, e.services)
+}
+
+// match binding, engines are not loaded on request path
+func match(c *gin.Context) (*binding, map[string]string) {
+	transcodeLock.RLock()
+	enabled, list, files := setting.Enabled, engines, descriptorFiles
+	transcodeLock.RUnlock()
+	if !enabled {
+		return nil, nil
+	}
+
+	for _, e := range list {
+		if files == nil {
+			e.loadAsync()
+		}
+		e.lock.Lock()
+		bindings := e.bindings
+		e.lock.Unlock()
+		for _, b := range bindings {
+			if b.httpMethod != c.Request.Method {
+				continue
+			}
+			if vars, ok := b.template.match(c.Request.URL.Path); ok {
+				return b, vars
+			}
+		}
+	}
+	return nil, nil
+}
+
+// request matches a server streaming binding, response is flushed without timeout buffering
+func Streaming(c *gin.Context) bool {
+	b, _ := match(c)
+	return b != nil && b.method.IsStreamingServer()
+}
+
+// metadata from headers, Grpc-Metadata-*, token and Authorization
+func requestMetadata(h http.Header) metadata.MD {
+	md := metadata.MD{}
+	for name, values := range h {
+		if strings.HasPrefix(name, METADATA_HEADER_PREFIX) {
+			md.Append(strings.ToLower(strings.TrimPrefix(name, METADATA_HEADER_PREFIX)), values...)
+		}
+	}
+	if token := h.Get("token"); token != "" {
+		md.Set("token", token)
+	}
+	// 标准 Authorization: Bearer <token>
+	if authorization := h.Get("Authorization"); authorization != "" {
+		md.Set("authorization", authorization)
+	}
+	return md
+}
+
+// handle http request, return false if no binding matched
+func Handle(c *gin.Context) bool {
+	b, vars := match(c)
+	if b == nil {
+		return false
+	}
+
+	if b.method.IsStreamingClient() {
+		writeError(c, status.Error(codes.Unimplemented, "client streaming is not supported over http"))
+		return true
+	}
+
+	// build request message
+	req := dynamicpb.NewMessage(b.method.Input())
+	if err := fillRequest(c, b, vars, req); err != nil {
+		writeError(c, status.Error(codes.InvalidArgument, err.Error()))
+		return true
+	}
+
+	md := requestMetadata(c.Request.Header)
+	ctx := c.Request.Context()
+	client, err := grpcPool.AcquireEngineClient(ctx, b.engineType, md)
+	if err != nil {
+		writeError(c, err)
+		return true
+	}
+	if client == nil {
+		writeError(c, status.Error(codes.Unavailable, "engine pool is busy"))
+		return true
+	}
+	defer client.Close()
+	outCtx := metadata.NewOutgoingContext(ctx, md)
+
+	if b.method.IsStreamingServer() {
+		serverStream(c, b, client.ClientConn, outCtx, req)
+		return true
+	}
+
+	transcodeLock.RLock()
+	timeOut := time.Duration(setting.RequestTimeOut) * time.Second
+	transcodeLock.RUnlock()
+	unaryCtx, cancel := context.WithTimeout(outCtx, timeOut)
+	defer cancel()
+
+	res := dynamicpb.NewMessage(b.method.Output())
+	var header metadata.MD
+	if err := client.ClientConn.Invoke(unaryCtx, b.fullMethod, req, res, grpc.Header(&header)); err != nil {
+		writeError(c, err)
+		return true
+	}
+	forwardHeader(c, header)
+	data, err := marshalResponse(b, res)
+	if err != nil {
+		writeError(c, status.Error(codes.Internal, err.Error()))
+		return true
+	}
+	c.Data(http.StatusOK, "application/json", data)
+	return true
+}
+
+// fill request from body, path variables and query
+func fillRequest(c *gin.Context, b *binding, vars map[string]string, req *dynamicpb.Message) error {
+	unmarshal := protojson.UnmarshalOptions{DiscardUnknown: true}
+	if b.body != "" {
+		body, err := ioutil.ReadAll(c.Request.Body)
+		if err != nil {
+			return err
+		}
+		if len(body) > 0 {
+			if b.body != "*" {
+				// wrap body into the bound field
+				wrapped, err := json.Marshal(map[string]json.RawMessage{b.body: body})
+				if err != nil {
+					return err
+				}
+				body = wrapped
+			}
+			if err := unmarshal.Unmarshal(body, req); err != nil {
+				return err
+			}
+		}
+	}
+
+	for name, value := range vars {
+		if err := setFieldPath(req.ProtoReflect(), name, []string{value}); err != nil {
+			return err
+		}
+	}
+
+	// query params for fields not bound by body
+	if b.body != "*" {
+		for name, values := range c.Request.URL.Query() {
+			if _, ok := vars[name]; ok {
+				continue
+			}
+			if err := setFieldPath(req.ProtoReflect(), name, values); err != nil {
+				logging.Log.Debug("transcode ignore query param ", name, ": ", err)
+			}
+		}
+	}
+	return nil
+}
+
+// server streaming response
+func serverStream(c *gin.Context, b *binding, conn *grpc.ClientConn, ctx context.Context, req proto.Message) {
+	streamCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	stream, err := conn.NewStream(streamCtx, &grpc.StreamDesc{ServerStreams: true}, b.fullMethod)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	if err := stream.SendMsg(req); err != nil {
+		writeError(c, err)
+		return
+	}
+	if err := stream.CloseSend(); err != nil {
+		writeError(c, err)
+		return
+	}
+
+	transcodeLock.RLock()
+	sse := setting.StreamFormat == STREAM_FORMAT_SSE
+	transcodeLock.RUnlock()
+	accept := c.GetHeader("Accept")
+	if strings.Contains(accept, "text/event-stream") {
+		sse = true
+	} else if strings.Contains(accept, "application/x-ndjson") {
+		sse = false
+	}
+
+	for i := 0; ; i++ {
+		res := dynamicpb.NewMessage(b.method.Output())
+		err := stream.RecvMsg(res)
+		if i == 0 {
+			if err != nil && err != io.EOF {
+				writeError(c, err)
+				return
+			}
+			if header, errHeader := stream.Header(); errHeader == nil {
+				forwardHeader(c, header)
+			}
+			if sse {
+				c.Header("Content-Type", "text/event-stream")
+				c.Header("Cache-Control", "no-cache")
+			} else {
+				c.Header("Content-Type", "application/x-ndjson")
+			}
+			c.Status(http.StatusOK)
+		}
+		if err == io.EOF {
+			return
+		}
+		if err != nil {
+			writeStreamError(c, err, sse)
+			return
+		}
+
+		data, err := marshalResponse(b, res)
+		if err != nil {
+			writeStreamError(c, status.Error(codes.Internal, err.Error()), sse)
+			return
+		}
+		if sse {
+			fmt.Fprintf(c.Writer, "data: %s\n\n", data)
+		} else {
+			c.Writer.Write(data)
+			c.Writer.Write([]byte("\n"))
+		}
+		c.Writer.Flush()
+	}
+}
+
+// marshal response or response body field
+func marshalResponse(b *binding, res *dynamicpb.Message) ([]byte, error) {
+	marshal := protojson.MarshalOptions{}
+	if b.responseBody != "" && b.responseBody != "*" {
+		fd := findField(res.Descriptor(), b.responseBody)
+		if fd != nil && fd.Kind() == protoreflect.MessageKind && !fd.IsList() && !fd.IsMap() {
+			return marshal.Marshal(res.Get(fd).Message().Interface())
+		}
+	}
+	return marshal.Marshal(res)
+}
+
+// forward grpc header as http header
+func forwardHeader(c *gin.Context, md metadata.MD) {
+	for name, values := range md {
+		for _, value := range values {
+			c.Writer.Header().Add(METADATA_HEADER_PREFIX+name, value)
+		}
+	}
+}
+
+// error body
+func errorBody(err error) (int, gin.H) {
+	st := status.Convert(err)
+	return httpStatusFromCode(st.Code()), gin.H{
+		"code":    int(st.Code()),
+		"message": st.Message(),
+	}
+}
+
+// write error
+func writeError(c *gin.Context, err error) {
+	httpStatus, body := errorBody(err)
+	c.JSON(httpStatus, body)
+}
+
+// write stream error
+func writeStreamError(c *gin.Context, err error, sse bool) {
+	_, body := errorBody(err)
+	data, _ := json.Marshal(gin.H{"error": body})
+	if sse {
+		fmt.Fprintf(c.Writer, "event: error\ndata: %s\n\n", data)
+	} else {
+		c.Writer.Write(data)
+		c.Writer.Write([]byte("\n"))
+	}
+	c.Writer.Flush()
+}
@@ -0,0 +1,116 @@
+package transcode
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/reflect/protoregistry"
+)
+
+func setTestEngines(t *testing.T, files *protoregistry.Files, list ...*engine) {
+	t.Helper()
+	transcodeLock.Lock()
+	setting = Setting{Enabled: true, StreamFormat: STREAM_FORMAT_NDJSON, RequestTimeOut: REQUEST_TIMEOUT}
+	engines = list
+	descriptorFiles = files
+	transcodeLock.Unlock()
+	t.Cleanup(func() {
+		transcodeLock.Lock()
+		setting, engines, descriptorFiles = Setting{}, nil, nil
+		transcodeLock.Unlock()
+	})
+}
+
+func testContext(method, path string) *gin.Context {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(method, path, nil)
+	return c
+}
+
+func TestStreaming(t *testing.T) {
+	files := new(protoregistry.Files)
+	if err := files.RegisterFile(healthpb.File_grpc_health_v1_health_proto); err != nil {
+		t.Fatal(err)
+	}
+	e := &engine{engineType: "asr", services: []string{"grpc.health.v1.Health"}}
+	e.build(files)
+	setTestEngines(t, files, e)
+
+	cases := []struct {
+		method, path string
+		streaming    bool
+	}{
+		{http.MethodPost, "/grpc.health.v1.Health/Watch", true},
+		{http.MethodPost, "/grpc.health.v1.Health/Check", false},
+		{http.MethodGet, "/grpc.health.v1.Health/Watch", false},
+		{http.MethodPost, "/unknown", false},
+	}
+	for _, c := range cases {
+		if got := Streaming(testContext(c.method, c.path)); got != c.streaming {
+			t.Errorf("%s %s: streaming %v, want %v", c.method, c.path, got, c.streaming)
+		}
+	}
+}
+
+func TestMatchDoesNotReflectOnRequestPath(t *testing.T) {
+	// 无连接池时 reflection 失败, 请求不等待加载
+	e := &engine{engineType: "asr"}
+	setTestEngines(t, nil, e)
+
+	start := time.Now()
+	if b, _ := match(testContext(http.MethodPost, "/svc/Method")); b != nil {
+		t.Fatal("unloaded engine matched")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("match took %v", elapsed)
+	}
+	// 后台加载失败后按 RELOAD_INTERVAL 重试
+	deadline := time.Now().Add(time.Second)
+	for {
+		e.lock.Lock()
+		loading, lastLoad := e.loading, e.lastLoad
+		e.lock.Unlock()
+		if !loading && !lastLoad.IsZero() {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("reflection not finished")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestRequestMetadata(t *testing.T) {
+	cases := []struct {
+		name   string
+		header http.Header
+		want   metadata.MD
+	}{
+		{"none", http.Header{"Accept": {"*/*"}}, metadata.MD{}},
+		{
+			"grpc metadata",
+			http.Header{"Grpc-Metadata-Trace-Id": {"t1", "t2"}, "X-Other": {"x"}},
+			metadata.MD{"trace-id": {"t1", "t2"}},
+		},
+		{"token", http.Header{"Token": {"abc"}}, metadata.MD{"token": {"abc"}}},
+		{"authorization", http.Header{"Authorization": {"Bearer abc"}}, metadata.MD{"authorization": {"Bearer abc"}}},
+		{
+			"token and authorization",
+			http.Header{"Token": {"a"}, "Authorization": {"Bearer b"}},
+			metadata.MD{"token": {"a"}, "authorization": {"Bearer b"}},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := requestMetadata(tc.header); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("requestMetadata = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,353 @@
+package proxy
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	logging "rpc-gateway/pkg/core/log"
+	"rpc-gateway/pkg/plugins/metrics"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// default websocket idle timeout (second)
+	WS_IDLE_TIMEOUT = 60
+	// websocket dial timeout
+	WS_DIAL_TIMEOUT = 10 * time.Second
+)
+
+// websocket opcodes and close codes, RFC 6455
+const (
+	wsOpClose             = 0x8
+	wsCloseGoingAway      = 1001
+	wsCloseNoStatus       = 1005
+	wsCloseAbnormal       = 1006
+	wsMaxControlFrameSize = 125
+)
+
+// websocket side
+type wsPeer struct {
+	conn     net.Conn
+	reader   *bufio.Reader
+	masked   bool // client role, frames must be masked
+	lock     sync.Mutex
+	closeFin int32 // close frame sent
+}
+
+// is websocket upgrade request
+func isWebSocketRequest(r *http.Request) bool {
+	return headerContainsToken(r.Header, "Connection", "upgrade") &&
+		headerContainsToken(r.Header, "Upgrade", "websocket")
+}
+
+// header contains token
+func headerContainsToken(h http.Header, name, token string) bool {
+	for _, value := range h.Values(name) {
+		for _, v := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(v), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// websocket proxy
+func runWebSocketProxy(c *gin.Context, route *HttpRoute) {
+	if !isWebSocketRequest(c.Request) {
+		c.String(http.StatusBadRequest, "websocket upgrade required")
+		return
+	}
+
+	upstreamUrl, err := url.Parse(route.To)
+	if err != nil {
+		c.String(http.StatusInternalServerError, fmt.Sprintln(err))
+		return
+	}
+	upstreamUrl.RawQuery = c.Request.URL.RawQuery
+
+	// dial upstream
+	upstreamConn, err := dialWebSocket(upstreamUrl)
+	if err != nil {
+		logging.Log.Error("websocket dial upstream error: ", err)
+		c.String(http.StatusBadGateway, fmt.Sprintln(err))
+		return
+	}
+
+	// upgrade request
+	header := route.Headers.applyRequest(c.Request.Header)
+	for _, name := range []string{"Sec-Websocket-Key", "Sec-Websocket-Version", "Sec-Websocket-Protocol", "Sec-Websocket-Extensions"} {
+		if values := c.Request.Header.Values(name); len(values) > 0 {
+			header[name] = values
+		}
+	}
+	header.Set("Connection", "Upgrade")
+	header.Set("Upgrade", "websocket")
+	upstreamReq := &http.Request{
+		Method:     http.MethodGet,
+		URL:        &url.URL{Path: upstreamUrl.Path, RawQuery: upstreamUrl.RawQuery},
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     header,
+		Host:       upstreamUrl.Host,
+	}
+	if upstreamReq.URL.Path == "" {
+		upstreamReq.URL.Path = "/"
+	}
+
+	upstreamConn.SetDeadline(time.Now().Add(WS_DIAL_TIMEOUT))
+	if err := upstreamReq.Write(upstreamConn); err != nil {
+		upstreamConn.Close()
+		c.String(http.StatusBadGateway, fmt.Sprintln(err))
+		return
+	}
+	upstreamReader := bufio.NewReader(upstreamConn)
+	upstreamRes, err := http.ReadResponse(upstreamReader, upstreamReq)
+	if err != nil {
+		upstreamConn.Close()
+		c.String(http.StatusBadGateway, fmt.Sprintln(err))
+		return
+	}
+	upstreamConn.SetDeadline(time.Time{})
+
+	// upstream refused upgrade
+	if upstreamRes.StatusCode != http.StatusSwitchingProtocols {
+		body, _ := ioutil.ReadAll(upstreamRes.Body)
+		upstreamRes.Body.Close()
+		upstreamConn.Close()
+		writeResponse(c, route, &HttpResponse{
+			StatusCode: upstreamRes.StatusCode,
+			Header:     upstreamRes.Header,
+			Body:       body,
+		})
+		return
+	}
+
+	// hijack client conn
+	clientConn, clientBuf, err := c.Writer.Hijack()
+	if err != nil {
+		upstreamConn.Close()
+		logging.Log.Error("websocket hijack error: ", err)
+		return
+	}
+
+	// switching protocols response
+	resHeader := route.Headers.applyResponse(upstreamRes.Header)
+	for _, name := range []string{"Sec-Websocket-Accept", "Sec-Websocket-Protocol", "Sec-Websocket-Extensions"} {
+		if values := upstreamRes.Header.Values(name); len(values) > 0 {
+			resHeader[name] = values
+		}
+	}
+	resHeader.Set("Connection", "Upgrade")
+	resHeader.Set("Upgrade", "websocket")
+	clientBuf.WriteString("HTTP/1.1 101 Switching Protocols\r\n")
+	resHeader.Write(clientBuf)
+	clientBuf.WriteString("\r\n")
+	if err := clientBuf.Flush(); err != nil {
+		clientConn.Close()
+		upstreamConn.Close()
+		return
+	}
+
+	client := &wsPeer{conn: clientConn, reader: clientBuf.Reader}
+	upstream := &wsPeer{conn: upstreamConn, reader: upstreamReader, masked: true}
+
+	metrics.WebSocketConnOpen()
+	closeCode := pipeWebSocket(client, upstream, time.Duration(route.IdleTimeout)*time.Second)
+	metrics.WebSocketConnClose(closeCode)
+	logging.Log.Info("websocket ", route.Path, " closed, code ", closeCode)
+}
+
+// dial websocket upstream
+func dialWebSocket(u *url.URL) (net.Conn, error) {
+	host := u.Host
+	switch strings.ToLower(u.Scheme) {
+	case "ws", "http":
+		if u.Port() == "" {
+			host = net.JoinHostPort(u.Hostname(), "80")
+		}
+		return net.DialTimeout("tcp", host, WS_DIAL_TIMEOUT)
+	case "wss", "https":
+		if u.Port() == "" {
+			host = net.JoinHostPort(u.Hostname(), "443")
+		}
+		dialer := &net.Dialer{Timeout: WS_DIAL_TIMEOUT}
+		return tls.DialWithDialer(dialer, "tcp", host, &tls.Config{ServerName: u.Hostname()})
+	}
+	return nil, errors.New("unsupported websocket scheme " + u.Scheme)
+}
+
+// pipe frames both ways, return close code
+func pipeWebSocket(client, upstream *wsPeer, idleTimeout time.Duration) int {
+	if idleTimeout <= 0 {
+		idleTimeout = WS_IDLE_TIMEOUT * time.Second
+	}
+	lastActive := time.Now().UnixNano()
+	var closeCode int32 = wsCloseAbnormal
+	var idleExpired int32
+	done := make(chan struct{}, 2)
+
+	copyFrames := func(dst, src *wsPeer) {
+		defer func() { done <- struct{}{} }()
+		for {
+			code, err := copyFrame(dst, src)
+			atomic.StoreInt64(&lastActive, time.Now().UnixNano())
+			if code > 0 {
+				// close frame forwarded
+				atomic.CompareAndSwapInt32(&closeCode, wsCloseAbnormal, int32(code))
+				atomic.StoreInt32(&dst.closeFin, 1)
+			}
+			if err != nil {
+				// peer gone or idle without close frame
+				reason := "peer closed"
+				if atomic.LoadInt32(&idleExpired) == 1 {
+					reason = "idle timeout"
+				}
+				dst.writeClose(wsCloseGoingAway, reason)
+				return
+			}
+		}
+	}
+
+	go copyFrames(upstream, client)
+	go copyFrames(client, upstream)
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			client.conn.Close()
+			upstream.conn.Close()
+			<-done
+			return int(atomic.LoadInt32(&closeCode))
+		case <-ticker.C:
+			idle := time.Since(time.Unix(0, atomic.LoadInt64(&lastActive)))
+			if idle >= idleTimeout {
+				atomic.StoreInt32(&idleExpired, 1)
+				atomic.CompareAndSwapInt32(&closeCode, wsCloseAbnormal, wsCloseGoingAway)
+				// unblock readers, they send close frames on exit
+				client.conn.SetReadDeadline(time.Now())
+				upstream.conn.SetReadDeadline(time.Now())
+				<-done
+				<-done
+				client.conn.Close()
+				upstream.conn.Close()
+				return int(atomic.LoadInt32(&closeCode))
+			}
+		}
+	}
+}
+
+// copy one frame, return close code if close frame
+func copyFrame(dst, src *wsPeer) (int, error) {
+	head := make([]byte, 2, 14)
+	if _, err := io.ReadFull(src.reader, head); err != nil {
+		return 0, err
+	}
+	opcode := head[0] & 0x0f
+	masked := head[1]&0x80 != 0
+	length := uint64(head[1] & 0x7f)
+
+	switch length {
+	case 126:
+		ext := make([]byte, 2)
+		if _, err := io.ReadFull(src.reader, ext); err != nil {
+			return 0, err
+		}
+		head = append(head, ext...)
+		length = uint64(binary.BigEndian.Uint16(ext))
+	case 127:
+		ext := make([]byte, 8)
+		if _, err := io.ReadFull(src.reader, ext); err != nil {
+			return 0, err
+		}
+		head = append(head, ext...)
+		length = binary.BigEndian.Uint64(ext)
+	}
+
+	var maskKey []byte
+	if masked {
+		maskKey = make([]byte, 4)
+		if _, err := io.ReadFull(src.reader, maskKey); err != nil {
+			return 0, err
+		}
+		head = append(head, maskKey...)
+	}
+
+	dst.lock.Lock()
+	defer dst.lock.Unlock()
+
+	if _, err := dst.conn.Write(head); err != nil {
+		return 0, err
+	}
+
+	// close frame, read code
+	if opcode == wsOpClose && length <= wsMaxControlFrameSize {
+		payload := make([]byte, length)
+		if _, err := io.ReadFull(src.reader, payload); err != nil {
+			return 0, err
+		}
+		if _, err := dst.conn.Write(payload); err != nil {
+			return 0, err
+		}
+		code := wsCloseNoStatus
+		if length >= 2 {
+			raw := make([]byte, 2)
+			copy(raw, payload[:2])
+			if masked {
+				raw[0] ^= maskKey[0]
+				raw[1] ^= maskKey[1]
+			}
+			code = int(binary.BigEndian.Uint16(raw))
+		}
+		return code, nil
+	}
+
+	_, err := io.CopyN(dst.conn, src.reader, int64(length))
+	return 0, err
+}
+
+// write close frame
+func (peer *wsPeer) writeClose(code int, reason string) {
+	if !atomic.CompareAndSwapInt32(&peer.closeFin, 0, 1) {
+		return
+	}
+	payload := make([]byte, 2, 2+len(reason))
+	binary.BigEndian.PutUint16(payload, uint16(code))
+	payload = append(payload, reason...)
+	if len(payload) > wsMaxControlFrameSize {
+		payload = payload[:wsMaxControlFrameSize]
+	}
+
+	frame := []byte{0x80 | wsOpClose, byte(len(payload))}
+	if peer.masked {
+		maskKey := make([]byte, 4)
+		rand.Read(maskKey)
+		frame[1] |= 0x80
+		frame = append(frame, maskKey...)
+		for i := range payload {
+			payload[i] ^= maskKey[i%4]
+		}
+	}
+	frame = append(frame, payload...)
+
+	peer.lock.Lock()
+	defer peer.lock.Unlock()
+	peer.conn.SetWriteDeadline(time.Now().Add(time.Second))
+	peer.conn.Write(frame)
+}
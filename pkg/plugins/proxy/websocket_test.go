@@ -0,0 +1,265 @@
+package proxy
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+// websocket frame, masked when mask key is given
+func wsFrame(opcode byte, payload []byte, maskKey []byte) []byte {
+	frame := []byte{0x80 | opcode, 0}
+	switch {
+	case len(payload) < 126:
+		frame[1] = byte(len(payload))
+	case len(payload) <= 0xffff:
+		frame[1] = 126
+		frame = append(frame, 0, 0)
+		binary.BigEndian.PutUint16(frame[2:], uint16(len(payload)))
+	default:
+		frame[1] = 127
+		frame = append(frame, make([]byte, 8)...)
+		binary.BigEndian.PutUint64(frame[2:], uint64(len(payload)))
+	}
+	data := append([]byte(nil), payload...)
+	if maskKey != nil {
+		frame[1] |= 0x80
+		frame = append(frame, maskKey...)
+		for i := range data {
+			data[i] ^= maskKey[i%4]
+		}
+	}
+	return append(frame, data...)
+}
+
+func closePayload(code int, reason string) []byte {
+	payload := make([]byte, 2, 2+len(reason))
+	binary.BigEndian.PutUint16(payload, uint16(code))
+	return append(payload, reason...)
+}
+
+// conn of test, writes are buffered
+type bufConn struct {
+	net.Conn
+	buf bytes.Buffer
+}
+
+func (c *bufConn) Write(b []byte) (int, error) {
+	return c.buf.Write(b)
+}
+
+func TestCopyFrame(t *testing.T) {
+	mask := []byte{1, 2, 3, 4}
+	cases := []struct {
+		name  string
+		frame []byte
+		code  int
+	}{
+		{"text", wsFrame(0x1, []byte("hello"), nil), 0},
+		{"masked text", wsFrame(0x1, []byte("hello"), mask), 0},
+		{"16 bit length", wsFrame(0x2, bytes.Repeat([]byte("a"), 300), nil), 0},
+		{"64 bit length", wsFrame(0x2, bytes.Repeat([]byte("b"), 70000), mask), 0},
+		{"close", wsFrame(wsOpClose, closePayload(1000, "bye"), nil), 1000},
+		{"masked close", wsFrame(wsOpClose, closePayload(4001, ""), mask), 4001},
+		{"close without status", wsFrame(wsOpClose, nil, nil), wsCloseNoStatus},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			// 两帧连续读取, 不多读
+			data := append(append([]byte(nil), tc.frame...), tc.frame...)
+			src := &wsPeer{reader: bufio.NewReader(bytes.NewReader(data))}
+			dst := &wsPeer{conn: &bufConn{}}
+			for i := 0; i < 2; i++ {
+				code, err := copyFrame(dst, src)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if code != tc.code {
+					t.Errorf("close code = %d, want %d", code, tc.code)
+				}
+			}
+			if got := dst.conn.(*bufConn).buf.Bytes(); !bytes.Equal(got, data) {
+				t.Errorf("forwarded %d bytes, want %d unchanged", len(got), len(data))
+			}
+			if _, err := copyFrame(dst, src); err != io.EOF {
+				t.Errorf("copy after end = %v, want EOF", err)
+			}
+		})
+	}
+}
+
+func TestCopyFrameTruncated(t *testing.T) {
+	frame := wsFrame(0x1, []byte("hello"), nil)
+	src := &wsPeer{reader: bufio.NewReader(bytes.NewReader(frame[:4]))}
+	if _, err := copyFrame(&wsPeer{conn: &bufConn{}}, src); err == nil {
+		t.Error("truncated frame copied")
+	}
+}
+
+// data read from conn until closed
+type recorder struct {
+	lock sync.Mutex
+	buf  bytes.Buffer
+	done chan struct{}
+}
+
+func record(conn net.Conn) *recorder {
+	r := &recorder{done: make(chan struct{})}
+	go func() {
+		defer close(r.done)
+		chunk := make([]byte, 1024)
+		for {
+			n, err := conn.Read(chunk)
+			r.lock.Lock()
+			r.buf.Write(chunk[:n])
+			r.lock.Unlock()
+			if err != nil {
+				return
+			}
+		}
+	}()
+	return r
+}
+
+func (r *recorder) bytes() []byte {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return append([]byte(nil), r.buf.Bytes()...)
+}
+
+// wait until n bytes received
+func (r *recorder) waitLen(t *testing.T, n int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for len(r.bytes()) < n {
+		if time.Now().After(deadline) {
+			t.Fatalf("received %d bytes, want %d", len(r.bytes()), n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// gateway between test client and test upstream
+type wsPipe struct {
+	client   net.Conn // test side of client
+	upstream net.Conn // test side of upstream
+	toClient *recorder
+	toUp     *recorder
+	code     chan int
+}
+
+func newWSPipe(idleTimeout time.Duration) *wsPipe {
+	client, gatewayClient := net.Pipe()
+	gatewayUpstream, upstream := net.Pipe()
+	p := &wsPipe{client: client, upstream: upstream, toClient: record(client), toUp: record(upstream), code: make(chan int, 1)}
+	go func() {
+		p.code <- pipeWebSocket(
+			&wsPeer{conn: gatewayClient, reader: bufio.NewReader(gatewayClient)},
+			&wsPeer{conn: gatewayUpstream, reader: bufio.NewReader(gatewayUpstream), masked: true},
+			idleTimeout)
+	}()
+	return p
+}
+
+// close code and data received by client and upstream
+func (p *wsPipe) wait(t *testing.T) (int, []byte, []byte) {
+	t.Helper()
+	select {
+	case code := <-p.code:
+		<-p.toClient.done
+		<-p.toUp.done
+		return code, p.toClient.bytes(), p.toUp.bytes()
+	case <-time.After(5 * time.Second):
+		t.Fatal("pipe not closed")
+	}
+	return 0, nil, nil
+}
+
+// unmask close frame sent by gateway
+func readClose(t *testing.T, frame []byte) (int, string) {
+	t.Helper()
+	if len(frame) < 4 || frame[0] != 0x80|wsOpClose {
+		t.Fatalf("close frame = %v", frame)
+	}
+	length := int(frame[1] & 0x7f)
+	payload := frame[2:]
+	if frame[1]&0x80 != 0 {
+		maskKey := payload[:4]
+		payload = append([]byte(nil), payload[4:]...)
+		for i := range payload {
+			payload[i] ^= maskKey[i%4]
+		}
+	}
+	if len(payload) != length {
+		t.Fatalf("close payload length %d, want %d", len(payload), length)
+	}
+	return int(binary.BigEndian.Uint16(payload)), string(payload[2:])
+}
+
+func TestPipeWebSocketClose(t *testing.T) {
+	p := newWSPipe(time.Minute)
+	mask := []byte{9, 8, 7, 6}
+	text := wsFrame(0x1, []byte("ping"), mask)
+	clientClose := wsFrame(wsOpClose, closePayload(4000, "done"), mask)
+	reply := wsFrame(0x1, []byte("pong"), nil)
+	upstreamClose := wsFrame(wsOpClose, closePayload(1000, ""), nil)
+
+	p.client.Write(text)
+	p.upstream.Write(reply)
+	p.client.Write(clientClose)
+	p.upstream.Write(upstreamClose)
+	// 关闭帧转发完成后断开
+	p.toUp.waitLen(t, len(text)+len(clientClose))
+	p.toClient.waitLen(t, len(reply)+len(upstreamClose))
+	p.client.Close()
+	p.upstream.Close()
+
+	code, toClient, toUpstream := p.wait(t)
+	// 首个关闭帧的 code
+	if code != 4000 {
+		t.Errorf("close code = %d, want 4000", code)
+	}
+	if want := append(append([]byte(nil), text...), clientClose...); !bytes.Equal(toUpstream, want) {
+		t.Errorf("upstream received %v, want %v", toUpstream, want)
+	}
+	if want := append(append([]byte(nil), reply...), upstreamClose...); !bytes.Equal(toClient, want) {
+		t.Errorf("client received %v, want %v", toClient, want)
+	}
+}
+
+func TestPipeWebSocketPeerGone(t *testing.T) {
+	p := newWSPipe(time.Minute)
+	// 上游未发送关闭帧断开
+	p.upstream.Close()
+
+	code, toClient, _ := p.wait(t)
+	if code != wsCloseAbnormal {
+		t.Errorf("close code = %d, want %d", code, wsCloseAbnormal)
+	}
+	if closeCode, reason := readClose(t, toClient); closeCode != wsCloseGoingAway || reason != "peer closed" {
+		t.Errorf("client close frame = %d %q", closeCode, reason)
+	}
+}
+
+func TestPipeWebSocketIdle(t *testing.T) {
+	p := newWSPipe(10 * time.Millisecond)
+
+	code, toClient, toUpstream := p.wait(t)
+	if code != wsCloseGoingAway {
+		t.Errorf("close code = %d, want %d", code, wsCloseGoingAway)
+	}
+	for name, frame := range map[string][]byte{"client": toClient, "upstream": toUpstream} {
+		if closeCode, reason := readClose(t, frame); closeCode != wsCloseGoingAway || reason != "idle timeout" {
+			t.Errorf("%s close frame = %d %q", name, closeCode, reason)
+		}
+	}
+	// 发往上游的帧需掩码
+	if toUpstream[1]&0x80 == 0 || toClient[1]&0x80 != 0 {
+		t.Errorf("mask bits client %x upstream %x", toClient[1], toUpstream[1])
+	}
+}
@@ -0,0 +1,64 @@
+package semaphore
+
+import (
+	"sync"
+	"time"
+)
+
+// slots of current gateway, used when redis is disabled or unavailable
+type memoryStore struct {
+	lock  sync.Mutex
+	slots map[string]map[string]time.Time
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{slots: make(map[string]map[string]time.Time)}
+}
+
+func (s *memoryStore) acquire(key, id string, limit int, lease time.Duration) (bool, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	now := time.Now()
+	slots := s.slots[key]
+	if slots == nil {
+		slots = make(map[string]time.Time)
+		s.slots[key] = slots
+	}
+	for slot, expireAt := range slots {
+		if !expireAt.After(now) {
+			delete(slots, slot)
+		}
+	}
+	if len(slots) >= limit {
+		return false, nil
+	}
+	slots[id] = now.Add(lease)
+	return true, nil
+}
+
+func (s *memoryStore) renew(key string, ids []string, lease time.Duration) (int, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	expireAt := time.Now().Add(lease)
+	renewed := 0
+	for _, id := range ids {
+		if _, ok := s.slots[key][id]; ok {
+			s.slots[key][id] = expireAt
+			renewed++
+		}
+	}
+	return renewed, nil
+}
+
+func (s *memoryStore) release(key, id string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	delete(s.slots[key], id)
+	if len(s.slots[key]) == 0 {
+		delete(s.slots, key)
+	}
+	return nil
+}
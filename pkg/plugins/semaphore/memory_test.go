@@ -0,0 +1,78 @@
+package semaphore
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMemoryAcquireRelease(t *testing.T) {
+	s := newMemoryStore()
+	for i, id := range []string{"a", "b"} {
+		if ok, err := s.acquire("k", id, 2, time.Minute); !ok || err != nil {
+			t.Fatalf("acquire %d = %v, %v", i, ok, err)
+		}
+	}
+	if ok, _ := s.acquire("k", "c", 2, time.Minute); ok {
+		t.Error("acquired over limit")
+	}
+	// 其他 key 不受影响
+	if ok, _ := s.acquire("other", "c", 1, time.Minute); !ok {
+		t.Error("other key not acquired")
+	}
+
+	if err := s.release("k", "a"); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := s.acquire("k", "c", 2, time.Minute); !ok {
+		t.Error("released slot not acquired")
+	}
+	// 未持有的 slot
+	if err := s.release("k", "missing"); err != nil {
+		t.Fatal(err)
+	}
+	s.release("k", "b")
+	s.release("k", "c")
+	if _, ok := s.slots["k"]; ok {
+		t.Error("empty key not removed")
+	}
+}
+
+func TestMemoryLeaseExpiry(t *testing.T) {
+	s := newMemoryStore()
+	lease := 30 * time.Millisecond
+	s.acquire("k", "a", 1, lease)
+	if ok, _ := s.acquire("k", "b", 1, lease); ok {
+		t.Fatal("acquired over limit")
+	}
+	time.Sleep(2 * lease)
+	if ok, _ := s.acquire("k", "b", 1, lease); !ok {
+		t.Error("expired slot not reclaimed")
+	}
+	if _, ok := s.slots["k"]["a"]; ok {
+		t.Error("expired slot kept")
+	}
+}
+
+func TestMemoryRenew(t *testing.T) {
+	s := newMemoryStore()
+	lease := 50 * time.Millisecond
+	s.acquire("k", "a", 2, lease)
+	s.acquire("k", "b", 2, lease)
+
+	time.Sleep(lease / 2)
+	renewed, err := s.renew("k", []string{"a", "missing"}, lease)
+	if err != nil || renewed != 1 {
+		t.Fatalf("renew = %d, %v, want 1", renewed, err)
+	}
+	if _, ok := s.slots["k"]["missing"]; ok {
+		t.Error("renew added slot")
+	}
+	time.Sleep(lease * 3 / 4)
+	// a 续约后仍持有, b 已过期
+	if ok, _ := s.acquire("k", "c", 2, lease); !ok {
+		t.Fatal("expired slot not reclaimed")
+	}
+	if ok, _ := s.acquire("k", "d", 2, lease); ok {
+		t.Error("renewed slot reclaimed")
+	}
+}
@@ -0,0 +1,69 @@
+package semaphore
+
+import (
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+// slot key namespace, sorted set of slot id, score is the lease end in ms
+const KEY_PREFIX = "pigeon:semaphore:"
+
+// redis time is used as the clock of all gateways, one key per script for cluster mode
+var acquireScript = redis.NewScript(`
+redis.replicate_commands()
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', now)
+if redis.call('ZCARD', KEYS[1]) >= tonumber(ARGV[1]) then
+	return 0
+end
+redis.call('ZADD', KEYS[1], now + tonumber(ARGV[2]), ARGV[3])
+redis.call('PEXPIRE', KEYS[1], ARGV[2])
+return 1
+`)
+
+var renewScript = redis.NewScript(`
+redis.replicate_commands()
+local t = redis.call('TIME')
+local expireAt = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000) + tonumber(ARGV[1])
+local renewed = 0
+for i = 2, #ARGV do
+	if redis.call('ZSCORE', KEYS[1], ARGV[i]) then
+		redis.call('ZADD', KEYS[1], expireAt, ARGV[i])
+		renewed = renewed + 1
+	end
+end
+if renewed > 0 then
+	redis.call('PEXPIRE', KEYS[1], ARGV[1])
+end
+return renewed
+`)
+
+// slots on db.Cache, single or cluster mode
+type redisStore struct {
+	client redis.Cmdable
+}
+
+func newRedisStore(client redis.Cmdable) *redisStore {
+	return &redisStore{client: client}
+}
+
+func (s *redisStore) acquire(key, id string, limit int, lease time.Duration) (bool, error) {
+	n, err := acquireScript.Run(s.client, []string{KEY_PREFIX + key}, limit, lease.Milliseconds(), id).Int64()
+	return n == 1, err
+}
+
+func (s *redisStore) renew(key string, ids []string, lease time.Duration) (int, error) {
+	args := make([]interface{}, 0, len(ids)+1)
+	args = append(args, lease.Milliseconds())
+	for _, id := range ids {
+		args = append(args, id)
+	}
+	n, err := renewScript.Run(s.client, []string{KEY_PREFIX + key}, args...).Int64()
+	return int(n), err
+}
+
+func (s *redisStore) release(key, id string) error {
+	return s.client.ZRem(KEY_PREFIX+key, id).Err()
+}
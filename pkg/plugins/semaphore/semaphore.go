@@ -0,0 +1,162 @@
+package semaphore
+
+import (
+	"errors"
+	"fmt"
+	"rpc-gateway/pkg/core/common"
+	"rpc-gateway/pkg/core/config"
+	logging "rpc-gateway/pkg/core/log"
+	"rpc-gateway/pkg/plugins/cluster"
+	"rpc-gateway/pkg/plugins/httpserver/db"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// pool.setting.limiter.FAIL_POLICY
+	FAIL_OPEN   = "open"
+	FAIL_CLOSED = "closed"
+)
+
+var (
+	ErrLimitExceeded = errors.New("concurrency limit exceeded")
+	ErrUnavailable   = errors.New("concurrency limiter unavailable")
+)
+
+// slots of key, each slot expires at lease end unless renewed
+type store interface {
+	// take a slot when less than limit slots are alive
+	acquire(key, id string, limit int, lease time.Duration) (bool, error)
+	// extend slots, returns renewed count
+	renew(key string, ids []string, lease time.Duration) (int, error)
+	release(key, id string) error
+}
+
+// acquired slot, released when the engine client is closed
+type Permit struct {
+	store store
+	key   string
+	id    string
+	once  sync.Once
+}
+
+var (
+	enabled  bool
+	lease    time.Duration
+	failOpen bool
+	remote   store // redis, nil when cache disabled
+	local    = newMemoryStore()
+	held     = make(map[*Permit]struct{})
+	heldLock sync.Mutex
+	initOnce sync.Once
+)
+
+// init limiter and renew held slots every third of lease
+func Init(setting config.Limiter) {
+	initOnce.Do(func() {
+		if !setting.Enabled {
+			return
+		}
+		enabled = true
+		lease = time.Duration(setting.Lease) * time.Second
+		failOpen = strings.EqualFold(setting.FailPolicy, FAIL_OPEN)
+		if db.Cache != nil {
+			remote = newRedisStore(db.Cache)
+			logging.Log.Info("concurrency limiter use redis store, fail policy ", setting.FailPolicy)
+		} else {
+			logging.Log.Warn("concurrency limiter use memory store, limits are not shared by gateways")
+		}
+		go func() {
+			for range time.Tick(lease / 3) {
+				renewHeld()
+			}
+		}()
+	})
+}
+
+// limiter enabled
+func Enabled() bool {
+	return enabled
+}
+
+// acquire slot of key, no limit when limit <= 0
+// redis 出错时 FAIL_POLICY 为 open 使用本地限流, 本地上限为当前节点分到的并发
+func Acquire(key string, limit int) (*Permit, error) {
+	if !enabled || limit <= 0 {
+		return nil, nil
+	}
+	id := cluster.NodeId() + ":" + common.GenXid()
+	s, localLimit := remote, limit
+	if s != nil {
+		ok, err := s.acquire(key, id, limit, lease)
+		if err == nil {
+			return newPermit(s, key, id, ok)
+		}
+		if !failOpen {
+			return nil, fmt.Errorf("%w: %v", ErrUnavailable, err)
+		}
+		logging.Log.Warn("concurrency limiter redis error, use memory store: ", err)
+		if share, ok := cluster.Share(limit, key); ok {
+			localLimit = share
+		}
+	}
+	ok, err := local.acquire(key, id, localLimit, lease)
+	if err != nil {
+		return nil, err
+	}
+	return newPermit(local, key, id, ok)
+}
+
+func newPermit(s store, key, id string, ok bool) (*Permit, error) {
+	if !ok {
+		return nil, ErrLimitExceeded
+	}
+	permit := &Permit{store: s, key: key, id: id}
+	heldLock.Lock()
+	held[permit] = struct{}{}
+	heldLock.Unlock()
+	return permit, nil
+}
+
+// release slot, nil permit is ignored
+func (permit *Permit) Release() {
+	if permit == nil {
+		return
+	}
+	permit.once.Do(func() {
+		heldLock.Lock()
+		delete(held, permit)
+		heldLock.Unlock()
+		// 释放失败的 slot 到期后回收
+		if err := permit.store.release(permit.key, permit.id); err != nil {
+			logging.Log.Warn("release concurrency slot ", permit.key, " error: ", err)
+		}
+	})
+}
+
+// renew held slots by store and key
+func renewHeld() {
+	slots := make(map[store]map[string][]string)
+	heldLock.Lock()
+	for permit := range held {
+		if slots[permit.store] == nil {
+			slots[permit.store] = make(map[string][]string)
+		}
+		slots[permit.store][permit.key] = append(slots[permit.store][permit.key], permit.id)
+	}
+	heldLock.Unlock()
+	for s, keys := range slots {
+		for key, ids := range keys {
+			renewed, err := s.renew(key, ids, lease)
+			if err != nil {
+				logging.Log.Warn("renew concurrency slots ", key, " error: ", err)
+				continue
+			}
+			// redis 故障期间过期的 slot 已被回收
+			if renewed < len(ids) {
+				logging.Log.Warn("concurrency slots ", key, " expired before renewal, ", len(ids)-renewed, " lost")
+			}
+		}
+	}
+}
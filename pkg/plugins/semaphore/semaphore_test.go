@@ -0,0 +1,146 @@
+package semaphore
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"rpc-gateway/pkg/core/config"
+	logging "rpc-gateway/pkg/core/log"
+	"rpc-gateway/pkg/plugins/cluster"
+	"rpc-gateway/pkg/plugins/httpserver/db"
+
+	"github.com/go-redis/redis"
+	"go.uber.org/zap"
+)
+
+// redis store of test, every call fails
+type errStore struct{}
+
+func (errStore) acquire(key, id string, limit int, lease time.Duration) (bool, error) {
+	return false, errors.New("connection refused")
+}
+
+func (errStore) renew(key string, ids []string, lease time.Duration) (int, error) {
+	return 0, errors.New("connection refused")
+}
+
+func (errStore) release(key, id string) error {
+	return errors.New("connection refused")
+}
+
+// limiter of test, remote is nil when cache disabled
+func setupLimiter(t *testing.T, s store, policy string) {
+	t.Helper()
+	logging.Log = zap.NewNop().Sugar()
+	oldEnabled, oldLease, oldFailOpen, oldRemote, oldLocal := enabled, lease, failOpen, remote, local
+	enabled, lease, failOpen, remote, local = true, time.Minute, policy == FAIL_OPEN, s, newMemoryStore()
+	t.Cleanup(func() {
+		enabled, lease, failOpen, remote, local = oldEnabled, oldLease, oldFailOpen, oldRemote, oldLocal
+	})
+}
+
+// acquire permits until limit exceeded
+func acquireAll(t *testing.T, key string, limit int) []*Permit {
+	t.Helper()
+	var permits []*Permit
+	for {
+		permit, err := Acquire(key, limit)
+		if errors.Is(err, ErrLimitExceeded) {
+			return permits
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		permits = append(permits, permit)
+		if len(permits) > limit {
+			t.Fatalf("acquired %d permits over limit %d", len(permits), limit)
+		}
+	}
+}
+
+// cluster members script of fake redis, heartbeat has 2 args
+type membersCache struct {
+	redis.Cmdable
+	members []interface{}
+}
+
+func (c *membersCache) EvalSha(sha1 string, keys []string, args ...interface{}) *redis.Cmd {
+	if len(args) == 1 {
+		return redis.NewCmdResult(c.members, nil)
+	}
+	return redis.NewCmdResult(int64(0), nil)
+}
+
+func TestAcquireDisabled(t *testing.T) {
+	setupLimiter(t, nil, FAIL_CLOSED)
+	if permit, err := Acquire("k", 0); permit != nil || err != nil {
+		t.Errorf("Acquire without limit = %v, %v", permit, err)
+	}
+	enabled = false
+	if permit, err := Acquire("k", 1); permit != nil || err != nil {
+		t.Errorf("Acquire of disabled limiter = %v, %v", permit, err)
+	}
+	// nil permit
+	var permit *Permit
+	permit.Release()
+}
+
+func TestAcquireMemory(t *testing.T) {
+	setupLimiter(t, nil, FAIL_CLOSED)
+	permits := acquireAll(t, "k", 2)
+	if len(permits) != 2 {
+		t.Fatalf("acquired %d permits, want 2", len(permits))
+	}
+	permits[0].Release()
+	permits[0].Release()
+	if permits = acquireAll(t, "k", 2); len(permits) != 1 {
+		t.Errorf("acquired %d permits after release, want 1", len(permits))
+	}
+}
+
+func TestAcquireFailClosed(t *testing.T) {
+	setupLimiter(t, errStore{}, FAIL_CLOSED)
+	permit, err := Acquire("k", 2)
+	if permit != nil || !errors.Is(err, ErrUnavailable) {
+		t.Errorf("Acquire = %v, %v, want ErrUnavailable", permit, err)
+	}
+	if len(local.slots) != 0 {
+		t.Errorf("memory slots = %v, want none", local.slots)
+	}
+}
+
+func TestAcquireFailOpen(t *testing.T) {
+	setupLimiter(t, errStore{}, FAIL_OPEN)
+	// 节点列表未知时使用全部并发, cluster 只能启动一次
+	if !cluster.Dynamic() {
+		if permits := acquireAll(t, "engine:asr:a", 4); len(permits) != 4 {
+			t.Errorf("acquired %d permits without members, want 4", len(permits))
+		}
+		cache := db.Cache
+		db.Cache = &membersCache{members: []interface{}{"node-other", cluster.NodeId()}}
+		setting := config.PoolSetting{ClusterMembership: cluster.MEMBERSHIP_REDIS, ClusterHeartbeat: 3600}
+		err := cluster.Start(setting, func() {})
+		db.Cache = cache
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if members := cluster.Members(); len(members) != 2 {
+		t.Fatalf("cluster members = %v", members)
+	}
+	// 本地上限为当前节点分到的并发
+	share, _ := cluster.Share(4, "engine:asr:b")
+	if permits := acquireAll(t, "engine:asr:b", 4); len(permits) != share || share != 2 {
+		t.Errorf("acquired %d permits of 2 members, want share %d", len(permits), share)
+	}
+	// 释放到本地 slot, redis 出错不影响
+	permit, err := Acquire("engine:asr:c", 2)
+	if err != nil || permit.store != local {
+		t.Fatalf("Acquire = %v, %v, want memory permit", permit, err)
+	}
+	permit.Release()
+	if len(local.slots["engine:asr:c"]) != 0 {
+		t.Error("memory permit not released")
+	}
+}
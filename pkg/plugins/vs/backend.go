@@ -0,0 +1,192 @@
+package vs
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"rpc-gateway/pkg/plugins/vs/dvs"
+	"rpc-gateway/pkg/plugins/vs/kvs"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+const (
+	BACKEND_KVS = "kvs"
+	BACKEND_DVS = "dvs"
+)
+
+var ErrNoBackend = errors.New("no virtualization backend, vs.kvs or vs.dvs must be enabled")
+
+// virtualization backend of engines
+type Backend interface {
+	// kvs or dvs
+	Type() string
+	// render engine templates
+	Validate(engine Engine) error
+	Exists(engine Engine) (bool, error)
+	Deploy(engine Engine) error
+	Update(engine Engine) error
+	Delete(engine Engine) error
+	// ready engine addresses, host:port
+	ReadyAddresses(engine Engine, port string) ([]string, error)
+}
+
+// active backend, kvs first
+func ActiveBackend() (Backend, error) {
+	if client := kvs.Default(); client != nil {
+		return &kvsBackend{client: client}, nil
+	}
+	if client := dvs.Default(); client != nil {
+		return &dvsBackend{client: client}, nil
+	}
+	return nil, ErrNoBackend
+}
+
+// k8s deployment and service
+type kvsBackend struct {
+	client *kvs.Client
+}
+
+func (b *kvsBackend) Type() string {
+	return BACKEND_KVS
+}
+
+func (b *kvsBackend) engine(engine Engine) kvs.Engine {
+	return kvs.Engine{
+		EngineType:  engine.EngineType,
+		SceneCode:   engine.SceneCode,
+		Concurrency: engine.Concurrency,
+		Replicas:    engine.Replicas,
+		Tenant:      engine.Tenant,
+		Cpu:         engine.Cpu,
+		Memory:      engine.Memory,
+	}
+}
+
+func (b *kvsBackend) Validate(engine Engine) error {
+	_, err := b.client.RenderEngine(b.engine(engine))
+	return err
+}
+
+func (b *kvsBackend) Exists(engine Engine) (bool, error) {
+	deployments, err := b.client.GetDeployments(map[string]string{kvs.APP_LABEL: engine.Name()})
+	if err != nil {
+		return false, err
+	}
+	return len(deployments.Items) > 0, nil
+}
+
+func (b *kvsBackend) Deploy(engine Engine) error {
+	return b.client.DeployEngine(b.engine(engine))
+}
+
+// deploy is create or update
+func (b *kvsBackend) Update(engine Engine) error {
+	return b.client.DeployEngine(b.engine(engine))
+}
+
+func (b *kvsBackend) Delete(engine Engine) error {
+	return b.client.DeleteEngine(engine.Name())
+}
+
+// ready when all replicas are ready endpoints
+func (b *kvsBackend) ReadyAddresses(engine Engine, port string) ([]string, error) {
+	endpoints, err := b.client.GetEndpoints(engine.Name())
+	if apierrors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	addrs := readyAddresses(endpoints, port)
+	if len(addrs) < int(engine.Replicas) {
+		return nil, nil
+	}
+	return addrs, nil
+}
+
+func readyAddresses(endpoints *corev1.Endpoints, port string) []string {
+	addrs := make([]string, 0)
+	for _, subset := range endpoints.Subsets {
+		for _, address := range subset.Addresses {
+			addrs = append(addrs, net.JoinHostPort(address.IP, port))
+		}
+	}
+	return addrs
+}
+
+// docker container
+type dvsBackend struct {
+	client *dvs.Client
+}
+
+func (b *dvsBackend) Type() string {
+	return BACKEND_DVS
+}
+
+func (b *dvsBackend) engine(engine Engine) dvs.Engine {
+	return dvs.Engine{
+		EngineType:  engine.EngineType,
+		SceneCode:   engine.SceneCode,
+		Concurrency: engine.Concurrency,
+		Tenant:      engine.Tenant,
+		Cpu:         engine.Cpu,
+		Memory:      engine.Memory,
+	}
+}
+
+func (b *dvsBackend) Validate(engine Engine) error {
+	if engine.Replicas != 1 {
+		return fmt.Errorf("replicas must be 1 with %s", BACKEND_DVS)
+	}
+	_, err := b.client.RenderEngine(b.engine(engine))
+	return err
+}
+
+func (b *dvsBackend) container(engine Engine) (*dvs.EngineContainer, error) {
+	containers, err := b.client.ListEngines(engine.EngineType)
+	if err != nil {
+		return nil, err
+	}
+	for i := range containers {
+		if containers[i].Name == engine.Name() {
+			return &containers[i], nil
+		}
+	}
+	return nil, nil
+}
+
+func (b *dvsBackend) Exists(engine Engine) (bool, error) {
+	container, err := b.container(engine)
+	return container != nil, err
+}
+
+func (b *dvsBackend) Deploy(engine Engine) error {
+	_, err := b.client.DeployEngine(b.engine(engine))
+	return err
+}
+
+// container config is immutable, recreate container
+func (b *dvsBackend) Update(engine Engine) error {
+	if err := b.client.DeleteEngine(engine.Name()); err != nil {
+		return err
+	}
+	return b.Deploy(engine)
+}
+
+func (b *dvsBackend) Delete(engine Engine) error {
+	return b.client.DeleteEngine(engine.Name())
+}
+
+func (b *dvsBackend) ReadyAddresses(engine Engine, port string) ([]string, error) {
+	container, err := b.container(engine)
+	if err != nil || container == nil {
+		return nil, err
+	}
+	addr, ok := b.client.EngineAddr(*container, port)
+	if !ok {
+		return nil, nil
+	}
+	return []string{addr}, nil
+}
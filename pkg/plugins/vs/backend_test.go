@@ -0,0 +1,219 @@
+package vs
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"rpc-gateway/pkg/core/config"
+	logging "rpc-gateway/pkg/core/log"
+	"rpc-gateway/pkg/plugins/vs/kvs"
+
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+const testDeployment = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: ${ENGINE_NAME}
+spec:
+  replicas: 1
+  template:
+    spec:
+      containers:
+      - name: engine-server
+        image: engine/${ENGINE_TYPE}:latest
+        args: ["--concurrency=${CONCURRENCY}", "--scene=${SCENE_CODE}"]
+`
+
+const testService = `apiVersion: v1
+kind: Service
+metadata:
+  name: ${ENGINE_NAME}
+spec:
+  ports:
+  - name: grpc
+    port: 9000
+`
+
+var testEngine = Engine{EngineType: "asr", SceneCode: "scene-a", Concurrency: 2, Replicas: 1, Tenant: "t1"}
+
+// asr engine pool enabled, kvs backend of fake clientset
+func setupBackend(t *testing.T) *fake.Clientset {
+	t.Helper()
+	logging.Log = zap.NewNop().Sugar()
+	cfg := &config.Configs{}
+	cfg.Pool.Setting.EngineServiceSelectorKey = "engine"
+	cfg.Pool.Setting.AsrEngineServiceSelectorValue = "zhuiyi.ai.asr"
+	cfg.Pool.Setting.TtsEngineServiceSelectorValue = "zhuiyi.ai.tts"
+	cfg.Pool.Engine = []config.PoolEngine{{EngineName: "ASR", PoolEnabled: true, EngineServerPort: "9000", RequestIdleTime: 10}}
+	config.Set(cfg)
+
+	dir, err := ioutil.TempDir("", "vs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "asr"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range map[string]string{"1-deployment.yaml": testDeployment, "2-service.yaml": testService} {
+		if err := ioutil.WriteFile(filepath.Join(dir, "asr", name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	clientset := fake.NewSimpleClientset()
+	client, err := kvs.NewClient(clientset, config.KvsConfig{Namespace: "engines", DeployFilePath: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	kvs.SetDefault(client)
+
+	readyTimeout, readyInterval := READY_TIMEOUT, READY_INTERVAL
+	READY_TIMEOUT, READY_INTERVAL = 5*time.Second, 5*time.Millisecond
+	t.Cleanup(func() {
+		os.RemoveAll(dir)
+		kvs.SetDefault(nil)
+		config.Set(nil)
+		READY_TIMEOUT, READY_INTERVAL = readyTimeout, readyInterval
+	})
+	return clientset
+}
+
+// endpoints of engine service
+func setEndpoints(t *testing.T, clientset *fake.Clientset, name string, ips ...string) {
+	t.Helper()
+	addresses := make([]corev1.EndpointAddress, 0, len(ips))
+	for _, ip := range ips {
+		addresses = append(addresses, corev1.EndpointAddress{IP: ip})
+	}
+	endpoints := &corev1.Endpoints{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "engines"},
+		Subsets:    []corev1.EndpointSubset{{Addresses: addresses}},
+	}
+	if _, err := clientset.CoreV1().Endpoints("engines").Create(context.Background(), endpoints, metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestActiveBackend(t *testing.T) {
+	if _, err := ActiveBackend(); err != ErrNoBackend {
+		t.Errorf("ActiveBackend without client error = %v, want %v", err, ErrNoBackend)
+	}
+	setupBackend(t)
+	backend, err := ActiveBackend()
+	if err != nil || backend.Type() != BACKEND_KVS {
+		t.Errorf("ActiveBackend = %v, %v, want kvs", backend, err)
+	}
+}
+
+func TestKvsBackend(t *testing.T) {
+	clientset := setupBackend(t)
+	backend, _ := ActiveBackend()
+	engine := testEngine
+
+	if err := backend.Validate(engine); err != nil {
+		t.Fatal(err)
+	}
+	invalid := engine
+	invalid.SceneCode = "Scene_A"
+	if err := backend.Validate(invalid); err == nil {
+		t.Error("invalid engine name validated")
+	}
+	if found, err := backend.Exists(engine); err != nil || found {
+		t.Fatalf("Exists before deploy = %v, %v", found, err)
+	}
+	if err := backend.Deploy(engine); err != nil {
+		t.Fatal(err)
+	}
+	if found, err := backend.Exists(engine); err != nil || !found {
+		t.Fatalf("Exists after deploy = %v, %v", found, err)
+	}
+
+	// endpoints 未创建
+	if addrs, err := backend.ReadyAddresses(engine, "9000"); err != nil || addrs != nil {
+		t.Errorf("ReadyAddresses without endpoints = %v, %v", addrs, err)
+	}
+	setEndpoints(t, clientset, engine.Name(), "10.0.0.5")
+	// 就绪副本数不足
+	scaled := engine
+	scaled.Replicas = 2
+	if addrs, err := backend.ReadyAddresses(scaled, "9000"); err != nil || addrs != nil {
+		t.Errorf("ReadyAddresses of 1/2 replicas = %v, %v", addrs, err)
+	}
+	if addrs, err := backend.ReadyAddresses(engine, "9000"); err != nil || !reflect.DeepEqual(addrs, []string{"10.0.0.5:9000"}) {
+		t.Errorf("ReadyAddresses = %v, %v", addrs, err)
+	}
+
+	if err := backend.Update(scaled); err != nil {
+		t.Fatal(err)
+	}
+	deploy, err := clientset.AppsV1().Deployments("engines").Get(context.Background(), engine.Name(), metav1.GetOptions{})
+	if err != nil || *deploy.Spec.Replicas != 2 {
+		t.Errorf("updated deployment = %v, %v", deploy, err)
+	}
+	if err := backend.Delete(engine); err != nil {
+		t.Fatal(err)
+	}
+	if found, err := backend.Exists(engine); err != nil || found {
+		t.Errorf("Exists after delete = %v, %v", found, err)
+	}
+}
+
+func TestReadyAddresses(t *testing.T) {
+	endpoints := &corev1.Endpoints{Subsets: []corev1.EndpointSubset{
+		{
+			Addresses:         []corev1.EndpointAddress{{IP: "10.0.0.1"}, {IP: "fd00::1"}},
+			NotReadyAddresses: []corev1.EndpointAddress{{IP: "10.0.0.2"}},
+		},
+		{Addresses: []corev1.EndpointAddress{{IP: "10.0.0.3"}}},
+	}}
+	want := []string{"10.0.0.1:9000", "[fd00::1]:9000", "10.0.0.3:9000"}
+	if got := readyAddresses(endpoints, "9000"); !reflect.DeepEqual(got, want) {
+		t.Errorf("readyAddresses = %v, want %v", got, want)
+	}
+}
+
+func TestDvsBackendReplicas(t *testing.T) {
+	engine := testEngine
+	engine.Replicas = 2
+	err := (&dvsBackend{}).Validate(engine)
+	if err == nil || !strings.Contains(err.Error(), "replicas must be 1") {
+		t.Errorf("dvs Validate of 2 replicas error = %v", err)
+	}
+}
+
+func TestEngineValidate(t *testing.T) {
+	setupBackend(t)
+	cases := []struct {
+		name   string
+		modify func(engine *Engine)
+		want   string
+	}{
+		{"valid", func(engine *Engine) {}, ""},
+		{"engine type", func(engine *Engine) { engine.EngineType = "nlp" }, "engine_type must be asr or tts"},
+		{"concurrency", func(engine *Engine) { engine.Concurrency = 0 }, "concurrency must be a positive number"},
+		{"scene code", func(engine *Engine) { engine.SceneCode = "" }, "scene_code is required"},
+		{"replicas", func(engine *Engine) { engine.Replicas = 0 }, "replicas must be a positive number"},
+		{"cpu", func(engine *Engine) { engine.Cpu = "two" }, `invalid cpu "two"`},
+		{"memory", func(engine *Engine) { engine.Memory = "1Qi" }, `invalid memory "1Qi"`},
+		{"pool not enabled", func(engine *Engine) { engine.EngineType = "tts" }, "tts engine pool is not enabled"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			engine := testEngine
+			tc.modify(&engine)
+			err := engine.Validate()
+			if (tc.want == "") != (err == nil) || (err != nil && err.Error() != tc.want) {
+				t.Errorf("Validate error = %v, want %q", err, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,191 @@
+package dvs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"rpc-gateway/pkg/core/config"
+	"strings"
+	"sync"
+	"time"
+)
+
+// docker api request timeout
+var REQUEST_TIMEOUT = 30 * time.Second
+
+var ErrNotInitialized = errors.New("dvs client not initialized")
+
+// docker api error response
+type APIError struct {
+	StatusCode int
+	Message    string `json:"message"`
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("docker api error %d: %s", e.StatusCode, e.Message)
+}
+
+// docker engine api client, unix socket or tcp
+type Client struct {
+	baseURL      string
+	dockerHost   string // host 模式下引擎地址的 host
+	http         *http.Client
+	templatePath string
+	network      string
+	addressMode  string
+}
+
+// new client of dvs config, HOST 为 unix:///var/run/docker.sock, tcp://host:2375 或 host
+func NewClient(cfg config.DvsConfig) (*Client, error) {
+	c := &Client{
+		dockerHost:   "127.0.0.1",
+		templatePath: cfg.TemplatePath,
+		network:      cfg.Network,
+		addressMode:  strings.ToLower(cfg.AddressMode),
+	}
+	transport := &http.Transport{}
+	host := cfg.Host
+	if !strings.Contains(host, "://") {
+		host = "tcp://" + host
+	}
+	u, err := url.Parse(host)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "unix":
+		socket := u.Path
+		transport.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", socket)
+		}
+		c.baseURL = "http://docker"
+	case "tcp", "http", "https":
+		scheme := "http"
+		if u.Scheme == "https" {
+			scheme = "https"
+		}
+		hostPort := u.Host
+		if u.Port() == "" {
+			hostPort = net.JoinHostPort(u.Hostname(), "2375")
+		}
+		c.baseURL = scheme + "://" + hostPort
+		c.dockerHost = u.Hostname()
+	default:
+		return nil, fmt.Errorf("unsupported docker host %q", cfg.Host)
+	}
+	if cfg.APIVersion != "" {
+		c.baseURL += "/" + strings.TrimPrefix(cfg.APIVersion, "/")
+	}
+	c.http = &http.Client{Transport: transport, Timeout: REQUEST_TIMEOUT}
+	return c, nil
+}
+
+// default client
+var defaultClient *Client
+var defaultLock sync.RWMutex
+
+// closed when default client set
+var defaultReady = make(chan struct{})
+var defaultReadyOnce sync.Once
+
+// init default client and ping docker
+func Init(cfg config.DvsConfig) error {
+	c, err := NewClient(cfg)
+	if err != nil {
+		return err
+	}
+	if err := c.Ping(); err != nil {
+		return err
+	}
+	SetDefault(c)
+	return nil
+}
+
+// set default client
+func SetDefault(c *Client) {
+	defaultLock.Lock()
+	defer defaultLock.Unlock()
+	defaultClient = c
+	if c != nil {
+		defaultReadyOnce.Do(func() { close(defaultReady) })
+	}
+}
+
+// default client, nil before Init
+func Default() *Client {
+	defaultLock.RLock()
+	defer defaultLock.RUnlock()
+	return defaultClient
+}
+
+// closed when default client set
+func Ready() <-chan struct{} {
+	return defaultReady
+}
+
+// default client, blocks until Init
+func WaitDefault() *Client {
+	<-defaultReady
+	return Default()
+}
+
+// ping docker engine
+func (c *Client) Ping() error {
+	return c.do(http.MethodGet, "/_ping", nil, nil, nil)
+}
+
+// docker api request, body and out are json, non 2xx status returns APIError
+func (c *Client) do(method, path string, query url.Values, body interface{}, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	target := c.baseURL + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+	req, err := http.NewRequest(method, target, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		apiErr := &APIError{StatusCode: resp.StatusCode}
+		if json.Unmarshal(data, apiErr) != nil || apiErr.Message == "" {
+			apiErr.Message = strings.TrimSpace(string(data))
+		}
+		return apiErr
+	}
+	if out != nil && len(data) > 0 {
+		return json.Unmarshal(data, out)
+	}
+	return nil
+}
+
+// api error with status
+func isStatus(err error, statusCode int) bool {
+	apiErr, ok := err.(*APIError)
+	return ok && apiErr.StatusCode == statusCode
+}
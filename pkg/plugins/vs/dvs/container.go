@@ -0,0 +1,320 @@
+package dvs
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"rpc-gateway/pkg/plugins/vs/kvs"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+const (
+	// label of containers created by gateway
+	MANAGED_BY_LABEL = "zhuiyi.ai/managed-by"
+	MANAGED_BY       = "rpc-gateway"
+	// container stop timeout, second
+	STOP_TIMEOUT = 10
+	// address mode
+	ADDRESS_MODE_CONTAINER = "container"
+	ADDRESS_MODE_HOST      = "host"
+)
+
+// docker container name
+var containerNamePattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]+$`)
+
+// engine to run, container template TEMPLATE_PATH/<engine type>.yaml
+type Engine struct {
+	EngineType  string // asr, tts
+	SceneCode   string
+	Concurrency int
+	Tenant      string
+	Cpu         string // 为空使用模板值, 设置 HostConfig.NanoCpus
+	Memory      string // 为空使用模板值, 设置 HostConfig.Memory
+}
+
+// container name, <engine type>-<concurrency>-<scene code>
+func (engine Engine) Name() string {
+	return kvs.EngineName(engine.EngineType, engine.Concurrency, engine.SceneCode)
+}
+
+// engine container of list
+type EngineContainer struct {
+	ID    string
+	Name  string
+	State string // created, running, exited ...
+	Meta  kvs.EngineMeta
+	IP    string            // container ip on NETWORK
+	Ports map[string]string // private port -> host port
+}
+
+// container list entry, GET /containers/json
+type containerSummary struct {
+	Id     string
+	Names  []string
+	State  string
+	Labels map[string]string
+	Ports  []struct {
+		PrivatePort int
+		PublicPort  int
+		Type        string
+	}
+	NetworkSettings struct {
+		Networks map[string]struct {
+			IPAddress string
+		}
+	}
+}
+
+// template variables, ${ENGINE_NAME} etc.
+func templateVars(engine Engine) map[string]string {
+	return map[string]string{
+		"ENGINE_NAME": engine.Name(),
+		"ENGINE_TYPE": strings.ToLower(engine.EngineType),
+		"SCENE_CODE":  engine.SceneCode,
+		"CONCURRENCY": strconv.Itoa(engine.Concurrency),
+		"TENANT":      engine.Tenant,
+	}
+}
+
+// engine schema labels, same keys as kvs engine service
+func engineLabels(engine Engine) map[string]string {
+	labels := map[string]string{
+		kvs.ENGINE_TYPE_LABEL:      strings.ToLower(engine.EngineType),
+		kvs.SCENE_CODE_LABEL:       engine.SceneCode,
+		kvs.CONCURRENCY_ANNOTATION: strconv.Itoa(engine.Concurrency),
+		MANAGED_BY_LABEL:           MANAGED_BY,
+	}
+	if engine.Tenant != "" {
+		labels[kvs.TENANT_ANNOTATION] = engine.Tenant
+	}
+	return labels
+}
+
+// render container create config of engine template
+func (c *Client) RenderEngine(engine Engine) (map[string]interface{}, error) {
+	name := engine.Name()
+	if !containerNamePattern.MatchString(name) {
+		return nil, fmt.Errorf("invalid engine container name %q", name)
+	}
+	file := filepath.Join(c.templatePath, strings.ToLower(engine.EngineType)+".yaml")
+	template, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	rendered, err := kvs.Render(template, templateVars(engine))
+	if err != nil {
+		return nil, fmt.Errorf("render %s error: %v", file, err)
+	}
+	data, err := yaml.YAMLToJSON(rendered)
+	if err != nil {
+		return nil, fmt.Errorf("decode %s error: %v", file, err)
+	}
+	body := make(map[string]interface{})
+	if err := json.Unmarshal(data, &body); err != nil {
+		return nil, fmt.Errorf("decode %s error: %v", file, err)
+	}
+	labels, _ := body["Labels"].(map[string]interface{})
+	if labels == nil {
+		labels = make(map[string]interface{})
+	}
+	for key, value := range engineLabels(engine) {
+		labels[key] = value
+	}
+	body["Labels"] = labels
+	if err := setResources(body, engine); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+// engine cpu and memory limits of host config
+func setResources(body map[string]interface{}, engine Engine) error {
+	if engine.Cpu == "" && engine.Memory == "" {
+		return nil
+	}
+	hostConfig, _ := body["HostConfig"].(map[string]interface{})
+	if hostConfig == nil {
+		hostConfig = make(map[string]interface{})
+	}
+	if engine.Cpu != "" {
+		cpu, err := resource.ParseQuantity(engine.Cpu)
+		if err != nil {
+			return fmt.Errorf("invalid cpu %q: %v", engine.Cpu, err)
+		}
+		hostConfig["NanoCpus"] = cpu.MilliValue() * 1000000
+	}
+	if engine.Memory != "" {
+		memory, err := resource.ParseQuantity(engine.Memory)
+		if err != nil {
+			return fmt.Errorf("invalid memory %q: %v", engine.Memory, err)
+		}
+		hostConfig["Memory"] = memory.Value()
+	}
+	body["HostConfig"] = hostConfig
+	return nil
+}
+
+// create engine container, returns container id
+func (c *Client) CreateEngine(engine Engine) (string, error) {
+	body, err := c.RenderEngine(engine)
+	if err != nil {
+		return "", err
+	}
+	created := struct {
+		Id       string
+		Warnings []string
+	}{}
+	query := url.Values{"name": []string{engine.Name()}}
+	if err := c.do(http.MethodPost, "/containers/create", query, body, &created); err != nil {
+		return "", err
+	}
+	return created.Id, nil
+}
+
+// start container, already started is ok
+func (c *Client) StartContainer(id string) error {
+	err := c.do(http.MethodPost, "/containers/"+url.PathEscape(id)+"/start", nil, nil, nil)
+	if isStatus(err, http.StatusNotModified) {
+		return nil
+	}
+	return err
+}
+
+// stop container, already stopped is ok
+func (c *Client) StopContainer(id string) error {
+	query := url.Values{"t": []string{strconv.Itoa(STOP_TIMEOUT)}}
+	err := c.do(http.MethodPost, "/containers/"+url.PathEscape(id)+"/stop", query, nil, nil)
+	if isStatus(err, http.StatusNotModified) {
+		return nil
+	}
+	return err
+}
+
+// force remove container, not found is ok
+func (c *Client) RemoveContainer(id string) error {
+	query := url.Values{"force": []string{"true"}, "v": []string{"true"}}
+	err := c.do(http.MethodDelete, "/containers/"+url.PathEscape(id), query, nil, nil)
+	if isStatus(err, http.StatusNotFound) {
+		return nil
+	}
+	return err
+}
+
+// create and start engine container, existing container is started
+func (c *Client) DeployEngine(engine Engine) (string, error) {
+	id, err := c.CreateEngine(engine)
+	if isStatus(err, http.StatusConflict) {
+		id, err = engine.Name(), nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return id, c.StartContainer(id)
+}
+
+// stop and remove engine container
+func (c *Client) DeleteEngine(name string) error {
+	if err := c.StopContainer(name); err != nil && !isStatus(err, http.StatusNotFound) {
+		return err
+	}
+	return c.RemoveContainer(name)
+}
+
+// engine containers of type, all states
+func (c *Client) ListEngines(engineType string) ([]EngineContainer, error) {
+	filters, err := json.Marshal(map[string][]string{
+		"label": {kvs.ENGINE_TYPE_LABEL + "=" + strings.ToLower(engineType)},
+	})
+	if err != nil {
+		return nil, err
+	}
+	summaries := make([]containerSummary, 0)
+	query := url.Values{"all": []string{"true"}, "filters": []string{string(filters)}}
+	if err := c.do(http.MethodGet, "/containers/json", query, nil, &summaries); err != nil {
+		return nil, err
+	}
+	containers := make([]EngineContainer, 0, len(summaries))
+	for _, summary := range summaries {
+		container := EngineContainer{
+			ID:    summary.Id,
+			State: summary.State,
+			Meta:  parseEngineLabels(summary.Labels, engineType),
+			Ports: make(map[string]string),
+		}
+		if len(summary.Names) > 0 {
+			container.Name = strings.TrimPrefix(summary.Names[0], "/")
+		}
+		container.IP = c.networkIP(summary)
+		for _, port := range summary.Ports {
+			if port.PublicPort > 0 && (port.Type == "" || port.Type == "tcp") {
+				container.Ports[strconv.Itoa(port.PrivatePort)] = strconv.Itoa(port.PublicPort)
+			}
+		}
+		containers = append(containers, container)
+	}
+	return containers, nil
+}
+
+// container ip on NETWORK, first network with ip when NETWORK is empty
+func (c *Client) networkIP(summary containerSummary) string {
+	networks := summary.NetworkSettings.Networks
+	if c.network != "" {
+		return networks[c.network].IPAddress
+	}
+	names := make([]string, 0, len(networks))
+	for name := range networks {
+		names = append(names, name)
+	}
+	// map 无序, 按名称取第一个
+	sort.Strings(names)
+	for _, name := range names {
+		if ip := networks[name].IPAddress; ip != "" {
+			return ip
+		}
+	}
+	return ""
+}
+
+// engine address of container port, false if container not running or address unknown
+func (c *Client) EngineAddr(container EngineContainer, port string) (string, bool) {
+	if container.State != "running" {
+		return "", false
+	}
+	if c.addressMode == ADDRESS_MODE_HOST {
+		hostPort, ok := container.Ports[port]
+		if !ok {
+			return "", false
+		}
+		return net.JoinHostPort(c.dockerHost, hostPort), true
+	}
+	if container.IP == "" {
+		return "", false
+	}
+	return net.JoinHostPort(container.IP, port), true
+}
+
+// engine meta of container labels, concurrency 0 if missing or invalid
+func parseEngineLabels(labels map[string]string, defaultEngineType string) kvs.EngineMeta {
+	meta := kvs.EngineMeta{
+		EngineType: strings.ToLower(labels[kvs.ENGINE_TYPE_LABEL]),
+		SceneCode:  labels[kvs.SCENE_CODE_LABEL],
+		Tenant:     labels[kvs.TENANT_ANNOTATION],
+	}
+	if concurrency, err := strconv.Atoi(labels[kvs.CONCURRENCY_ANNOTATION]); err == nil && concurrency > 0 {
+		meta.Concurrency = concurrency
+	}
+	if meta.EngineType == "" {
+		meta.EngineType = strings.ToLower(defaultEngineType)
+	}
+	return meta
+}
@@ -0,0 +1,384 @@
+package dvs
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"rpc-gateway/pkg/core/config"
+	"rpc-gateway/pkg/plugins/vs/kvs"
+)
+
+const testTemplate = `Image: engine/${ENGINE_TYPE}:latest
+Cmd: ["--concurrency=${CONCURRENCY}", "--scene=${SCENE_CODE}"]
+Labels:
+  app: ${ENGINE_NAME}
+HostConfig:
+  PortBindings:
+    8080/tcp: [{HostPort: ""}]
+`
+
+type fakeContainer struct {
+	id     string
+	name   string
+	state  string
+	body   map[string]interface{}
+	port   int
+	ip     string
+	stopTo string
+}
+
+// docker engine api of containers in memory
+type fakeDocker struct {
+	sync.Mutex
+	containers map[string]*fakeContainer // by name
+	nextPort   int
+}
+
+func (d *fakeDocker) find(idOrName string) *fakeContainer {
+	for _, container := range d.containers {
+		if container.id == idOrName || container.name == idOrName {
+			return container
+		}
+	}
+	return nil
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"message": message})
+}
+
+func (d *fakeDocker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	d.Lock()
+	defer d.Unlock()
+	path := strings.TrimPrefix(r.URL.Path, "/v1.40")
+	switch {
+	case path == "/_ping":
+		w.Write([]byte("OK"))
+	case r.Method == http.MethodPost && path == "/containers/create":
+		name := r.URL.Query().Get("name")
+		if d.containers[name] != nil {
+			writeError(w, http.StatusConflict, "Conflict. The container name \"/"+name+"\" is already in use")
+			return
+		}
+		body := make(map[string]interface{})
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		d.nextPort++
+		container := &fakeContainer{id: "id-" + name, name: name, state: "created", body: body, port: d.nextPort, ip: "172.18.0.2"}
+		d.containers[name] = container
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]interface{}{"Id": container.id, "Warnings": []string{}})
+	case r.Method == http.MethodGet && path == "/containers/json":
+		filters := make(map[string][]string)
+		json.Unmarshal([]byte(r.URL.Query().Get("filters")), &filters)
+		summaries := make([]map[string]interface{}, 0)
+		for _, container := range d.containers {
+			labels := make(map[string]string)
+			for key, value := range container.body["Labels"].(map[string]interface{}) {
+				labels[key] = value.(string)
+			}
+			matched := true
+			for _, filter := range filters["label"] {
+				kv := strings.SplitN(filter, "=", 2)
+				if labels[kv[0]] != kv[1] {
+					matched = false
+				}
+			}
+			if !matched {
+				continue
+			}
+			summary := map[string]interface{}{
+				"Id":     container.id,
+				"Names":  []string{"/" + container.name},
+				"State":  container.state,
+				"Labels": labels,
+				"NetworkSettings": map[string]interface{}{"Networks": map[string]interface{}{
+					"bridge":  map[string]string{"IPAddress": "172.17.0.2"},
+					"engines": map[string]string{"IPAddress": container.ip},
+				}},
+			}
+			if container.state == "running" {
+				summary["Ports"] = []map[string]interface{}{
+					{"PrivatePort": 8080, "PublicPort": 32000 + container.port, "Type": "tcp"},
+					{"PrivatePort": 9090, "Type": "tcp"},
+				}
+			}
+			summaries = append(summaries, summary)
+		}
+		json.NewEncoder(w).Encode(summaries)
+	case strings.HasPrefix(path, "/containers/"):
+		data := strings.Split(strings.TrimPrefix(path, "/containers/"), "/")
+		container := d.find(data[0])
+		if container == nil {
+			writeError(w, http.StatusNotFound, "No such container: "+data[0])
+			return
+		}
+		action := ""
+		if len(data) > 1 {
+			action = data[1]
+		}
+		switch {
+		case r.Method == http.MethodPost && action == "start":
+			if container.state == "running" {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			container.state = "running"
+		case r.Method == http.MethodPost && action == "stop":
+			if container.state != "running" {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			container.state = "exited"
+			container.stopTo = r.URL.Query().Get("t")
+		case r.Method == http.MethodDelete && action == "":
+			if r.URL.Query().Get("force") != "true" && container.state == "running" {
+				writeError(w, http.StatusConflict, "container is running")
+				return
+			}
+			delete(d.containers, container.name)
+		default:
+			writeError(w, http.StatusNotFound, "page not found")
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeError(w, http.StatusNotFound, "page not found")
+	}
+}
+
+// client of fake docker, template of asr in temp dir
+func testClient(t *testing.T, addressMode string, network string) (*Client, *fakeDocker) {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "dvs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	if err := ioutil.WriteFile(filepath.Join(dir, "asr.yaml"), []byte(testTemplate), 0644); err != nil {
+		t.Fatal(err)
+	}
+	docker := &fakeDocker{containers: make(map[string]*fakeContainer)}
+	server := httptest.NewServer(docker)
+	t.Cleanup(server.Close)
+	c, err := NewClient(config.DvsConfig{
+		Host:         server.URL,
+		APIVersion:   "v1.40",
+		TemplatePath: dir,
+		Network:      network,
+		AddressMode:  addressMode,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Ping(); err != nil {
+		t.Fatal(err)
+	}
+	return c, docker
+}
+
+func TestContainerLifecycle(t *testing.T) {
+	c, docker := testClient(t, ADDRESS_MODE_CONTAINER, "engines")
+	engine := Engine{EngineType: "ASR", SceneCode: "scene-a", Concurrency: 4, Tenant: "t1", Cpu: "1500m", Memory: "1Gi"}
+
+	id, err := c.CreateEngine(engine)
+	if err != nil {
+		t.Fatal(err)
+	}
+	created := docker.containers["asr-4-scene-a"]
+	if created == nil || id != created.id || created.state != "created" {
+		t.Fatalf("created container = %+v, id %q", created, id)
+	}
+	labels := created.body["Labels"].(map[string]interface{})
+	if labels["app"] != "asr-4-scene-a" || labels[kvs.CONCURRENCY_ANNOTATION] != "4" ||
+		labels[kvs.TENANT_ANNOTATION] != "t1" || labels[MANAGED_BY_LABEL] != MANAGED_BY {
+		t.Errorf("labels = %v", labels)
+	}
+	hostConfig := created.body["HostConfig"].(map[string]interface{})
+	if hostConfig["NanoCpus"] != float64(1500000000) || hostConfig["Memory"] != float64(1<<30) {
+		t.Errorf("host config = %v", hostConfig)
+	}
+	if hostConfig["PortBindings"] == nil {
+		t.Error("template host config dropped")
+	}
+
+	// 重名创建为 409
+	if _, err := c.CreateEngine(engine); !isStatus(err, http.StatusConflict) {
+		t.Errorf("create existing = %v, want 409", err)
+	}
+
+	if err := c.StartContainer(id); err != nil {
+		t.Fatal(err)
+	}
+	if created.state != "running" {
+		t.Errorf("state after start = %s", created.state)
+	}
+	// 已启动为 304
+	if err := c.StartContainer(id); err != nil {
+		t.Errorf("start running container: %v", err)
+	}
+
+	if err := c.StopContainer(id); err != nil {
+		t.Fatal(err)
+	}
+	if created.state != "exited" || created.stopTo != "10" {
+		t.Errorf("stopped container = %+v", created)
+	}
+	// 已停止为 304
+	if err := c.StopContainer(id); err != nil {
+		t.Errorf("stop exited container: %v", err)
+	}
+
+	if err := c.RemoveContainer(id); err != nil {
+		t.Fatal(err)
+	}
+	if len(docker.containers) != 0 {
+		t.Errorf("containers after remove = %v", docker.containers)
+	}
+	// 不存在为 404
+	if err := c.RemoveContainer(id); err != nil {
+		t.Errorf("remove missing container: %v", err)
+	}
+	if err := c.StartContainer(id); !isStatus(err, http.StatusNotFound) {
+		t.Errorf("start missing container = %v, want 404", err)
+	}
+	if err := c.StopContainer(id); !isStatus(err, http.StatusNotFound) {
+		t.Errorf("stop missing container = %v, want 404", err)
+	}
+}
+
+func TestDeployAndDeleteEngine(t *testing.T) {
+	c, docker := testClient(t, ADDRESS_MODE_CONTAINER, "")
+	engine := Engine{EngineType: "asr", SceneCode: "a", Concurrency: 2}
+	id, err := c.DeployEngine(engine)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != "id-asr-2-a" || docker.containers["asr-2-a"].state != "running" {
+		t.Errorf("deployed %q, containers %v", id, docker.containers)
+	}
+	// 已存在时启动已有容器
+	docker.containers["asr-2-a"].state = "exited"
+	id, err = c.DeployEngine(engine)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != "asr-2-a" || docker.containers["asr-2-a"].state != "running" {
+		t.Errorf("redeployed %q, containers %v", id, docker.containers)
+	}
+
+	if err := c.DeleteEngine("asr-2-a"); err != nil {
+		t.Fatal(err)
+	}
+	if len(docker.containers) != 0 {
+		t.Errorf("containers after delete = %v", docker.containers)
+	}
+	// 已删除不报错
+	if err := c.DeleteEngine("asr-2-a"); err != nil {
+		t.Errorf("delete missing engine: %v", err)
+	}
+}
+
+func TestIsStatus(t *testing.T) {
+	c, _ := testClient(t, ADDRESS_MODE_CONTAINER, "")
+	err := c.do(http.MethodGet, "/containers/missing/json", nil, nil, nil)
+	if !isStatus(err, http.StatusNotFound) || isStatus(err, http.StatusConflict) {
+		t.Errorf("isStatus of %v", err)
+	}
+	apiErr, ok := err.(*APIError)
+	if !ok || apiErr.Message != "No such container: missing" {
+		t.Errorf("api error = %#v", err)
+	}
+	if isStatus(nil, http.StatusNotFound) || isStatus(os.ErrNotExist, http.StatusNotFound) {
+		t.Error("isStatus of non api error")
+	}
+}
+
+func TestEngineAddr(t *testing.T) {
+	cases := []struct {
+		mode    string
+		network string
+		want    string
+	}{
+		{ADDRESS_MODE_CONTAINER, "engines", "172.18.0.2:8080"},
+		// 未设置 NETWORK 时按名称取第一个
+		{ADDRESS_MODE_CONTAINER, "", "172.17.0.2:8080"},
+		{ADDRESS_MODE_HOST, "", "127.0.0.1:32001"},
+	}
+	for _, tc := range cases {
+		c, _ := testClient(t, tc.mode, tc.network)
+		if _, err := c.DeployEngine(Engine{EngineType: "asr", SceneCode: "a", Concurrency: 2}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := c.CreateEngine(Engine{EngineType: "asr", SceneCode: "b", Concurrency: 2}); err != nil {
+			t.Fatal(err)
+		}
+		containers, err := c.ListEngines("ASR")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(containers) != 2 {
+			t.Fatalf("containers = %+v", containers)
+		}
+		for _, container := range containers {
+			addr, ok := c.EngineAddr(container, "8080")
+			switch container.Name {
+			case "asr-2-a":
+				if !ok || addr != tc.want {
+					t.Errorf("%s mode %q: addr = %q, %v, want %q", tc.mode, tc.network, addr, ok, tc.want)
+				}
+				if container.Meta != (kvs.EngineMeta{EngineType: "asr", Concurrency: 2, SceneCode: "a"}) {
+					t.Errorf("meta = %+v", container.Meta)
+				}
+				// 未映射的端口
+				if _, ok := c.EngineAddr(container, "9090"); ok && tc.mode == ADDRESS_MODE_HOST {
+					t.Errorf("%s mode: unpublished port has address", tc.mode)
+				}
+			case "asr-2-b":
+				// 未运行的容器没有地址
+				if ok {
+					t.Errorf("%s mode: created container addr = %q", tc.mode, addr)
+				}
+			default:
+				t.Errorf("unexpected container %s", container.Name)
+			}
+		}
+	}
+}
+
+func TestNewClientHost(t *testing.T) {
+	cases := []struct {
+		host       string
+		apiVersion string
+		baseURL    string
+		dockerHost string
+	}{
+		{"unix:///var/run/docker.sock", "", "http://docker", "127.0.0.1"},
+		{"10.0.0.1", "v1.40", "http://10.0.0.1:2375/v1.40", "10.0.0.1"},
+		{"tcp://docker:2376", "/v1.41", "http://docker:2376/v1.41", "docker"},
+		{"https://docker", "", "https://docker:2375", "docker"},
+	}
+	for _, tc := range cases {
+		c, err := NewClient(config.DvsConfig{Host: tc.host, APIVersion: tc.apiVersion})
+		if err != nil {
+			t.Errorf("NewClient(%q) error: %v", tc.host, err)
+			continue
+		}
+		if c.baseURL != tc.baseURL || c.dockerHost != tc.dockerHost {
+			t.Errorf("NewClient(%q) = %s, %s, want %s, %s", tc.host, c.baseURL, c.dockerHost, tc.baseURL, tc.dockerHost)
+		}
+	}
+	if _, err := NewClient(config.DvsConfig{Host: "ssh://docker"}); err == nil {
+		t.Error("ssh docker host accepted")
+	}
+}
@@ -0,0 +1,67 @@
+package vs
+
+import (
+	"errors"
+	"fmt"
+	"rpc-gateway/pkg/core/config"
+	"rpc-gateway/pkg/plugins/vs/kvs"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// engine of lifecycle api, identified by engine type, concurrency and scene code
+type Engine struct {
+	EngineType  string `json:"engineType"` // asr, tts
+	SceneCode   string `json:"sceneCode"`
+	Concurrency int    `json:"concurrency"`
+	Replicas    int32  `json:"replicas"` // dvs 只支持 1
+	Tenant      string `json:"tenant"`
+	Cpu         string `json:"cpu"`    // 为空使用模板值
+	Memory      string `json:"memory"` // 为空使用模板值
+}
+
+// engine name, <engine type>-<concurrency>-<scene code>
+func (engine Engine) Name() string {
+	return kvs.EngineName(engine.EngineType, engine.Concurrency, engine.SceneCode)
+}
+
+// validate engine request, engine pool must be enabled
+func (engine Engine) Validate() error {
+	if engine.EngineType != "asr" && engine.EngineType != "tts" {
+		return errors.New("engine_type must be asr or tts")
+	}
+	if engine.Concurrency <= 0 {
+		return errors.New("concurrency must be a positive number")
+	}
+	if engine.SceneCode == "" {
+		return errors.New("scene_code is required")
+	}
+	if engine.Replicas <= 0 {
+		return errors.New("replicas must be a positive number")
+	}
+	if engine.Cpu != "" {
+		if _, err := resource.ParseQuantity(engine.Cpu); err != nil {
+			return fmt.Errorf("invalid cpu %q", engine.Cpu)
+		}
+	}
+	if engine.Memory != "" {
+		if _, err := resource.ParseQuantity(engine.Memory); err != nil {
+			return fmt.Errorf("invalid memory %q", engine.Memory)
+		}
+	}
+	if _, ok := engineServerPort(engine.EngineType); !ok {
+		return fmt.Errorf("%s engine pool is not enabled", engine.EngineType)
+	}
+	return nil
+}
+
+// ENGINE_SERVER_PORT of enabled engine pool
+func engineServerPort(engineType string) (string, bool) {
+	for _, engineConfig := range config.Get().Pool.Engine {
+		if engineConfig.PoolEnabled && strings.EqualFold(engineConfig.EngineName, engineType) {
+			return engineConfig.EngineServerPort, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,160 @@
+package vs
+
+import (
+	logging "rpc-gateway/pkg/core/log"
+	"rpc-gateway/pkg/plugins/discovery"
+	"rpc-gateway/pkg/plugins/httpserver/db"
+	"rpc-gateway/pkg/plugins/httpserver/model"
+	"rpc-gateway/pkg/plugins/pool/grpc"
+	"sync"
+)
+
+// run job with engine status saved to db, failed status on error
+func recordEngine(engine Engine, backend Backend, status string, run func() error) error {
+	if err := saveEngine(engine, backend, status); err != nil {
+		return err
+	}
+	if err := run(); err != nil {
+		if db.Enabled() {
+			if statusErr := db.Engines.SetStatus(engine.Name(), model.ENGINE_FAILED); statusErr != nil {
+				logging.Log.Error("save engine ", engine.Name(), " status error: ", statusErr)
+			}
+		}
+		return err
+	}
+	return saveEngine(engine, backend, model.ENGINE_READY)
+}
+
+// save engine to db, no-op when db is not enabled
+func saveEngine(engine Engine, backend Backend, status string) error {
+	if !db.Enabled() {
+		return nil
+	}
+	return db.Engines.Save(&model.Engine{
+		Name:        engine.Name(),
+		EngineType:  engine.EngineType,
+		SceneCode:   engine.SceneCode,
+		Concurrency: engine.Concurrency,
+		Replicas:    engine.Replicas,
+		Tenant:      engine.Tenant,
+		Cpu:         engine.Cpu,
+		Memory:      engine.Memory,
+		Backend:     backend.Type(),
+		Status:      status,
+	})
+}
+
+// set engine status, no-op when db is not enabled
+func setEngineStatus(engine Engine, status string) error {
+	if !db.Enabled() {
+		return nil
+	}
+	return db.Engines.SetStatus(engine.Name(), status)
+}
+
+// save engine pools to db, no-op when db is not enabled
+func savePools(engine Engine, endpoints []discovery.Endpoint) error {
+	if !db.Enabled() {
+		return nil
+	}
+	pools := make([]model.Pool, 0, len(endpoints))
+	for _, endpoint := range endpoints {
+		pools = append(pools, model.Pool{
+			Name:       engine.Name() + "/" + endpoint.Addr,
+			EngineType: engine.EngineType,
+			EngineName: engine.Name(),
+			Addr:       endpoint.Addr,
+			SceneCode:  endpoint.SceneCode,
+			Tenant:     endpoint.Tenant,
+			Size:       endpoint.PoolSize,
+		})
+	}
+	return db.Pools.Replace(engine.Name(), pools)
+}
+
+// delete engine and pools from db, no-op when db is not enabled
+func deleteEngine(engine Engine) error {
+	if !db.Enabled() {
+		return nil
+	}
+	return db.Engines.Delete(engine.Name())
+}
+
+// engines of db with pools on this replica, name -> engine type
+var syncedEngines = make(map[string]string)
+var syncLock sync.Mutex
+
+// rebuild pools of ready engines from db, called after pools init
+func RestorePools() {
+	if !db.Enabled() {
+		return
+	}
+	syncPools()
+}
+
+// poll engines shared by replicas, engines created or deleted by other replicas apply here
+func WatchPools() {
+	if !db.Enabled() {
+		return
+	}
+	go db.Poll(syncPools)
+}
+
+// pools of ready engines, pools of engines deleted from db are released, creating or updating engines are kept
+func syncPools() {
+	syncLock.Lock()
+	defer syncLock.Unlock()
+	engines, err := db.Engines.List("")
+	if err != nil {
+		logging.Log.Error("sync engine pools error: ", err)
+		return
+	}
+	exists := make(map[string]bool, len(engines))
+	for _, engine := range engines {
+		exists[engine.Name] = true
+		if engine.Status != model.ENGINE_READY {
+			continue
+		}
+		if _, ok := engineServerPort(engine.EngineType); !ok {
+			if _, synced := syncedEngines[engine.Name]; !synced {
+				logging.Log.Warn("restore ", engine.Name, " pools skipped: ", engine.EngineType, " engine pool is not enabled")
+				syncedEngines[engine.Name] = ""
+			}
+			continue
+		}
+		pools, err := db.Pools.ListByEngine(engine.Name)
+		if err != nil {
+			logging.Log.Error("restore ", engine.Name, " pools error: ", err)
+			continue
+		}
+		endpoints := make([]discovery.Endpoint, 0, len(pools))
+		for _, pool := range pools {
+			endpoints = append(endpoints, discovery.Endpoint{
+				Addr:      pool.Addr,
+				SceneCode: pool.SceneCode,
+				PoolSize:  pool.Size,
+				Tenant:    pool.Tenant,
+			})
+		}
+		// 未变化的连接池保留
+		grpc.InitPoolFromExistEngineForUpdate(engine.EngineType, engine.Name, endpoints)
+		if _, synced := syncedEngines[engine.Name]; !synced {
+			logging.Log.Info("restore ", engine.Name, " pools, ", len(endpoints), " endpoints")
+		}
+		syncedEngines[engine.Name] = engine.EngineType
+	}
+	for name, engineType := range syncedEngines {
+		if exists[name] {
+			continue
+		}
+		delete(syncedEngines, name)
+		if engineType == "" {
+			continue
+		}
+		// 已被其他副本删除
+		for _, poolName := range grpc.EnginePoolNames(engineType, name) {
+			grpc.ReleaseGrpcPool(poolName, engineType)
+		}
+		logging.Log.Info("engine ", name, " deleted, pools released")
+	}
+}
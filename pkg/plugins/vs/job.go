@@ -0,0 +1,187 @@
+package vs
+
+import (
+	"encoding/json"
+	"fmt"
+	"rpc-gateway/pkg/core/common"
+	logging "rpc-gateway/pkg/core/log"
+	"rpc-gateway/pkg/plugins/httpserver/db"
+	"rpc-gateway/pkg/plugins/httpserver/model"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	JOB_CREATE = "create"
+	JOB_UPDATE = "update"
+	JOB_DELETE = "delete"
+
+	JOB_PENDING = model.JOB_PENDING
+	JOB_RUNNING = model.JOB_RUNNING
+	JOB_SUCCESS = model.JOB_SUCCESS
+	JOB_FAILED  = model.JOB_FAILED
+)
+
+// finished jobs are kept for JOB_TTL
+var JOB_TTL = time.Hour
+
+// engine lifecycle job
+type Job struct {
+	ID         string    `json:"id"`
+	Type       string    `json:"type"`
+	Backend    string    `json:"backend"`
+	Engine     Engine    `json:"engine"`
+	EngineName string    `json:"engineName"`
+	Status     string    `json:"status"`
+	Message    string    `json:"message"`
+	Endpoints  []string  `json:"endpoints"`
+	CreateTime time.Time `json:"createTime"`
+	UpdateTime time.Time `json:"updateTime"`
+}
+
+func (job *Job) finished() bool {
+	return job.Status == JOB_SUCCESS || job.Status == JOB_FAILED
+}
+
+// jobs of this gateway
+var jobs = make(map[string]*Job)
+var jobsLock sync.RWMutex
+
+// job snapshot by id, jobs of other gateways are read from db
+func GetJob(id string) (Job, bool) {
+	jobsLock.RLock()
+	job, ok := jobs[id]
+	var snapshot Job
+	if ok {
+		snapshot = *job
+	}
+	jobsLock.RUnlock()
+	if ok {
+		return snapshot, true
+	}
+	if !db.Enabled() {
+		return Job{}, false
+	}
+	record, err := db.Jobs.Get(id)
+	if err != nil {
+		logging.Log.Error("get job ", id, " error: ", err)
+		return Job{}, false
+	}
+	if record == nil {
+		return Job{}, false
+	}
+	return jobOf(record), true
+}
+
+// submit job, one unfinished job per engine
+func submitJob(jobType string, engine Engine, backend Backend, run func(id string) error) (Job, error) {
+	jobsLock.Lock()
+	defer jobsLock.Unlock()
+	now := time.Now()
+	for id, job := range jobs {
+		if job.finished() && now.Sub(job.UpdateTime) > JOB_TTL {
+			delete(jobs, id)
+			continue
+		}
+		if !job.finished() && job.EngineName == engine.Name() {
+			return Job{}, fmt.Errorf("engine %s has unfinished %s job %s", job.EngineName, job.Type, job.ID)
+		}
+	}
+	if db.Enabled() {
+		record, err := db.Jobs.Unfinished(engine.Name())
+		if err != nil {
+			return Job{}, err
+		}
+		if record != nil {
+			return Job{}, fmt.Errorf("engine %s has unfinished %s job %s", record.EngineName, record.Type, record.JobId)
+		}
+	}
+	job := &Job{
+		ID:         common.GenXid(),
+		Type:       jobType,
+		Backend:    backend.Type(),
+		Engine:     engine,
+		EngineName: engine.Name(),
+		Status:     JOB_PENDING,
+		CreateTime: now,
+		UpdateTime: now,
+	}
+	if db.Enabled() {
+		if err := db.Jobs.Save(jobRecord(*job)); err != nil {
+			return Job{}, err
+		}
+	}
+	jobs[job.ID] = job
+	go runJob(job.ID, run)
+	return *job, nil
+}
+
+func runJob(id string, run func(id string) error) {
+	updateJob(id, func(job *Job) {
+		job.Status = JOB_RUNNING
+	})
+	err := run(id)
+	updateJob(id, func(job *Job) {
+		if err != nil {
+			job.Status = JOB_FAILED
+			job.Message = err.Error()
+			logging.Log.Error(job.Type, " engine ", job.EngineName, " job ", job.ID, " failed: ", err)
+			return
+		}
+		job.Status = JOB_SUCCESS
+		logging.Log.Info(job.Type, " engine ", job.EngineName, " job ", job.ID, " success")
+	})
+}
+
+// update job, saved to db
+func updateJob(id string, update func(job *Job)) {
+	jobsLock.Lock()
+	job, ok := jobs[id]
+	if !ok {
+		jobsLock.Unlock()
+		return
+	}
+	update(job)
+	job.UpdateTime = time.Now()
+	snapshot := *job
+	jobsLock.Unlock()
+	if db.Enabled() {
+		if err := db.Jobs.Save(jobRecord(snapshot)); err != nil {
+			logging.Log.Error("save job ", id, " error: ", err)
+		}
+	}
+}
+
+func jobRecord(job Job) *model.Job {
+	engine, _ := json.Marshal(job.Engine)
+	return &model.Job{
+		JobId:      job.ID,
+		Type:       job.Type,
+		Backend:    job.Backend,
+		EngineName: job.EngineName,
+		Engine:     string(engine),
+		Status:     job.Status,
+		Message:    job.Message,
+		Endpoints:  strings.Join(job.Endpoints, ","),
+		BaseModel:  model.BaseModel{CreateTime: job.CreateTime, UpdateTime: job.UpdateTime},
+	}
+}
+
+func jobOf(record *model.Job) Job {
+	job := Job{
+		ID:         record.JobId,
+		Type:       record.Type,
+		Backend:    record.Backend,
+		EngineName: record.EngineName,
+		Status:     record.Status,
+		Message:    record.Message,
+		CreateTime: record.CreateTime,
+		UpdateTime: record.UpdateTime,
+	}
+	json.Unmarshal([]byte(record.Engine), &job.Engine)
+	if record.Endpoints != "" {
+		job.Endpoints = strings.Split(record.Endpoints, ",")
+	}
+	return job
+}
@@ -0,0 +1,282 @@
+package kvs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"rpc-gateway/pkg/core/config"
+	logging "rpc-gateway/pkg/core/log"
+	"sync"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+const (
+	// k8s api request timeout
+	REQUEST_TIMEOUT = 10 * time.Second
+	// resource types of ResDelete
+	RES_DEPLOYMENT = "deployment"
+	RES_SERVICE    = "service"
+	RES_CONFIGMAP  = "configmap"
+)
+
+var ErrNotInitialized = errors.New("kvs client not initialized")
+
+// k8s client of one namespace
+type Client struct {
+	clientset  kubernetes.Interface
+	namespace  string
+	deployPath string
+	minCpu     resource.Quantity
+	minMemory  resource.Quantity
+}
+
+// new client, clientset 可为 fake clientset
+func NewClient(clientset kubernetes.Interface, cfg config.KvsConfig) (*Client, error) {
+	c := &Client{clientset: clientset, namespace: cfg.Namespace, deployPath: cfg.DeployFilePath}
+	var err error
+	if cfg.MinCpu != "" {
+		if c.minCpu, err = resource.ParseQuantity(cfg.MinCpu); err != nil {
+			return nil, fmt.Errorf("invalid MIN_CPU %q: %v", cfg.MinCpu, err)
+		}
+	}
+	if cfg.MinMemory != "" {
+		if c.minMemory, err = resource.ParseQuantity(cfg.MinMemory); err != nil {
+			return nil, fmt.Errorf("invalid MIN_MEMORY %q: %v", cfg.MinMemory, err)
+		}
+	}
+	return c, nil
+}
+
+// default client, used by pool
+var defaultClient *Client
+var defaultLock sync.RWMutex
+
+// closed when default client set
+var defaultReady = make(chan struct{})
+var defaultReadyOnce sync.Once
+
+// init default client, in cluster config, KUBECONFIG out of cluster
+func Init(cfg config.KvsConfig) error {
+	restConfig, err := rest.InClusterConfig()
+	if err != nil {
+		kubeconfig := os.Getenv("KUBECONFIG")
+		if kubeconfig == "" {
+			return err
+		}
+		if restConfig, err = clientcmd.BuildConfigFromFlags("", kubeconfig); err != nil {
+			return err
+		}
+	}
+	clientset, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		return err
+	}
+	c, err := NewClient(clientset, cfg)
+	if err != nil {
+		return err
+	}
+	SetDefault(c)
+	return nil
+}
+
+// set default client
+func SetDefault(c *Client) {
+	defaultLock.Lock()
+	defer defaultLock.Unlock()
+	defaultClient = c
+	if c != nil {
+		defaultReadyOnce.Do(func() { close(defaultReady) })
+	}
+}
+
+// default client, blocks until Init
+func WaitDefault() *Client {
+	<-defaultReady
+	return Default()
+}
+
+// default client, nil before Init
+func Default() *Client {
+	defaultLock.RLock()
+	defer defaultLock.RUnlock()
+	return defaultClient
+}
+
+// engine services by label, empty list on error
+func GetEngineSvcs(selector map[string]string) *corev1.ServiceList {
+	c := Default()
+	if c == nil {
+		logging.Log.Warn("get engine services: ", ErrNotInitialized)
+		return &corev1.ServiceList{}
+	}
+	svcs, err := c.GetServices(selector)
+	if err != nil {
+		logging.Log.Error("get engine services error: ", err)
+		return &corev1.ServiceList{}
+	}
+	return svcs
+}
+
+// endpoints by service name
+func GetEndpointsByName(name string) (*corev1.Endpoints, error) {
+	c := Default()
+	if c == nil {
+		return &corev1.Endpoints{}, ErrNotInitialized
+	}
+	endpoints, err := c.GetEndpoints(name)
+	if err != nil {
+		return &corev1.Endpoints{}, err
+	}
+	return endpoints, nil
+}
+
+func listOptions(selector map[string]string) metav1.ListOptions {
+	return metav1.ListOptions{LabelSelector: labels.SelectorFromSet(selector).String()}
+}
+
+func requestContext() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), REQUEST_TIMEOUT)
+}
+
+// services by label
+func (c *Client) GetServices(selector map[string]string) (*corev1.ServiceList, error) {
+	ctx, cancel := requestContext()
+	defer cancel()
+	return c.clientset.CoreV1().Services(c.namespace).List(ctx, listOptions(selector))
+}
+
+// watch services by label, stopped when ctx done
+func (c *Client) WatchServices(ctx context.Context, selector map[string]string) (watch.Interface, error) {
+	return c.clientset.CoreV1().Services(c.namespace).Watch(ctx, listOptions(selector))
+}
+
+// create or update service, cluster ip is kept
+func (c *Client) DeployService(svc *corev1.Service) error {
+	ctx, cancel := requestContext()
+	defer cancel()
+	services := c.clientset.CoreV1().Services(c.namespace)
+	svc.Namespace = c.namespace
+	exist, err := services.Get(ctx, svc.Name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		_, err = services.Create(ctx, svc, metav1.CreateOptions{})
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	svc.ResourceVersion = exist.ResourceVersion
+	svc.Spec.ClusterIP = exist.Spec.ClusterIP
+	_, err = services.Update(ctx, svc, metav1.UpdateOptions{})
+	return err
+}
+
+// endpoints by service name
+func (c *Client) GetEndpoints(name string) (*corev1.Endpoints, error) {
+	ctx, cancel := requestContext()
+	defer cancel()
+	return c.clientset.CoreV1().Endpoints(c.namespace).Get(ctx, name, metav1.GetOptions{})
+}
+
+// endpoints by label, endpoints 继承 service 的 label
+func (c *Client) ListEndpoints(selector map[string]string) (*corev1.EndpointsList, error) {
+	ctx, cancel := requestContext()
+	defer cancel()
+	return c.clientset.CoreV1().Endpoints(c.namespace).List(ctx, listOptions(selector))
+}
+
+// watch endpoints by label, stopped when ctx done
+func (c *Client) WatchEndpoints(ctx context.Context, selector map[string]string) (watch.Interface, error) {
+	return c.clientset.CoreV1().Endpoints(c.namespace).Watch(ctx, listOptions(selector))
+}
+
+// deployments by label
+func (c *Client) GetDeployments(selector map[string]string) (*appsv1.DeploymentList, error) {
+	ctx, cancel := requestContext()
+	defer cancel()
+	return c.clientset.AppsV1().Deployments(c.namespace).List(ctx, listOptions(selector))
+}
+
+// create or update deployment
+func (c *Client) DeployDeployment(deploy *appsv1.Deployment) error {
+	ctx, cancel := requestContext()
+	defer cancel()
+	deployments := c.clientset.AppsV1().Deployments(c.namespace)
+	deploy.Namespace = c.namespace
+	exist, err := deployments.Get(ctx, deploy.Name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		_, err = deployments.Create(ctx, deploy, metav1.CreateOptions{})
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	deploy.ResourceVersion = exist.ResourceVersion
+	_, err = deployments.Update(ctx, deploy, metav1.UpdateOptions{})
+	return err
+}
+
+// configmaps by label
+func (c *Client) GetConfigMaps(selector map[string]string) (*corev1.ConfigMapList, error) {
+	ctx, cancel := requestContext()
+	defer cancel()
+	return c.clientset.CoreV1().ConfigMaps(c.namespace).List(ctx, listOptions(selector))
+}
+
+// create or update configmap
+func (c *Client) DeployConfigMap(cm *corev1.ConfigMap) error {
+	ctx, cancel := requestContext()
+	defer cancel()
+	configMaps := c.clientset.CoreV1().ConfigMaps(c.namespace)
+	cm.Namespace = c.namespace
+	exist, err := configMaps.Get(ctx, cm.Name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		_, err = configMaps.Create(ctx, cm, metav1.CreateOptions{})
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	cm.ResourceVersion = exist.ResourceVersion
+	_, err = configMaps.Update(ctx, cm, metav1.UpdateOptions{})
+	return err
+}
+
+// pods by label
+func (c *Client) GetPodsByLabel(selector map[string]string) (*corev1.PodList, error) {
+	ctx, cancel := requestContext()
+	defer cancel()
+	return c.clientset.CoreV1().Pods(c.namespace).List(ctx, listOptions(selector))
+}
+
+// delete resource by type and name, not found is ignored
+func (c *Client) ResDelete(resourceType string, resName string) error {
+	ctx, cancel := requestContext()
+	defer cancel()
+	var err error
+	switch resourceType {
+	case RES_DEPLOYMENT:
+		err = c.clientset.AppsV1().Deployments(c.namespace).Delete(ctx, resName, metav1.DeleteOptions{})
+	case RES_SERVICE:
+		err = c.clientset.CoreV1().Services(c.namespace).Delete(ctx, resName, metav1.DeleteOptions{})
+	case RES_CONFIGMAP:
+		err = c.clientset.CoreV1().ConfigMaps(c.namespace).Delete(ctx, resName, metav1.DeleteOptions{})
+	default:
+		return fmt.Errorf("unsupported resource type %q", resourceType)
+	}
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,86 @@
+package kvs
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// engine service schema, label 优先, 其次 annotation, 都没有时解析 service 名称
+const (
+	// label, asr 或 tts, 未设置时为监听的引擎类型
+	ENGINE_TYPE_LABEL = "zhuiyi.ai/engine-type"
+	// label 或 annotation, 场景码不符合 label 值格式时使用 annotation
+	SCENE_CODE_LABEL = "zhuiyi.ai/scene-code"
+	// annotation 或 label, 引擎并发数, 即每个 endpoint 的连接池大小
+	CONCURRENCY_ANNOTATION = "zhuiyi.ai/concurrency"
+	// annotation 或 label, 租户
+	TENANT_ANNOTATION = "zhuiyi.ai/tenant"
+)
+
+// engine metadata of service
+type EngineMeta struct {
+	EngineType  string
+	Concurrency int
+	SceneCode   string
+	Tenant      string
+}
+
+// engine metadata from labels and annotations, service name <engine type>-<concurrency>-<scene code> as fallback
+func ParseEngineService(svc *corev1.Service, defaultEngineType string) (EngineMeta, error) {
+	meta := EngineMeta{
+		EngineType: strings.ToLower(metaValue(svc, ENGINE_TYPE_LABEL)),
+		SceneCode:  metaValue(svc, SCENE_CODE_LABEL),
+		Tenant:     metaValue(svc, TENANT_ANNOTATION),
+	}
+	if value := metaValue(svc, CONCURRENCY_ANNOTATION); value != "" {
+		concurrency, err := strconv.Atoi(value)
+		if err != nil || concurrency <= 0 {
+			return meta, fmt.Errorf("invalid %s %q", CONCURRENCY_ANNOTATION, value)
+		}
+		meta.Concurrency = concurrency
+	}
+	if meta.SceneCode == "" || meta.Concurrency == 0 {
+		engineType, concurrency, sceneCode, ok := parseEngineName(svc.Name)
+		if !ok {
+			return meta, fmt.Errorf("%s and %s are required, or service name <engine type>-<concurrency>-<scene code>",
+				SCENE_CODE_LABEL, CONCURRENCY_ANNOTATION)
+		}
+		if meta.EngineType == "" {
+			meta.EngineType = engineType
+		}
+		if meta.SceneCode == "" {
+			meta.SceneCode = sceneCode
+		}
+		if meta.Concurrency == 0 {
+			meta.Concurrency = concurrency
+		}
+	}
+	if meta.EngineType == "" {
+		meta.EngineType = strings.ToLower(defaultEngineType)
+	}
+	return meta, nil
+}
+
+// label, then annotation
+func metaValue(svc *corev1.Service, key string) string {
+	if value := strings.TrimSpace(svc.Labels[key]); value != "" {
+		return value
+	}
+	return strings.TrimSpace(svc.Annotations[key])
+}
+
+// <engine type>-<concurrency>-<scene code>, scene code may contain '-'
+func parseEngineName(name string) (string, int, string, bool) {
+	data := strings.SplitN(name, "-", 3)
+	if len(data) != 3 || data[2] == "" {
+		return "", 0, "", false
+	}
+	concurrency, err := strconv.Atoi(data[1])
+	if err != nil || concurrency <= 0 {
+		return "", 0, "", false
+	}
+	return strings.ToLower(data[0]), concurrency, data[2], true
+}
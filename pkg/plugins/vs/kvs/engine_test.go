@@ -0,0 +1,79 @@
+package kvs
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestParseEngineService(t *testing.T) {
+	cases := []struct {
+		name        string
+		labels      map[string]string
+		annotations map[string]string
+		defaultType string
+		want        EngineMeta
+		wantErr     bool
+	}{
+		{name: "asr-4-scene", want: EngineMeta{EngineType: "asr", Concurrency: 4, SceneCode: "scene"}},
+		{name: "TTS-2-scene-with-dash", want: EngineMeta{EngineType: "tts", Concurrency: 2, SceneCode: "scene-with-dash"}},
+		{
+			name:        "engine",
+			labels:      map[string]string{ENGINE_TYPE_LABEL: "ASR", SCENE_CODE_LABEL: "s1"},
+			annotations: map[string]string{CONCURRENCY_ANNOTATION: "8", TENANT_ANNOTATION: "t1"},
+			want:        EngineMeta{EngineType: "asr", Concurrency: 8, SceneCode: "s1", Tenant: "t1"},
+		},
+		{
+			// label 优先于 annotation
+			name:        "engine",
+			labels:      map[string]string{SCENE_CODE_LABEL: "label", CONCURRENCY_ANNOTATION: "3"},
+			annotations: map[string]string{SCENE_CODE_LABEL: "annotation", CONCURRENCY_ANNOTATION: "5"},
+			defaultType: "TTS",
+			want:        EngineMeta{EngineType: "tts", Concurrency: 3, SceneCode: "label"},
+		},
+		{
+			// 场景码不符合 label 格式时使用 annotation
+			name:        "engine",
+			annotations: map[string]string{SCENE_CODE_LABEL: "场景_1", CONCURRENCY_ANNOTATION: " 2 "},
+			defaultType: "asr",
+			want:        EngineMeta{EngineType: "asr", Concurrency: 2, SceneCode: "场景_1"},
+		},
+		{
+			// 缺少的字段从名称解析
+			name:        "asr-6-from-name",
+			annotations: map[string]string{SCENE_CODE_LABEL: "explicit"},
+			defaultType: "tts",
+			want:        EngineMeta{EngineType: "asr", Concurrency: 6, SceneCode: "explicit"},
+		},
+		{
+			name:        "tts-6-from-name",
+			labels:      map[string]string{ENGINE_TYPE_LABEL: "asr"},
+			annotations: map[string]string{CONCURRENCY_ANNOTATION: "1"},
+			want:        EngineMeta{EngineType: "asr", Concurrency: 1, SceneCode: "from-name"},
+		},
+		{name: "engine", annotations: map[string]string{CONCURRENCY_ANNOTATION: "0", SCENE_CODE_LABEL: "s"}, wantErr: true},
+		{name: "engine", annotations: map[string]string{CONCURRENCY_ANNOTATION: "x", SCENE_CODE_LABEL: "s"}, wantErr: true},
+		{name: "engine", wantErr: true},
+		{name: "asr-0-scene", wantErr: true},
+		{name: "asr-x-scene", wantErr: true},
+		{name: "asr-4-", wantErr: true},
+	}
+	for _, c := range cases {
+		svc := &corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: c.name, Labels: c.labels, Annotations: c.annotations}}
+		got, err := ParseEngineService(svc, c.defaultType)
+		if c.wantErr {
+			if err == nil {
+				t.Errorf("ParseEngineService(%s, %v, %v) = %+v, want error", c.name, c.labels, c.annotations, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseEngineService(%s, %v, %v) error: %v", c.name, c.labels, c.annotations, err)
+			continue
+		}
+		if got != c.want {
+			t.Errorf("ParseEngineService(%s, %v, %v) = %+v, want %+v", c.name, c.labels, c.annotations, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package kvs
+
+import (
+	"fmt"
+	logging "rpc-gateway/pkg/core/log"
+	"sort"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1beta1 "k8s.io/api/discovery/v1beta1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/selection"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/tools/cache"
+)
+
+// ready endpoints of engine service, Service 为空表示 service 已删除
+type ServiceEndpoints struct {
+	Name      string
+	Service   *corev1.Service
+	Addresses []string // ready endpoint ip, sorted
+}
+
+// called on every change of engine service or its endpoint slices, and on resync, 可能并发调用
+type EndpointsHandler func(endpoints ServiceEndpoints)
+
+// watch engine services by label and their endpoint slices, blocks until stopCh closed
+func (c *Client) WatchEngineEndpoints(stopCh <-chan struct{}, selector map[string]string, resync time.Duration, handler EndpointsHandler) error {
+	svcFactory := informers.NewSharedInformerFactoryWithOptions(c.clientset, resync,
+		informers.WithNamespace(c.namespace),
+		informers.WithTweakListOptions(func(options *metav1.ListOptions) {
+			options.LabelSelector = labels.SelectorFromSet(selector).String()
+		}))
+	// endpoint slice 按 service 名称关联
+	sliceFactory := informers.NewSharedInformerFactoryWithOptions(c.clientset, resync,
+		informers.WithNamespace(c.namespace),
+		informers.WithTweakListOptions(func(options *metav1.ListOptions) {
+			options.LabelSelector = discoveryv1beta1.LabelServiceName
+		}))
+	svcInformer := svcFactory.Core().V1().Services()
+	sliceInformer := sliceFactory.Discovery().V1beta1().EndpointSlices()
+	svcLister := svcInformer.Lister().Services(c.namespace)
+	sliceLister := sliceInformer.Lister().EndpointSlices(c.namespace)
+
+	notify := func(name string) {
+		if name == "" {
+			return
+		}
+		endpoints := ServiceEndpoints{Name: name}
+		svc, err := svcLister.Get(name)
+		if err != nil {
+			if !apierrors.IsNotFound(err) {
+				logging.Log.Error("get engine service ", name, " error: ", err)
+				return
+			}
+			handler(endpoints)
+			return
+		}
+		endpoints.Service = svc
+		requirement, err := labels.NewRequirement(discoveryv1beta1.LabelServiceName, selection.Equals, []string{name})
+		if err != nil {
+			logging.Log.Error("endpoint slice selector of ", name, " error: ", err)
+			return
+		}
+		slices, err := sliceLister.List(labels.NewSelector().Add(*requirement))
+		if err != nil {
+			logging.Log.Error("list endpoint slices of ", name, " error: ", err)
+			return
+		}
+		endpoints.Addresses = readyAddresses(slices)
+		handler(endpoints)
+	}
+	svcInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(obj interface{}) { notify(objectName(obj)) },
+		UpdateFunc: func(_, obj interface{}) { notify(objectName(obj)) },
+		DeleteFunc: func(obj interface{}) { notify(objectName(obj)) },
+	})
+	sliceInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(obj interface{}) { notify(sliceServiceName(obj)) },
+		UpdateFunc: func(_, obj interface{}) { notify(sliceServiceName(obj)) },
+		DeleteFunc: func(obj interface{}) { notify(sliceServiceName(obj)) },
+	})
+
+	// slices first, services 事件时 slices 已同步
+	sliceFactory.Start(stopCh)
+	if !cache.WaitForCacheSync(stopCh, sliceInformer.Informer().HasSynced) {
+		return fmt.Errorf("endpoint slice cache sync failed")
+	}
+	svcFactory.Start(stopCh)
+	if !cache.WaitForCacheSync(stopCh, svcInformer.Informer().HasSynced) {
+		return fmt.Errorf("service cache sync failed")
+	}
+	<-stopCh
+	return nil
+}
+
+// ready addresses of endpoint slices, ready 为空视为 ready
+func readyAddresses(slices []*discoveryv1beta1.EndpointSlice) []string {
+	seen := make(map[string]bool)
+	addresses := make([]string, 0)
+	for _, slice := range slices {
+		for _, endpoint := range slice.Endpoints {
+			if endpoint.Conditions.Ready != nil && !*endpoint.Conditions.Ready {
+				continue
+			}
+			for _, address := range endpoint.Addresses {
+				if !seen[address] {
+					seen[address] = true
+					addresses = append(addresses, address)
+				}
+			}
+		}
+	}
+	sort.Strings(addresses)
+	return addresses
+}
+
+func objectName(obj interface{}) string {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	if svc, ok := obj.(*corev1.Service); ok {
+		return svc.Name
+	}
+	return ""
+}
+
+func sliceServiceName(obj interface{}) string {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	if slice, ok := obj.(*discoveryv1beta1.EndpointSlice); ok {
+		return slice.Labels[discoveryv1beta1.LabelServiceName]
+	}
+	return ""
+}
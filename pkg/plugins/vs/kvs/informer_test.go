@@ -0,0 +1,131 @@
+package kvs
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"rpc-gateway/pkg/core/config"
+	logging "rpc-gateway/pkg/core/log"
+
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1beta1 "k8s.io/api/discovery/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func endpointSlice(name string, service string, ready map[string]*bool) *discoveryv1beta1.EndpointSlice {
+	slice := &discoveryv1beta1.EndpointSlice{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "engines",
+			Labels:    map[string]string{discoveryv1beta1.LabelServiceName: service},
+		},
+		AddressType: discoveryv1beta1.AddressTypeIPv4,
+	}
+	for address, isReady := range ready {
+		slice.Endpoints = append(slice.Endpoints, discoveryv1beta1.Endpoint{
+			Addresses:  []string{address},
+			Conditions: discoveryv1beta1.EndpointConditions{Ready: isReady},
+		})
+	}
+	return slice
+}
+
+func boolPtr(b bool) *bool {
+	return &b
+}
+
+func TestReadyAddresses(t *testing.T) {
+	slices := []*discoveryv1beta1.EndpointSlice{
+		endpointSlice("a-1", "a", map[string]*bool{"10.0.0.2": boolPtr(true), "10.0.0.3": boolPtr(false), "10.0.0.1": nil}),
+		endpointSlice("a-2", "a", map[string]*bool{"10.0.0.2": boolPtr(true), "10.0.0.4": boolPtr(true)}),
+	}
+	got := readyAddresses(slices)
+	want := []string{"10.0.0.1", "10.0.0.2", "10.0.0.4"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("readyAddresses = %v, want %v", got, want)
+	}
+	if got := readyAddresses(nil); got == nil || len(got) != 0 {
+		t.Errorf("readyAddresses(nil) = %#v, want empty", got)
+	}
+}
+
+// wait for handler call of service matching check
+func waitEndpoints(t *testing.T, ch <-chan ServiceEndpoints, check func(ServiceEndpoints) bool) ServiceEndpoints {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case endpoints := <-ch:
+			if check(endpoints) {
+				return endpoints
+			}
+		case <-timeout:
+			t.Fatal("timeout waiting for endpoints")
+		}
+	}
+}
+
+func TestWatchEngineEndpoints(t *testing.T) {
+	logging.Log = zap.NewNop().Sugar()
+	selector := map[string]string{"engine": "zhuiyi.ai.asr"}
+	svc := &corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "asr-2-a", Namespace: "engines", Labels: selector}}
+	clientset := fake.NewSimpleClientset(svc,
+		endpointSlice("asr-2-a-x", "asr-2-a", map[string]*bool{"10.0.0.1": boolPtr(true), "10.0.0.2": boolPtr(false)}))
+	c, err := NewClient(clientset, config.KvsConfig{Namespace: "engines"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ch := make(chan ServiceEndpoints, 100)
+	stopCh := make(chan struct{})
+	done := make(chan error, 1)
+	go func() {
+		done <- c.WatchEngineEndpoints(stopCh, selector, 0, func(endpoints ServiceEndpoints) { ch <- endpoints })
+	}()
+	// 停止时可能仍在等待 cache sync, 只检查返回
+	defer func() {
+		close(stopCh)
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Error("watch not stopped")
+		}
+	}()
+
+	// NotReady endpoint 不可用
+	endpoints := waitEndpoints(t, ch, func(e ServiceEndpoints) bool { return e.Name == "asr-2-a" && e.Service != nil })
+	if !reflect.DeepEqual(endpoints.Addresses, []string{"10.0.0.1"}) {
+		t.Errorf("initial addresses = %v, want [10.0.0.1]", endpoints.Addresses)
+	}
+
+	// NotReady 变为 ready
+	ctx := context.Background()
+	slices := clientset.DiscoveryV1beta1().EndpointSlices("engines")
+	updated := endpointSlice("asr-2-a-x", "asr-2-a", map[string]*bool{"10.0.0.1": boolPtr(true), "10.0.0.2": boolPtr(true)})
+	if _, err := slices.Update(ctx, updated, metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	waitEndpoints(t, ch, func(e ServiceEndpoints) bool {
+		return reflect.DeepEqual(e.Addresses, []string{"10.0.0.1", "10.0.0.2"})
+	})
+
+	// 全部 NotReady
+	updated = endpointSlice("asr-2-a-x", "asr-2-a", map[string]*bool{"10.0.0.1": boolPtr(false), "10.0.0.2": boolPtr(false)})
+	if _, err := slices.Update(ctx, updated, metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	endpoints = waitEndpoints(t, ch, func(e ServiceEndpoints) bool { return len(e.Addresses) == 0 })
+	if endpoints.Service == nil {
+		t.Error("service missing while endpoints not ready")
+	}
+
+	// service 删除
+	if err := clientset.CoreV1().Services("engines").Delete(ctx, "asr-2-a", metav1.DeleteOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	waitEndpoints(t, ch, func(e ServiceEndpoints) bool { return e.Name == "asr-2-a" && e.Service == nil })
+}
@@ -0,0 +1,42 @@
+package kvs
+
+import (
+	"context"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+// k8s api 封装, Client 实现, 测试时使用 fake clientset 构造 Client
+type Interface interface {
+	// service
+	GetServices(selector map[string]string) (*corev1.ServiceList, error)
+	WatchServices(ctx context.Context, selector map[string]string) (watch.Interface, error)
+	DeployService(svc *corev1.Service) error
+	// endpoints
+	GetEndpoints(name string) (*corev1.Endpoints, error)
+	ListEndpoints(selector map[string]string) (*corev1.EndpointsList, error)
+	WatchEndpoints(ctx context.Context, selector map[string]string) (watch.Interface, error)
+	// deployment
+	GetDeployments(selector map[string]string) (*appsv1.DeploymentList, error)
+	DeployDeployment(deploy *appsv1.Deployment) error
+	// configmap
+	GetConfigMaps(selector map[string]string) (*corev1.ConfigMapList, error)
+	DeployConfigMap(cm *corev1.ConfigMap) error
+	// pod
+	GetPodsByLabel(selector map[string]string) (*corev1.PodList, error)
+	// lease
+	RenewLease(name, holder string, renewTime time.Time, duration time.Duration, labels map[string]string) error
+	ListLeases(selector map[string]string) (*coordinationv1.LeaseList, error)
+	DeleteLease(name string) error
+	// engine, rendered from manifest templates
+	DeployEngine(engine Engine) error
+	DeleteEngine(name string) error
+	// resource delete
+	ResDelete(resourceType string, resName string) error
+}
+
+var _ Interface = (*Client)(nil)
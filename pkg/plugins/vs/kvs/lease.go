@@ -0,0 +1,62 @@
+package kvs
+
+import (
+	"time"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// create or renew lease held by holder, renewTime is of the clock shared by holders
+func (c *Client) RenewLease(name, holder string, renewTime time.Time, duration time.Duration, labels map[string]string) error {
+	ctx, cancel := requestContext()
+	defer cancel()
+	leases := c.clientset.CoordinationV1().Leases(c.namespace)
+	seconds := int32(duration / time.Second)
+	now := metav1.NewMicroTime(renewTime)
+	exist, err := leases.Get(ctx, name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		lease := &coordinationv1.Lease{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: c.namespace, Labels: labels},
+			Spec: coordinationv1.LeaseSpec{
+				HolderIdentity:       &holder,
+				LeaseDurationSeconds: &seconds,
+				AcquireTime:          &now,
+				RenewTime:            &now,
+			},
+		}
+		_, err = leases.Create(ctx, lease, metav1.CreateOptions{})
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	if exist.Spec.HolderIdentity == nil || *exist.Spec.HolderIdentity != holder {
+		exist.Spec.AcquireTime = &now
+	}
+	exist.Labels = labels
+	exist.Spec.HolderIdentity = &holder
+	exist.Spec.LeaseDurationSeconds = &seconds
+	exist.Spec.RenewTime = &now
+	_, err = leases.Update(ctx, exist, metav1.UpdateOptions{})
+	return err
+}
+
+// leases by label
+func (c *Client) ListLeases(selector map[string]string) (*coordinationv1.LeaseList, error) {
+	ctx, cancel := requestContext()
+	defer cancel()
+	return c.clientset.CoordinationV1().Leases(c.namespace).List(ctx, listOptions(selector))
+}
+
+// delete lease, not found is ignored
+func (c *Client) DeleteLease(name string) error {
+	ctx, cancel := requestContext()
+	defer cancel()
+	err := c.clientset.CoordinationV1().Leases(c.namespace).Delete(ctx, name, metav1.DeleteOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
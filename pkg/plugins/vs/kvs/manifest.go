@@ -0,0 +1,320 @@
+package kvs
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"rpc-gateway/pkg/core/config"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/drone/envsubst"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+const (
+	// app label, 用于 deployment 和 service 的 selector
+	APP_LABEL = "app"
+	// engine container in manifest template, MIN_CPU/MIN_MEMORY 作用于该容器
+	ENGINE_CONTAINER = "engine-server"
+)
+
+// engine to deploy, manifest templates in DEPLOY_FILE_PATH/<engine type>/
+type Engine struct {
+	EngineType  string // asr, tts
+	SceneCode   string
+	Concurrency int
+	Replicas    int32
+	Tenant      string
+	Cpu         string // 为空使用模板值, 不低于 MIN_CPU
+	Memory      string // 为空使用模板值, 不低于 MIN_MEMORY
+}
+
+// engine name, <engine type>-<concurrency>-<scene code>, also service name
+func EngineName(engineType string, concurrency int, sceneCode string) string {
+	return strings.ToLower(engineType) + "-" + strconv.Itoa(concurrency) + "-" + sceneCode
+}
+
+func (engine Engine) Name() string {
+	return EngineName(engine.EngineType, engine.Concurrency, engine.SceneCode)
+}
+
+// engine service selector of pool setting, e.g. engine: zhuiyi.ai.asr
+func EngineSelector(engineType string) map[string]string {
+	setting := config.Get().Pool.Setting
+	value := ""
+	switch strings.ToLower(engineType) {
+	case "asr":
+		value = setting.AsrEngineServiceSelectorValue
+	case "tts":
+		value = setting.TtsEngineServiceSelectorValue
+	}
+	return map[string]string{setting.EngineServiceSelectorKey: value}
+}
+
+// template variables, ${ENGINE_NAME} etc.
+func (c *Client) templateVars(engine Engine) map[string]string {
+	return map[string]string{
+		"ENGINE_NAME":   engine.Name(),
+		"ENGINE_TYPE":   strings.ToLower(engine.EngineType),
+		"SCENE_CODE":    engine.SceneCode,
+		"CONCURRENCY":   strconv.Itoa(engine.Concurrency),
+		"TENANT":        engine.Tenant,
+		"REPLICAS":      strconv.Itoa(int(engine.Replicas)),
+		"NAMESPACE":     c.namespace,
+		"MIN_CPU":       c.minCpu.String(),
+		"MIN_MEMORY":    c.minMemory.String(),
+		"ENGINE_CPU":    engine.Cpu,
+		"ENGINE_MEMORY": engine.Memory,
+	}
+}
+
+// render manifest template, unknown variables are kept
+func Render(template []byte, vars map[string]string) ([]byte, error) {
+	rendered, err := envsubst.Eval(string(template), func(name string) string {
+		if value, ok := vars[name]; ok {
+			return value
+		}
+		return "${" + name + "}"
+	})
+	if err != nil {
+		return nil, err
+	}
+	return []byte(rendered), nil
+}
+
+// decode manifest into typed object
+func Unmarshal(bytes []byte) (runtime.Object, error) {
+	obj, _, err := scheme.Codecs.UniversalDeserializer().Decode(bytes, nil, nil)
+	return obj, err
+}
+
+func UnmarshalService(bytes []byte) (*corev1.Service, error) {
+	obj, err := Unmarshal(bytes)
+	if err != nil {
+		return nil, err
+	}
+	svc, ok := obj.(*corev1.Service)
+	if !ok {
+		return nil, fmt.Errorf("expected Service, got %T", obj)
+	}
+	return svc, nil
+}
+
+func UnmarshalDeployment(bytes []byte) (*appsv1.Deployment, error) {
+	obj, err := Unmarshal(bytes)
+	if err != nil {
+		return nil, err
+	}
+	deploy, ok := obj.(*appsv1.Deployment)
+	if !ok {
+		return nil, fmt.Errorf("expected Deployment, got %T", obj)
+	}
+	return deploy, nil
+}
+
+func UnmarshalConfigMap(bytes []byte) (*corev1.ConfigMap, error) {
+	obj, err := Unmarshal(bytes)
+	if err != nil {
+		return nil, err
+	}
+	cm, ok := obj.(*corev1.ConfigMap)
+	if !ok {
+		return nil, fmt.Errorf("expected ConfigMap, got %T", obj)
+	}
+	return cm, nil
+}
+
+// render engine manifests, sorted by file name
+func (c *Client) RenderEngine(engine Engine) ([]runtime.Object, error) {
+	name := engine.Name()
+	if errs := validation.IsDNS1123Label(name); len(errs) > 0 {
+		return nil, fmt.Errorf("invalid engine name %q: %s", name, strings.Join(errs, ", "))
+	}
+	if engine.Concurrency <= 0 {
+		return nil, fmt.Errorf("invalid engine concurrency %d", engine.Concurrency)
+	}
+	files, err := filepath.Glob(filepath.Join(c.deployPath, strings.ToLower(engine.EngineType), "*.yaml"))
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no %s manifest in %s", engine.EngineType, c.deployPath)
+	}
+	sort.Strings(files)
+	vars := c.templateVars(engine)
+	objs := make([]runtime.Object, 0, len(files))
+	for _, file := range files {
+		template, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		rendered, err := Render(template, vars)
+		if err != nil {
+			return nil, fmt.Errorf("render %s: %v", file, err)
+		}
+		obj, err := Unmarshal(rendered)
+		if err != nil {
+			return nil, fmt.Errorf("decode %s: %v", file, err)
+		}
+		switch obj := obj.(type) {
+		case *appsv1.Deployment:
+			if err := c.prepareDeployment(obj, engine); err != nil {
+				return nil, fmt.Errorf("%s: %v", file, err)
+			}
+		case *corev1.Service:
+			prepareService(obj, engine)
+		case *corev1.ConfigMap:
+			// 引擎共享的 configmap, 按模板名称部署
+		default:
+			return nil, fmt.Errorf("%s: unsupported kind %T", file, obj)
+		}
+		objs = append(objs, obj)
+	}
+	return objs, nil
+}
+
+// render and apply engine manifests, configmaps first
+func (c *Client) DeployEngine(engine Engine) error {
+	objs, err := c.RenderEngine(engine)
+	if err != nil {
+		return err
+	}
+	sort.SliceStable(objs, func(i, j int) bool {
+		return applyOrder(objs[i]) < applyOrder(objs[j])
+	})
+	for _, obj := range objs {
+		switch obj := obj.(type) {
+		case *corev1.ConfigMap:
+			err = c.DeployConfigMap(obj)
+		case *corev1.Service:
+			err = c.DeployService(obj)
+		case *appsv1.Deployment:
+			err = c.DeployDeployment(obj)
+		}
+		if err != nil {
+			return fmt.Errorf("apply %T for engine %s: %v", obj, engine.Name(), err)
+		}
+	}
+	return nil
+}
+
+// delete engine deployment and service, shared configmaps are kept
+func (c *Client) DeleteEngine(name string) error {
+	if err := c.ResDelete(RES_DEPLOYMENT, name); err != nil {
+		return err
+	}
+	return c.ResDelete(RES_SERVICE, name)
+}
+
+func applyOrder(obj runtime.Object) int {
+	switch obj.(type) {
+	case *corev1.ConfigMap:
+		return 0
+	case *corev1.Service:
+		return 1
+	}
+	return 2
+}
+
+// engine schema labels, see engine.go
+func engineLabels(engine Engine) map[string]string {
+	labels := EngineSelector(engine.EngineType)
+	labels[APP_LABEL] = engine.Name()
+	labels[ENGINE_TYPE_LABEL] = strings.ToLower(engine.EngineType)
+	if len(validation.IsValidLabelValue(engine.SceneCode)) == 0 {
+		labels[SCENE_CODE_LABEL] = engine.SceneCode
+	}
+	return labels
+}
+
+// engine schema annotations, see engine.go
+func engineAnnotations(engine Engine) map[string]string {
+	annotations := map[string]string{
+		SCENE_CODE_LABEL:       engine.SceneCode,
+		CONCURRENCY_ANNOTATION: strconv.Itoa(engine.Concurrency),
+	}
+	if engine.Tenant != "" {
+		annotations[TENANT_ANNOTATION] = engine.Tenant
+	}
+	return annotations
+}
+
+func mergeLabels(dst map[string]string, src map[string]string) map[string]string {
+	if dst == nil {
+		dst = make(map[string]string, len(src))
+	}
+	for k, v := range src {
+		dst[k] = v
+	}
+	return dst
+}
+
+// deployment name, labels, selector, replicas and engine container resources
+func (c *Client) prepareDeployment(deploy *appsv1.Deployment, engine Engine) error {
+	name := engine.Name()
+	engineLabels := engineLabels(engine)
+	deploy.Name = name
+	deploy.Namespace = c.namespace
+	deploy.Labels = mergeLabels(deploy.Labels, engineLabels)
+	deploy.Annotations = mergeLabels(deploy.Annotations, engineAnnotations(engine))
+	deploy.Spec.Selector = &metav1.LabelSelector{MatchLabels: map[string]string{APP_LABEL: name}}
+	deploy.Spec.Template.Labels = mergeLabels(deploy.Spec.Template.Labels, engineLabels)
+	if engine.Replicas > 0 {
+		replicas := engine.Replicas
+		deploy.Spec.Replicas = &replicas
+	}
+	containers := deploy.Spec.Template.Spec.Containers
+	for i := range containers {
+		if containers[i].Name != ENGINE_CONTAINER {
+			continue
+		}
+		if err := setResource(&containers[i].Resources, corev1.ResourceCPU, engine.Cpu, c.minCpu); err != nil {
+			return err
+		}
+		return setResource(&containers[i].Resources, corev1.ResourceMemory, engine.Memory, c.minMemory)
+	}
+	return fmt.Errorf("container %s not found in deployment", ENGINE_CONTAINER)
+}
+
+// set request and limit, not lower than min
+func setResource(res *corev1.ResourceRequirements, name corev1.ResourceName, value string, min resource.Quantity) error {
+	if res.Requests == nil {
+		res.Requests = corev1.ResourceList{}
+	}
+	if res.Limits == nil {
+		res.Limits = corev1.ResourceList{}
+	}
+	if value != "" {
+		quantity, err := resource.ParseQuantity(value)
+		if err != nil {
+			return fmt.Errorf("invalid %s %q: %v", name, value, err)
+		}
+		res.Requests[name] = quantity
+		res.Limits[name] = quantity
+	}
+	if min.IsZero() {
+		return nil
+	}
+	for _, list := range []corev1.ResourceList{res.Requests, res.Limits} {
+		if current, ok := list[name]; !ok || current.Cmp(min) < 0 {
+			list[name] = min.DeepCopy()
+		}
+	}
+	return nil
+}
+
+// service name, labels and selector, endpoints 继承 service 的 label
+func prepareService(svc *corev1.Service, engine Engine) {
+	svc.Name = engine.Name()
+	svc.Labels = mergeLabels(svc.Labels, engineLabels(engine))
+	svc.Annotations = mergeLabels(svc.Annotations, engineAnnotations(engine))
+	svc.Spec.Selector = map[string]string{APP_LABEL: engine.Name()}
+}
@@ -0,0 +1,270 @@
+package kvs
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"rpc-gateway/pkg/core/config"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+const testDeployment = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: ${ENGINE_NAME}
+spec:
+  replicas: 1
+  template:
+    spec:
+      containers:
+      - name: engine-server
+        image: engine/${ENGINE_TYPE}:latest
+        args: ["--concurrency=${CONCURRENCY}", "--scene=${SCENE_CODE}"]
+        resources:
+          requests:
+            cpu: 500m
+            memory: 1Gi
+      - name: sidecar
+        image: sidecar:latest
+`
+
+const testService = `apiVersion: v1
+kind: Service
+metadata:
+  name: ${ENGINE_NAME}
+spec:
+  ports:
+  - name: grpc
+    port: 8080
+`
+
+const testConfigMap = `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: ${ENGINE_TYPE}-shared
+data:
+  namespace: ${NAMESPACE}
+`
+
+func setTestConfig() {
+	cfg := &config.Configs{}
+	cfg.Pool.Setting.EngineServiceSelectorKey = "engine"
+	cfg.Pool.Setting.AsrEngineServiceSelectorValue = "zhuiyi.ai.asr"
+	cfg.Pool.Setting.TtsEngineServiceSelectorValue = "zhuiyi.ai.tts"
+	config.Set(cfg)
+}
+
+// manifest templates of asr in temp dir
+func testClient(t *testing.T, minCpu string, minMemory string) (*Client, *fake.Clientset) {
+	t.Helper()
+	setTestConfig()
+	dir, err := ioutil.TempDir("", "kvs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	files := map[string]string{
+		"0-configmap.yaml":  testConfigMap,
+		"1-deployment.yaml": testDeployment,
+		"2-service.yaml":    testService,
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "asr"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, "asr", name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	clientset := fake.NewSimpleClientset()
+	c, err := NewClient(clientset, config.KvsConfig{
+		Namespace:      "engines",
+		DeployFilePath: dir,
+		MinCpu:         minCpu,
+		MinMemory:      minMemory,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c, clientset
+}
+
+func TestDeployAndDeleteEngine(t *testing.T) {
+	c, clientset := testClient(t, "", "")
+	engine := Engine{EngineType: "ASR", SceneCode: "scene-a", Concurrency: 4, Replicas: 2, Tenant: "t1"}
+	if err := c.DeployEngine(engine); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	name := "asr-4-scene-a"
+
+	deploy, err := clientset.AppsV1().Deployments("engines").Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *deploy.Spec.Replicas != 2 {
+		t.Errorf("replicas = %d, want 2", *deploy.Spec.Replicas)
+	}
+	if got := deploy.Spec.Selector.MatchLabels[APP_LABEL]; got != name {
+		t.Errorf("selector app = %q, want %q", got, name)
+	}
+	labels := deploy.Spec.Template.Labels
+	if labels["engine"] != "zhuiyi.ai.asr" || labels[ENGINE_TYPE_LABEL] != "asr" || labels[SCENE_CODE_LABEL] != "scene-a" {
+		t.Errorf("pod labels = %v", labels)
+	}
+	if deploy.Annotations[CONCURRENCY_ANNOTATION] != "4" || deploy.Annotations[TENANT_ANNOTATION] != "t1" {
+		t.Errorf("annotations = %v", deploy.Annotations)
+	}
+	args := deploy.Spec.Template.Spec.Containers[0].Args
+	if len(args) != 2 || args[0] != "--concurrency=4" || args[1] != "--scene=scene-a" {
+		t.Errorf("rendered args = %v", args)
+	}
+
+	svc, err := clientset.CoreV1().Services("engines").Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if svc.Spec.Selector[APP_LABEL] != name {
+		t.Errorf("service selector = %v", svc.Spec.Selector)
+	}
+	meta, err := ParseEngineService(svc, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meta != (EngineMeta{EngineType: "asr", Concurrency: 4, SceneCode: "scene-a", Tenant: "t1"}) {
+		t.Errorf("deployed service meta = %+v", meta)
+	}
+
+	cm, err := clientset.CoreV1().ConfigMaps("engines").Get(ctx, "asr-shared", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cm.Data["namespace"] != "engines" {
+		t.Errorf("configmap data = %v", cm.Data)
+	}
+
+	// 重复部署为更新
+	engine.Replicas = 3
+	if err := c.DeployEngine(engine); err != nil {
+		t.Fatal(err)
+	}
+	deploy, err = clientset.AppsV1().Deployments("engines").Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *deploy.Spec.Replicas != 3 {
+		t.Errorf("replicas after update = %d, want 3", *deploy.Spec.Replicas)
+	}
+
+	if err := c.DeleteEngine(name); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := clientset.AppsV1().Deployments("engines").Get(ctx, name, metav1.GetOptions{}); !apierrors.IsNotFound(err) {
+		t.Errorf("deployment not deleted: %v", err)
+	}
+	if _, err := clientset.CoreV1().Services("engines").Get(ctx, name, metav1.GetOptions{}); !apierrors.IsNotFound(err) {
+		t.Errorf("service not deleted: %v", err)
+	}
+	if _, err := clientset.CoreV1().ConfigMaps("engines").Get(ctx, "asr-shared", metav1.GetOptions{}); err != nil {
+		t.Errorf("shared configmap deleted: %v", err)
+	}
+	// 已删除不报错
+	if err := c.DeleteEngine(name); err != nil {
+		t.Errorf("delete missing engine: %v", err)
+	}
+}
+
+func TestRenderEngineInvalid(t *testing.T) {
+	c, _ := testClient(t, "", "")
+	cases := []Engine{
+		{EngineType: "asr", SceneCode: "Scene_A", Concurrency: 1},
+		{EngineType: "asr", SceneCode: "a", Concurrency: 0},
+		{EngineType: "tts", SceneCode: "a", Concurrency: 1},
+		{EngineType: "asr", SceneCode: "a", Concurrency: 1, Cpu: "lots"},
+	}
+	for _, engine := range cases {
+		if _, err := c.RenderEngine(engine); err == nil {
+			t.Errorf("RenderEngine(%+v) succeeded, want error", engine)
+		}
+	}
+}
+
+func TestEngineResourceClamping(t *testing.T) {
+	cases := []struct {
+		name                   string
+		minCpu, minMemory      string
+		cpu, memory            string
+		requestCpu, requestMem string
+		limitCpu, limitMem     string // 为空表示不设置 limit
+	}{
+		{"template", "", "", "", "", "500m", "1Gi", "", ""},
+		{"template above min", "200m", "512Mi", "", "", "500m", "1Gi", "200m", "512Mi"},
+		{"template below min", "1", "2Gi", "", "", "1", "2Gi", "1", "2Gi"},
+		{"engine without min", "", "", "2", "4Gi", "2", "4Gi", "2", "4Gi"},
+		{"engine above min", "1", "2Gi", "2", "4Gi", "2", "4Gi", "2", "4Gi"},
+		{"engine below min", "1", "2Gi", "100m", "256Mi", "1", "2Gi", "1", "2Gi"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			c, _ := testClient(t, tc.minCpu, tc.minMemory)
+			objs, err := c.RenderEngine(Engine{EngineType: "asr", SceneCode: "a", Concurrency: 1, Cpu: tc.cpu, Memory: tc.memory})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var containers []corev1.Container
+			for _, obj := range objs {
+				if deploy, ok := obj.(*appsv1.Deployment); ok {
+					containers = deploy.Spec.Template.Spec.Containers
+				}
+			}
+			if len(containers) != 2 {
+				t.Fatalf("deployment containers = %d, want 2", len(containers))
+			}
+			res := containers[0].Resources
+			assertQuantity(t, "request cpu", res.Requests, corev1.ResourceCPU, tc.requestCpu)
+			assertQuantity(t, "request memory", res.Requests, corev1.ResourceMemory, tc.requestMem)
+			assertQuantity(t, "limit cpu", res.Limits, corev1.ResourceCPU, tc.limitCpu)
+			assertQuantity(t, "limit memory", res.Limits, corev1.ResourceMemory, tc.limitMem)
+			// 只作用于引擎容器
+			if sidecar := containers[1].Resources; len(sidecar.Requests) != 0 || len(sidecar.Limits) != 0 {
+				t.Errorf("sidecar resources = %+v, want none", sidecar)
+			}
+		})
+	}
+}
+
+func assertQuantity(t *testing.T, what string, list corev1.ResourceList, name corev1.ResourceName, want string) {
+	t.Helper()
+	got, ok := list[name]
+	if want == "" {
+		if ok {
+			t.Errorf("%s = %s, want none", what, got.String())
+		}
+		return
+	}
+	if !ok {
+		t.Errorf("%s missing, want %s", what, want)
+		return
+	}
+	if got.Cmp(resource.MustParse(want)) != 0 {
+		t.Errorf("%s = %s, want %s", what, got.String(), want)
+	}
+}
+
+func TestNewClientInvalidMin(t *testing.T) {
+	if _, err := NewClient(fake.NewSimpleClientset(), config.KvsConfig{MinCpu: "x"}); err == nil {
+		t.Error("invalid MIN_CPU accepted")
+	}
+	if _, err := NewClient(fake.NewSimpleClientset(), config.KvsConfig{MinMemory: "1Qi"}); err == nil {
+		t.Error("invalid MIN_MEMORY accepted")
+	}
+}
@@ -0,0 +1,143 @@
+package vs
+
+import (
+	"fmt"
+	"rpc-gateway/pkg/plugins/discovery"
+	"rpc-gateway/pkg/plugins/httpserver/model"
+	"rpc-gateway/pkg/plugins/pool/grpc"
+	"time"
+)
+
+// engine ready wait
+var READY_TIMEOUT = 5 * time.Minute
+var READY_INTERVAL = 2 * time.Second
+
+// create engine job, deploy and init pools when ready
+func CreateEngine(engine Engine) (Job, error) {
+	backend, err := prepare(engine, false)
+	if err != nil {
+		return Job{}, err
+	}
+	return submitJob(JOB_CREATE, engine, backend, func(id string) error {
+		return recordEngine(engine, backend, model.ENGINE_CREATING, func() error {
+			if err := backend.Deploy(engine); err != nil {
+				return err
+			}
+			return initPools(id, backend, engine)
+		})
+	})
+}
+
+// update engine job, redeploy and replace pools when ready
+func UpdateEngine(engine Engine) (Job, error) {
+	backend, err := prepare(engine, true)
+	if err != nil {
+		return Job{}, err
+	}
+	return submitJob(JOB_UPDATE, engine, backend, func(id string) error {
+		return recordEngine(engine, backend, model.ENGINE_UPDATING, func() error {
+			if err := backend.Update(engine); err != nil {
+				return err
+			}
+			return initPools(id, backend, engine)
+		})
+	})
+}
+
+// delete engine job, release pools and delete engine
+func DeleteEngine(engine Engine) (Job, error) {
+	if err := engine.Validate(); err != nil {
+		return Job{}, err
+	}
+	backend, err := ActiveBackend()
+	if err != nil {
+		return Job{}, err
+	}
+	return submitJob(JOB_DELETE, engine, backend, func(id string) error {
+		// 其他副本和同步不再创建连接池
+		if err := setEngineStatus(engine, model.ENGINE_DELETING); err != nil {
+			return err
+		}
+		// 正在使用的连接归还时销毁
+		for _, poolName := range grpc.EnginePoolNames(engine.EngineType, engine.Name()) {
+			grpc.ReleaseGrpcPool(poolName, engine.EngineType)
+		}
+		if err := backend.Delete(engine); err != nil {
+			return err
+		}
+		return deleteEngine(engine)
+	})
+}
+
+// validate engine of backend, update requires existing engine
+func prepare(engine Engine, exists bool) (Backend, error) {
+	if err := engine.Validate(); err != nil {
+		return nil, err
+	}
+	backend, err := ActiveBackend()
+	if err != nil {
+		return nil, err
+	}
+	if err := backend.Validate(engine); err != nil {
+		return nil, err
+	}
+	found, err := backend.Exists(engine)
+	if err != nil {
+		return nil, err
+	}
+	if found != exists {
+		if exists {
+			return nil, fmt.Errorf("engine %s not found", engine.Name())
+		}
+		return nil, fmt.Errorf("engine %s already exists", engine.Name())
+	}
+	return backend, nil
+}
+
+// wait engine ready and init pools, pool size is the engine concurrency
+func initPools(id string, backend Backend, engine Engine) error {
+	port, _ := engineServerPort(engine.EngineType)
+	addrs, err := waitReady(backend, engine, port)
+	if err != nil {
+		return err
+	}
+	endpoints := make([]discovery.Endpoint, 0, len(addrs))
+	for _, addr := range addrs {
+		endpoints = append(endpoints, discovery.Endpoint{
+			Addr:      addr,
+			SceneCode: engine.SceneCode,
+			PoolSize:  engine.Concurrency,
+			Tenant:    engine.Tenant,
+		})
+	}
+	grpc.InitPoolFromExistEngineForUpdate(engine.EngineType, engine.Name(), endpoints)
+	if err := savePools(engine, endpoints); err != nil {
+		return err
+	}
+	updateJob(id, func(job *Job) {
+		job.Endpoints = addrs
+	})
+	return nil
+}
+
+// ready addresses, errors are retried until READY_TIMEOUT
+func waitReady(backend Backend, engine Engine, port string) ([]string, error) {
+	deadline := time.Now().Add(READY_TIMEOUT)
+	var lastErr error
+	for {
+		addrs, err := backend.ReadyAddresses(engine, port)
+		if err == nil && len(addrs) > 0 {
+			return addrs, nil
+		}
+		if err != nil {
+			lastErr = err
+		}
+		if time.Now().After(deadline) {
+			if lastErr != nil {
+				return nil, fmt.Errorf("engine %s not ready in %s: %v", engine.Name(), READY_TIMEOUT, lastErr)
+			}
+			return nil, fmt.Errorf("engine %s not ready in %s", engine.Name(), READY_TIMEOUT)
+		}
+		time.Sleep(READY_INTERVAL)
+	}
+}
@@ -0,0 +1,333 @@
+package vs
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"rpc-gateway/pkg/plugins/httpserver/db"
+	"rpc-gateway/pkg/plugins/httpserver/model"
+	"rpc-gateway/pkg/plugins/pool/grpc"
+
+	"gorm.io/gorm"
+)
+
+// engine DAO of test, statuses are recorded in order
+type fakeEngines struct {
+	lock     sync.Mutex
+	engines  map[string]model.Engine
+	statuses map[string][]string
+}
+
+func (dao *fakeEngines) List(engineType string) ([]model.Engine, error) {
+	dao.lock.Lock()
+	defer dao.lock.Unlock()
+	list := make([]model.Engine, 0)
+	for _, engine := range dao.engines {
+		if engineType == "" || engine.EngineType == engineType {
+			list = append(list, engine)
+		}
+	}
+	return list, nil
+}
+
+func (dao *fakeEngines) Get(name string) (*model.Engine, error) {
+	dao.lock.Lock()
+	defer dao.lock.Unlock()
+	if engine, ok := dao.engines[name]; ok {
+		return &engine, nil
+	}
+	return nil, nil
+}
+
+func (dao *fakeEngines) Save(engine *model.Engine) error {
+	dao.lock.Lock()
+	defer dao.lock.Unlock()
+	dao.engines[engine.Name] = *engine
+	dao.statuses[engine.Name] = append(dao.statuses[engine.Name], engine.Status)
+	return nil
+}
+
+func (dao *fakeEngines) SetStatus(name, status string) error {
+	dao.lock.Lock()
+	defer dao.lock.Unlock()
+	engine := dao.engines[name]
+	engine.Status = status
+	dao.engines[name] = engine
+	dao.statuses[name] = append(dao.statuses[name], status)
+	return nil
+}
+
+func (dao *fakeEngines) Delete(name string) error {
+	dao.lock.Lock()
+	defer dao.lock.Unlock()
+	delete(dao.engines, name)
+	return nil
+}
+
+func (dao *fakeEngines) history(name string) []string {
+	dao.lock.Lock()
+	defer dao.lock.Unlock()
+	return append([]string(nil), dao.statuses[name]...)
+}
+
+type fakePools struct {
+	lock  sync.Mutex
+	pools map[string][]model.Pool
+}
+
+func (dao *fakePools) List(engineType string) ([]model.Pool, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (dao *fakePools) ListByEngine(engineName string) ([]model.Pool, error) {
+	dao.lock.Lock()
+	defer dao.lock.Unlock()
+	return dao.pools[engineName], nil
+}
+
+func (dao *fakePools) Replace(engineName string, pools []model.Pool) error {
+	dao.lock.Lock()
+	defer dao.lock.Unlock()
+	dao.pools[engineName] = pools
+	return nil
+}
+
+type fakeJobs struct {
+	lock sync.Mutex
+	jobs map[string]model.Job
+}
+
+func (dao *fakeJobs) Get(jobId string) (*model.Job, error) {
+	dao.lock.Lock()
+	defer dao.lock.Unlock()
+	if job, ok := dao.jobs[jobId]; ok {
+		return &job, nil
+	}
+	return nil, nil
+}
+
+func (dao *fakeJobs) Save(job *model.Job) error {
+	dao.lock.Lock()
+	defer dao.lock.Unlock()
+	dao.jobs[job.JobId] = *job
+	return nil
+}
+
+func (dao *fakeJobs) Unfinished(engineName string) (*model.Job, error) {
+	dao.lock.Lock()
+	defer dao.lock.Unlock()
+	for _, job := range dao.jobs {
+		if job.EngineName == engineName && (job.Status == JOB_PENDING || job.Status == JOB_RUNNING) {
+			return &job, nil
+		}
+	}
+	return nil, nil
+}
+
+// db enabled with fake DAOs, the connection is not used
+func setupDB(t *testing.T) (*fakeEngines, *fakePools, *fakeJobs) {
+	t.Helper()
+	engines := &fakeEngines{engines: make(map[string]model.Engine), statuses: make(map[string][]string)}
+	pools := &fakePools{pools: make(map[string][]model.Pool)}
+	jobDAO := &fakeJobs{jobs: make(map[string]model.Job)}
+	conn, oldEngines, oldPools, oldJobs := db.Conn, db.Engines, db.Pools, db.Jobs
+	db.Conn, db.Engines, db.Pools, db.Jobs = &gorm.DB{}, engines, pools, jobDAO
+	t.Cleanup(func() {
+		db.Conn, db.Engines, db.Pools, db.Jobs = conn, oldEngines, oldPools, oldJobs
+	})
+	return engines, pools, jobDAO
+}
+
+// reset jobs and release pools of test engines
+func resetJobs(t *testing.T) {
+	jobsLock.Lock()
+	jobs = make(map[string]*Job)
+	jobsLock.Unlock()
+	t.Cleanup(func() {
+		for _, name := range grpc.EnginePoolNames("asr", testEngine.Name()) {
+			grpc.ReleaseGrpcPool(name, "asr")
+		}
+	})
+}
+
+// wait job saved as finished, the last step of job goroutine
+func waitJob(t *testing.T, jobDAO *fakeJobs, id string) Job {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		record, _ := jobDAO.Get(id)
+		if record != nil && (record.Status == JOB_SUCCESS || record.Status == JOB_FAILED) {
+			job, _ := GetJob(id)
+			return job
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("job %s not finished, record %+v", id, record)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestEngineLifecycle(t *testing.T) {
+	clientset := setupBackend(t)
+	resetJobs(t)
+	_, _, jobDAO := setupDB(t)
+	engine := testEngine
+
+	job, err := CreateEngine(engine)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.Type != JOB_CREATE || job.Backend != BACKEND_KVS || job.EngineName != "asr-2-scene-a" {
+		t.Errorf("create job = %+v", job)
+	}
+	// 等待就绪期间不接受该引擎的其他任务
+	if _, err := DeleteEngine(engine); err == nil || !strings.Contains(err.Error(), "unfinished create job") {
+		t.Errorf("delete during create error = %v", err)
+	}
+	setEndpoints(t, clientset, engine.Name(), "10.0.0.5")
+	job = waitJob(t, jobDAO, job.ID)
+	if job.Status != JOB_SUCCESS || !reflect.DeepEqual(job.Endpoints, []string{"10.0.0.5:9000"}) {
+		t.Fatalf("create job = %+v", job)
+	}
+	if names := grpc.EnginePoolNames("asr", engine.Name()); !reflect.DeepEqual(names, []string{"asr-2-scene-a/10.0.0.5:9000"}) {
+		t.Errorf("pools after create = %v", names)
+	}
+	if _, err := CreateEngine(engine); err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Errorf("create existing engine error = %v", err)
+	}
+
+	engine.Cpu = "2"
+	if job, err = UpdateEngine(engine); err != nil {
+		t.Fatal(err)
+	}
+	if job = waitJob(t, jobDAO, job.ID); job.Status != JOB_SUCCESS {
+		t.Errorf("update job = %+v", job)
+	}
+
+	if job, err = DeleteEngine(engine); err != nil {
+		t.Fatal(err)
+	}
+	if job = waitJob(t, jobDAO, job.ID); job.Status != JOB_SUCCESS {
+		t.Errorf("delete job = %+v", job)
+	}
+	if names := grpc.EnginePoolNames("asr", engine.Name()); len(names) != 0 {
+		t.Errorf("pools after delete = %v", names)
+	}
+	if _, err := UpdateEngine(engine); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("update deleted engine error = %v", err)
+	}
+}
+
+func TestEngineNotReady(t *testing.T) {
+	setupBackend(t)
+	resetJobs(t)
+	READY_TIMEOUT = 20 * time.Millisecond
+	engines, _, jobDAO := setupDB(t)
+
+	job, err := CreateEngine(testEngine)
+	if err != nil {
+		t.Fatal(err)
+	}
+	job = waitJob(t, jobDAO, job.ID)
+	if job.Status != JOB_FAILED || !strings.Contains(job.Message, "not ready") {
+		t.Errorf("job = %+v, want not ready failure", job)
+	}
+	want := []string{model.ENGINE_CREATING, model.ENGINE_FAILED}
+	if got := engines.history(testEngine.Name()); !reflect.DeepEqual(got, want) {
+		t.Errorf("engine statuses = %v, want %v", got, want)
+	}
+}
+
+func TestEngineLifecycleRecords(t *testing.T) {
+	clientset := setupBackend(t)
+	resetJobs(t)
+	engines, pools, jobDAO := setupDB(t)
+	engine := testEngine
+
+	setEndpoints(t, clientset, engine.Name(), "10.0.0.5")
+	job, err := CreateEngine(engine)
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitJob(t, jobDAO, job.ID)
+	want := []string{model.ENGINE_CREATING, model.ENGINE_READY}
+	if got := engines.history(engine.Name()); !reflect.DeepEqual(got, want) {
+		t.Errorf("engine statuses = %v, want %v", got, want)
+	}
+	saved, _ := pools.ListByEngine(engine.Name())
+	if len(saved) != 1 || saved[0].Addr != "10.0.0.5:9000" || saved[0].Size != 2 || saved[0].Tenant != "t1" {
+		t.Errorf("saved pools = %+v", saved)
+	}
+	record, _ := jobDAO.Get(job.ID)
+	if record == nil || record.Status != JOB_SUCCESS || record.Endpoints != "10.0.0.5:9000" {
+		t.Errorf("job record = %+v", record)
+	}
+
+	// 其他副本的任务从 db 读取
+	jobsLock.Lock()
+	delete(jobs, job.ID)
+	jobsLock.Unlock()
+	if other, ok := GetJob(job.ID); !ok || other.Status != JOB_SUCCESS || other.Engine != engine {
+		t.Errorf("job of db = %+v, %v", other, ok)
+	}
+	// db 中未完成的任务
+	jobDAO.Save(&model.Job{JobId: "other", Type: JOB_UPDATE, EngineName: engine.Name(), Status: JOB_RUNNING})
+	if _, err := DeleteEngine(engine); err == nil || !strings.Contains(err.Error(), "unfinished update job other") {
+		t.Errorf("delete with unfinished db job error = %v", err)
+	}
+	jobDAO.Save(&model.Job{JobId: "other", Type: JOB_UPDATE, EngineName: engine.Name(), Status: JOB_FAILED})
+
+	if job, err = DeleteEngine(engine); err != nil {
+		t.Fatal(err)
+	}
+	waitJob(t, jobDAO, job.ID)
+	if got, _ := engines.Get(engine.Name()); got != nil {
+		t.Errorf("engine after delete = %+v", got)
+	}
+	if got := engines.history(engine.Name()); got[len(got)-1] != model.ENGINE_DELETING {
+		t.Errorf("engine statuses = %v", got)
+	}
+}
+
+func TestSyncPools(t *testing.T) {
+	setupBackend(t)
+	resetJobs(t)
+	engines, pools, _ := setupDB(t)
+	syncLock.Lock()
+	syncedEngines = make(map[string]string)
+	syncLock.Unlock()
+	name := testEngine.Name()
+	pool := model.Pool{EngineName: name, Addr: "10.0.0.5:9000", SceneCode: "scene-a", Size: 2}
+	pools.Replace(name, []model.Pool{pool})
+
+	// 创建中的引擎不恢复连接池
+	engines.Save(&model.Engine{Name: name, EngineType: "asr", Status: model.ENGINE_CREATING})
+	syncPools()
+	if names := grpc.EnginePoolNames("asr", name); len(names) != 0 {
+		t.Errorf("pools of creating engine = %v", names)
+	}
+
+	engines.SetStatus(name, model.ENGINE_READY)
+	engines.Save(&model.Engine{Name: "tts-1-scene-b", EngineType: "tts", Status: model.ENGINE_READY})
+	syncPools()
+	if names := grpc.EnginePoolNames("asr", name); !reflect.DeepEqual(names, []string{name + "/10.0.0.5:9000"}) {
+		t.Errorf("restored pools = %v", names)
+	}
+	if engineType, ok := syncedEngines["tts-1-scene-b"]; !ok || engineType != "" {
+		t.Errorf("engine of disabled pool synced as %q, %v", engineType, ok)
+	}
+
+	// 其他副本删除引擎
+	engines.Delete(name)
+	syncPools()
+	if names := grpc.EnginePoolNames("asr", name); len(names) != 0 {
+		t.Errorf("pools of deleted engine = %v", names)
+	}
+	if _, ok := syncedEngines[name]; ok {
+		t.Error("deleted engine still synced")
+	}
+}